@@ -1,9 +1,31 @@
-import type { ListResponse, Problem, GradeResponse } from "../types";
+import type {
+  ListResponse,
+  ProblemSummary,
+  Problem,
+  SimilarResponse,
+  GradeResponse,
+  Attempt,
+  AttemptListResponse,
+  AttemptMessage,
+  HintsResponse,
+  InterviewResponse,
+  InterviewMessageResponse,
+  RubricsResponse,
+  RunResult,
+  TestsResult,
+  Complexity,
+  User,
+  APIToken,
+  CreateTokenResponse,
+} from "../types";
 
 const BASE = "/api";
 
 async function fetchJSON<T>(url: string, init?: RequestInit): Promise<T> {
   const res = await fetch(url, init);
+  if (res.status === 401 && window.location.pathname !== "/login") {
+    window.location.href = "/login";
+  }
   if (!res.ok) {
     const text = await res.text();
     throw new Error(`${res.status}: ${text}`);
@@ -11,10 +33,27 @@ async function fetchJSON<T>(url: string, init?: RequestInit): Promise<T> {
   return res.json() as Promise<T>;
 }
 
+export function login(username: string, password: string): Promise<User> {
+  return fetchJSON<User>(`${BASE}/login`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ username, password }),
+  });
+}
+
+export async function logout(): Promise<void> {
+  await fetch(`${BASE}/logout`, { method: "POST" });
+}
+
+export function getMe(): Promise<User> {
+  return fetchJSON<User>(`${BASE}/me`);
+}
+
 export interface ListParams {
   q?: string;
   difficulty?: string;
   topic?: string;
+  sort?: "number" | "relevance";
   limit?: number;
   offset?: number;
 }
@@ -24,6 +63,7 @@ export function listProblems(params: ListParams = {}): Promise<ListResponse> {
   if (params.q) sp.set("q", params.q);
   if (params.difficulty) sp.set("difficulty", params.difficulty);
   if (params.topic) sp.set("topic", params.topic);
+  if (params.sort) sp.set("sort", params.sort);
   if (params.limit) sp.set("limit", String(params.limit));
   if (params.offset) sp.set("offset", String(params.offset));
   return fetchJSON<ListResponse>(`${BASE}/problems?${sp}`);
@@ -33,6 +73,23 @@ export function getProblem(id: number): Promise<Problem> {
   return fetchJSON<Problem>(`${BASE}/problems/${id}`);
 }
 
+export interface SimilarParams {
+  difficulty?: string;
+  topic?: string;
+  limit?: number;
+}
+
+export function similarProblems(
+  id: number,
+  params: SimilarParams = {},
+): Promise<SimilarResponse> {
+  const sp = new URLSearchParams();
+  if (params.difficulty) sp.set("difficulty", params.difficulty);
+  if (params.topic) sp.set("topic", params.topic);
+  if (params.limit) sp.set("limit", String(params.limit));
+  return fetchJSON<SimilarResponse>(`${BASE}/problems/${id}/similar?${sp}`);
+}
+
 export function listTopics(): Promise<string[]> {
   return fetchJSON<string[]>(`${BASE}/topics`);
 }
@@ -50,10 +107,161 @@ export function smokeTest(): Promise<SmokeResponse> {
 export function gradeAnswer(
   problemId: number,
   answer: string,
+  rubric?: string,
+  samples?: number,
+  code?: string,
 ): Promise<GradeResponse> {
   return fetchJSON<GradeResponse>(`${BASE}/grade`, {
     method: "POST",
     headers: { "Content-Type": "application/json" },
-    body: JSON.stringify({ problem_id: problemId, answer }),
+    body: JSON.stringify({ problem_id: problemId, answer, rubric, samples, code }),
+  });
+}
+
+export function listHints(problemId: number): Promise<HintsResponse> {
+  return fetchJSON<HintsResponse>(`${BASE}/problems/${problemId}/hints`);
+}
+
+export function nextHint(problemId: number, answer: string): Promise<HintsResponse> {
+  return fetchJSON<HintsResponse>(`${BASE}/problems/${problemId}/hint`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ answer }),
+  });
+}
+
+export function runCode(problemId: number, code: string): Promise<RunResult> {
+  return fetchJSON<RunResult>(`${BASE}/run`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ problem_id: problemId, code }),
+  });
+}
+
+export function runTests(
+  problemId: number,
+  code: string,
+  regenerate = false,
+): Promise<TestsResult> {
+  return fetchJSON<TestsResult>(`${BASE}/run/tests`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ problem_id: problemId, code, regenerate }),
+  });
+}
+
+export function benchmarkCode(problemId: number, code: string): Promise<Complexity> {
+  return fetchJSON<Complexity>(`${BASE}/run/benchmark`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ problem_id: problemId, code }),
+  });
+}
+
+export function listRubrics(): Promise<RubricsResponse> {
+  return fetchJSON<RubricsResponse>(`${BASE}/rubrics`);
+}
+
+export function listAttempts(
+  params: { problemId?: number; limit?: number; offset?: number } = {},
+): Promise<AttemptListResponse> {
+  const sp = new URLSearchParams();
+  if (params.limit) sp.set("limit", String(params.limit));
+  if (params.offset) sp.set("offset", String(params.offset));
+  const path = params.problemId
+    ? `${BASE}/problems/${params.problemId}/attempts`
+    : `${BASE}/attempts`;
+  return fetchJSON<AttemptListResponse>(`${path}?${sp}`);
+}
+
+export function getAttempt(id: number): Promise<Attempt> {
+  return fetchJSON<Attempt>(`${BASE}/attempts/${id}`);
+}
+
+export function listMessages(
+  attemptId: number,
+): Promise<{ messages: AttemptMessage[] }> {
+  return fetchJSON<{ messages: AttemptMessage[] }>(
+    `${BASE}/attempts/${attemptId}/messages`,
+  );
+}
+
+export function sendMessage(
+  attemptId: number,
+  content: string,
+): Promise<{ messages: AttemptMessage[] }> {
+  return fetchJSON<{ messages: AttemptMessage[] }>(
+    `${BASE}/attempts/${attemptId}/messages`,
+    {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ content }),
+    },
+  );
+}
+
+export function startInterview(
+  problemId: number,
+  rubric?: string,
+): Promise<InterviewResponse> {
+  return fetchJSON<InterviewResponse>(`${BASE}/interviews`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ problem_id: problemId, rubric }),
   });
 }
+
+export function getInterview(id: number): Promise<InterviewResponse> {
+  return fetchJSON<InterviewResponse>(`${BASE}/interviews/${id}`);
+}
+
+export function sendInterviewMessage(
+  id: number,
+  content: string,
+  done = false,
+): Promise<InterviewMessageResponse> {
+  return fetchJSON<InterviewMessageResponse>(`${BASE}/interviews/${id}/messages`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ content, done }),
+  });
+}
+
+export interface RandomParams {
+  difficulty?: string;
+  topic?: string;
+  skipRecentDays?: number;
+  weighted?: boolean;
+}
+
+export function randomProblem(params: RandomParams = {}): Promise<ProblemSummary> {
+  const sp = new URLSearchParams();
+  if (params.difficulty) sp.set("difficulty", params.difficulty);
+  if (params.topic) sp.set("topic", params.topic);
+  if (params.skipRecentDays) sp.set("skip_recent_days", String(params.skipRecentDays));
+  if (params.weighted) sp.set("weighted", "true");
+  return fetchJSON<ProblemSummary>(`${BASE}/problems/random?${sp}`);
+}
+
+export function listTokens(): Promise<{ tokens: APIToken[] }> {
+  return fetchJSON<{ tokens: APIToken[] }>(`${BASE}/tokens`);
+}
+
+export function createToken(
+  name: string,
+  scopes: string[],
+  expiresIn?: string,
+): Promise<CreateTokenResponse> {
+  return fetchJSON<CreateTokenResponse>(`${BASE}/tokens`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ name, scopes, expires_in: expiresIn }),
+  });
+}
+
+export async function revokeToken(id: number): Promise<void> {
+  const res = await fetch(`${BASE}/tokens/${id}`, { method: "DELETE" });
+  if (!res.ok) {
+    throw new Error(`${res.status}: ${await res.text()}`);
+  }
+}
@@ -3,9 +3,10 @@ import { useState } from "react";
 interface Props {
   onSubmit: (answer: string) => void;
   loading: boolean;
+  onChange?: (answer: string) => void;
 }
 
-export function AnswerForm({ onSubmit, loading }: Props) {
+export function AnswerForm({ onSubmit, loading, onChange }: Props) {
   const [answer, setAnswer] = useState("");
 
   const handleSubmit = (e: React.FormEvent) => {
@@ -23,7 +24,10 @@ export function AnswerForm({ onSubmit, loading }: Props) {
         </label>
         <textarea
           value={answer}
-          onChange={(e) => setAnswer(e.target.value)}
+          onChange={(e) => {
+            setAnswer(e.target.value);
+            onChange?.(e.target.value);
+          }}
           rows={12}
           placeholder={`Describe your approach:
 - What pattern/algorithm would you use?
@@ -0,0 +1,73 @@
+import { useEffect, useState } from "react";
+import { listMessages, sendMessage } from "../api/client";
+import type { AttemptMessage } from "../types";
+
+interface Props {
+  attemptId: number;
+}
+
+export function FollowUp({ attemptId }: Props) {
+  const [messages, setMessages] = useState<AttemptMessage[]>([]);
+  const [question, setQuestion] = useState("");
+  const [sending, setSending] = useState(false);
+  const [error, setError] = useState("");
+
+  useEffect(() => {
+    listMessages(attemptId)
+      .then((res) => setMessages(res.messages))
+      .catch((err) => setError(String(err)));
+  }, [attemptId]);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!question.trim()) return;
+    setSending(true);
+    setError("");
+    try {
+      const res = await sendMessage(attemptId, question);
+      setMessages((prev) => [...prev, ...res.messages]);
+      setQuestion("");
+    } catch (err) {
+      setError(`Follow-up failed: ${err}`);
+    } finally {
+      setSending(false);
+    }
+  };
+
+  return (
+    <div className="space-y-4 p-6 bg-bg-surface border border-border rounded-xl">
+      <h2 className="text-xs font-semibold uppercase tracking-wider text-fg-muted">
+        Ask about your grade
+      </h2>
+      {messages.map((m) => (
+        <div
+          key={m.id}
+          className={`px-4 py-3 rounded-xl text-sm whitespace-pre-wrap leading-relaxed ${
+            m.role === "user"
+              ? "bg-bg-main border border-border text-fg-main ml-12"
+              : "bg-tn-blue/10 border border-tn-blue/20 text-fg-main mr-12"
+          }`}
+        >
+          {m.content}
+        </div>
+      ))}
+      {error && <div className="text-sm text-tn-red">{error}</div>}
+      <form onSubmit={handleSubmit} className="flex gap-3">
+        <input
+          value={question}
+          onChange={(e) => setQuestion(e.target.value)}
+          placeholder="Why did I lose points on complexity?"
+          className="flex-1 bg-bg-main border border-border rounded-xl px-4 py-2 text-fg-main placeholder-fg-muted focus:outline-none focus:border-tn-blue focus:ring-1 focus:ring-tn-blue/30 transition-all text-sm"
+          disabled={sending}
+        />
+        <button
+          type="submit"
+          disabled={!question.trim() || sending}
+          className="px-4 py-2 bg-gradient-to-r from-tn-blue to-tn-purple text-bg-main font-semibold rounded-xl hover:opacity-90 disabled:opacity-20 disabled:cursor-not-allowed transition-all"
+        >
+          {sending ? "Asking..." : "Ask"}
+        </button>
+      </form>
+    </div>
+  );
+}
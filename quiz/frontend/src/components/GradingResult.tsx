@@ -1,65 +1,78 @@
-import type { GradingResult as GradingResultType } from "../types";
+import type { CriterionResult, GradingResult as GradingResultType } from "../types";
 
 interface Props {
   result: GradingResultType;
 }
 
-const criteriaLabels = [
-  { key: "pattern_identified" as const, label: "Pattern Identified", desc: "Correct algorithmic technique" },
-  { key: "solution_works" as const, label: "Solution Works", desc: "Produces correct results" },
-  { key: "complexity_analysis" as const, label: "Complexity Analysis", desc: "Time and space stated correctly" },
-  { key: "optimal_solution" as const, label: "Optimal Solution", desc: "Best known time complexity" },
-];
+function formatScore(n: number) {
+  return String(Math.round(n * 100) / 100);
+}
+
+function criterionStatus(c: CriterionResult) {
+  if (c.points >= c.max_points) return "pass";
+  if (c.points > 0) return "partial";
+  return "fail";
+}
+
+const statusStyles = {
+  pass: { border: "border-tn-green/30", badge: "bg-tn-green/15 text-tn-green", dot: "bg-tn-green", icon: "\u2713" },
+  partial: { border: "border-tn-yellow/30", badge: "bg-tn-yellow/15 text-tn-yellow", dot: "bg-tn-yellow", icon: "~" },
+  fail: { border: "border-tn-red/30", badge: "bg-tn-red/15 text-tn-red", dot: "bg-tn-red/50", icon: "\u2717" },
+};
 
 export function GradingResult({ result }: Props) {
-  const score = criteriaLabels.filter((c) => result[c.key].score).length;
+  const fraction = result.max_score > 0 ? result.score / result.max_score : 0;
 
   return (
     <div className="space-y-6">
       <div className="flex items-center gap-4 p-6 bg-bg-surface border border-border rounded-xl">
         <div className="text-4xl font-bold bg-gradient-to-r from-tn-blue to-tn-purple bg-clip-text text-transparent">
-          {score}/4
+          {formatScore(result.score)}/{formatScore(result.max_score)}
         </div>
         <div>
           <div className="text-fg-bright font-medium">
-            {score === 4 ? "Excellent" : score >= 3 ? "Good" : score >= 2 ? "Partial" : score >= 1 ? "Needs Work" : "Incorrect"}
+            {fraction >= 1 ? "Excellent" : fraction >= 0.75 ? "Good" : fraction >= 0.5 ? "Partial" : fraction > 0 ? "Needs Work" : "Incorrect"}
+          </div>
+          <div className="text-sm text-fg-muted">
+            {result.rubric} rubric
+            {result.cached && " \u00b7 cached result from an identical earlier answer"}
+            {(result.samples ?? 0) > 1 && ` \u00b7 consensus of ${result.samples} samples`}
+            {(result.hints_used ?? 0) > 0 &&
+              ` \u00b7 -${formatScore((result.hint_penalty ?? 0) * result.max_score)} for ${result.hints_used} hint${result.hints_used === 1 ? "" : "s"}`}
           </div>
-          <div className="text-sm text-fg-muted">criteria passed</div>
         </div>
         <div className="ml-auto flex gap-1.5">
-          {criteriaLabels.map(({ key }) => (
-            <div
-              key={key}
-              className={`w-3 h-3 rounded-full ${result[key].score ? "bg-tn-green" : "bg-tn-red/50"}`}
-            />
+          {result.criteria.map((c) => (
+            <div key={c.key} className={`w-3 h-3 rounded-full ${statusStyles[criterionStatus(c)].dot}`} />
           ))}
         </div>
       </div>
 
       <div className="grid gap-3">
-        {criteriaLabels.map(({ key, label, desc }) => {
-          const criterion = result[key];
+        {result.criteria.map((criterion) => {
+          const style = statusStyles[criterionStatus(criterion)];
           return (
-            <div
-              key={key}
-              className={`bg-bg-surface border rounded-xl p-4 ${
-                criterion.score ? "border-tn-green/30" : "border-tn-red/30"
-              }`}
-            >
+            <div key={criterion.key} className={`bg-bg-surface border rounded-xl p-4 ${style.border}`}>
               <div className="flex items-start gap-3">
                 <div
-                  className={`w-6 h-6 rounded-full flex items-center justify-center shrink-0 mt-0.5 text-sm font-bold ${
-                    criterion.score
-                      ? "bg-tn-green/15 text-tn-green"
-                      : "bg-tn-red/15 text-tn-red"
-                  }`}
+                  className={`w-6 h-6 rounded-full flex items-center justify-center shrink-0 mt-0.5 text-sm font-bold ${style.badge}`}
                 >
-                  {criterion.score ? "\u2713" : "\u2717"}
+                  {style.icon}
                 </div>
                 <div className="flex-1 min-w-0">
                   <div className="flex items-baseline gap-2">
-                    <span className="font-medium text-fg-bright">{label}</span>
-                    <span className="text-xs text-fg-muted">{desc}</span>
+                    <span className="font-medium text-fg-bright">{criterion.label}</span>
+                    {criterion.max_points > 1 && (
+                      <span className="text-xs text-fg-muted">
+                        {criterion.points}/{criterion.max_points}
+                      </span>
+                    )}
+                    {criterion.weight !== 1 && (
+                      <span className="text-xs text-fg-muted">weight {formatScore(criterion.weight)}</span>
+                    )}
+                    {criterion.agreement !== undefined && (
+                      <span className="text-xs text-fg-muted">{Math.round(criterion.agreement * 100)}% agree</span>
+                    )}
                   </div>
                   <p className="text-sm text-fg-muted mt-1 leading-relaxed">
                     {criterion.comment}
@@ -79,6 +92,15 @@ export function GradingResult({ result }: Props) {
           {result.overall_feedback}
         </p>
       </div>
+
+      {result.evidence && (
+        <details className="bg-bg-surface border border-border rounded-xl p-5">
+          <summary className="text-xs font-semibold uppercase tracking-wider text-fg-muted cursor-pointer">
+            Measured Performance
+          </summary>
+          <pre className="mt-3 text-xs text-fg-main whitespace-pre-wrap">{result.evidence}</pre>
+        </details>
+      )}
     </div>
   );
 }
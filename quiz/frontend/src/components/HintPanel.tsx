@@ -0,0 +1,78 @@
+import { useEffect, useState } from "react";
+import { listHints, nextHint } from "../api/client";
+import type { HintsResponse } from "../types";
+
+interface Props {
+  problemId: number;
+  answer: string;
+}
+
+export function HintPanel({ problemId, answer }: Props) {
+  const [state, setState] = useState<HintsResponse | null>(null);
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState("");
+
+  useEffect(() => {
+    listHints(problemId)
+      .then(setState)
+      .catch((err) => setError(String(err)));
+  }, [problemId]);
+
+  const handleHint = async () => {
+    setLoading(true);
+    setError("");
+    try {
+      setState(await nextHint(problemId, answer));
+    } catch (err) {
+      setError(`Hint failed: ${err}`);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  if (!state) return null;
+
+  const more = state.hints.length < state.max_level;
+
+  return (
+    <div className="bg-bg-surface border border-border rounded-xl p-5 space-y-3">
+      <div className="flex items-center justify-between">
+        <h2 className="text-xs font-semibold uppercase tracking-wider text-fg-muted">
+          Hints ({state.hints.length}/{state.max_level})
+          {state.penalty > 0 &&
+            ` · costs ${Math.round(state.penalty * 100)}% of your next score`}
+        </h2>
+        {more && (
+          <button
+            onClick={handleHint}
+            disabled={loading}
+            className="text-xs text-tn-blue hover:text-tn-purple disabled:opacity-20 transition-colors border-b border-tn-blue/30"
+          >
+            {loading ? "Thinking..." : state.hints.length === 0 ? "Get a hint" : "Next hint"}
+          </button>
+        )}
+      </div>
+      {state.hints.length > 0 && (
+        <ul className="space-y-2">
+          {state.hints.map((h) =>
+            h.source === "stored" ? (
+              <li
+                key={h.id}
+                className="text-sm text-fg-main pl-4 border-l-2 border-tn-purple/30"
+                dangerouslySetInnerHTML={{ __html: h.content }}
+              />
+            ) : (
+              <li
+                key={h.id}
+                className="text-sm text-fg-main pl-4 border-l-2 border-tn-blue/30 whitespace-pre-wrap"
+              >
+                {h.content}
+              </li>
+            ),
+          )}
+        </ul>
+      )}
+      {error && <div className="text-sm text-tn-red">{error}</div>}
+    </div>
+  );
+}
@@ -84,23 +84,6 @@ export function ProblemDetail({ problem }: Props) {
           </pre>
         </section>
       )}
-
-      {problem.hints.length > 0 && (
-        <details className="bg-bg-surface border border-border rounded-xl p-5 group">
-          <summary className="text-xs font-semibold uppercase tracking-wider text-fg-muted cursor-pointer select-none">
-            Hints ({problem.hints.length})
-          </summary>
-          <ul className="mt-3 space-y-2">
-            {problem.hints.map((h, i) => (
-              <li
-                key={i}
-                className="text-sm text-fg-main pl-4 border-l-2 border-tn-purple/30"
-                dangerouslySetInnerHTML={{ __html: h }}
-              />
-            ))}
-          </ul>
-        </details>
-      )}
     </div>
   );
 }
@@ -46,6 +46,13 @@ export function ProblemList({
               </span>
               <DifficultyBadge difficulty={p.difficulty} />
             </div>
+            {p.snippet && (
+              // The server escapes snippets; only <mark> tags are HTML.
+              <div
+                className="mt-1.5 ml-15 text-xs text-fg-muted [&_mark]:bg-tn-yellow/20 [&_mark]:text-fg-bright"
+                dangerouslySetInnerHTML={{ __html: p.snippet }}
+              />
+            )}
             {p.topics.length > 0 && (
               <div className="flex gap-1 mt-2 ml-15 flex-wrap">
                 {p.topics.map((t) => (
@@ -13,7 +13,7 @@ export function ProblemSearch({ value, onChange }: Props) {
         type="text"
         value={value}
         onChange={(e) => onChange(e.target.value)}
-        placeholder="Search problems... (e.g., 'two sum' or '#1')"
+        placeholder="Search problems... (e.g., 'sliding window', '&quot;linked list&quot;' or '#1')"
         className="w-full bg-bg-surface border border-border rounded-xl pl-9 pr-4 py-3 text-fg-main placeholder-fg-muted focus:outline-none focus:border-tn-blue focus:ring-1 focus:ring-tn-blue/30 transition-all"
       />
     </div>
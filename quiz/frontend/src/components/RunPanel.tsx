@@ -0,0 +1,205 @@
+import { useState } from "react";
+import { benchmarkCode, runCode, runTests } from "../api/client";
+import type { Complexity, RunCase, RunResult, TestsResult } from "../types";
+
+interface Props {
+  problemId: number;
+  snippet: string;
+  onCodeChange?: (code: string) => void;
+  // attach says whether the code is timed and shown to the grader along
+  // with the answer.
+  attach?: boolean;
+  onAttachChange?: (attach: boolean) => void;
+}
+
+function formatSeconds(s: number) {
+  if (s >= 1) return `${s.toFixed(2)} s`;
+  if (s >= 1e-3) return `${(s * 1e3).toFixed(2)} ms`;
+  return `${(s * 1e6).toFixed(2)} \u00b5s`;
+}
+
+const statusColors: Record<RunCase["status"], string> = {
+  pass: "text-tn-green",
+  fail: "text-tn-red",
+  error: "text-tn-red",
+  timeout: "text-tn-yellow",
+  skipped: "text-fg-muted",
+};
+
+export function RunPanel({ problemId, snippet, onCodeChange, attach, onAttachChange }: Props) {
+  const [code, setCode] = useState(snippet);
+  const [result, setResult] = useState<RunResult | null>(null);
+  const [tests, setTests] = useState<TestsResult | null>(null);
+  const [loading, setLoading] = useState(false);
+  const [testing, setTesting] = useState(false);
+  const [complexity, setComplexity] = useState<Complexity | null>(null);
+  const [measuring, setMeasuring] = useState(false);
+  const [error, setError] = useState("");
+
+  const handleRun = async () => {
+    setLoading(true);
+    setError("");
+    try {
+      setResult(await runCode(problemId, code));
+    } catch (err) {
+      setResult(null);
+      setError(`Run failed: ${err}`);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleTests = async () => {
+    setTesting(true);
+    setError("");
+    try {
+      setTests(await runTests(problemId, code));
+    } catch (err) {
+      setTests(null);
+      setError(`Testing failed: ${err}`);
+    } finally {
+      setTesting(false);
+    }
+  };
+
+  const handleBenchmark = async () => {
+    setMeasuring(true);
+    setError("");
+    try {
+      setComplexity(await benchmarkCode(problemId, code));
+    } catch (err) {
+      setComplexity(null);
+      setError(`Timing failed: ${err}`);
+    } finally {
+      setMeasuring(false);
+    }
+  };
+
+  return (
+    <div className="bg-bg-surface border border-border rounded-xl p-5 space-y-3">
+      <div className="flex items-center justify-between">
+        <h2 className="text-xs font-semibold uppercase tracking-wider text-fg-muted">
+          Python 3
+          {result && ` · ${result.passed}/${result.run} examples passed`}
+        </h2>
+        <div className="flex gap-4">
+          <button
+            onClick={handleRun}
+            disabled={loading || !code.trim()}
+            className="text-xs text-tn-blue hover:text-tn-purple disabled:opacity-20 transition-colors border-b border-tn-blue/30"
+          >
+            {loading ? "Running..." : "Run examples"}
+          </button>
+          <button
+            onClick={handleTests}
+            disabled={testing || !code.trim()}
+            className="text-xs text-tn-blue hover:text-tn-purple disabled:opacity-20 transition-colors border-b border-tn-blue/30"
+          >
+            {testing ? "Testing..." : "Test on generated inputs"}
+          </button>
+          <button
+            onClick={handleBenchmark}
+            disabled={measuring || !code.trim()}
+            className="text-xs text-tn-blue hover:text-tn-purple disabled:opacity-20 transition-colors border-b border-tn-blue/30"
+          >
+            {measuring ? "Measuring..." : "Measure complexity"}
+          </button>
+        </div>
+      </div>
+      <textarea
+        value={code}
+        onChange={(e) => {
+          setCode(e.target.value);
+          onCodeChange?.(e.target.value);
+        }}
+        rows={10}
+        spellCheck={false}
+        className="w-full bg-bg-main border border-border rounded-xl px-4 py-3 text-fg-main focus:outline-none focus:border-tn-blue focus:ring-1 focus:ring-tn-blue/30 transition-all resize-y font-mono text-sm leading-relaxed"
+      />
+      {onAttachChange && (
+        <label className="flex items-center gap-2 text-xs text-fg-muted">
+          <input
+            type="checkbox"
+            checked={attach ?? false}
+            onChange={(e) => onAttachChange(e.target.checked)}
+          />
+          Time this code when grading and show the measurements to the grader
+        </label>
+      )}
+      {result?.error && (
+        <pre className="text-xs text-tn-red whitespace-pre-wrap">{result.error}</pre>
+      )}
+      {result && (
+        <ul className="space-y-2">
+          {result.cases.map((c) => (
+            <li key={c.example} className="text-sm space-y-1">
+              <div>
+                <span className="text-fg-muted">Example {c.example}: </span>
+                <span className={`font-semibold uppercase ${statusColors[c.status]}`}>
+                  {c.status}
+                </span>
+              </div>
+              {c.status !== "pass" && (
+                <div className="pl-4 border-l-2 border-border font-mono text-xs text-fg-main space-y-1">
+                  <div>Input: {c.input}</div>
+                  <div>Expected: {c.expected}</div>
+                  {c.got && <div>Got: {c.got}</div>}
+                  {c.stdout && <pre className="whitespace-pre-wrap text-fg-muted">{c.stdout}</pre>}
+                  {c.error && <pre className="whitespace-pre-wrap text-tn-red">{c.error}</pre>}
+                </div>
+              )}
+            </li>
+          ))}
+        </ul>
+      )}
+      {tests && (
+        <div className="space-y-2">
+          <div className="text-sm text-fg-muted">
+            Generated inputs:{" "}
+            <span className={tests.failed > 0 || tests.error ? "text-tn-red" : "text-tn-green"}>
+              {tests.passed}/{tests.checked} passed
+            </span>
+            {tests.timed_out && " · ran out of time"} · seed {tests.seed}
+          </div>
+          {tests.error && (
+            <pre className="text-xs text-tn-red whitespace-pre-wrap">{tests.error}</pre>
+          )}
+          {tests.counterexamples.map((c, i) => (
+            <div
+              key={i}
+              className="pl-4 border-l-2 border-tn-red/30 font-mono text-xs text-fg-main space-y-1"
+            >
+              <div>Input: {c.input}</div>
+              {c.expected && <div>Expected: {c.expected}</div>}
+              {c.got && <div>Got: {c.got}</div>}
+              {c.error && <pre className="whitespace-pre-wrap text-tn-red">{c.error}</pre>}
+            </div>
+          ))}
+        </div>
+      )}
+      {complexity && (
+        <div className="space-y-2">
+          <div className="text-sm text-fg-muted">
+            Estimated complexity:{" "}
+            <span className="text-fg-bright">{complexity.class ?? "too few timings to tell"}</span>
+          </div>
+          {complexity.error && (
+            <pre className="text-xs text-tn-red whitespace-pre-wrap">{complexity.error}</pre>
+          )}
+          {complexity.points.length > 0 && (
+            <div className="pl-4 border-l-2 border-border font-mono text-xs text-fg-main">
+              {complexity.points
+                .filter((_, i, all) => i % Math.max(1, Math.floor(all.length / 8)) === 0 || i === all.length - 1)
+                .map((p) => (
+                  <div key={p.n}>
+                    n = {p.n}: {formatSeconds(p.seconds)}
+                  </div>
+                ))}
+            </div>
+          )}
+        </div>
+      )}
+      {error && <div className="text-sm text-tn-red">{error}</div>}
+    </div>
+  );
+}
@@ -0,0 +1,78 @@
+import { useEffect, useState } from "react";
+import { Link } from "@tanstack/react-router";
+import { similarProblems } from "../api/client";
+import type { SimilarProblem } from "../types";
+import { DifficultyBadge } from "./DifficultyBadge";
+
+interface Props {
+  problemId: number;
+  topics: string[];
+}
+
+export function SimilarProblems({ problemId, topics }: Props) {
+  const [problems, setProblems] = useState<SimilarProblem[] | null>(null);
+  const [topic, setTopic] = useState("");
+  const [error, setError] = useState("");
+
+  useEffect(() => {
+    setProblems(null);
+    setError("");
+    similarProblems(problemId, { topic: topic || undefined, limit: 5 })
+      .then((res) => setProblems(res.problems))
+      .catch((err) => setError(String(err)));
+  }, [problemId, topic]);
+
+  return (
+    <div className="space-y-3">
+      <div className="flex items-center gap-3 flex-wrap">
+        <h2 className="text-xs font-semibold uppercase tracking-wider text-fg-muted">
+          Similar problems
+        </h2>
+        {topics.length > 0 && (
+          <select
+            value={topic}
+            onChange={(e) => setTopic(e.target.value)}
+            className="bg-bg-surface border border-border rounded-md text-xs text-fg-muted px-2 py-1"
+          >
+            <option value="">Any topic</option>
+            {topics.map((t) => (
+              <option key={t} value={t}>
+                {t}
+              </option>
+            ))}
+          </select>
+        )}
+      </div>
+      {error && <div className="text-tn-red text-xs">{error}</div>}
+      {!problems && !error && (
+        <div className="text-xs text-fg-muted">Finding similar problems...</div>
+      )}
+      {problems?.length === 0 && (
+        <div className="text-xs text-fg-muted">No similar problems found.</div>
+      )}
+      {problems && problems.length > 0 && (
+        <div className="space-y-1.5">
+          {problems.map((p) => (
+            <Link
+              key={p.id}
+              to="/problem/$id"
+              params={{ id: String(p.id) }}
+              className="flex items-center gap-3 bg-bg-surface border border-border rounded-lg px-4 py-2 hover:bg-bg-elevated transition-all group"
+            >
+              <span className="text-fg-muted text-xs font-mono w-12 shrink-0">
+                #{p.source_id}
+              </span>
+              <span className="flex-1 text-sm text-fg-main group-hover:text-fg-bright transition-colors">
+                {p.title}
+              </span>
+              <span className="text-xs text-fg-muted font-mono">
+                {Math.round(p.similarity * 100)}%
+              </span>
+              <DifficultyBadge difficulty={p.difficulty} />
+            </Link>
+          ))}
+        </div>
+      )}
+    </div>
+  );
+}
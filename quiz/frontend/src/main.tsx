@@ -1,4 +1,4 @@
-import { StrictMode } from "react";
+import { StrictMode, useEffect, useState } from "react";
 import { createRoot } from "react-dom/client";
 import {
   createRouter,
@@ -11,9 +11,40 @@ import {
 import { HomePage } from "./routes/index";
 import { ProblemPage } from "./routes/problem/$id.index";
 import { ResultPage } from "./routes/problem/$id.result";
+import { InterviewPage } from "./routes/interview/$id";
 import { SmokePage } from "./routes/smoke";
+import { LoginPage } from "./routes/login";
+import { getMe, logout } from "./api/client";
+import type { User } from "./types";
 import "./index.css";
 
+function UserMenu() {
+  const [user, setUser] = useState<User | null>(null);
+
+  useEffect(() => {
+    if (window.location.pathname !== "/login") {
+      getMe().then(setUser).catch(() => setUser(null));
+    }
+  }, []);
+
+  if (!user) return null;
+
+  return (
+    <div className="flex items-center gap-3 text-xs text-fg-muted">
+      <span>{user.username}</span>
+      <button
+        onClick={async () => {
+          await logout();
+          window.location.href = "/login";
+        }}
+        className="hover:text-tn-blue transition-colors"
+      >
+        Sign out
+      </button>
+    </div>
+  );
+}
+
 const rootRoute = createRootRoute({
   component: () => (
     <div className="min-h-screen bg-bg-main text-fg-main">
@@ -26,6 +57,7 @@ const rootRoute = createRootRoute({
             LeetTomato Quiz
           </Link>
           <span className="text-xs text-fg-muted ml-auto">LLM-graded practice</span>
+          <UserMenu />
         </div>
       </header>
       <main className="max-w-5xl mx-auto px-6 py-8">
@@ -53,13 +85,32 @@ const resultRoute = createRoute({
   component: ResultPage,
 });
 
+const interviewRoute = createRoute({
+  getParentRoute: () => rootRoute,
+  path: "/interview/$id",
+  component: InterviewPage,
+});
+
 const smokeRoute = createRoute({
   getParentRoute: () => rootRoute,
   path: "/smoke",
   component: SmokePage,
 });
 
-const routeTree = rootRoute.addChildren([indexRoute, problemRoute, resultRoute, smokeRoute]);
+const loginRoute = createRoute({
+  getParentRoute: () => rootRoute,
+  path: "/login",
+  component: LoginPage,
+});
+
+const routeTree = rootRoute.addChildren([
+  indexRoute,
+  problemRoute,
+  resultRoute,
+  interviewRoute,
+  smokeRoute,
+  loginRoute,
+]);
 
 const router = createRouter({ routeTree });
 
@@ -16,6 +16,7 @@ const DIFFICULTIES = [
 export function HomePage() {
   const [query, setQuery] = useState("");
   const [difficulty, setDifficulty] = useState("");
+  const [sort, setSort] = useState<"number" | "relevance">("relevance");
   const [problems, setProblems] = useState<ProblemSummary[]>([]);
   const [total, setTotal] = useState(0);
   const [offset, setOffset] = useState(0);
@@ -25,12 +26,13 @@ export function HomePage() {
   const debounceRef = useRef<ReturnType<typeof setTimeout>>(null);
 
   const fetchProblems = useCallback(
-    async (q: string, diff: string, off: number) => {
+    async (q: string, diff: string, order: "number" | "relevance", off: number) => {
       setLoading(true);
       try {
         const res = await listProblems({
           q: q || undefined,
           difficulty: diff || undefined,
+          sort: q ? order : undefined,
           limit,
           offset: off,
         });
@@ -50,20 +52,20 @@ export function HomePage() {
     if (debounceRef.current) clearTimeout(debounceRef.current);
     debounceRef.current = setTimeout(() => {
       setOffset(0);
-      fetchProblems(query, difficulty, 0);
+      fetchProblems(query, difficulty, sort, 0);
     }, 200);
     return () => {
       if (debounceRef.current) clearTimeout(debounceRef.current);
     };
-  }, [query, difficulty, fetchProblems]);
+  }, [query, difficulty, sort, fetchProblems]);
 
   // Page change (no debounce)
   const handlePageChange = useCallback(
     (newOffset: number) => {
       setOffset(newOffset);
-      fetchProblems(query, difficulty, newOffset);
+      fetchProblems(query, difficulty, sort, newOffset);
     },
-    [query, difficulty, fetchProblems],
+    [query, difficulty, sort, fetchProblems],
   );
 
   return (
@@ -105,8 +107,16 @@ export function HomePage() {
           </button>
         ))}
 
+        {query && (
+          <button
+            onClick={() => setSort(sort === "relevance" ? "number" : "relevance")}
+            className="ml-auto text-xs text-tn-blue hover:text-tn-purple transition-colors self-center"
+          >
+            {sort === "relevance" ? "Sorted by relevance" : "Sorted by number"}
+          </button>
+        )}
         {!loading && (
-          <span className="ml-auto text-xs text-fg-muted self-center">
+          <span className={`${query ? "" : "ml-auto "}text-xs text-fg-muted self-center`}>
             {total} problems
           </span>
         )}
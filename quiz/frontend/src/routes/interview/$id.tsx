@@ -0,0 +1,129 @@
+import { useEffect, useState } from "react";
+import { useParams, Link } from "@tanstack/react-router";
+import { getInterview, sendInterviewMessage } from "../../api/client";
+import { GradingResult } from "../../components/GradingResult";
+import type { Attempt, Interview, InterviewMessage } from "../../types";
+
+export function InterviewPage() {
+  const { id } = useParams({ from: "/interview/$id" });
+  const [interview, setInterview] = useState<Interview | null>(null);
+  const [messages, setMessages] = useState<InterviewMessage[]>([]);
+  const [attempt, setAttempt] = useState<Attempt | null>(null);
+  const [content, setContent] = useState("");
+  const [sending, setSending] = useState(false);
+  const [error, setError] = useState("");
+
+  const load = () =>
+    getInterview(Number(id))
+      .then((res) => {
+        setInterview(res.interview);
+        setMessages(res.messages);
+        setAttempt(res.attempt ?? null);
+      })
+      .catch((err) => setError(String(err)));
+
+  useEffect(() => {
+    load();
+  }, [id]);
+
+  const send = async (done: boolean) => {
+    if (!done && !content.trim()) return;
+    setSending(true);
+    setError("");
+    try {
+      const res = await sendInterviewMessage(Number(id), content, done);
+      setMessages((prev) => [...prev, ...res.messages]);
+      setContent("");
+      if (res.status === "graded") {
+        await load();
+      }
+    } catch (err) {
+      setError(`Interview failed: ${err}`);
+    } finally {
+      setSending(false);
+    }
+  };
+
+  if (!interview) {
+    return <div className="text-center text-fg-muted py-12">{error || "Loading..."}</div>;
+  }
+
+  const open = interview.status === "open";
+
+  return (
+    <div className="space-y-6">
+      <div className="flex items-center justify-between">
+        <h1 className="text-2xl font-bold text-fg-bright">
+          Mock interview: {interview.problem_title}
+        </h1>
+        <Link
+          to="/problem/$id"
+          params={{ id: String(interview.problem_id) }}
+          className="px-4 py-2 bg-bg-surface border border-border rounded-xl text-fg-muted hover:text-fg-main hover:border-fg-muted transition-colors"
+        >
+          View Problem
+        </Link>
+      </div>
+
+      <div className="space-y-3">
+        {messages.map((m) => (
+          <div
+            key={m.id}
+            className={`px-4 py-3 rounded-xl text-sm whitespace-pre-wrap leading-relaxed ${
+              m.role === "user"
+                ? "bg-bg-main border border-border text-fg-main ml-12"
+                : "bg-tn-blue/10 border border-tn-blue/20 text-fg-main mr-12"
+            }`}
+          >
+            {m.content}
+          </div>
+        ))}
+      </div>
+
+      {error && <div className="text-sm text-tn-red">{error}</div>}
+
+      {open && (
+        <form
+          onSubmit={(e) => {
+            e.preventDefault();
+            send(false);
+          }}
+          className="space-y-3"
+        >
+          <textarea
+            value={content}
+            onChange={(e) => setContent(e.target.value)}
+            rows={4}
+            placeholder="Ask a clarifying question or explain your approach..."
+            className="w-full bg-bg-main border border-border rounded-xl px-4 py-3 text-fg-main placeholder-fg-muted focus:outline-none focus:border-tn-blue focus:ring-1 focus:ring-tn-blue/30 transition-all resize-y font-mono text-sm leading-relaxed"
+            disabled={sending}
+          />
+          <div className="flex gap-3">
+            <button
+              type="submit"
+              disabled={!content.trim() || sending}
+              className="px-6 py-2 bg-gradient-to-r from-tn-blue to-tn-purple text-bg-main font-semibold rounded-xl hover:opacity-90 disabled:opacity-20 disabled:cursor-not-allowed transition-all"
+            >
+              {sending ? "Waiting..." : "Send"}
+            </button>
+            <button
+              type="button"
+              onClick={() => send(true)}
+              disabled={sending}
+              className="px-6 py-2 bg-bg-surface border border-border rounded-xl text-fg-muted hover:text-fg-main hover:border-fg-muted disabled:opacity-20 transition-colors"
+            >
+              I'm done, grade me
+            </button>
+          </div>
+        </form>
+      )}
+
+      {attempt && (
+        <>
+          <hr className="border-bg-highlight" />
+          <GradingResult result={attempt} />
+        </>
+      )}
+    </div>
+  );
+}
@@ -0,0 +1,57 @@
+import { useState } from "react";
+import { login } from "../api/client";
+
+export function LoginPage() {
+  const [username, setUsername] = useState("");
+  const [password, setPassword] = useState("");
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  async function handleSubmit(e: React.FormEvent) {
+    e.preventDefault();
+    setLoading(true);
+    setError(null);
+    try {
+      await login(username, password);
+      // Full reload so every page refetches as the new user.
+      window.location.href = "/";
+    } catch (e) {
+      setError(e instanceof Error ? e.message : String(e));
+      setLoading(false);
+    }
+  }
+
+  const inputClass =
+    "w-full bg-bg-main border border-border rounded-xl px-4 py-3 text-fg-main placeholder-fg-muted focus:outline-none focus:border-tn-blue focus:ring-1 focus:ring-tn-blue/30 transition-all text-sm";
+
+  return (
+    <form onSubmit={handleSubmit} className="max-w-sm mx-auto space-y-4">
+      <h1 className="text-2xl font-bold text-fg-bright">Sign in</h1>
+      <input
+        value={username}
+        onChange={(e) => setUsername(e.target.value)}
+        placeholder="Username"
+        autoComplete="username"
+        className={inputClass}
+        disabled={loading}
+      />
+      <input
+        type="password"
+        value={password}
+        onChange={(e) => setPassword(e.target.value)}
+        placeholder="Password"
+        autoComplete="current-password"
+        className={inputClass}
+        disabled={loading}
+      />
+      <button
+        type="submit"
+        disabled={!username || !password || loading}
+        className="w-full px-6 py-3 bg-gradient-to-r from-tn-blue to-tn-purple text-bg-main font-semibold rounded-xl hover:opacity-90 disabled:opacity-20 disabled:cursor-not-allowed transition-all"
+      >
+        {loading ? "Signing in..." : "Sign in"}
+      </button>
+      {error && <p className="text-tn-red text-sm">{error}</p>}
+    </form>
+  );
+}
@@ -1,8 +1,11 @@
 import { useState, useEffect } from "react";
 import { useParams, useNavigate } from "@tanstack/react-router";
-import { getProblem, gradeAnswer } from "../../api/client";
+import { getProblem, gradeAnswer, startInterview } from "../../api/client";
 import { ProblemDetail } from "../../components/ProblemDetail";
 import { AnswerForm } from "../../components/AnswerForm";
+import { HintPanel } from "../../components/HintPanel";
+import { RunPanel } from "../../components/RunPanel";
+import { SimilarProblems } from "../../components/SimilarProblems";
 import type { Problem } from "../../types";
 
 export function ProblemPage() {
@@ -12,6 +15,9 @@ export function ProblemPage() {
   const [loading, setLoading] = useState(true);
   const [grading, setGrading] = useState(false);
   const [error, setError] = useState("");
+  const [answer, setAnswer] = useState("");
+  const [code, setCode] = useState<string | null>(null);
+  const [attachCode, setAttachCode] = useState(false);
 
   useEffect(() => {
     setLoading(true);
@@ -25,12 +31,20 @@ export function ProblemPage() {
     setGrading(true);
     setError("");
     try {
-      const res = await gradeAnswer(Number(id), answer);
+      const submitted = code ?? problem?.python3_snippet ?? "";
+      const res = await gradeAnswer(
+        Number(id),
+        answer,
+        undefined,
+        undefined,
+        attachCode ? submitted : undefined,
+      );
       // Store result in sessionStorage and navigate to result page
       sessionStorage.setItem(
         `grade-result-${id}`,
         JSON.stringify(res.result),
       );
+      sessionStorage.setItem(`grade-attempt-${id}`, String(res.attempt_id));
       navigate({ to: "/problem/$id/result", params: { id } });
     } catch (err) {
       setError(`Grading failed: ${err}`);
@@ -39,6 +53,16 @@ export function ProblemPage() {
     }
   };
 
+  const handleInterview = async () => {
+    setError("");
+    try {
+      const res = await startInterview(Number(id));
+      navigate({ to: "/interview/$id", params: { id: String(res.interview.id) } });
+    } catch (err) {
+      setError(`Could not start the interview: ${err}`);
+    }
+  };
+
   if (loading) {
     return <div className="text-center text-fg-muted py-12">Loading...</div>;
   }
@@ -57,8 +81,33 @@ export function ProblemPage() {
     <div className="space-y-8">
       <ProblemDetail problem={problem} />
       <hr className="border-bg-highlight" />
-      <AnswerForm onSubmit={handleSubmit} loading={grading} />
+      <HintPanel problemId={problem.id} answer={answer} />
+      <AnswerForm onSubmit={handleSubmit} loading={grading} onChange={setAnswer} />
+      {problem.python3_snippet && (
+        <RunPanel
+          problemId={problem.id}
+          snippet={problem.python3_snippet}
+          onCodeChange={setCode}
+          attach={attachCode}
+          onAttachChange={setAttachCode}
+        />
+      )}
+      <div className="text-sm text-fg-muted">
+        Prefer a conversation?{" "}
+        <button
+          onClick={handleInterview}
+          className="text-tn-blue hover:text-tn-purple transition-colors border-b border-tn-blue/30"
+        >
+          Start a mock interview
+        </button>
+      </div>
       {error && <div className="text-tn-red text-sm">{error}</div>}
+      <hr className="border-bg-highlight" />
+      <SimilarProblems
+        key={problem.id}
+        problemId={problem.id}
+        topics={problem.topics}
+      />
     </div>
   );
 }
@@ -1,5 +1,6 @@
 import { useParams, Link } from "@tanstack/react-router";
 import { GradingResult } from "../../components/GradingResult";
+import { FollowUp } from "../../components/FollowUp";
 import type { GradingResult as GradingResultType } from "../../types";
 
 export function ResultPage() {
@@ -22,6 +23,7 @@ export function ResultPage() {
   }
 
   const result: GradingResultType = JSON.parse(stored);
+  const attemptId = Number(sessionStorage.getItem(`grade-attempt-${id}`));
 
   return (
     <div className="space-y-6">
@@ -44,6 +46,7 @@ export function ResultPage() {
         </div>
       </div>
       <GradingResult result={result} />
+      {attemptId > 0 && <FollowUp attemptId={attemptId} />}
     </div>
   );
 }
@@ -5,6 +5,7 @@ export interface ProblemSummary {
   title: string;
   difficulty: "Easy" | "Medium" | "Hard";
   topics: string[];
+  snippet?: string;
 }
 
 export interface Problem extends ProblemSummary {
@@ -12,10 +13,18 @@ export interface Problem extends ProblemSummary {
   description: string;
   examples: Example[];
   constraints: string[];
-  hints: string[];
   python3_snippet: string;
 }
 
+export interface SimilarProblem extends ProblemSummary {
+  similarity: number;
+}
+
+export interface SimilarResponse {
+  problems: SimilarProblem[];
+  model: string;
+}
+
 export interface Example {
   example_num: number;
   example_text: string;
@@ -29,19 +38,200 @@ export interface ListResponse {
 }
 
 export interface CriterionResult {
-  score: boolean;
+  key: string;
+  label: string;
+  points: number;
+  max_points: number;
+  weight: number;
   comment: string;
+  agreement?: number;
 }
 
 export interface GradingResult {
-  pattern_identified: CriterionResult;
-  solution_works: CriterionResult;
-  complexity_analysis: CriterionResult;
-  optimal_solution: CriterionResult;
+  rubric: string;
+  criteria: CriterionResult[];
   overall_feedback: string;
+  score: number;
+  max_score: number;
+  cached: boolean;
+  samples?: number;
+  models?: string[];
+  hints_used?: number;
+  hint_penalty?: number;
+  evidence?: string;
+}
+
+export interface RubricLevel {
+  label: string;
+  description?: string;
+}
+
+export interface RubricCriterion {
+  key: string;
+  label: string;
+  description: string;
+  guidance?: string;
+  weight: number;
+  scale?: RubricLevel[];
+}
+
+export interface Rubric {
+  name: string;
+  description?: string;
+  instructions?: string;
+  criteria: RubricCriterion[];
+}
+
+export interface RubricsResponse {
+  rubrics: Rubric[];
+  default: string;
 }
 
 export interface GradeResponse {
   problem_id: number;
+  attempt_id: number;
   result: GradingResult;
+  next_review?: string;
+  evidence_error?: string;
+}
+
+export interface Attempt extends GradingResult {
+  id: number;
+  problem_id: number;
+  problem_slug: string;
+  problem_title: string;
+  answer: string;
+  model: string;
+  created_at: string;
+}
+
+export interface AttemptMessage {
+  id: number;
+  attempt_id: number;
+  role: "user" | "assistant";
+  content: string;
+  created_at: string;
+}
+
+export interface Interview {
+  id: number;
+  user_id: number;
+  problem_id: number;
+  problem_slug: string;
+  problem_title: string;
+  rubric: string;
+  status: "open" | "graded";
+  grading?: boolean;
+  attempt_id?: number;
+  created_at: string;
+}
+
+export interface InterviewMessage {
+  id: number;
+  interview_id: number;
+  role: "user" | "assistant";
+  content: string;
+  created_at: string;
+}
+
+export interface InterviewResponse {
+  interview: Interview;
+  messages: InterviewMessage[];
+  attempt?: Attempt;
+}
+
+export interface InterviewMessageResponse {
+  messages: InterviewMessage[];
+  status: "open" | "graded";
+  attempt_id?: number;
+  result?: GradingResult;
+  next_review?: string;
+}
+
+export interface Hint {
+  id: number;
+  level: number;
+  source: "stored" | "llm";
+  kind?: string;
+  content: string;
+  penalty: number;
+  created_at: string;
+}
+
+export interface HintsResponse {
+  hints: Hint[];
+  max_level: number;
+  penalty: number;
+}
+
+export interface RunCase {
+  example: number;
+  status: "pass" | "fail" | "error" | "skipped" | "timeout";
+  input: string;
+  expected: string;
+  got?: string;
+  error?: string;
+  stdout?: string;
+}
+
+export interface RunResult {
+  cases: RunCase[];
+  passed: number;
+  run: number;
+  error?: string;
+}
+
+export interface Counterexample {
+  input: string;
+  expected?: string;
+  got?: string;
+  status: "fail" | "error" | "timeout";
+  error?: string;
+}
+
+export interface TestsResult {
+  checked: number;
+  passed: number;
+  failed: number;
+  counterexamples: Counterexample[];
+  timed_out?: boolean;
+  error?: string;
+  seed: number;
+  generator_model: string;
+  generator_created_at: string;
+}
+
+export interface Complexity {
+  class?: string;
+  points: { n: number; seconds: number }[];
+  fits: { class: string; error: number }[];
+  error?: string;
+}
+
+export interface AttemptListResponse {
+  attempts: Attempt[];
+  total: number;
+  limit: number;
+  offset: number;
+}
+
+export interface User {
+  id: number;
+  username: string;
+  created_at: string;
+}
+
+export interface APIToken {
+  id: number;
+  name: string;
+  prefix: string;
+  scopes: string[];
+  expires_at?: string;
+  last_used_at?: string;
+  created_at: string;
+}
+
+export interface CreateTokenResponse {
+  token: string;
+  info: APIToken;
 }
@@ -6,12 +6,7 @@ export default defineConfig({
   plugins: [react(), tailwindcss()],
   server: {
     proxy: {
-      "/api": {
-        target: "http://localhost:8080",
-        headers: {
-          Authorization: "Basic " + Buffer.from(":changeme").toString("base64"),
-        },
-      },
+      "/api": "http://localhost:8080",
     },
   },
 });
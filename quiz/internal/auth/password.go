@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the shortest password accepted for an account.
+const MinPasswordLength = 8
+
+// ErrWeakPassword is returned for passwords shorter than MinPasswordLength.
+var ErrWeakPassword = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+
+// HashPassword returns a bcrypt hash of password.
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrWeakPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// dummyHash is compared against when a username does not exist, so that
+// unknown users take as long to reject as wrong passwords.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+// checkPassword reports whether password matches hash. An empty hash never
+// matches but costs the same as a real comparison.
+func checkPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,176 @@
+// Package auth authenticates users with session cookies or personal API
+// tokens, and carries the user in the request context.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+// CookieName is the session cookie set by Login.
+const CookieName = "quiz_session"
+
+// ErrInvalidCredentials is returned by Login for an unknown user or a wrong
+// password.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+type contextKey struct{}
+
+// WithUser returns a copy of ctx carrying the authenticated user.
+func WithUser(ctx context.Context, u *db.User) context.Context {
+	return context.WithValue(ctx, contextKey{}, u)
+}
+
+// UserFromContext returns the authenticated user, or nil outside of
+// Middleware.
+func UserFromContext(ctx context.Context) *db.User {
+	u, _ := ctx.Value(contextKey{}).(*db.User)
+	return u
+}
+
+// Sessions issues and checks login sessions stored in the database.
+type Sessions struct {
+	db       *db.DB
+	ttl      time.Duration
+	throttle *Throttle
+}
+
+// NewSessions returns sessions lasting ttl. Logins are limited by throttle,
+// which may be nil for no limit.
+func NewSessions(db *db.DB, ttl time.Duration, throttle *Throttle) *Sessions {
+	return &Sessions{db: db, ttl: ttl, throttle: throttle}
+}
+
+// Authenticate checks a username and password.
+func (s *Sessions) Authenticate(username, password string) (*db.User, error) {
+	u, hash, err := s.db.UserPasswordHash(username)
+	if err != nil {
+		return nil, err
+	}
+	if !checkPassword(hash, password) || u == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return u, nil
+}
+
+// Login checks the credentials and starts a session, setting its cookie.
+// It returns a *ThrottledError without checking them if the client or the
+// username has had too many failed logins.
+func (s *Sessions) Login(w http.ResponseWriter, r *http.Request, username, password string) (*db.User, error) {
+	if s.throttle != nil {
+		if ok, wait := s.throttle.Allow(r, username, time.Now()); !ok {
+			return nil, &ThrottledError{RetryAfter: wait}
+		}
+	}
+
+	u, err := s.Authenticate(username, password)
+	if errors.Is(err, ErrInvalidCredentials) && s.throttle != nil {
+		s.throttle.Fail(r, username, time.Now())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.throttle != nil {
+		s.throttle.Succeed(username)
+	}
+
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	expires := time.Now().Add(s.ttl)
+
+	if err := s.db.CreateSession(hashToken(token), u.ID, expires); err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return u, nil
+}
+
+// Logout ends the request's session, if any, and clears its cookie.
+func (s *Sessions) Logout(w http.ResponseWriter, r *http.Request) error {
+	if c, err := r.Cookie(CookieName); err == nil {
+		if err := s.db.DeleteSession(hashToken(c.Value)); err != nil {
+			return err
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// Middleware puts the user identified by the session cookie into the
+// request context unless an earlier middleware (such as Tokens.Middleware)
+// already has. API requests without a user get
+// 401, except for the paths in public. Other paths (the SPA) are served
+// to everyone, since the app shows its own login page.
+func (s *Sessions) Middleware(public ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, err := s.userFor(r)
+			if err != nil {
+				log.Printf("authenticate request: %v", err)
+				http.Error(w, "authentication failed", http.StatusInternalServerError)
+				return
+			}
+			if u != nil {
+				r = r.WithContext(WithUser(r.Context(), u))
+			} else if strings.HasPrefix(r.URL.Path, "/api/") && !slices.Contains(public, r.URL.Path) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (s *Sessions) userFor(r *http.Request) (*db.User, error) {
+	if u := UserFromContext(r.Context()); u != nil {
+		return u, nil
+	}
+
+	if c, err := r.Cookie(CookieName); err == nil && c.Value != "" {
+		return s.db.SessionUser(hashToken(c.Value), time.Now())
+	}
+	return nil, nil
+}
+
+// hashToken is what is stored for a session token, so that a leaked
+// database does not leak usable sessions.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// isHTTPS reports whether the client connected over TLS, directly or via a
+// proxy that terminates it.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
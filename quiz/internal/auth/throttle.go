@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThrottledError is returned by Login while a client or username has had
+// too many failed logins.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed logins; try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// Throttle counts failed logins per client IP and per username, and refuses
+// further attempts from either once it reaches the limit, until the window
+// that started with the first failure has passed. This bounds both password
+// guessing and the bcrypt work an attacker can cause.
+type Throttle struct {
+	mu          sync.Mutex
+	maxFailures int
+	window      time.Duration
+	ipHeader    string
+	failures    map[string]*failures
+}
+
+type failures struct {
+	count int
+	reset time.Time
+}
+
+// NewThrottle allows maxFailures failed logins per window from each client
+// and for each username. It returns nil, meaning no limit, if maxFailures
+// is not positive. ipHeader names the header a trusted reverse proxy gives
+// the client's IP in, such as Fly-Client-IP or X-Forwarded-For; if empty,
+// the connecting address is taken to be the client's.
+func NewThrottle(maxFailures int, window time.Duration, ipHeader string) *Throttle {
+	if maxFailures <= 0 {
+		return nil
+	}
+	return &Throttle{maxFailures: maxFailures, window: window, ipHeader: ipHeader, failures: make(map[string]*failures)}
+}
+
+// Allow reports whether a login for username from r may be tried, and if
+// not, how long until it may.
+func (t *Throttle) Allow(r *http.Request, username string, now time.Time) (bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var wait time.Duration
+	for _, key := range t.keys(r, username) {
+		if f, ok := t.failures[key]; ok && now.Before(f.reset) && f.count >= t.maxFailures {
+			wait = max(wait, f.reset.Sub(now))
+		}
+	}
+	return wait == 0, wait
+}
+
+// Fail records a failed login for username from r.
+func (t *Throttle) Fail(r *http.Request, username string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.failures) >= pruneThreshold {
+		t.prune(now)
+	}
+	for _, key := range t.keys(r, username) {
+		f, ok := t.failures[key]
+		if !ok || !now.Before(f.reset) {
+			f = &failures{reset: now.Add(t.window)}
+			t.failures[key] = f
+		}
+		f.count++
+	}
+}
+
+// Succeed forgets the username's failures. The client's are kept, so that
+// logging in to one account does not reset guessing at others.
+func (t *Throttle) Succeed(username string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failures, userKey(username))
+}
+
+// pruneThreshold is how many counters Fail keeps before it looks for
+// expired ones to drop.
+const pruneThreshold = 1024
+
+// prune drops expired counters, so that the map does not grow with every
+// client and username ever seen.
+func (t *Throttle) prune(now time.Time) {
+	for key, f := range t.failures {
+		if !now.Before(f.reset) {
+			delete(t.failures, key)
+		}
+	}
+}
+
+// keys are the counters a login attempt counts against: the client's IP
+// and the username.
+func (t *Throttle) keys(r *http.Request, username string) []string {
+	return []string{"ip:" + clientIP(r, t.ipHeader), userKey(username)}
+}
+
+// clientIP returns the IP the proxy reports in header, if it is set, and
+// otherwise the connecting one. A proxy appends the address it saw to
+// X-Forwarded-For, so only the last hop is its own; the ones before it come
+// from the client.
+func clientIP(r *http.Request, header string) string {
+	if values := r.Header.Values(header); header != "" && len(values) > 0 {
+		hops := strings.Split(values[len(values)-1], ",")
+		if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+			return ip
+		}
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return ip
+}
+
+// userKey is the counter for a username. Usernames are matched without
+// regard to case, so every spelling of one shares a counter.
+func userKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
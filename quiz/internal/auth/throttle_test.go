@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+func TestThrottle(t *testing.T) {
+	now := time.Now()
+	from := func(ip string) *http.Request {
+		r := httptest.NewRequest("POST", "/api/login", nil)
+		r.RemoteAddr = ip + ":1234"
+		return r
+	}
+
+	th := NewThrottle(3, time.Minute, "")
+	for range 3 {
+		if ok, _ := th.Allow(from("10.0.0.1"), "alice", now); !ok {
+			t.Fatal("refused before the limit")
+		}
+		th.Fail(from("10.0.0.1"), "alice", now)
+	}
+
+	tests := []struct {
+		name     string
+		ip, user string
+		at       time.Duration
+		allowed  bool
+		wait     time.Duration
+	}{
+		{"same client and user", "10.0.0.1", "alice", 0, false, time.Minute},
+		{"same user from another client", "10.0.0.2", "alice", 10 * time.Second, false, 50 * time.Second},
+		{"same user in another case", "10.0.0.2", "ALICE", 0, false, time.Minute},
+		{"same user in mixed case", "10.0.0.3", "aLice", 0, false, time.Minute},
+		{"another user from the same client", "10.0.0.1", "bob", 0, false, time.Minute},
+		{"another user and client", "10.0.0.2", "bob", 0, true, 0},
+		{"after the window", "10.0.0.1", "alice", time.Minute, true, 0},
+	}
+	for _, tt := range tests {
+		ok, wait := th.Allow(from(tt.ip), tt.user, now.Add(tt.at))
+		if ok != tt.allowed || wait != tt.wait {
+			t.Errorf("%s: Allow = %v, %v; want %v, %v", tt.name, ok, wait, tt.allowed, tt.wait)
+		}
+	}
+
+	th.Succeed("Alice")
+	if ok, _ := th.Allow(from("10.0.0.2"), "alice", now); !ok {
+		t.Error("username still locked after a successful login")
+	}
+	if ok, _ := th.Allow(from("10.0.0.1"), "carol", now); ok {
+		t.Error("a successful login reset the client's failures")
+	}
+
+	if NewThrottle(0, time.Minute, "") != nil {
+		t.Error("NewThrottle(0) is not nil")
+	}
+}
+
+func TestThrottleBehindProxy(t *testing.T) {
+	now := time.Now()
+	// Every request comes from the proxy's address.
+	via := func(header, value string) *http.Request {
+		r := httptest.NewRequest("POST", "/api/login", nil)
+		r.RemoteAddr = "172.16.0.1:1234"
+		if value != "" {
+			r.Header.Set(header, value)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		// failing is where the failed logins come from, other where the
+		// next login does.
+		failing, other string
+		allowed        bool
+	}{
+		{"Fly-Client-IP", "Fly-Client-IP", "203.0.113.1", "203.0.113.2", true},
+		{"same client", "Fly-Client-IP", "203.0.113.1", "203.0.113.1", false},
+		{"X-Forwarded-For", "X-Forwarded-For", "203.0.113.1", "203.0.113.2", true},
+		// Only the last hop is added by the proxy; the client chose the rest.
+		{"forged hops", "X-Forwarded-For", "10.0.0.1, 203.0.113.1", "10.0.0.2, 203.0.113.1", false},
+		{"header missing", "Fly-Client-IP", "", "", false},
+		// Without a trusted header, clients behind the proxy share its
+		// address.
+		{"header not trusted", "", "203.0.113.1", "203.0.113.2", false},
+	}
+	for _, tt := range tests {
+		header := tt.header
+		if header == "" {
+			header = "Fly-Client-IP"
+		}
+		th := NewThrottle(3, time.Minute, tt.header)
+		for i := range 3 {
+			th.Fail(via(header, tt.failing), fmt.Sprintf("user%d", i), now)
+		}
+		if ok, _ := th.Allow(via(header, tt.other), "bob", now); ok != tt.allowed {
+			t.Errorf("%s: Allow = %v, want %v", tt.name, ok, tt.allowed)
+		}
+	}
+}
+
+func TestLoginThrottled(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.CreateUser("alice", hash); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSessions(d, time.Hour, NewThrottle(3, time.Minute, ""))
+
+	tests := []struct {
+		password string
+		want     error
+	}{
+		{"wrong", ErrInvalidCredentials},
+		{"correct horse", nil},
+		{"wrong", ErrInvalidCredentials},
+		{"wrong", ErrInvalidCredentials},
+		// The client has failed three times, so it is locked out even with
+		// the right password.
+		{"correct horse", &ThrottledError{}},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("POST", "/api/login", nil)
+		_, err := s.Login(httptest.NewRecorder(), r, "alice", tt.password)
+
+		var throttled *ThrottledError
+		switch {
+		case tt.want == nil && err != nil:
+			t.Errorf("login %d: %v", i+1, err)
+		case errors.As(tt.want, &throttled):
+			if !errors.As(err, &throttled) || throttled.RetryAfter <= 0 {
+				t.Errorf("login %d: err = %v, want a ThrottledError", i+1, err)
+			}
+		case tt.want != nil && !errors.Is(err, tt.want):
+			t.Errorf("login %d: err = %v, want %v", i+1, err, tt.want)
+		}
+	}
+}
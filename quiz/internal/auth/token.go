@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+// Scopes an API token can be limited to. Sessions have every scope.
+const (
+	// ScopeProblemsRead allows browsing problems, topics and rubrics.
+	ScopeProblemsRead = "problems:read"
+	// ScopeAttemptsRead allows reading the user's attempts and reviews.
+	ScopeAttemptsRead = "attempts:read"
+	// ScopeGrade allows grading answers, which spends LLM budget.
+	ScopeGrade = "grade"
+)
+
+// AllScopes lists every scope; it is what a token gets if none are given.
+var AllScopes = []string{ScopeProblemsRead, ScopeAttemptsRead, ScopeGrade}
+
+// tokenPrefix marks quiz API tokens so they are easy to spot in scripts and
+// secret scanners.
+const tokenPrefix = "qz_"
+
+// ErrUnknownScope is returned when creating a token with an invalid scope.
+var ErrUnknownScope = errors.New("unknown scope")
+
+type tokenKey struct{}
+
+// TokenFromContext returns the API token the request authenticated with,
+// or nil if it used a session.
+func TokenFromContext(ctx context.Context) *db.APIToken {
+	t, _ := ctx.Value(tokenKey{}).(*db.APIToken)
+	return t
+}
+
+// Tokens issues and checks personal access tokens.
+type Tokens struct {
+	db *db.DB
+}
+
+func NewTokens(db *db.DB) *Tokens {
+	return &Tokens{db: db}
+}
+
+// Create issues a token for the user. It returns the secret, which is not
+// stored and cannot be recovered, along with the stored token. Empty scopes
+// mean all scopes; a zero ttl means the token never expires.
+func (t *Tokens) Create(userID int, name string, scopes []string, ttl time.Duration) (string, *db.APIToken, error) {
+	if name == "" {
+		return "", nil, errors.New("token name is required")
+	}
+	if len(scopes) == 0 {
+		scopes = AllScopes
+	}
+	for _, s := range scopes {
+		if !slices.Contains(AllScopes, s) {
+			return "", nil, fmt.Errorf("%w %q (valid: %s)", ErrUnknownScope, s, strings.Join(AllScopes, ", "))
+		}
+	}
+
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	secret := tokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	token := &db.APIToken{
+		UserID: userID,
+		Name:   name,
+		Prefix: secret[:len(tokenPrefix)+6],
+		Scopes: scopes,
+	}
+	if ttl > 0 {
+		token.ExpiresAt = time.Now().UTC().Add(ttl).Format(db.TimeFormat)
+	}
+	if err := t.db.CreateAPIToken(token, hashToken(secret)); err != nil {
+		return "", nil, err
+	}
+	return secret, token, nil
+}
+
+// Middleware authenticates requests carrying "Authorization: Bearer",
+// putting the token's user and the token into the request context. A bad
+// or expired token is rejected outright rather than falling back to other
+// credentials. Requests without a bearer token pass through untouched.
+func (t *Tokens) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, token, err := t.db.APITokenUser(hashToken(strings.TrimSpace(secret)), time.Now())
+			if err != nil {
+				log.Printf("authenticate token: %v", err)
+				http.Error(w, "authentication failed", http.StatusInternalServerError)
+				return
+			}
+			if user == nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(WithUser(r.Context(), user), tokenKey{}, token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScope rejects requests made with an API token that lacks scope.
+// Session requests are always allowed.
+func RequireScope(scope string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if t := TokenFromContext(r.Context()); t != nil && !slices.Contains(t.Scopes, scope) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope))
+			http.Error(w, "token lacks scope "+scope, http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// RequireSession rejects requests made with an API token, so that a token
+// cannot be used to mint or revoke tokens.
+func RequireSession(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if TokenFromContext(r.Context()) != nil {
+			http.Error(w, "not available to API tokens", http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// ParseTTL parses a token lifetime: a Go duration such as "720h" or a
+// number of days such as "90d". Empty means no expiry.
+func ParseTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid expiry %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid expiry %q", s)
+	}
+	return d, nil
+}
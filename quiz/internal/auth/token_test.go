@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+func TestTokensCreate(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	user, err := d.CreateUser("alice", "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := NewTokens(d)
+
+	if _, _, err := tokens.Create(user.ID, "", nil, 0); err == nil {
+		t.Error("Create accepted a token without a name")
+	}
+	if _, _, err := tokens.Create(user.ID, "ci", []string{"admin"}, 0); !errors.Is(err, ErrUnknownScope) {
+		t.Errorf("Create with an unknown scope = %v, want ErrUnknownScope", err)
+	}
+
+	secret, token, err := tokens.Create(user.ID, "ci", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token.Scopes) != len(AllScopes) || token.ExpiresAt != "" || token.Prefix != secret[:len(token.Prefix)] {
+		t.Errorf("token %+v for secret %s, want every scope, no expiry and the secret's prefix", token, secret)
+	}
+	if _, token, err := tokens.Create(user.ID, "short", []string{ScopeGrade}, time.Hour); err != nil || token.ExpiresAt == "" {
+		t.Errorf("Create with a ttl = %+v, %v; want an expiry", token, err)
+	}
+}
+
+func TestTokensMiddleware(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	user, err := d.CreateUser("alice", "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := NewTokens(d)
+
+	reader, _, err := tokens.Create(user.ID, "reader", []string{ScopeProblemsRead}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grader, _, err := tokens.Create(user.ID, "grader", []string{ScopeGrade}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expired = "qz_expired"
+	if err := d.CreateAPIToken(&db.APIToken{
+		UserID: user.ID, Name: "old", Prefix: expired[:6], Scopes: AllScopes,
+		ExpiresAt: time.Now().UTC().Add(-time.Minute).Format(db.TimeFormat),
+	}, hashToken(expired)); err != nil {
+		t.Fatal(err)
+	}
+	revoked, token, err := tokens.Create(user.ID, "revoked", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := d.DeleteAPIToken(user.ID, token.ID); !ok || err != nil {
+		t.Fatalf("DeleteAPIToken = %v, %v", ok, err)
+	}
+
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		if UserFromContext(r.Context()) == nil {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+	mux.HandleFunc("/problems", RequireScope(ScopeProblemsRead, ok))
+	mux.HandleFunc("/grade", RequireScope(ScopeGrade, ok))
+	mux.HandleFunc("/tokens", RequireSession(ok))
+	handler := tokens.Middleware()(mux)
+
+	tests := []struct {
+		name   string
+		path   string
+		secret string
+		// want is the status; 204 means the request reached the handler
+		// without a user.
+		want int
+	}{
+		{"no token", "/grade", "", http.StatusNoContent},
+		{"token with scope", "/problems", reader, http.StatusOK},
+		{"token without scope", "/grade", reader, http.StatusForbidden},
+		{"expiring token with scope", "/grade", grader, http.StatusOK},
+		{"expired token", "/problems", expired, http.StatusUnauthorized},
+		{"revoked token", "/problems", revoked, http.StatusUnauthorized},
+		{"unknown token", "/problems", "qz_unknown", http.StatusUnauthorized},
+		{"token on a session-only route", "/tokens", reader, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.secret != "" {
+			r.Header.Set("Authorization", "Bearer "+tt.secret)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	list, err := d.ListAPITokens(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tok := range list {
+		if used := tok.LastUsedAt != ""; used != (tok.Name == "reader" || tok.Name == "grader") {
+			t.Errorf("token %s last used %q", tok.Name, tok.LastUsedAt)
+		}
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"90d", 90 * 24 * time.Hour, false},
+		{"720h", 720 * time.Hour, false},
+		{"0d", 0, true},
+		{"-1h", 0, true},
+		{"xd", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTTL(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseTTL(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
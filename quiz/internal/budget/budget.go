@@ -0,0 +1,271 @@
+// Package budget enforces limits on LLM spend: daily and monthly token or
+// dollar budgets, per user and across all users, and a per-user rate limit.
+//
+// Usage is recorded per completion from the usage block the provider
+// returns, so repaired grading attempts and failed grades count too.
+// Budgets are checked before a request reaches the model; a request that
+// starts under budget may take the total slightly over it.
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/leettomato/quiz/internal/auth"
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/llm"
+)
+
+// Limits caps LLM usage. Zero fields are unlimited.
+type Limits struct {
+	DailyTokens   int64
+	MonthlyTokens int64
+	DailyUSD      float64
+	MonthlyUSD    float64
+}
+
+// Pricing converts token counts to dollars.
+type Pricing struct {
+	InputPerMTok  float64
+	OutputPerMTok float64
+}
+
+// Cost is the price of one completion in dollars.
+func (p Pricing) Cost(u llm.Usage) float64 {
+	return (float64(u.PromptTokens)*p.InputPerMTok + float64(u.CompletionTokens)*p.OutputPerMTok) / 1e6
+}
+
+var (
+	// ErrBudgetExceeded is wrapped by *ExceededError.
+	ErrBudgetExceeded = errors.New("LLM budget exceeded")
+	// ErrRateLimited is wrapped by *RateLimitError.
+	ErrRateLimited = errors.New("rate limit exceeded")
+)
+
+// ExceededError reports which budget ran out and when it resets.
+type ExceededError struct {
+	// Scope is "user" or "global".
+	Scope string
+	// Period is "daily" or "monthly".
+	Period string
+	// Unit is "tokens" or "USD".
+	Unit    string
+	Used    float64
+	Limit   float64
+	ResetAt time.Time
+}
+
+func (e *ExceededError) Error() string {
+	who := "your"
+	if e.Scope == "global" {
+		who = "the global"
+	}
+	return fmt.Sprintf("%s %s LLM budget is used up (%s of %s %s); it resets at %s UTC",
+		who, e.Period, formatAmount(e.Used), formatAmount(e.Limit), e.Unit, e.ResetAt.Format(db.TimeFormat))
+}
+
+func (e *ExceededError) Unwrap() error { return ErrBudgetExceeded }
+
+// RateLimitError reports how long to wait before trying again.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many LLM requests; try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// Guard checks budgets and the rate limit and records usage.
+type Guard struct {
+	db      *db.DB
+	user    Limits
+	global  Limits
+	pricing Pricing
+	limiter *RateLimiter
+}
+
+// NewGuard returns a guard applying the user limits to each user and the
+// global limits to everyone's usage combined. limiter may be nil.
+func NewGuard(db *db.DB, user, global Limits, pricing Pricing, limiter *RateLimiter) *Guard {
+	return &Guard{db: db, user: user, global: global, pricing: pricing, limiter: limiter}
+}
+
+// Check returns an *ExceededError if the user or global budget is used up,
+// or a *RateLimitError if the user is making requests too quickly. Only
+// requests that pass the budget check take from the rate limit.
+func (g *Guard) Check(userID int, now time.Time) error {
+	if err := g.checkLimits("user", userID, g.user, now); err != nil {
+		return err
+	}
+	if err := g.checkLimits("global", 0, g.global, now); err != nil {
+		return err
+	}
+	if g.limiter != nil {
+		if ok, wait := g.limiter.Allow(userID, now); !ok {
+			return &RateLimitError{RetryAfter: wait}
+		}
+	}
+	return nil
+}
+
+func (g *Guard) checkLimits(scope string, userID int, l Limits, now time.Time) error {
+	for _, p := range periods(l, now) {
+		if p.tokens <= 0 && p.usd <= 0 {
+			continue
+		}
+		used, err := g.db.UsageSince(userID, p.start)
+		if err != nil {
+			return err
+		}
+		if p.tokens > 0 && used.Tokens >= p.tokens {
+			return &ExceededError{Scope: scope, Period: p.name, Unit: "tokens",
+				Used: float64(used.Tokens), Limit: float64(p.tokens), ResetAt: p.end}
+		}
+		if p.usd > 0 && used.CostUSD >= p.usd {
+			return &ExceededError{Scope: scope, Period: p.name, Unit: "USD",
+				Used: used.CostUSD, Limit: p.usd, ResetAt: p.end}
+		}
+	}
+	return nil
+}
+
+// Context returns a context under which the llm.Client records the usage
+// of every completion against the user.
+func (g *Guard) Context(ctx context.Context, userID int) context.Context {
+	return llm.WithUsageHook(ctx, func(model string, u llm.Usage) {
+		err := g.db.RecordUsage(db.Usage{
+			UserID:           userID,
+			Model:            model,
+			PromptTokens:     u.PromptTokens,
+			CompletionTokens: u.CompletionTokens,
+			CostUSD:          g.pricing.Cost(u),
+		})
+		if err != nil {
+			log.Printf("record LLM usage: %v", err)
+		}
+	})
+}
+
+// Limit wraps a handler that calls the LLM. It rejects the request with 402
+// when a budget is used up or 429 when rate limited, both with Retry-After,
+// without calling h; otherwise it calls h with a context that records usage
+// against the request's user.
+func (g *Guard) Limit(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := auth.UserFromContext(r.Context()).ID
+		now := time.Now()
+
+		if err := g.Check(userID, now); err != nil {
+			var exceeded *ExceededError
+			var limited *RateLimitError
+			switch {
+			case errors.As(err, &exceeded):
+				setRetryAfter(w, exceeded.ResetAt.Sub(now))
+				http.Error(w, err.Error(), http.StatusPaymentRequired)
+			case errors.As(err, &limited):
+				setRetryAfter(w, limited.RetryAfter)
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+			default:
+				log.Printf("check LLM budget: %v", err)
+				http.Error(w, "checking LLM budget failed", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		h(w, r.WithContext(g.Context(r.Context(), userID)))
+	}
+}
+
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+// Period is one budget period's usage and limits.
+type Period struct {
+	Name    string  `json:"name"`
+	Tokens  int64   `json:"tokens"`
+	CostUSD float64 `json:"cost_usd"`
+	// Limits are omitted when unlimited.
+	TokenLimit int64   `json:"token_limit,omitempty"`
+	USDLimit   float64 `json:"usd_limit,omitempty"`
+	ResetsAt   string  `json:"resets_at"`
+}
+
+// Report is a user's usage against their budgets.
+type Report struct {
+	Periods []Period `json:"periods"`
+	// Blocked explains why grading is refused, if it is.
+	Blocked string `json:"blocked,omitempty"`
+}
+
+// Report returns the user's daily and monthly usage and whether their
+// budget or the global one is used up. It does not take from the rate
+// limit.
+func (g *Guard) Report(userID int, now time.Time) (*Report, error) {
+	rep := &Report{}
+	for _, p := range periods(g.user, now) {
+		used, err := g.db.UsageSince(userID, p.start)
+		if err != nil {
+			return nil, err
+		}
+		rep.Periods = append(rep.Periods, Period{
+			Name:       p.name,
+			Tokens:     used.Tokens,
+			CostUSD:    used.CostUSD,
+			TokenLimit: p.tokens,
+			USDLimit:   p.usd,
+			ResetsAt:   p.end.Format(db.TimeFormat),
+		})
+	}
+
+	err := g.checkLimits("user", userID, g.user, now)
+	if err == nil {
+		err = g.checkLimits("global", 0, g.global, now)
+	}
+	if errors.Is(err, ErrBudgetExceeded) {
+		rep.Blocked = err.Error()
+	} else if err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// period is a budget window and the limits that apply within it.
+type period struct {
+	name       string
+	start, end time.Time
+	tokens     int64
+	usd        float64
+}
+
+// periods returns the day and month containing now. Days start at midnight
+// UTC and months on the 1st.
+func periods(l Limits, now time.Time) []period {
+	day, month := dayStart(now), monthStart(now)
+	return []period{
+		{"daily", day, day.AddDate(0, 0, 1), l.DailyTokens, l.DailyUSD},
+		{"monthly", month, month.AddDate(0, 1, 0), l.MonthlyTokens, l.MonthlyUSD},
+	}
+}
+
+func dayStart(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func monthStart(t time.Time) time.Time {
+	y, m, _ := t.UTC().Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,188 @@
+package budget
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leettomato/quiz/internal/auth"
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/llm"
+)
+
+func TestPeriods(t *testing.T) {
+	utc := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse(db.TimeFormat, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	est := time.FixedZone("EST", -5*60*60)
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		day, month [2]string
+	}{
+		{
+			name:  "mid month",
+			now:   utc("2026-03-15 13:45:00"),
+			day:   [2]string{"2026-03-15 00:00:00", "2026-03-16 00:00:00"},
+			month: [2]string{"2026-03-01 00:00:00", "2026-04-01 00:00:00"},
+		},
+		{
+			name:  "last second of the year",
+			now:   utc("2026-12-31 23:59:59"),
+			day:   [2]string{"2026-12-31 00:00:00", "2027-01-01 00:00:00"},
+			month: [2]string{"2026-12-01 00:00:00", "2027-01-01 00:00:00"},
+		},
+		{
+			name:  "new year",
+			now:   utc("2027-01-01 00:00:00"),
+			day:   [2]string{"2027-01-01 00:00:00", "2027-01-02 00:00:00"},
+			month: [2]string{"2027-01-01 00:00:00", "2027-02-01 00:00:00"},
+		},
+		{
+			name:  "leap day",
+			now:   utc("2028-02-29 08:00:00"),
+			day:   [2]string{"2028-02-29 00:00:00", "2028-03-01 00:00:00"},
+			month: [2]string{"2028-02-01 00:00:00", "2028-03-01 00:00:00"},
+		},
+		{
+			// 22:00 on January 31st in New York is already February in UTC.
+			name:  "local time",
+			now:   time.Date(2026, 1, 31, 22, 0, 0, 0, est),
+			day:   [2]string{"2026-02-01 00:00:00", "2026-02-02 00:00:00"},
+			month: [2]string{"2026-02-01 00:00:00", "2026-03-01 00:00:00"},
+		},
+	}
+	for _, tt := range tests {
+		ps := periods(Limits{DailyTokens: 1, MonthlyUSD: 2}, tt.now)
+		if len(ps) != 2 || ps[0].name != "daily" || ps[1].name != "monthly" {
+			t.Fatalf("%s: periods %+v", tt.name, ps)
+		}
+		for i, want := range [][2]string{tt.day, tt.month} {
+			got := [2]string{ps[i].start.Format(db.TimeFormat), ps[i].end.Format(db.TimeFormat)}
+			if got != want {
+				t.Errorf("%s: %s period %v, want %v", tt.name, ps[i].name, got, want)
+			}
+		}
+		if ps[0].tokens != 1 || ps[1].usd != 2 {
+			t.Errorf("%s: limits %+v", tt.name, ps)
+		}
+	}
+}
+
+func TestGuardCheck(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	alice, err := d.CreateUser("alice", "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := d.CreateUser("bob", "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice has used 1000 tokens costing $0.006 today; bob nothing.
+	pricing := Pricing{InputPerMTok: 3, OutputPerMTok: 15}
+	for _, u := range []llm.Usage{{PromptTokens: 500, CompletionTokens: 300}, {PromptTokens: 200}} {
+		if err := d.RecordUsage(db.Usage{UserID: alice.ID, Model: "model", PromptTokens: u.PromptTokens,
+			CompletionTokens: u.CompletionTokens, CostUSD: pricing.Cost(u)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	tomorrow := dayStart(now).AddDate(0, 0, 1)
+	nextMonth := monthStart(now).AddDate(0, 1, 0)
+
+	tests := []struct {
+		name   string
+		user   Limits
+		global Limits
+		userID int
+		at     time.Time
+		// scope, period and unit describe the exceeded budget, if any.
+		scope, period, unit string
+	}{
+		{name: "unlimited", userID: alice.ID, at: now},
+		{name: "under the daily tokens", user: Limits{DailyTokens: 1001}, userID: alice.ID, at: now},
+		{name: "daily tokens used up", user: Limits{DailyTokens: 1000}, userID: alice.ID, at: now, scope: "user", period: "daily", unit: "tokens"},
+		{name: "daily tokens the next day", user: Limits{DailyTokens: 1000}, userID: alice.ID, at: tomorrow},
+		{name: "monthly dollars used up", user: Limits{MonthlyUSD: 0.005}, userID: alice.ID, at: now, scope: "user", period: "monthly", unit: "USD"},
+		{name: "monthly dollars next month", user: Limits{MonthlyUSD: 0.005}, userID: alice.ID, at: nextMonth},
+		{name: "another user's budget", user: Limits{DailyTokens: 1000}, userID: bob.ID, at: now},
+		{name: "global budget", global: Limits{DailyTokens: 1000}, userID: bob.ID, at: now, scope: "global", period: "daily", unit: "tokens"},
+	}
+	for _, tt := range tests {
+		err := NewGuard(d, tt.user, tt.global, pricing, nil).Check(tt.userID, tt.at)
+		var exceeded *ExceededError
+		switch {
+		case tt.scope == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.scope != "" && !errors.As(err, &exceeded):
+			t.Errorf("%s: err = %v, want an ExceededError", tt.name, err)
+		case tt.scope != "":
+			if exceeded.Scope != tt.scope || exceeded.Period != tt.period || exceeded.Unit != tt.unit {
+				t.Errorf("%s: exceeded %s %s %s, want %s %s %s", tt.name,
+					exceeded.Scope, exceeded.Period, exceeded.Unit, tt.scope, tt.period, tt.unit)
+			}
+			if want := map[string]time.Time{"daily": tomorrow, "monthly": nextMonth}[tt.period]; !exceeded.ResetAt.Equal(want) {
+				t.Errorf("%s: resets at %s, want %s", tt.name, exceeded.ResetAt, want)
+			}
+		}
+	}
+}
+
+func TestGuardLimit(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	user, err := d.CreateUser("alice", "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGuard(d, Limits{DailyTokens: 100}, Limits{}, Pricing{}, NewRateLimiter(60, 2))
+	h := g.Limit(func(w http.ResponseWriter, r *http.Request) {
+		if err := d.RecordUsage(db.Usage{UserID: user.ID, Model: "model", PromptTokens: 60}); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// The first request is allowed and spends 60 tokens, the second is
+	// allowed and takes the total over budget, and the third is refused for
+	// the budget, which is checked before the rate limit.
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusPaymentRequired} {
+		r := httptest.NewRequest(http.MethodPost, "/api/grade", nil)
+		r = r.WithContext(auth.WithUser(r.Context(), user))
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != want {
+			t.Errorf("request %d: status = %d, want %d", i, w.Code, want)
+		}
+		if want != http.StatusOK && w.Header().Get("Retry-After") == "" {
+			t.Errorf("request %d: no Retry-After", i)
+		}
+	}
+
+	rep, err := g.Report(user.ID, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Blocked == "" || rep.Periods[0].Tokens != 120 || rep.Periods[0].TokenLimit != 100 {
+		t.Errorf("report %+v, want 120 of 100 daily tokens and blocked", rep)
+	}
+}
@@ -0,0 +1,83 @@
+package budget
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/leettomato/quiz/internal/auth"
+)
+
+// RateLimiter is a token bucket per user: each user may make burst
+// requests at once, refilled at a steady rate.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[int]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter allows perMinute requests a minute per user with bursts of
+// up to burst requests. It returns nil, meaning no limit, if perMinute is
+// not positive. A burst below 1 is treated as 1.
+func NewRateLimiter(perMinute float64, burst int) *RateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    perMinute / 60,
+		burst:   float64(burst),
+		buckets: make(map[int]*bucket),
+	}
+}
+
+// Allow takes a token from the user's bucket. If the bucket is empty it
+// returns false and how long until a token is available.
+func (l *RateLimiter) Allow(userID int, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[userID]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[userID] = b
+	}
+
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// Limit wraps a handler that is costly to run but does not call the LLM, so
+// is not subject to the budgets. It rejects requests over the user's rate
+// limit with 429 and Retry-After without calling h. A nil limiter allows
+// every request.
+func (l *RateLimiter) Limit(h http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.Allow(auth.UserFromContext(r.Context()).ID, time.Now())
+		if !ok {
+			setRetryAfter(w, wait)
+			http.Error(w, fmt.Sprintf("too many requests; try again in %ds", int(math.Ceil(wait.Seconds()))),
+				http.StatusTooManyRequests)
+			return
+		}
+		h(w, r)
+	}
+}
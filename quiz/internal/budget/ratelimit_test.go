@@ -0,0 +1,78 @@
+package budget
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/leettomato/quiz/internal/auth"
+	"github.com/leettomato/quiz/internal/db"
+)
+
+func TestRateLimiterLimit(t *testing.T) {
+	limiter := NewRateLimiter(1, 2)
+	calls := 0
+	h := limiter.Limit(func(w http.ResponseWriter, r *http.Request) { calls++ })
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		r := httptest.NewRequest(http.MethodPost, "/api/run", nil)
+		r = r.WithContext(auth.WithUser(r.Context(), &db.User{ID: 1}))
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != want {
+			t.Errorf("request %d: status = %d, want %d", i, w.Code, want)
+		}
+		if want == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("request %d: no Retry-After", i)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+
+	// Without a limit every request goes through.
+	var none *RateLimiter
+	h = none.Limit(func(w http.ResponseWriter, r *http.Request) { calls++ })
+	for range 10 {
+		h(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/run", nil))
+	}
+	if calls != 12 {
+		t.Errorf("handler called %d times, want 12", calls)
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(60, 2)
+
+	steps := []struct {
+		after time.Duration
+		user  int
+		ok    bool
+		wait  time.Duration
+	}{
+		{0, 1, true, 0},
+		{0, 1, true, 0},
+		{0, 1, false, time.Second},
+		// Users have their own buckets.
+		{0, 2, true, 0},
+		{500 * time.Millisecond, 1, false, 500 * time.Millisecond},
+		{500 * time.Millisecond, 1, true, 0},
+		// Refills stop at the burst size.
+		{time.Hour, 1, true, 0},
+		{0, 1, true, 0},
+		{0, 1, false, time.Second},
+	}
+	for i, s := range steps {
+		now = now.Add(s.after)
+		ok, wait := l.Allow(s.user, now)
+		if ok != s.ok || wait != s.wait {
+			t.Errorf("step %d: Allow = %v, %s, want %v, %s", i, ok, wait, s.ok, s.wait)
+		}
+	}
+
+	if NewRateLimiter(0, 5) != nil {
+		t.Error("NewRateLimiter(0, 5) is not nil")
+	}
+}
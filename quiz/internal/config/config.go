@@ -1,61 +1,270 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"github.com/leettomato/quiz/internal/budget"
 )
 
 type Config struct {
-	Port         string
-	AuthPassword string
-	DBPath       string
-	StaticDir    string
-	LLMBaseURL   string
-	LLMAPIKey    string
-	LLMModel     string
+	Port        string
+	DBPath      string
+	StaticDir   string
+	LLMProvider string
+	LLMBaseURL  string
+	LLMAPIKey   string
+	LLMModel    string
+	// LLMTimeout bounds each LLM request attempt.
+	LLMTimeout time.Duration
+	// LLMMaxRetries is how many times a rate-limited or failed LLM request
+	// is retried with backoff.
+	LLMMaxRetries int
+	// LLMGradingAttempts is how many times the grader asks the model for a
+	// valid submit_grading call, feeding back validation errors each time.
+	LLMGradingAttempts int
+	// LLMConsensusModels are the models consensus grading rotates through;
+	// empty means LLMModel only. LLMMaxSamples caps samples per request.
+	LLMConsensusModels []string
+	LLMMaxSamples      int
+	// EmbeddingModel is the model similar problems are found with, through
+	// the LLM provider's /embeddings endpoint. Empty means a local TF-IDF
+	// model instead.
+	EmbeddingModel string
+	// HintPenalty is the fraction of the maximum score each hint costs the
+	// next attempt on the problem.
+	HintPenalty float64
+	// RunPython is the interpreter solutions are run with. Each run gets
+	// RunTimeout of wall and CPU time and RunMemoryMB of address space, with
+	// at most RunMaxConcurrent runs at once.
+	RunPython        string
+	RunTimeout       time.Duration
+	RunMemoryMB      int
+	RunMaxConcurrent int
+	// RubricsDir holds extra rubric files; DefaultRubric names the one used
+	// when a grade request does not pick one.
+	RubricsDir    string
+	DefaultRubric string
+	// SessionTTL is how long a login session lasts.
+	SessionTTL time.Duration
+	// LoginMaxFailures failed logins from one client or for one username
+	// lock out further attempts for LoginLockout. Zero disables the limit.
+	LoginMaxFailures int
+	LoginLockout     time.Duration
+	// ClientIPHeader names the header a trusted reverse proxy passes the
+	// client's IP in, for counting failed logins per client. Empty means
+	// requests come straight from clients.
+	ClientIPHeader string
+	// User is the account CLI commands act for when --user is not given.
+	User string
+	// InitialUser and InitialPassword are the account the server creates
+	// on start if there are no accounts, so that a fresh deploy can be
+	// logged into. Empty InitialUser creates none.
+	InitialUser     string
+	InitialPassword string
+	// UserBudget applies to each user and GlobalBudget to all users
+	// combined; LLMPricing turns token usage into dollars for both.
+	UserBudget   budget.Limits
+	GlobalBudget budget.Limits
+	LLMPricing   budget.Pricing
+	// RateLimitPerMinute caps each user's LLM requests, with bursts of up to
+	// RateLimitBurst. Zero disables the limit.
+	RateLimitPerMinute float64
+	RateLimitBurst     int
+	// RunRateLimitPerMinute caps each user's code runs, which use no LLM
+	// budget, with bursts of up to RunRateLimitBurst. Zero disables the
+	// limit.
+	RunRateLimitPerMinute float64
+	RunRateLimitBurst     int
 }
 
+// Load loads the server's config. Unlike LoadForCLI, it fails on a value
+// that cannot be parsed rather than start with a default the operator did
+// not choose.
 func Load() (*Config, error) {
-	// Load .env file if it exists (ignore error if missing)
-	godotenv.Load()
-
-	cfg := &Config{
-		Port:         getEnv("PORT", "8080"),
-		AuthPassword: os.Getenv("AUTH_PASSWORD"),
-		DBPath:       getEnv("DB_PATH", "./problems.db"),
-		StaticDir:    getEnv("STATIC_DIR", "./frontend/dist"),
-		LLMBaseURL:   getEnv("LLM_BASE_URL", "http://svc-litellm:4000/v1"),
-		LLMAPIKey:    os.Getenv("LLM_API_KEY"),
-		LLMModel:     getEnv("LLM_MODEL", "claude-sonnet-4-5"),
+	cfg, errs := load()
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
 
-	if cfg.AuthPassword == "" {
-		return nil, fmt.Errorf("AUTH_PASSWORD is required")
+	if cfg.SessionTTL <= 0 {
+		return nil, fmt.Errorf("SESSION_TTL must be positive")
+	}
+	if cfg.LoginMaxFailures > 0 && cfg.LoginLockout <= 0 {
+		return nil, fmt.Errorf("LOGIN_LOCKOUT must be positive")
+	}
+	if cfg.InitialUser != "" && cfg.InitialPassword == "" {
+		return nil, fmt.Errorf("INITIAL_USER needs INITIAL_PASSWORD")
+	}
+	if cfg.HintPenalty < 0 || cfg.HintPenalty > 1 {
+		return nil, fmt.Errorf("HINT_PENALTY must be between 0 and 1")
+	}
+	if cfg.EmbeddingModel != "" && cfg.LLMProvider != "openai" && cfg.LLMProvider != "" {
+		return nil, fmt.Errorf("EMBEDDING_MODEL needs LLM_PROVIDER=openai")
+	}
+	if cfg.RunTimeout <= 0 || cfg.RunMemoryMB <= 0 {
+		return nil, fmt.Errorf("RUN_TIMEOUT and RUN_MEMORY_MB must be positive")
+	}
+	for _, l := range []budget.Limits{cfg.UserBudget, cfg.GlobalBudget} {
+		if l.DailyTokens < 0 || l.MonthlyTokens < 0 || l.DailyUSD < 0 || l.MonthlyUSD < 0 {
+			return nil, fmt.Errorf("BUDGET_* limits must not be negative")
+		}
 	}
 
 	return cfg, nil
 }
 
-// LoadForCLI loads config without requiring AUTH_PASSWORD.
+// LoadForCLI loads config without the checks that only matter to the server.
+// Values that cannot be parsed are logged and the defaults used instead.
 func LoadForCLI() *Config {
+	cfg, errs := load()
+	for _, err := range errs {
+		log.Printf("%v; using the default", err)
+	}
+	return cfg
+}
+
+// load reads the config, with the default for each value that cannot be
+// parsed, and returns the errors parsing them.
+func load() (*Config, []error) {
+	// Load .env file if it exists (ignore error if missing)
 	godotenv.Load()
 
-	return &Config{
-		Port:         getEnv("PORT", "8080"),
-		AuthPassword: os.Getenv("AUTH_PASSWORD"),
-		DBPath:       getEnv("DB_PATH", "./problems.db"),
-		StaticDir:    getEnv("STATIC_DIR", "./frontend/dist"),
-		LLMBaseURL:   getEnv("LLM_BASE_URL", "http://svc-litellm:4000/v1"),
-		LLMAPIKey:    os.Getenv("LLM_API_KEY"),
-		LLMModel:     getEnv("LLM_MODEL", "claude-sonnet-4-5"),
+	provider := getEnv("LLM_PROVIDER", "openai")
+	var v vars
+
+	cfg := &Config{
+		Port:               getEnv("PORT", "8080"),
+		DBPath:             getEnv("DB_PATH", "./problems.db"),
+		StaticDir:          getEnv("STATIC_DIR", "./frontend/dist"),
+		LLMProvider:        provider,
+		LLMBaseURL:         getEnv("LLM_BASE_URL", defaultBaseURL(provider)),
+		LLMAPIKey:          os.Getenv("LLM_API_KEY"),
+		LLMModel:           getEnv("LLM_MODEL", "claude-sonnet-4-5"),
+		LLMTimeout:         v.duration("LLM_TIMEOUT", 2*time.Minute),
+		LLMMaxRetries:      v.int("LLM_MAX_RETRIES", 3),
+		LLMGradingAttempts: v.int("LLM_GRADING_ATTEMPTS", 3),
+		LLMConsensusModels: getList("LLM_CONSENSUS_MODELS"),
+		LLMMaxSamples:      v.int("LLM_MAX_SAMPLES", 5),
+		EmbeddingModel:     os.Getenv("EMBEDDING_MODEL"),
+		HintPenalty:        v.float("HINT_PENALTY", 0.1),
+		RunPython:          getEnv("RUN_PYTHON", "python3"),
+		RunTimeout:         v.duration("RUN_TIMEOUT", 10*time.Second),
+		RunMemoryMB:        v.int("RUN_MEMORY_MB", 256),
+		RunMaxConcurrent:   v.int("RUN_MAX_CONCURRENT", 2),
+		RubricsDir:         getEnv("RUBRICS_DIR", "./rubrics"),
+		DefaultRubric:      getEnv("DEFAULT_RUBRIC", "default"),
+		SessionTTL:         v.duration("SESSION_TTL", 30*24*time.Hour),
+		LoginMaxFailures:   v.int("LOGIN_MAX_FAILURES", 10),
+		LoginLockout:       v.duration("LOGIN_LOCKOUT", 15*time.Minute),
+		ClientIPHeader:     os.Getenv("CLIENT_IP_HEADER"),
+		User:               os.Getenv("QUIZ_USER"),
+		InitialUser:        os.Getenv("INITIAL_USER"),
+		InitialPassword:    os.Getenv("INITIAL_PASSWORD"),
+		UserBudget:         v.limits("BUDGET_USER_"),
+		GlobalBudget:       v.limits("BUDGET_GLOBAL_"),
+		LLMPricing: budget.Pricing{
+			InputPerMTok:  v.float("LLM_PRICE_INPUT_PER_MTOK", 0),
+			OutputPerMTok: v.float("LLM_PRICE_OUTPUT_PER_MTOK", 0),
+		},
+		RateLimitPerMinute:    v.float("RATE_LIMIT_PER_MINUTE", 0),
+		RateLimitBurst:        v.int("RATE_LIMIT_BURST", 5),
+		RunRateLimitPerMinute: v.float("RUN_RATE_LIMIT_PER_MINUTE", 10),
+		RunRateLimitBurst:     v.int("RUN_RATE_LIMIT_BURST", 3),
+	}
+	return cfg, v.errs
+}
+
+// limits reads the DAILY_TOKENS, MONTHLY_TOKENS, DAILY_USD and
+// MONTHLY_USD variables with the given prefix. Unset means unlimited.
+func (v *vars) limits(prefix string) budget.Limits {
+	return budget.Limits{
+		DailyTokens:   int64(v.int(prefix+"DAILY_TOKENS", 0)),
+		MonthlyTokens: int64(v.int(prefix+"MONTHLY_TOKENS", 0)),
+		DailyUSD:      v.float(prefix+"DAILY_USD", 0),
+		MonthlyUSD:    v.float(prefix+"MONTHLY_USD", 0),
 	}
 }
 
+// defaultBaseURL returns the usual endpoint for each LLM_PROVIDER.
+func defaultBaseURL(provider string) string {
+	switch provider {
+	case "anthropic":
+		return "https://api.anthropic.com/v1"
+	case "ollama":
+		return "http://localhost:11434"
+	default:
+		return "http://svc-litellm:4000/v1"
+	}
+}
+
+// getList splits a comma-separated variable, dropping empty items.
+func getList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func getEnv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
 	}
 	return fallback
 }
+
+// vars reads numbers and durations from the environment, noting the ones
+// that cannot be parsed.
+type vars struct {
+	errs []error
+}
+
+// duration parses a Go duration such as "90s".
+func (v *vars) duration(key string, fallback time.Duration) time.Duration {
+	s := os.Getenv(key)
+	if s == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		v.errs = append(v.errs, fmt.Errorf("invalid %s=%q: want a duration such as 90s, 15m or 720h", key, s))
+		return fallback
+	}
+	return d
+}
+
+func (v *vars) int(key string, fallback int) int {
+	s := os.Getenv(key)
+	if s == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		v.errs = append(v.errs, fmt.Errorf("invalid %s=%q: want a whole number", key, s))
+		return fallback
+	}
+	return n
+}
+
+func (v *vars) float(key string, fallback float64) float64 {
+	s := os.Getenv(key)
+	if s == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		v.errs = append(v.errs, fmt.Errorf("invalid %s=%q: want a number", key, s))
+		return fallback
+	}
+	return f
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		// want is part of the error expected, or empty for none.
+		want string
+	}{
+		{name: "defaults"},
+		{name: "valid", env: map[string]string{"LOGIN_LOCKOUT": "30m", "SESSION_TTL": "720h", "BUDGET_USER_DAILY_USD": "1.5"}},
+		{name: "lockout typo", env: map[string]string{"LOGIN_LOCKOUT": "15min"}, want: `invalid LOGIN_LOCKOUT="15min"`},
+		{name: "days", env: map[string]string{"SESSION_TTL": "30d"}, want: `invalid SESSION_TTL="30d"`},
+		{name: "failures", env: map[string]string{"LOGIN_MAX_FAILURES": "ten"}, want: "invalid LOGIN_MAX_FAILURES"},
+		{name: "budget tokens", env: map[string]string{"BUDGET_GLOBAL_MONTHLY_TOKENS": "1e6"}, want: "invalid BUDGET_GLOBAL_MONTHLY_TOKENS"},
+		{name: "budget dollars", env: map[string]string{"BUDGET_USER_DAILY_USD": "$5"}, want: "invalid BUDGET_USER_DAILY_USD"},
+		{name: "rate limit", env: map[string]string{"RATE_LIMIT_PER_MINUTE": "10/min"}, want: "invalid RATE_LIMIT_PER_MINUTE"},
+		{name: "run timeout", env: map[string]string{"RUN_TIMEOUT": "10"}, want: "invalid RUN_TIMEOUT"},
+		{name: "run memory", env: map[string]string{"RUN_MEMORY_MB": "256MB"}, want: "invalid RUN_MEMORY_MB"},
+		{name: "every error", env: map[string]string{"RUN_TIMEOUT": "x", "RUN_RATE_LIMIT_BURST": "y"}, want: "invalid RUN_RATE_LIMIT_BURST"},
+		{name: "out of range", env: map[string]string{"SESSION_TTL": "-1h"}, want: "SESSION_TTL must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := Load()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want one containing %q", err, tt.want)
+			}
+			if cfg != nil {
+				t.Errorf("Load returned a config as well as %v", err)
+			}
+		})
+	}
+
+	// The CLI carries on with the defaults.
+	t.Setenv("LOGIN_LOCKOUT", "15min")
+	if cfg := LoadForCLI(); cfg.LoginLockout != 15*time.Minute {
+		t.Errorf("LoadForCLI lockout %s, want the default 15m", cfg.LoginLockout)
+	}
+}
@@ -0,0 +1,304 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Criterion is a stored grade for one rubric criterion. It mirrors
+// llm.CriterionResult so that this package does not depend on llm.
+type Criterion struct {
+	Key       string  `json:"key"`
+	Label     string  `json:"label"`
+	Points    int     `json:"points"`
+	MaxPoints int     `json:"max_points"`
+	Weight    float64 `json:"weight"`
+	Comment   string  `json:"comment"`
+}
+
+// Attempt is a graded answer to a problem. Score is out of MaxScore, the
+// total criteria weight of the rubric it was graded against.
+type Attempt struct {
+	ID              int         `json:"id"`
+	UserID          int         `json:"user_id,omitempty"`
+	ProblemID       int         `json:"problem_id"`
+	ProblemSlug     string      `json:"problem_slug"`
+	ProblemTitle    string      `json:"problem_title"`
+	Answer          string      `json:"answer"`
+	Rubric          string      `json:"rubric"`
+	Criteria        []Criterion `json:"criteria"`
+	OverallFeedback string      `json:"overall_feedback"`
+	Model           string      `json:"model"`
+	Score           float64     `json:"score"`
+	MaxScore        float64     `json:"max_score"`
+	// HintsUsed is how many hints the attempt claimed, and HintPenalty the
+	// fraction of MaxScore they took off Score.
+	HintsUsed   int     `json:"hints_used"`
+	HintPenalty float64 `json:"hint_penalty"`
+	// Evidence is what was measured by running code submitted with the
+	// answer, as given to the grader, or empty.
+	Evidence  string `json:"evidence,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ScoreFraction is Score as a fraction of MaxScore.
+func (a *Attempt) ScoreFraction() float64 {
+	if a.MaxScore <= 0 {
+		return 0
+	}
+	return a.Score / a.MaxScore
+}
+
+// CreateAttempt stores a graded attempt and its criteria, and fills in its
+// ID and CreatedAt. An attempt by a user claims their pending hints on the
+// problem in the same transaction, and HintsUsed, HintPenalty and Score are
+// set from the hints it claimed.
+func (d *DB) CreateAttempt(a *Attempt) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	id, err := insertAttempt(tx, a)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return d.reloadAttempt(a, id)
+}
+
+// insertAttempt stores a and its criteria and claims its owner's pending
+// hints, as CreateAttempt describes, and returns its ID.
+func insertAttempt(tx *sql.Tx, a *Attempt) (int64, error) {
+	a.HintsUsed, a.HintPenalty = 0, 0
+	res, err := tx.Exec(`
+		INSERT INTO attempts (user_id, problem_id, answer, rubric, overall_feedback, model, score, max_score,
+		                      hints_used, hint_penalty, evidence)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, nullID(a.UserID), a.ProblemID, a.Answer, a.Rubric, a.OverallFeedback, a.Model, a.Score, a.MaxScore,
+		a.HintsUsed, a.HintPenalty, a.Evidence)
+	if err != nil {
+		return 0, fmt.Errorf("insert attempt: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("attempt id: %w", err)
+	}
+
+	for i, c := range a.Criteria {
+		if _, err := tx.Exec(`
+			INSERT INTO attempt_criteria (attempt_id, position, key, label, points, max_points, weight, comment)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, id, i, c.Key, c.Label, c.Points, c.MaxPoints, c.Weight, c.Comment); err != nil {
+			return 0, fmt.Errorf("insert attempt criterion: %w", err)
+		}
+	}
+
+	if a.UserID != 0 {
+		if err := claimHints(tx, a, id); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// reloadAttempt replaces a with the stored attempt id.
+func (d *DB) reloadAttempt(a *Attempt, id int64) error {
+	stored, err := d.GetAttempt(int(id))
+	if err != nil {
+		return err
+	}
+	*a = *stored
+	return nil
+}
+
+const attemptColumns = `
+	a.id, IFNULL(a.user_id, 0), a.problem_id, p.slug, p.title, a.answer, a.rubric,
+	a.overall_feedback, a.model, a.score, a.max_score, a.hints_used, a.hint_penalty, a.evidence, a.created_at
+`
+
+// nullID stores a zero ID as NULL.
+func nullID(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAttempt(row rowScanner) (*Attempt, error) {
+	var a Attempt
+	err := row.Scan(&a.ID, &a.UserID, &a.ProblemID, &a.ProblemSlug, &a.ProblemTitle, &a.Answer, &a.Rubric,
+		&a.OverallFeedback, &a.Model, &a.Score, &a.MaxScore, &a.HintsUsed, &a.HintPenalty, &a.Evidence, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// loadCriteria fills in the criteria of each attempt.
+func (d *DB) loadCriteria(attempts []Attempt) error {
+	if len(attempts) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*Attempt, len(attempts))
+	placeholders := make([]string, len(attempts))
+	args := make([]any, len(attempts))
+	for i := range attempts {
+		attempts[i].Criteria = []Criterion{}
+		byID[attempts[i].ID] = &attempts[i]
+		placeholders[i] = "?"
+		args[i] = attempts[i].ID
+	}
+
+	rows, err := d.conn.Query(`
+		SELECT attempt_id, key, label, points, max_points, weight, comment
+		FROM attempt_criteria
+		WHERE attempt_id IN (`+strings.Join(placeholders, ",")+`)
+		ORDER BY attempt_id, position
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("list attempt criteria: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var c Criterion
+		if err := rows.Scan(&id, &c.Key, &c.Label, &c.Points, &c.MaxPoints, &c.Weight, &c.Comment); err != nil {
+			return fmt.Errorf("scan attempt criterion: %w", err)
+		}
+		a := byID[id]
+		a.Criteria = append(a.Criteria, c)
+	}
+	return rows.Err()
+}
+
+// GetAttempt fetches a single attempt. Returns nil if it does not exist.
+func (d *DB) GetAttempt(id int) (*Attempt, error) {
+	row := d.conn.QueryRow(`
+		SELECT `+attemptColumns+`
+		FROM attempts a JOIN problems p ON p.id = a.problem_id
+		WHERE a.id = ?
+	`, id)
+	a, err := scanAttempt(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get attempt: %w", err)
+	}
+
+	attempts := []Attempt{*a}
+	if err := d.loadCriteria(attempts); err != nil {
+		return nil, err
+	}
+	return &attempts[0], nil
+}
+
+type AttemptListParams struct {
+	UserID    int
+	ProblemID int
+	Limit     int
+	Offset    int
+}
+
+// ListAttempts returns attempts newest first, optionally for a single user
+// and problem.
+func (d *DB) ListAttempts(params AttemptListParams) ([]Attempt, int, error) {
+	if params.Limit <= 0 {
+		params.Limit = 50
+	}
+
+	var where []string
+	var args []any
+
+	if params.UserID > 0 {
+		where = append(where, "a.user_id = ?")
+		args = append(args, params.UserID)
+	}
+	if params.ProblemID > 0 {
+		where = append(where, "a.problem_id = ?")
+		args = append(args, params.ProblemID)
+	}
+
+	whereClause := ""
+	if len(where) > 0 {
+		whereClause = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM attempts a %s", whereClause)
+	if err := d.conn.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count attempts: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM attempts a JOIN problems p ON p.id = a.problem_id
+		%s
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT ? OFFSET ?
+	`, attemptColumns, whereClause)
+
+	pageArgs := append(args, params.Limit, params.Offset)
+	rows, err := d.conn.Query(query, pageArgs...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []Attempt{}
+	for rows.Next() {
+		a, err := scanAttempt(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan attempt: %w", err)
+		}
+		attempts = append(attempts, *a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("list attempts: %w", err)
+	}
+	if err := d.loadCriteria(attempts); err != nil {
+		return nil, 0, err
+	}
+
+	return attempts, total, nil
+}
+
+// AttemptsOldestFirst returns every attempt that has an owner in the order
+// it was made, without criteria.
+func (d *DB) AttemptsOldestFirst() ([]Attempt, error) {
+	rows, err := d.conn.Query(`
+		SELECT ` + attemptColumns + `
+		FROM attempts a JOIN problems p ON p.id = a.problem_id
+		WHERE a.user_id IS NOT NULL
+		ORDER BY a.created_at, a.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list attempts: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []Attempt
+	for rows.Next() {
+		a, err := scanAttempt(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan attempt: %w", err)
+		}
+		attempts = append(attempts, *a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list attempts: %w", err)
+	}
+	return attempts, nil
+}
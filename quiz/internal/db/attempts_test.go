@@ -0,0 +1,82 @@
+package db
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestListAttempts(t *testing.T) {
+	d, twoSum, alice := openTestDB(t)
+	if _, err := d.ImportProblems([]ImportQuestion{{
+		Title: "Add Two Numbers", FrontendID: json.RawMessage(`"2"`), ProblemSlug: "add-two-numbers",
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	addTwo, err := d.GetProblemBySlug("add-two-numbers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := d.CreateUser("bob", "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Attempts are made in this order, so within a second the newest is
+	// the one with the highest ID.
+	made := []struct {
+		user    *User
+		problem *Problem
+	}{{alice, twoSum}, {alice, addTwo}, {bob, twoSum}, {alice, twoSum}}
+	ids := make([]int, len(made))
+	for i, m := range made {
+		a := &Attempt{
+			UserID: m.user.ID, ProblemID: m.problem.ID, Answer: "answer", Rubric: "default", Score: 3, MaxScore: 4,
+			Criteria: []Criterion{{Key: "correct", Label: "Correct", Points: 1, MaxPoints: 1, Weight: 3}},
+		}
+		if err := d.CreateAttempt(a); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = a.ID
+	}
+
+	tests := []struct {
+		name   string
+		params AttemptListParams
+		want   []int
+		total  int
+	}{
+		{"everyone's", AttemptListParams{}, []int{ids[3], ids[2], ids[1], ids[0]}, 4},
+		{"one user's", AttemptListParams{UserID: alice.ID}, []int{ids[3], ids[1], ids[0]}, 3},
+		{"one problem's", AttemptListParams{ProblemID: twoSum.ID}, []int{ids[3], ids[2], ids[0]}, 3},
+		{"one user's on one problem", AttemptListParams{UserID: alice.ID, ProblemID: twoSum.ID}, []int{ids[3], ids[0]}, 2},
+		{"first page", AttemptListParams{UserID: alice.ID, Limit: 2}, []int{ids[3], ids[1]}, 3},
+		{"last page", AttemptListParams{UserID: alice.ID, Limit: 2, Offset: 2}, []int{ids[0]}, 3},
+		{"past the end", AttemptListParams{UserID: alice.ID, Offset: 3}, []int{}, 3},
+		{"none", AttemptListParams{UserID: bob.ID, ProblemID: addTwo.ID}, []int{}, 0},
+	}
+	for _, tt := range tests {
+		attempts, total, err := d.ListAttempts(tt.params)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got := []int{}
+		for _, a := range attempts {
+			got = append(got, a.ID)
+			if len(a.Criteria) != 1 || a.Criteria[0].Key != "correct" || a.ProblemSlug == "" {
+				t.Errorf("%s: attempt %d has criteria %+v and problem %q", tt.name, a.ID, a.Criteria, a.ProblemSlug)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) || total != tt.total {
+			t.Errorf("%s: attempts %v of %d, want %v of %d", tt.name, got, total, tt.want, tt.total)
+		}
+	}
+
+	if a, err := d.GetAttempt(ids[1]); err != nil || a == nil || a.ProblemSlug != "add-two-numbers" || a.UserID != alice.ID {
+		t.Errorf("GetAttempt = %+v, %v", a, err)
+	}
+	if a, err := d.GetAttempt(ids[3] + 1); err != nil || a != nil {
+		t.Errorf("GetAttempt of a missing attempt = %+v, %v, want nil", a, err)
+	}
+}
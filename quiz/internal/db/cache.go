@@ -0,0 +1,34 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// CachedGrading returns the grading result stored under key, or nil if
+// there is none.
+func (d *DB) CachedGrading(key string) ([]byte, error) {
+	var result []byte
+	err := d.conn.QueryRow("SELECT result FROM grading_cache WHERE key = ?", key).Scan(&result)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get cached grading: %w", err)
+	}
+	return result, nil
+}
+
+// StoreGrading caches a grading result under key, replacing any result
+// already there.
+func (d *DB) StoreGrading(key string, problemID int, model string, result []byte) error {
+	_, err := d.conn.Exec(`
+		INSERT INTO grading_cache (key, problem_id, model, result) VALUES (?, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			model = excluded.model, result = excluded.result, created_at = datetime('now')
+	`, key, problemID, model, string(result))
+	if err != nil {
+		return fmt.Errorf("store cached grading: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Embedding is a problem's vector under some embedding model.
+type Embedding struct {
+	ProblemID int
+	Vector    []float32
+}
+
+// ProblemText is the text a problem's embedding is computed from.
+type ProblemText struct {
+	ID          int
+	Title       string
+	Description string
+}
+
+// ProblemTexts returns the title and description of every problem.
+func (d *DB) ProblemTexts() ([]ProblemText, error) {
+	rows, err := d.conn.Query("SELECT id, title, description FROM problems ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("list problem texts: %w", err)
+	}
+	defer rows.Close()
+
+	var texts []ProblemText
+	for rows.Next() {
+		var t ProblemText
+		if err := rows.Scan(&t.ID, &t.Title, &t.Description); err != nil {
+			return nil, fmt.Errorf("scan problem text: %w", err)
+		}
+		texts = append(texts, t)
+	}
+	return texts, rows.Err()
+}
+
+// UnembeddedProblems returns the IDs of problems with no vector under
+// model, including those whose text has changed since.
+func (d *DB) UnembeddedProblems(model string) ([]int, error) {
+	rows, err := d.conn.Query(`
+		SELECT p.id FROM problems p
+		WHERE NOT EXISTS (SELECT 1 FROM problem_embeddings e WHERE e.problem_id = p.id AND e.model = ?)
+		ORDER BY p.id
+	`, model)
+	if err != nil {
+		return nil, fmt.Errorf("list unembedded problems: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan problem id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// StoreEmbeddings saves vectors under model, replacing any the problems
+// had, all in one transaction.
+func (d *DB) StoreEmbeddings(model string, embeddings []Embedding) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("begin store embeddings: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, e := range embeddings {
+		if _, err := tx.Exec(`
+			INSERT INTO problem_embeddings (problem_id, model, vector) VALUES (?, ?, ?)
+			ON CONFLICT (problem_id, model) DO UPDATE SET
+				vector = excluded.vector, created_at = datetime('now')
+		`, e.ProblemID, model, encodeVector(e.Vector)); err != nil {
+			return fmt.Errorf("store embedding for problem %d: %w", e.ProblemID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit embeddings: %w", err)
+	}
+	return nil
+}
+
+// GetEmbedding returns the problem's vector under model, or nil if it has
+// none.
+func (d *DB) GetEmbedding(problemID int, model string) ([]float32, error) {
+	var blob []byte
+	err := d.conn.QueryRow(`
+		SELECT vector FROM problem_embeddings WHERE problem_id = ? AND model = ?
+	`, problemID, model).Scan(&blob)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get embedding: %w", err)
+	}
+	return decodeVector(blob), nil
+}
+
+// ListEmbeddings returns the vectors under model of the problems matching
+// params' filters. Limit and Offset are ignored.
+func (d *DB) ListEmbeddings(model string, params ListParams) ([]Embedding, error) {
+	where, args := params.filters()
+	where = append([]string{"e.model = ?"}, where...)
+	args = append([]any{model}, args...)
+
+	rows, err := d.conn.Query(fmt.Sprintf(`
+		SELECT e.problem_id, e.vector
+		FROM problem_embeddings e JOIN problems p ON p.id = e.problem_id
+		WHERE %s
+	`, strings.Join(where, " AND ")), args...)
+	if err != nil {
+		return nil, fmt.Errorf("list embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	var embeddings []Embedding
+	for rows.Next() {
+		var e Embedding
+		var blob []byte
+		if err := rows.Scan(&e.ProblemID, &blob); err != nil {
+			return nil, fmt.Errorf("scan embedding: %w", err)
+		}
+		e.Vector = decodeVector(blob)
+		embeddings = append(embeddings, e)
+	}
+	return embeddings, rows.Err()
+}
+
+func encodeVector(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(x))
+	}
+	return b
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrHintStale is returned by AddHint for a hint written for a level past
+// the next one, because an attempt claimed the pending hints meanwhile.
+var ErrHintStale = errors.New("the pending hints changed while the hint was written")
+
+// Hint sources.
+const (
+	HintStored = "stored"
+	HintLLM    = "llm"
+)
+
+// Hint is a hint given to a user on a problem. Levels count from 1 among
+// the hints given since the user's last attempt: the problem's stored hints
+// first, then generated ones of increasing Kind. AttemptID is zero until an
+// attempt claims the hint.
+type Hint struct {
+	ID        int    `json:"id"`
+	UserID    int    `json:"user_id"`
+	ProblemID int    `json:"problem_id"`
+	AttemptID int    `json:"attempt_id,omitempty"`
+	Level     int    `json:"level"`
+	Source    string `json:"source"`
+	Kind      string `json:"kind,omitempty"`
+	Content   string `json:"content"`
+	// Penalty is the fraction of the maximum score the hint costs.
+	Penalty   float64 `json:"penalty"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// PendingHints returns the hints the user has been given on the problem
+// since their last attempt, by level.
+func (d *DB) PendingHints(userID, problemID int) ([]Hint, error) {
+	rows, err := d.conn.Query(`
+		SELECT id, user_id, problem_id, level, source, kind, content, penalty, created_at
+		FROM hints
+		WHERE user_id = ? AND problem_id = ? AND attempt_id IS NULL
+		ORDER BY level, id
+	`, userID, problemID)
+	if err != nil {
+		return nil, fmt.Errorf("list pending hints: %w", err)
+	}
+	defer rows.Close()
+
+	hints := []Hint{}
+	for rows.Next() {
+		var h Hint
+		if err := rows.Scan(&h.ID, &h.UserID, &h.ProblemID, &h.Level, &h.Source, &h.Kind, &h.Content,
+			&h.Penalty, &h.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan hint: %w", err)
+		}
+		hints = append(hints, h)
+	}
+	return hints, rows.Err()
+}
+
+// AddHint stores a pending hint and fills in its ID and CreatedAt. Its
+// level should be the next one after the user's pending hints on the
+// problem, as read before the hint was written. If that level has been
+// given since, by a concurrent request, AddHint stores nothing, fills in h
+// with the hint already given and returns false. If pending hints have been
+// claimed since, it returns ErrHintStale, since the content was chosen for
+// a level that is not the next one any more.
+func (d *DB) AddHint(h *Hint) (bool, error) {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	var next int
+	if err := tx.QueryRow(`
+		SELECT IFNULL(MAX(level), 0) + 1 FROM hints
+		WHERE user_id = ? AND problem_id = ? AND attempt_id IS NULL
+	`, h.UserID, h.ProblemID).Scan(&next); err != nil {
+		return false, fmt.Errorf("next hint level: %w", err)
+	}
+	if h.Level > next {
+		return false, ErrHintStale
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO hints (user_id, problem_id, level, source, kind, content, penalty)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at
+	`, h.UserID, h.ProblemID, h.Level, h.Source, h.Kind, h.Content, h.Penalty).Scan(&h.ID, &h.CreatedAt)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(`
+			SELECT id, user_id, problem_id, level, source, kind, content, penalty, created_at
+			FROM hints
+			WHERE user_id = ? AND problem_id = ? AND attempt_id IS NULL AND level = ?
+		`, h.UserID, h.ProblemID, h.Level).Scan(&h.ID, &h.UserID, &h.ProblemID, &h.Level, &h.Source, &h.Kind,
+			&h.Content, &h.Penalty, &h.CreatedAt)
+		if err != nil {
+			return false, fmt.Errorf("get given hint: %w", err)
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("add hint: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit: %w", err)
+	}
+	return true, nil
+}
+
+// claimHints marks the user's pending hints on a's problem as used by the
+// attempt id, and takes their penalties off a's score. The penalties add up
+// to at most the whole score. Only the hints the update claims count, so a
+// hint given while the attempt was being graded is either claimed and paid
+// for here or left pending for the next attempt.
+func claimHints(tx *sql.Tx, a *Attempt, id int64) error {
+	rows, err := tx.Query(`
+		UPDATE hints SET attempt_id = ?
+		WHERE user_id = ? AND problem_id = ? AND attempt_id IS NULL
+		RETURNING penalty
+	`, id, a.UserID, a.ProblemID)
+	if err != nil {
+		return fmt.Errorf("claim hints: %w", err)
+	}
+	defer rows.Close()
+
+	var penalty float64
+	for rows.Next() {
+		var p float64
+		if err := rows.Scan(&p); err != nil {
+			return fmt.Errorf("scan hint penalty: %w", err)
+		}
+		a.HintsUsed++
+		penalty += p
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("claim hints: %w", err)
+	}
+	if a.HintsUsed == 0 {
+		return nil
+	}
+
+	a.HintPenalty = min(penalty, 1)
+	a.Score = max(0, a.Score-a.HintPenalty*a.MaxScore)
+	if _, err := tx.Exec(`
+		UPDATE attempts SET score = ?, hints_used = ?, hint_penalty = ? WHERE id = ?
+	`, a.Score, a.HintsUsed, a.HintPenalty, id); err != nil {
+		return fmt.Errorf("apply hint penalty: %w", err)
+	}
+	return nil
+}
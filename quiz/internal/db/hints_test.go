@@ -0,0 +1,79 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestAddHint(t *testing.T) {
+	d, problem, user := openTestDB(t)
+	hint := func(level int, content string) *Hint {
+		return &Hint{UserID: user.ID, ProblemID: problem.ID, Level: level, Source: HintLLM, Content: content, Penalty: 0.1}
+	}
+
+	first := hint(1, "first")
+	if added, err := d.AddHint(first); err != nil || !added {
+		t.Fatalf("AddHint = %v, %v", added, err)
+	}
+
+	// A second request for level 1 gets the hint already given.
+	dup := hint(1, "duplicate")
+	added, err := d.AddHint(dup)
+	if err != nil || added {
+		t.Fatalf("AddHint of a given level = %v, %v, want false", added, err)
+	}
+	if dup.ID != first.ID || dup.Content != "first" {
+		t.Errorf("AddHint filled in %+v, want the first hint", dup)
+	}
+
+	// Once an attempt claims the hints, levels start again from 1.
+	a := &Attempt{UserID: user.ID, ProblemID: problem.ID, Answer: "answer", Rubric: "default", Score: 4, MaxScore: 4}
+	if err := d.CreateAttempt(a); err != nil {
+		t.Fatal(err)
+	}
+	late := hint(2, "written before the attempt")
+	if added, err := d.AddHint(late); !errors.Is(err, ErrHintStale) || added {
+		t.Errorf("AddHint after a claim = %v, %v, want ErrHintStale", added, err)
+	}
+	pending, err := d.PendingHints(user.ID, problem.ID)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("pending hints %+v, %v after a stale hint, want none", pending, err)
+	}
+	if added, err := d.AddHint(hint(1, "rewritten")); err != nil || !added {
+		t.Errorf("AddHint at the new next level = %v, %v", added, err)
+	}
+}
+
+func TestAddHintConcurrent(t *testing.T) {
+	d, problem, user := openTestDB(t)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	added := 0
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h := &Hint{UserID: user.ID, ProblemID: problem.ID, Level: 1, Source: HintStored, Content: "hint", Penalty: 0.1}
+			ok, err := d.AddHint(h)
+			if err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				added++
+			}
+		}()
+	}
+	wg.Wait()
+
+	pending, err := d.PendingHints(user.ID, problem.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 || len(pending) != 1 {
+		t.Errorf("%d hints added and %d pending, want 1", added, len(pending))
+	}
+}
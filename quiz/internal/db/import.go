@@ -0,0 +1,286 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// ImportQuestion is one entry of merged_problems.json.
+type ImportQuestion struct {
+	Title        string            `json:"title"`
+	FrontendID   json.RawMessage   `json:"frontend_id"`
+	ProblemID    json.RawMessage   `json:"problem_id"`
+	ProblemSlug  string            `json:"problem_slug"`
+	Difficulty   string            `json:"difficulty"`
+	Description  string            `json:"description"`
+	Examples     json.RawMessage   `json:"examples"`
+	Constraints  json.RawMessage   `json:"constraints"`
+	Hints        json.RawMessage   `json:"hints"`
+	CodeSnippets map[string]string `json:"code_snippets"`
+	Topics       []string          `json:"topics"`
+}
+
+// ImportStats reports what an import changed.
+type ImportStats struct {
+	Added     int
+	Updated   int
+	Unchanged int
+	Skipped   int
+}
+
+// ParseImport reads merged_problems.json, which is either an object with a
+// "questions" array or a bare array of questions.
+func ParseImport(r io.Reader) ([]ImportQuestion, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read import: %w", err)
+	}
+
+	var questions []ImportQuestion
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &questions); err != nil {
+			return nil, fmt.Errorf("parse questions: %w", err)
+		}
+		return questions, nil
+	}
+
+	var wrapper struct {
+		Questions []ImportQuestion `json:"questions"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return nil, fmt.Errorf("parse questions: %w", err)
+	}
+	return wrapper.Questions, nil
+}
+
+// importRow is a question normalized into the column values of problems.
+type importRow struct {
+	sourceID       string
+	slug           string
+	title          string
+	difficulty     string
+	description    string
+	examples       string
+	constraints    string
+	hints          string
+	python3Snippet string
+	topics         []string
+}
+
+func newImportRow(q ImportQuestion) importRow {
+	row := importRow{
+		sourceID:    rawString(q.FrontendID),
+		slug:        q.ProblemSlug,
+		title:       q.Title,
+		difficulty:  q.Difficulty,
+		description: q.Description,
+		examples:    rawArray(q.Examples),
+		constraints: rawArray(q.Constraints),
+		hints:       rawArray(q.Hints),
+	}
+	if row.sourceID == "" {
+		row.sourceID = rawString(q.ProblemID)
+	}
+	if row.difficulty == "" {
+		row.difficulty = "Medium"
+	}
+	if q.CodeSnippets != nil {
+		row.python3Snippet = q.CodeSnippets["python3"]
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range q.Topics {
+		if t != "" && !seen[t] {
+			seen[t] = true
+			row.topics = append(row.topics, t)
+		}
+	}
+	slices.Sort(row.topics)
+	return row
+}
+
+// rawString renders a JSON string or number as plain text.
+func rawString(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// rawArray returns a compact JSON array, defaulting to "[]".
+func rawArray(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "[]"
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return "[]"
+	}
+	return buf.String()
+}
+
+// sameJSON reports whether two JSON documents are semantically equal, so that
+// formatting differences (e.g. from the old Python builder) are not counted
+// as updates.
+func sameJSON(a, b string) bool {
+	var va, vb any
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return a == b
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return bytes.Equal(ca, cb)
+}
+
+// ImportProblems upserts questions into problems, topics and problem_topics
+// keyed on (source, slug), all in one transaction. The FTS triggers keep
+// problems_fts in sync.
+func (d *DB) ImportProblems(questions []ImportQuestion) (ImportStats, error) {
+	var stats ImportStats
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return stats, fmt.Errorf("begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	topicIDs := make(map[string]int64)
+
+	for _, q := range questions {
+		row := newImportRow(q)
+		if row.title == "" || row.slug == "" {
+			stats.Skipped++
+			continue
+		}
+
+		id, changed, added, err := upsertProblem(tx, row)
+		if err != nil {
+			return stats, err
+		}
+
+		topicsChanged, err := syncTopics(tx, id, row.topics, topicIDs)
+		if err != nil {
+			return stats, err
+		}
+
+		switch {
+		case added:
+			stats.Added++
+		case changed || topicsChanged:
+			stats.Updated++
+		default:
+			stats.Unchanged++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return stats, fmt.Errorf("commit import: %w", err)
+	}
+	return stats, nil
+}
+
+func upsertProblem(tx *sql.Tx, row importRow) (id int64, changed, added bool, err error) {
+	var cur importRow
+	err = tx.QueryRow(`
+		SELECT id, source_id, title, difficulty, description, examples, constraints, hints, python3_snippet
+		FROM problems WHERE source = 'leetcode' AND slug = ?
+	`, row.slug).Scan(&id, &cur.sourceID, &cur.title, &cur.difficulty, &cur.description,
+		&cur.examples, &cur.constraints, &cur.hints, &cur.python3Snippet)
+
+	if err == sql.ErrNoRows {
+		res, err := tx.Exec(`
+			INSERT INTO problems (source, source_id, slug, title, difficulty, description, examples, constraints, hints, python3_snippet)
+			VALUES ('leetcode', ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, row.sourceID, row.slug, row.title, row.difficulty, row.description,
+			row.examples, row.constraints, row.hints, row.python3Snippet)
+		if err != nil {
+			return 0, false, false, fmt.Errorf("insert %s: %w", row.slug, err)
+		}
+		id, err = res.LastInsertId()
+		if err != nil {
+			return 0, false, false, fmt.Errorf("insert %s: %w", row.slug, err)
+		}
+		return id, true, true, nil
+	}
+	if err != nil {
+		return 0, false, false, fmt.Errorf("lookup %s: %w", row.slug, err)
+	}
+
+	if cur.sourceID == row.sourceID && cur.title == row.title && cur.difficulty == row.difficulty &&
+		cur.description == row.description && cur.python3Snippet == row.python3Snippet &&
+		sameJSON(cur.examples, row.examples) && sameJSON(cur.constraints, row.constraints) &&
+		sameJSON(cur.hints, row.hints) {
+		return id, false, false, nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE problems SET
+		    source_id = ?, title = ?, difficulty = ?, description = ?,
+		    examples = ?, constraints = ?, hints = ?, python3_snippet = ?,
+		    updated_at = datetime('now')
+		WHERE id = ?
+	`, row.sourceID, row.title, row.difficulty, row.description,
+		row.examples, row.constraints, row.hints, row.python3Snippet, id)
+	if err != nil {
+		return 0, false, false, fmt.Errorf("update %s: %w", row.slug, err)
+	}
+	return id, true, false, nil
+}
+
+// syncTopics replaces the problem's topic links if they differ from topics,
+// which must be sorted. topicIDs caches topic name lookups across calls.
+func syncTopics(tx *sql.Tx, problemID int64, topics []string, topicIDs map[string]int64) (bool, error) {
+	rows, err := tx.Query(`
+		SELECT t.name FROM topics t
+		JOIN problem_topics pt ON pt.topic_id = t.id
+		WHERE pt.problem_id = ?
+		ORDER BY t.name
+	`, problemID)
+	if err != nil {
+		return false, fmt.Errorf("fetch topics: %w", err)
+	}
+	var current []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return false, fmt.Errorf("scan topic: %w", err)
+		}
+		current = append(current, name)
+	}
+	rows.Close()
+
+	if strings.Join(current, "\x00") == strings.Join(topics, "\x00") {
+		return false, nil
+	}
+
+	if _, err := tx.Exec("DELETE FROM problem_topics WHERE problem_id = ?", problemID); err != nil {
+		return false, fmt.Errorf("clear topics: %w", err)
+	}
+
+	for _, name := range topics {
+		topicID, ok := topicIDs[name]
+		if !ok {
+			if _, err := tx.Exec("INSERT OR IGNORE INTO topics (name) VALUES (?)", name); err != nil {
+				return false, fmt.Errorf("insert topic %s: %w", name, err)
+			}
+			if err := tx.QueryRow("SELECT id FROM topics WHERE name = ?", name).Scan(&topicID); err != nil {
+				return false, fmt.Errorf("lookup topic %s: %w", name, err)
+			}
+			topicIDs[name] = topicID
+		}
+		if _, err := tx.Exec("INSERT OR IGNORE INTO problem_topics (problem_id, topic_id) VALUES (?, ?)", problemID, topicID); err != nil {
+			return false, fmt.Errorf("link topic %s: %w", name, err)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseImport(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"wrapped", `{"questions": [{"title": "Two Sum"}, {"title": "Add Two Numbers"}]}`, []string{"Two Sum", "Add Two Numbers"}},
+		{"bare array", ` [{"title": "Two Sum"}]`, []string{"Two Sum"}},
+		{"no questions", `{}`, nil},
+	}
+	for _, tt := range tests {
+		questions, err := ParseImport(strings.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var titles []string
+		for _, q := range questions {
+			titles = append(titles, q.Title)
+		}
+		if !reflect.DeepEqual(titles, tt.want) {
+			t.Errorf("%s: titles %v, want %v", tt.name, titles, tt.want)
+		}
+	}
+
+	if _, err := ParseImport(strings.NewReader(`{"questions": [`)); err == nil {
+		t.Error("ParseImport accepted malformed JSON")
+	}
+}
+
+func TestImportProblems(t *testing.T) {
+	question := func(slug, title string, topics ...string) ImportQuestion {
+		return ImportQuestion{
+			Title:        title,
+			FrontendID:   json.RawMessage(`1`),
+			ProblemSlug:  slug,
+			Difficulty:   "Easy",
+			Examples:     json.RawMessage(`[{"example_num": 1, "example_text": "x"}]`),
+			CodeSnippets: map[string]string{"python3": "class Solution: pass"},
+			Topics:       topics,
+		}
+	}
+	// The same examples, formatted differently.
+	reformatted := question("two-sum", "Two Sum II", "Array")
+	reformatted.Examples = json.RawMessage("[ {\"example_text\": \"x\",\n \"example_num\": 1} ]")
+
+	tests := []struct {
+		name      string
+		questions []ImportQuestion
+		want      ImportStats
+	}{
+		{
+			name:      "first import",
+			questions: []ImportQuestion{question("two-sum", "Two Sum", "Array", "Hash Table"), question("add-two", "Add Two Numbers")},
+			want:      ImportStats{Added: 2},
+		},
+		{
+			name:      "unchanged",
+			questions: []ImportQuestion{question("two-sum", "Two Sum", "Hash Table", "Array", "Array"), question("add-two", "Add Two Numbers")},
+			want:      ImportStats{Unchanged: 2},
+		},
+		{
+			name:      "updated title",
+			questions: []ImportQuestion{question("two-sum", "Two Sum II", "Array", "Hash Table")},
+			want:      ImportStats{Updated: 1},
+		},
+		{
+			name:      "updated topics",
+			questions: []ImportQuestion{question("two-sum", "Two Sum II", "Array")},
+			want:      ImportStats{Updated: 1},
+		},
+		{
+			name:      "reformatted JSON",
+			questions: []ImportQuestion{reformatted},
+			want:      ImportStats{Unchanged: 1},
+		},
+		{
+			name:      "missing slug or title",
+			questions: []ImportQuestion{question("", "No Slug"), question("no-title", ""), question("three-sum", "3Sum")},
+			want:      ImportStats{Added: 1, Skipped: 2},
+		},
+	}
+
+	d, err := Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, tt := range tests {
+		stats, err := d.ImportProblems(tt.questions)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if stats != tt.want {
+			t.Errorf("%s: stats %+v, want %+v", tt.name, stats, tt.want)
+		}
+	}
+
+	p, err := d.GetProblemBySlug("two-sum")
+	if err != nil || p == nil {
+		t.Fatalf("GetProblemBySlug = %v, %v", p, err)
+	}
+	if p.Title != "Two Sum II" || !reflect.DeepEqual(p.Topics, []string{"Array"}) || p.Python3Snippet == "" {
+		t.Errorf("problem after re-import = %+v", p)
+	}
+}
@@ -0,0 +1,220 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Interview statuses.
+const (
+	InterviewOpen   = "open"
+	InterviewGraded = "graded"
+)
+
+// gradingClaimAge is how long a claim to grade an interview lasts. A claim
+// older than this was left by a request that died while grading, and
+// another request may take the interview over.
+const gradingClaimAge = "-1 hour"
+
+// Interview is a mock interview on a problem. It is open while the
+// candidate talks to the interviewer and graded once the interviewer has
+// submitted its grading, which is stored as the attempt AttemptID. Grading
+// is set while a request is grading it.
+type Interview struct {
+	ID           int    `json:"id"`
+	UserID       int    `json:"user_id"`
+	ProblemID    int    `json:"problem_id"`
+	ProblemSlug  string `json:"problem_slug"`
+	ProblemTitle string `json:"problem_title"`
+	Rubric       string `json:"rubric"`
+	Status       string `json:"status"`
+	Grading      bool   `json:"grading,omitempty"`
+	AttemptID    int    `json:"attempt_id,omitempty"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// InterviewMessage is one turn of an interview. Role is "user" for the
+// candidate and "assistant" for the interviewer.
+type InterviewMessage struct {
+	ID          int    `json:"id"`
+	InterviewID int    `json:"interview_id"`
+	Role        string `json:"role"`
+	Content     string `json:"content"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// CreateInterview stores an open interview with its first messages, and
+// fills in the IDs and CreatedAt of both.
+func (d *DB) CreateInterview(iv *Interview, messages []InterviewMessage) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO interviews (user_id, problem_id, rubric) VALUES (?, ?, ?)
+	`, iv.UserID, iv.ProblemID, iv.Rubric)
+	if err != nil {
+		return fmt.Errorf("insert interview: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("interview id: %w", err)
+	}
+
+	for i := range messages {
+		messages[i].InterviewID = int(id)
+	}
+	if err := addInterviewMessages(tx, messages); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	stored, err := d.GetInterview(int(id))
+	if err != nil {
+		return err
+	}
+	*iv = *stored
+	return nil
+}
+
+// GetInterview returns the interview with the given ID, or nil if there is
+// none.
+func (d *DB) GetInterview(id int) (*Interview, error) {
+	var iv Interview
+	err := d.conn.QueryRow(`
+		SELECT i.id, i.user_id, i.problem_id, p.slug, p.title, i.rubric, i.status,
+		       IFNULL(i.grading_at > datetime('now', ?), 0), IFNULL(i.attempt_id, 0), i.created_at
+		FROM interviews i JOIN problems p ON p.id = i.problem_id
+		WHERE i.id = ?
+	`, gradingClaimAge, id).Scan(&iv.ID, &iv.UserID, &iv.ProblemID, &iv.ProblemSlug, &iv.ProblemTitle, &iv.Rubric,
+		&iv.Status, &iv.Grading, &iv.AttemptID, &iv.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get interview: %w", err)
+	}
+	return &iv, nil
+}
+
+// ListInterviewMessages returns the interview's transcript, oldest first.
+func (d *DB) ListInterviewMessages(interviewID int) ([]InterviewMessage, error) {
+	rows, err := d.conn.Query(`
+		SELECT id, interview_id, role, content, created_at
+		FROM interview_messages
+		WHERE interview_id = ?
+		ORDER BY id
+	`, interviewID)
+	if err != nil {
+		return nil, fmt.Errorf("list interview messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []InterviewMessage{}
+	for rows.Next() {
+		var m InterviewMessage
+		if err := rows.Scan(&m.ID, &m.InterviewID, &m.Role, &m.Content, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan interview message: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// AddInterviewMessages appends messages to an interview in one transaction,
+// filling in their IDs and CreatedAt.
+func (d *DB) AddInterviewMessages(messages []InterviewMessage) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := addInterviewMessages(tx, messages); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ClaimInterview marks an open interview as being graded, so that no other
+// request grades it too. It returns the claim to pass to FinishInterview or
+// ReleaseInterview, or false if the interview is not open or another
+// request is grading it.
+func (d *DB) ClaimInterview(id int) (string, bool, error) {
+	var claim string
+	err := d.conn.QueryRow(`
+		UPDATE interviews SET grading_at = datetime('now')
+		WHERE id = ? AND status = ? AND (grading_at IS NULL OR grading_at <= datetime('now', ?))
+		RETURNING grading_at
+	`, id, InterviewOpen, gradingClaimAge).Scan(&claim)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("claim interview: %w", err)
+	}
+	return claim, true, nil
+}
+
+// ReleaseInterview gives up a claim to grade an interview, leaving it open.
+func (d *DB) ReleaseInterview(id int, claim string) error {
+	if _, err := d.conn.Exec(`
+		UPDATE interviews SET grading_at = NULL WHERE id = ? AND status = ? AND grading_at = ?
+	`, id, InterviewOpen, claim); err != nil {
+		return fmt.Errorf("release interview: %w", err)
+	}
+	return nil
+}
+
+// FinishInterview stores the attempt an interview was graded as, appends
+// the last messages and marks the interview graded, all in one transaction.
+// The attempt claims its owner's pending hints as CreateAttempt's does. It
+// returns false, and stores nothing, if the claim is no longer held.
+func (d *DB) FinishInterview(id int, claim string, a *Attempt, messages []InterviewMessage) (bool, error) {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	attemptID, err := insertAttempt(tx, a)
+	if err != nil {
+		return false, err
+	}
+	res, err := tx.Exec(`
+		UPDATE interviews SET status = ?, attempt_id = ?, grading_at = NULL
+		WHERE id = ? AND status = ? AND grading_at = ?
+	`, InterviewGraded, attemptID, id, InterviewOpen, claim)
+	if err != nil {
+		return false, fmt.Errorf("finish interview: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if err := addInterviewMessages(tx, messages); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit: %w", err)
+	}
+	return true, d.reloadAttempt(a, attemptID)
+}
+
+func addInterviewMessages(tx *sql.Tx, messages []InterviewMessage) error {
+	for i := range messages {
+		m := &messages[i]
+		err := tx.QueryRow(`
+			INSERT INTO interview_messages (interview_id, role, content) VALUES (?, ?, ?)
+			RETURNING id, created_at
+		`, m.InterviewID, m.Role, m.Content).Scan(&m.ID, &m.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("insert interview message: %w", err)
+		}
+	}
+	return nil
+}
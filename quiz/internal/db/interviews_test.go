@@ -0,0 +1,62 @@
+package db
+
+import "testing"
+
+func TestFinishInterview(t *testing.T) {
+	d, problem, user := openTestDB(t)
+	iv := &Interview{UserID: user.ID, ProblemID: problem.ID, Rubric: "default"}
+	if err := d.CreateInterview(iv, []InterviewMessage{{Role: "assistant", Content: "Let's begin."}}); err != nil {
+		t.Fatal(err)
+	}
+
+	claim, claimed, err := d.ClaimInterview(iv.ID)
+	if err != nil || !claimed {
+		t.Fatalf("ClaimInterview = %v, %v", claimed, err)
+	}
+	if _, claimed, err := d.ClaimInterview(iv.ID); err != nil || claimed {
+		t.Fatalf("second ClaimInterview = %v, %v, want false", claimed, err)
+	}
+
+	// A finish without the claim stores nothing.
+	a := &Attempt{UserID: user.ID, ProblemID: problem.ID, Answer: "transcript", Rubric: "default", Score: 3, MaxScore: 4}
+	if finished, err := d.FinishInterview(iv.ID, "1970-01-01 00:00:00", a, nil); err != nil || finished {
+		t.Fatalf("FinishInterview with the wrong claim = %v, %v, want false", finished, err)
+	}
+	if attempts, _ := d.AttemptsOldestFirst(); len(attempts) != 0 {
+		t.Fatalf("%d attempts stored", len(attempts))
+	}
+
+	finished, err := d.FinishInterview(iv.ID, claim, a, []InterviewMessage{{InterviewID: iv.ID, Role: "user", Content: "Done."}})
+	if err != nil || !finished {
+		t.Fatalf("FinishInterview = %v, %v", finished, err)
+	}
+	stored, err := d.GetInterview(iv.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != InterviewGraded || stored.Grading || stored.AttemptID != a.ID || a.ID == 0 {
+		t.Errorf("interview = %+v, want graded with attempt %d", stored, a.ID)
+	}
+	if _, claimed, err := d.ClaimInterview(iv.ID); err != nil || claimed {
+		t.Errorf("ClaimInterview after finishing = %v, %v, want false", claimed, err)
+	}
+}
+
+func TestReleaseInterview(t *testing.T) {
+	d, problem, user := openTestDB(t)
+	iv := &Interview{UserID: user.ID, ProblemID: problem.ID, Rubric: "default"}
+	if err := d.CreateInterview(iv, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	claim, _, err := d.ClaimInterview(iv.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ReleaseInterview(iv.ID, claim); err != nil {
+		t.Fatal(err)
+	}
+	if _, claimed, err := d.ClaimInterview(iv.ID); err != nil || !claimed {
+		t.Errorf("ClaimInterview after release = %v, %v, want true", claimed, err)
+	}
+}
@@ -0,0 +1,59 @@
+package db
+
+import "fmt"
+
+// AttemptMessage is one turn of a follow-up conversation about an attempt.
+// Role is "user" or "assistant".
+type AttemptMessage struct {
+	ID        int    `json:"id"`
+	AttemptID int    `json:"attempt_id"`
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ListAttemptMessages returns the attempt's conversation, oldest first.
+func (d *DB) ListAttemptMessages(attemptID int) ([]AttemptMessage, error) {
+	rows, err := d.conn.Query(`
+		SELECT id, attempt_id, role, content, created_at
+		FROM attempt_messages
+		WHERE attempt_id = ?
+		ORDER BY id
+	`, attemptID)
+	if err != nil {
+		return nil, fmt.Errorf("list attempt messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []AttemptMessage{}
+	for rows.Next() {
+		var m AttemptMessage
+		if err := rows.Scan(&m.ID, &m.AttemptID, &m.Role, &m.Content, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan attempt message: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// AddAttemptMessages appends messages to the attempt's conversation in one
+// transaction, filling in their IDs and CreatedAt.
+func (d *DB) AddAttemptMessages(messages []AttemptMessage) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i := range messages {
+		m := &messages[i]
+		err := tx.QueryRow(`
+			INSERT INTO attempt_messages (attempt_id, role, content) VALUES (?, ?, ?)
+			RETURNING id, created_at
+		`, m.AttemptID, m.Role, m.Content).Scan(&m.ID, &m.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("insert attempt message: %w", err)
+		}
+	}
+	return tx.Commit()
+}
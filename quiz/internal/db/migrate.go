@@ -0,0 +1,500 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// migration is a forward-only schema change. Versions start at 1 and must be
+// contiguous; never edit a migration once it has shipped, add a new one.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+var migrations = []migration{
+	{1, "problems", `
+CREATE TABLE IF NOT EXISTS problems (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    source          TEXT NOT NULL DEFAULT 'leetcode',
+    source_id       TEXT NOT NULL,
+    slug            TEXT NOT NULL,
+    title           TEXT NOT NULL,
+    difficulty      TEXT NOT NULL CHECK(difficulty IN ('Easy','Medium','Hard')),
+    description     TEXT NOT NULL DEFAULT '',
+    examples        TEXT NOT NULL DEFAULT '[]',
+    constraints     TEXT NOT NULL DEFAULT '[]',
+    hints           TEXT NOT NULL DEFAULT '[]',
+    python3_snippet TEXT NOT NULL DEFAULT '',
+    created_at      TEXT NOT NULL DEFAULT (datetime('now')),
+    updated_at      TEXT NOT NULL DEFAULT (datetime('now')),
+    UNIQUE(source, slug)
+);
+
+CREATE TABLE IF NOT EXISTS topics (
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS problem_topics (
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    topic_id   INTEGER NOT NULL REFERENCES topics(id) ON DELETE CASCADE,
+    PRIMARY KEY (problem_id, topic_id)
+);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS problems_fts USING fts5(
+    title,
+    content=problems,
+    content_rowid=id
+);
+
+CREATE TRIGGER IF NOT EXISTS problems_ai AFTER INSERT ON problems BEGIN
+    INSERT INTO problems_fts(rowid, title) VALUES (new.id, new.title);
+END;
+
+CREATE TRIGGER IF NOT EXISTS problems_ad AFTER DELETE ON problems BEGIN
+    INSERT INTO problems_fts(problems_fts, rowid, title) VALUES ('delete', old.id, old.title);
+END;
+
+CREATE TRIGGER IF NOT EXISTS problems_au AFTER UPDATE ON problems BEGIN
+    INSERT INTO problems_fts(problems_fts, rowid, title) VALUES ('delete', old.id, old.title);
+    INSERT INTO problems_fts(rowid, title) VALUES (new.id, new.title);
+END;
+`},
+	{2, "attempts", `
+CREATE TABLE IF NOT EXISTS attempts (
+    id                          INTEGER PRIMARY KEY AUTOINCREMENT,
+    problem_id                  INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    answer                      TEXT NOT NULL,
+    pattern_identified_score    INTEGER NOT NULL,
+    pattern_identified_comment  TEXT NOT NULL DEFAULT '',
+    solution_works_score        INTEGER NOT NULL,
+    solution_works_comment      TEXT NOT NULL DEFAULT '',
+    complexity_analysis_score   INTEGER NOT NULL,
+    complexity_analysis_comment TEXT NOT NULL DEFAULT '',
+    optimal_solution_score      INTEGER NOT NULL,
+    optimal_solution_comment    TEXT NOT NULL DEFAULT '',
+    overall_feedback            TEXT NOT NULL DEFAULT '',
+    model                       TEXT NOT NULL DEFAULT '',
+    created_at                  TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX IF NOT EXISTS attempts_problem_id ON attempts(problem_id, created_at);
+`},
+	{3, "reviews", `
+CREATE TABLE reviews (
+    problem_id      INTEGER PRIMARY KEY REFERENCES problems(id) ON DELETE CASCADE,
+    ease            REAL NOT NULL DEFAULT 2.5,
+    interval_days   INTEGER NOT NULL DEFAULT 0,
+    repetitions     INTEGER NOT NULL DEFAULT 0,
+    due_at          TEXT NOT NULL,
+    last_attempt_id INTEGER REFERENCES attempts(id) ON DELETE SET NULL,
+    last_score      INTEGER NOT NULL DEFAULT 0,
+    updated_at      TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX reviews_due_at ON reviews(due_at);
+`},
+	{4, "rubrics", `
+-- Criteria move out of fixed columns so that any rubric can be stored.
+-- Labels and weights are copied per attempt so history survives rubric edits.
+CREATE TABLE attempt_criteria (
+    attempt_id INTEGER NOT NULL REFERENCES attempts(id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    key        TEXT NOT NULL,
+    label      TEXT NOT NULL,
+    points     INTEGER NOT NULL,
+    max_points INTEGER NOT NULL,
+    weight     REAL NOT NULL,
+    comment    TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (attempt_id, position)
+);
+
+ALTER TABLE attempts ADD COLUMN rubric TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE attempts ADD COLUMN score REAL NOT NULL DEFAULT 0;
+ALTER TABLE attempts ADD COLUMN max_score REAL NOT NULL DEFAULT 0;
+
+INSERT INTO attempt_criteria (attempt_id, position, key, label, points, max_points, weight, comment)
+SELECT id, 0, 'pattern_identified', 'Pattern Identified', pattern_identified_score, 1, 1, pattern_identified_comment FROM attempts;
+INSERT INTO attempt_criteria (attempt_id, position, key, label, points, max_points, weight, comment)
+SELECT id, 1, 'solution_works', 'Solution Works', solution_works_score, 1, 1, solution_works_comment FROM attempts;
+INSERT INTO attempt_criteria (attempt_id, position, key, label, points, max_points, weight, comment)
+SELECT id, 2, 'complexity_analysis', 'Complexity Analysis', complexity_analysis_score, 1, 1, complexity_analysis_comment FROM attempts;
+INSERT INTO attempt_criteria (attempt_id, position, key, label, points, max_points, weight, comment)
+SELECT id, 3, 'optimal_solution', 'Optimal Solution', optimal_solution_score, 1, 1, optimal_solution_comment FROM attempts;
+
+UPDATE attempts SET
+    score = pattern_identified_score + solution_works_score +
+            complexity_analysis_score + optimal_solution_score,
+    max_score = 4;
+
+ALTER TABLE attempts DROP COLUMN pattern_identified_score;
+ALTER TABLE attempts DROP COLUMN pattern_identified_comment;
+ALTER TABLE attempts DROP COLUMN solution_works_score;
+ALTER TABLE attempts DROP COLUMN solution_works_comment;
+ALTER TABLE attempts DROP COLUMN complexity_analysis_score;
+ALTER TABLE attempts DROP COLUMN complexity_analysis_comment;
+ALTER TABLE attempts DROP COLUMN optimal_solution_score;
+ALTER TABLE attempts DROP COLUMN optimal_solution_comment;
+
+-- last_score becomes the fraction of the rubric's maximum score.
+CREATE TABLE reviews_new (
+    problem_id      INTEGER PRIMARY KEY REFERENCES problems(id) ON DELETE CASCADE,
+    ease            REAL NOT NULL DEFAULT 2.5,
+    interval_days   INTEGER NOT NULL DEFAULT 0,
+    repetitions     INTEGER NOT NULL DEFAULT 0,
+    due_at          TEXT NOT NULL,
+    last_attempt_id INTEGER REFERENCES attempts(id) ON DELETE SET NULL,
+    last_score      REAL NOT NULL DEFAULT 0,
+    updated_at      TEXT NOT NULL DEFAULT (datetime('now'))
+);
+INSERT INTO reviews_new
+SELECT problem_id, ease, interval_days, repetitions, due_at, last_attempt_id, last_score / 4.0, updated_at
+FROM reviews;
+DROP TABLE reviews;
+ALTER TABLE reviews_new RENAME TO reviews;
+CREATE INDEX reviews_due_at ON reviews(due_at);
+`},
+	{5, "users", `
+CREATE TABLE users (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    username      TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    password_hash TEXT NOT NULL,
+    created_at    TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+-- Only a hash of each session token is stored.
+CREATE TABLE sessions (
+    token_hash TEXT PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX sessions_user_id ON sessions(user_id);
+
+-- Attempts made before accounts existed have no owner until a user claims
+-- them with "quiz user add --claim".
+ALTER TABLE attempts ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX attempts_user_id ON attempts(user_id, created_at);
+
+-- Schedules become per user. They are derived from attempts, so they are
+-- rebuilt when attempts are claimed rather than migrated.
+DROP TABLE reviews;
+
+CREATE TABLE reviews (
+    user_id         INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    problem_id      INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    ease            REAL NOT NULL DEFAULT 2.5,
+    interval_days   INTEGER NOT NULL DEFAULT 0,
+    repetitions     INTEGER NOT NULL DEFAULT 0,
+    due_at          TEXT NOT NULL,
+    last_attempt_id INTEGER REFERENCES attempts(id) ON DELETE SET NULL,
+    last_score      REAL NOT NULL DEFAULT 0,
+    updated_at      TEXT NOT NULL DEFAULT (datetime('now')),
+    PRIMARY KEY (user_id, problem_id)
+);
+
+CREATE INDEX reviews_due_at ON reviews(user_id, due_at);
+`},
+	{6, "api_tokens", `
+-- Personal access tokens. Only a hash of each token is stored; prefix is
+-- its first few characters so that users can tell tokens apart.
+CREATE TABLE api_tokens (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id      INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    token_hash   TEXT NOT NULL UNIQUE,
+    prefix       TEXT NOT NULL,
+    scopes       TEXT NOT NULL,
+    expires_at   TEXT,
+    last_used_at TEXT,
+    created_at   TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX api_tokens_user_id ON api_tokens(user_id);
+`},
+	{7, "llm_usage", `
+-- One row per LLM completion, for budgets. Rows outlive their user so that
+-- global spend is not reset by deleting accounts.
+CREATE TABLE llm_usage (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id           INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    model             TEXT NOT NULL,
+    prompt_tokens     INTEGER NOT NULL,
+    completion_tokens INTEGER NOT NULL,
+    cost_usd          REAL NOT NULL,
+    created_at        TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX llm_usage_user_id ON llm_usage(user_id, created_at);
+CREATE INDEX llm_usage_created_at ON llm_usage(created_at);
+`},
+	{8, "grading_cache", `
+-- Grading results by content key: a hash of the problem, the normalized
+-- answer, the model and the prompt version. result is GradingResult JSON.
+CREATE TABLE grading_cache (
+    key        TEXT PRIMARY KEY,
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    model      TEXT NOT NULL,
+    result     TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX grading_cache_problem_id ON grading_cache(problem_id);
+`},
+	{9, "attempt_messages", `
+-- Follow-up conversation with the grader about an attempt.
+CREATE TABLE attempt_messages (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    attempt_id INTEGER NOT NULL REFERENCES attempts(id) ON DELETE CASCADE,
+    role       TEXT NOT NULL CHECK (role IN ('user', 'assistant')),
+    content    TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX attempt_messages_attempt_id ON attempt_messages(attempt_id, id);
+`},
+	{10, "interviews", `
+-- Mock interviews: a conversation with an interviewer that ends in a
+-- graded attempt.
+CREATE TABLE interviews (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    rubric     TEXT NOT NULL,
+    status     TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'graded')),
+    attempt_id INTEGER REFERENCES attempts(id) ON DELETE SET NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX interviews_user_id ON interviews(user_id, created_at);
+
+CREATE TABLE interview_messages (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    interview_id INTEGER NOT NULL REFERENCES interviews(id) ON DELETE CASCADE,
+    role         TEXT NOT NULL CHECK (role IN ('user', 'assistant')),
+    content      TEXT NOT NULL,
+    created_at   TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX interview_messages_interview_id ON interview_messages(interview_id, id);
+`},
+	{11, "hints", `
+-- Hints given to a user on a problem. They are pending until the user's
+-- next attempt on the problem claims them; each costs that attempt penalty,
+-- as a fraction of its maximum score.
+CREATE TABLE hints (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    attempt_id INTEGER REFERENCES attempts(id) ON DELETE CASCADE,
+    level      INTEGER NOT NULL,
+    source     TEXT NOT NULL CHECK (source IN ('stored', 'llm')),
+    kind       TEXT NOT NULL DEFAULT '',
+    content    TEXT NOT NULL,
+    penalty    REAL NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX hints_user_problem ON hints(user_id, problem_id, attempt_id);
+
+ALTER TABLE attempts ADD COLUMN hints_used INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE attempts ADD COLUMN hint_penalty REAL NOT NULL DEFAULT 0;
+`},
+	{12, "test_generators", `
+-- Generated test inputs for a problem: Python source for an input
+-- generator and a brute-force reference solution, written by the LLM once
+-- and used to check solutions against many inputs.
+CREATE TABLE test_generators (
+    problem_id INTEGER PRIMARY KEY REFERENCES problems(id) ON DELETE CASCADE,
+    generator  TEXT NOT NULL,
+    reference  TEXT NOT NULL,
+    model      TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+`},
+	{13, "attempt_evidence", `
+-- Measurements from running the code submitted with an answer, as given
+-- to the grader.
+ALTER TABLE attempts ADD COLUMN evidence TEXT NOT NULL DEFAULT '';
+`},
+	{14, "problem_search", `
+-- Search covers the whole problem, not just its title. The index keeps its
+-- own copy of the text, since constraints and hints are stored as JSON
+-- arrays and topics live in another table.
+DROP TRIGGER IF EXISTS problems_ai;
+DROP TRIGGER IF EXISTS problems_ad;
+DROP TRIGGER IF EXISTS problems_au;
+DROP TABLE IF EXISTS problems_fts;
+
+CREATE VIRTUAL TABLE problems_fts USING fts5(
+    title,
+    description,
+    constraints,
+    hints,
+    topics,
+    tokenize = 'porter unicode61 remove_diacritics 2'
+);
+
+INSERT INTO problems_fts(rowid, title, description, constraints, hints, topics)
+SELECT p.id, p.title, p.description,
+       (SELECT coalesce(group_concat(value, ' '), '') FROM json_each(p.constraints)),
+       (SELECT coalesce(group_concat(value, ' '), '') FROM json_each(p.hints)),
+       (SELECT coalesce(group_concat(t.name, ' '), '') FROM problem_topics pt
+        JOIN topics t ON t.id = pt.topic_id WHERE pt.problem_id = p.id)
+FROM problems p;
+
+CREATE TRIGGER problems_ai AFTER INSERT ON problems BEGIN
+    INSERT INTO problems_fts(rowid, title, description, constraints, hints, topics)
+    VALUES (new.id, new.title, new.description,
+            (SELECT coalesce(group_concat(value, ' '), '') FROM json_each(new.constraints)),
+            (SELECT coalesce(group_concat(value, ' '), '') FROM json_each(new.hints)),
+            '');
+END;
+
+CREATE TRIGGER problems_ad AFTER DELETE ON problems BEGIN
+    DELETE FROM problems_fts WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER problems_au AFTER UPDATE ON problems BEGIN
+    UPDATE problems_fts SET
+        title = new.title,
+        description = new.description,
+        constraints = (SELECT coalesce(group_concat(value, ' '), '') FROM json_each(new.constraints)),
+        hints = (SELECT coalesce(group_concat(value, ' '), '') FROM json_each(new.hints))
+    WHERE rowid = new.id;
+END;
+
+CREATE TRIGGER problem_topics_ai AFTER INSERT ON problem_topics BEGIN
+    UPDATE problems_fts SET topics = (
+        SELECT coalesce(group_concat(t.name, ' '), '') FROM problem_topics pt
+        JOIN topics t ON t.id = pt.topic_id WHERE pt.problem_id = new.problem_id
+    ) WHERE rowid = new.problem_id;
+END;
+
+CREATE TRIGGER problem_topics_ad AFTER DELETE ON problem_topics BEGIN
+    UPDATE problems_fts SET topics = (
+        SELECT coalesce(group_concat(t.name, ' '), '') FROM problem_topics pt
+        JOIN topics t ON t.id = pt.topic_id WHERE pt.problem_id = old.problem_id
+    ) WHERE rowid = old.problem_id;
+END;
+`},
+	{15, "problem_embeddings", `
+-- Vectors of problem texts for finding similar problems, one per problem
+-- and embedding model, stored as little-endian float32s. A problem's
+-- vectors are dropped when its text changes, to be computed again.
+CREATE TABLE problem_embeddings (
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    model      TEXT NOT NULL,
+    vector     BLOB NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    PRIMARY KEY (problem_id, model)
+);
+
+CREATE TRIGGER problems_embeddings_au AFTER UPDATE OF title, description ON problems
+WHEN new.title IS NOT old.title OR new.description IS NOT old.description BEGIN
+    DELETE FROM problem_embeddings WHERE problem_id = new.id;
+END;
+`},
+	{16, "interview_grading", `
+-- When a request started grading an open interview. Only that request may
+-- finish it; the claim is cleared if grading fails.
+ALTER TABLE interviews ADD COLUMN grading_at TEXT;
+`},
+	{17, "hint_levels", `
+-- Each hint level is given once per user, problem and attempt, pending
+-- hints included, whose attempt_id is NULL. Duplicates stored by concurrent
+-- requests are dropped, keeping the first.
+DELETE FROM hints WHERE id NOT IN (
+    SELECT MIN(id) FROM hints GROUP BY user_id, problem_id, IFNULL(attempt_id, 0), level
+);
+
+CREATE UNIQUE INDEX hints_level ON hints(user_id, problem_id, IFNULL(attempt_id, 0), level);
+`},
+}
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer
+// build than this one.
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
+
+// LatestVersion is the schema version this build migrates to.
+func LatestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion returns the version recorded in the database, or 0 if no
+// migrations have been applied.
+func (d *DB) SchemaVersion() (int, error) {
+	var exists int
+	if err := d.conn.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'",
+	).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("check schema_version: %w", err)
+	}
+	if exists == 0 {
+		return 0, nil
+	}
+
+	var version sql.NullInt64
+	if err := d.conn.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// Migrate applies any pending migrations, each in its own transaction.
+// It returns the versions that were applied.
+func (d *DB) Migrate() ([]int, error) {
+	if _, err := d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+		    version    INTEGER PRIMARY KEY,
+		    name       TEXT NOT NULL,
+		    applied_at TEXT NOT NULL DEFAULT (datetime('now'))
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("create schema_version: %w", err)
+	}
+
+	current, err := d.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if current > LatestVersion() {
+		return nil, fmt.Errorf("%w (database at %d, binary at %d)", ErrSchemaTooNew, current, LatestVersion())
+	}
+
+	var applied []int
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := d.applyMigration(m); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m.version)
+	}
+	return applied, nil
+}
+
+func (d *DB) applyMigration(m migration) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("migration %d (%s): begin: %w", m.version, m.name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+	}
+	if _, err := tx.Exec("INSERT INTO schema_version (version, name) VALUES (?, ?)", m.version, m.name); err != nil {
+		return fmt.Errorf("migration %d (%s): record version: %w", m.version, m.name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %d (%s): commit: %w", m.version, m.name, err)
+	}
+	return nil
+}
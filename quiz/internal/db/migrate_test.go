@@ -0,0 +1,118 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildDBSchema returns the schema scripts/build_db.py creates, which has no
+// schema_version table.
+func buildDBSchema(t *testing.T) string {
+	t.Helper()
+	script, err := os.ReadFile(filepath.Join("..", "..", "scripts", "build_db.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, schema, ok := strings.Cut(string(script), `SCHEMA = """`)
+	if ok {
+		schema, _, ok = strings.Cut(schema, `"""`)
+	}
+	if !ok {
+		t.Fatal("no SCHEMA in build_db.py")
+	}
+	return schema
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name string
+		// buildDB starts from a database made by build_db.py, with a problem.
+		buildDB bool
+		// from is the version the database starts at.
+		from int
+	}{
+		{name: "empty"},
+		{name: "from build_db.py", buildDB: true},
+		{name: "part way", from: 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := OpenUnmigrated(filepath.Join(t.TempDir(), "quiz.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			if tt.buildDB {
+				if _, err := d.conn.Exec(buildDBSchema(t)); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := d.conn.Exec(`
+					INSERT INTO problems (source_id, slug, title, difficulty) VALUES ('1', 'two-sum', 'Two Sum', 'Easy')
+				`); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.from > 0 {
+				saved := migrations
+				migrations = migrations[:tt.from]
+				_, err := d.Migrate()
+				migrations = saved
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if v, err := d.SchemaVersion(); err != nil || v != tt.from {
+				t.Fatalf("SchemaVersion = %d, %v before migrating; want %d", v, err, tt.from)
+			}
+			applied, err := d.Migrate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(applied) != LatestVersion()-tt.from || applied[0] != tt.from+1 {
+				t.Errorf("applied %v, want %d through %d", applied, tt.from+1, LatestVersion())
+			}
+			if v, err := d.SchemaVersion(); err != nil || v != LatestVersion() {
+				t.Errorf("SchemaVersion = %d, %v; want %d", v, err, LatestVersion())
+			}
+			if applied, err := d.Migrate(); err != nil || len(applied) != 0 {
+				t.Errorf("migrating again applied %v, %v", applied, err)
+			}
+
+			if tt.buildDB {
+				problems, total, err := d.ListProblems(ListParams{Query: "two", Limit: 10})
+				if err != nil || total != 1 || problems[0].Slug != "two-sum" {
+					t.Errorf("search after migrating = %v, %d, %v; want two-sum", problems, total, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrationVersions(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("migration %q has version %d, want %d", m.name, m.version, i+1)
+		}
+	}
+}
+
+func TestMigrateTooNew(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if _, err := d.conn.Exec("INSERT INTO schema_version (version, name) VALUES (?, 'future')", LatestVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Migrate(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Migrate = %v, want ErrSchemaTooNew", err)
+	}
+}
@@ -32,14 +32,39 @@ type ProblemSummary struct {
 	Title      string   `json:"title"`
 	Difficulty string   `json:"difficulty"`
 	Topics     []string `json:"topics"`
+	// Snippet is set on text search results: an excerpt of the
+	// description, hints or constraints around the matching words, as
+	// escaped HTML with the matches in <mark> elements.
+	Snippet string `json:"snippet,omitempty"`
 }
 
 type DB struct {
 	conn *sql.DB
 }
 
+// Open opens the database and applies any pending migrations. It refuses to
+// open a database whose schema is newer than this binary.
 func Open(path string) (*DB, error) {
-	conn, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=foreign_keys(ON)")
+	d, err := OpenUnmigrated(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Migrate(); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// OpenUnmigrated opens the database without touching its schema.
+//
+// Requests write concurrently, so a writer waits up to busy_timeout for
+// the lock instead of failing with SQLITE_BUSY. Transactions take the write
+// lock when they begin, since a transaction that reads first cannot wait
+// for it once another writer holds it.
+func OpenUnmigrated(path string) (*DB, error) {
+	conn, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=foreign_keys(ON)"+
+		"&_pragma=busy_timeout(5000)&_txlock=immediate")
 	if err != nil {
 		return nil, fmt.Errorf("open db: %w", err)
 	}
@@ -57,15 +82,15 @@ type ListParams struct {
 	Query      string
 	Difficulty string
 	Topic      string
-	Limit      int
-	Offset     int
+	// Sort is SortNumber or SortRelevance; empty means SortNumber.
+	Sort   string
+	Limit  int
+	Offset int
 }
 
-func (d *DB) ListProblems(params ListParams) ([]ProblemSummary, int, error) {
-	if params.Limit <= 0 {
-		params.Limit = 50
-	}
-
+// filters returns the WHERE conditions and arguments for the query,
+// difficulty and topic filters, against problems aliased as p.
+func (params ListParams) filters() ([]string, []any) {
 	var where []string
 	var args []any
 
@@ -74,10 +99,12 @@ func (d *DB) ListProblems(params ListParams) ([]ProblemSummary, int, error) {
 		idStr := strings.TrimPrefix(params.Query, "#")
 		where = append(where, "p.source_id = ?")
 		args = append(args, idStr)
-	} else if params.Query != "" {
+	} else if q := ftsQuery(params.Query); q != "" {
 		where = append(where, "p.id IN (SELECT rowid FROM problems_fts WHERE problems_fts MATCH ?)")
-		// FTS5 prefix search
-		args = append(args, params.Query+"*")
+		args = append(args, q)
+	} else if strings.TrimSpace(params.Query) != "" {
+		// Nothing searchable was typed, e.g. only punctuation.
+		where = append(where, "0")
 	}
 
 	if params.Difficulty != "" {
@@ -90,6 +117,41 @@ func (d *DB) ListProblems(params ListParams) ([]ProblemSummary, int, error) {
 		args = append(args, params.Topic)
 	}
 
+	return where, args
+}
+
+func (d *DB) ListProblems(params ListParams) ([]ProblemSummary, int, error) {
+	if params.Limit <= 0 {
+		params.Limit = 50
+	}
+
+	// A text search joins the index, for ranking and snippets, rather than
+	// filtering on it.
+	from, columns, order := "problems p", "", "CAST(p.source_id AS INTEGER)"
+	search := ""
+	if !strings.HasPrefix(params.Query, "#") {
+		search = ftsQuery(params.Query)
+	}
+	var where []string
+	var args []any
+	if search != "" {
+		rest := params
+		rest.Query = ""
+		where, args = rest.filters()
+		where = append([]string{"problems_fts MATCH ?"}, where...)
+		args = append([]any{search}, args...)
+
+		from = "problems_fts JOIN problems p ON p.id = problems_fts.rowid"
+		for _, col := range snippetColumns {
+			columns += fmt.Sprintf(", snippet(problems_fts, %d, '%s', '%s', '…', 16)", col, snippetStart, snippetEnd)
+		}
+		if params.Sort == SortRelevance {
+			order = searchRank + ", " + order
+		}
+	} else {
+		where, args = params.filters()
+	}
+
 	whereClause := ""
 	if len(where) > 0 {
 		whereClause = "WHERE " + strings.Join(where, " AND ")
@@ -97,18 +159,18 @@ func (d *DB) ListProblems(params ListParams) ([]ProblemSummary, int, error) {
 
 	// Count total
 	var total int
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM problems p %s", whereClause)
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s %s", from, whereClause)
 	if err := d.conn.QueryRow(countQuery, args...).Scan(&total); err != nil {
 		return nil, 0, fmt.Errorf("count problems: %w", err)
 	}
 
 	// Fetch page
 	query := fmt.Sprintf(`
-		SELECT p.id, p.source_id, p.slug, p.title, p.difficulty
-		FROM problems p %s
-		ORDER BY CAST(p.source_id AS INTEGER)
+		SELECT p.id, p.source_id, p.slug, p.title, p.difficulty%s
+		FROM %s %s
+		ORDER BY %s
 		LIMIT ? OFFSET ?
-	`, whereClause)
+	`, columns, from, whereClause, order)
 
 	pageArgs := append(args, params.Limit, params.Offset)
 	rows, err := d.conn.Query(query, pageArgs...)
@@ -117,12 +179,24 @@ func (d *DB) ListProblems(params ListParams) ([]ProblemSummary, int, error) {
 	}
 	defer rows.Close()
 
-	var problems []ProblemSummary
+	problems := []ProblemSummary{}
+	snippets := make([]string, len(snippetColumns))
 	for rows.Next() {
 		var p ProblemSummary
-		if err := rows.Scan(&p.ID, &p.SourceID, &p.Slug, &p.Title, &p.Difficulty); err != nil {
+		dest := []any{&p.ID, &p.SourceID, &p.Slug, &p.Title, &p.Difficulty}
+		if search != "" {
+			for i := range snippets {
+				dest = append(dest, &snippets[i])
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
 			return nil, 0, fmt.Errorf("scan problem: %w", err)
 		}
+		for _, s := range snippets {
+			if p.Snippet = formatSnippet(s); p.Snippet != "" {
+				break
+			}
+		}
 		problems = append(problems, p)
 	}
 
@@ -187,7 +261,16 @@ func (d *DB) GetProblem(id int) (*Problem, error) {
 	json.Unmarshal([]byte(constraintsJSON), &p.Constraints)
 	json.Unmarshal([]byte(hintsJSON), &p.Hints)
 
-	// Fetch topics
+	p.Topics, err = d.problemTopics(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// problemTopics returns the topic names for a single problem.
+func (d *DB) problemTopics(id int) ([]string, error) {
 	rows, err := d.conn.Query(`
 		SELECT t.name FROM topics t
 		JOIN problem_topics pt ON pt.topic_id = t.id
@@ -198,18 +281,38 @@ func (d *DB) GetProblem(id int) (*Problem, error) {
 	}
 	defer rows.Close()
 
+	topics := []string{}
 	for rows.Next() {
 		var name string
 		if err := rows.Scan(&name); err != nil {
 			return nil, fmt.Errorf("scan topic: %w", err)
 		}
-		p.Topics = append(p.Topics, name)
-	}
-	if p.Topics == nil {
-		p.Topics = []string{}
+		topics = append(topics, name)
 	}
+	return topics, nil
+}
 
-	return &p, nil
+// ProblemSummaries returns summaries of the problems with the given IDs,
+// in the same order, skipping any that no longer exist.
+func (d *DB) ProblemSummaries(ids []int) ([]ProblemSummary, error) {
+	summaries := []ProblemSummary{}
+	for _, id := range ids {
+		var p ProblemSummary
+		err := d.conn.QueryRow(`
+			SELECT id, source_id, slug, title, difficulty FROM problems WHERE id = ?
+		`, id).Scan(&p.ID, &p.SourceID, &p.Slug, &p.Title, &p.Difficulty)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get problem summary: %w", err)
+		}
+		if p.Topics, err = d.problemTopics(id); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, p)
+	}
+	return summaries, nil
 }
 
 // GetProblemBySlug fetches a problem by its slug.
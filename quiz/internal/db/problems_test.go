@@ -0,0 +1,75 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestDB returns a migrated database in a temporary file with one
+// problem and one user.
+func openTestDB(t *testing.T) (*DB, *Problem, *User) {
+	t.Helper()
+	d, err := Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	if _, err := d.ImportProblems([]ImportQuestion{{
+		Title:       "Two Sum",
+		FrontendID:  json.RawMessage(`"1"`),
+		ProblemSlug: "two-sum",
+		Description: "Return the indices of the two numbers that add up to target.",
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	problem, err := d.GetProblemBySlug("two-sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := d.CreateUser("alice", "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, problem, user
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	d, problem, user := openTestDB(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 400)
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 25 {
+				a := &Attempt{UserID: user.ID, ProblemID: problem.ID, Answer: "answer", Rubric: "default", Score: 1, MaxScore: 4}
+				if err := d.CreateAttempt(a); err != nil {
+					errs <- err
+				}
+				token := fmt.Sprintf("%d-%d", g, i)
+				if err := d.CreateSession(token, user.ID, time.Now().Add(time.Hour)); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	attempts, err := d.AttemptsOldestFirst()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 200 {
+		t.Errorf("%d attempts stored, want 200", len(attempts))
+	}
+}
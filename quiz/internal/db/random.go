@@ -0,0 +1,163 @@
+package db
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// RandomParams selects a random problem. The embedded ListParams filters
+// apply; Limit and Offset are ignored.
+type RandomParams struct {
+	ListParams
+	// UserID scopes SkipRecentDays and Weighted to one user's attempts.
+	UserID int
+	// SkipRecentDays excludes problems attempted within this many days.
+	SkipRecentDays int
+	// Weighted favours problems whose topics have weak past scores.
+	Weighted bool
+}
+
+// RandomProblem picks a problem matching params, or returns nil if none do.
+func (d *DB) RandomProblem(params RandomParams) (*ProblemSummary, error) {
+	where, args := params.filters()
+
+	if params.SkipRecentDays > 0 {
+		since := time.Now().UTC().AddDate(0, 0, -params.SkipRecentDays).Format(TimeFormat)
+		where = append(where, "p.id NOT IN (SELECT problem_id FROM attempts WHERE user_id = ? AND created_at >= ?)")
+		args = append(args, params.UserID, since)
+	}
+
+	whereClause := ""
+	if len(where) > 0 {
+		whereClause = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := d.conn.Query(fmt.Sprintf(`
+		SELECT p.id, p.source_id, p.slug, p.title, p.difficulty
+		FROM problems p %s
+	`, whereClause), args...)
+	if err != nil {
+		return nil, fmt.Errorf("random candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []ProblemSummary
+	for rows.Next() {
+		var p ProblemSummary
+		if err := rows.Scan(&p.ID, &p.SourceID, &p.Slug, &p.Title, &p.Difficulty); err != nil {
+			return nil, fmt.Errorf("scan problem: %w", err)
+		}
+		candidates = append(candidates, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("random candidates: %w", err)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	var pick ProblemSummary
+	if params.Weighted {
+		pick, err = d.weightedPick(params.UserID, candidates)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		pick = candidates[rand.IntN(len(candidates))]
+	}
+
+	pick.Topics, err = d.problemTopics(pick.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &pick, nil
+}
+
+// weightedPick chooses a candidate with weight 1 + 3×(weakness of its
+// weakest topic), where a topic's weakness is 1 minus its mean attempt score
+// as a fraction for the user. Topics with no attempts have no weakness.
+func (d *DB) weightedPick(userID int, candidates []ProblemSummary) (ProblemSummary, error) {
+	weakness, err := d.topicWeakness(userID)
+	if err != nil {
+		return ProblemSummary{}, err
+	}
+
+	problemTopics, err := d.topicsByProblem()
+	if err != nil {
+		return ProblemSummary{}, err
+	}
+
+	weights := make([]float64, len(candidates))
+	var total float64
+	for i, c := range candidates {
+		worst := 0.0
+		for _, t := range problemTopics[c.ID] {
+			worst = max(worst, weakness[t])
+		}
+		weights[i] = 1 + 3*worst
+		total += weights[i]
+	}
+
+	r := rand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return candidates[i], nil
+		}
+		r -= w
+	}
+	return candidates[len(candidates)-1], nil
+}
+
+// topicWeakness returns 1 minus the user's mean attempt score fraction per
+// topic.
+func (d *DB) topicWeakness(userID int) (map[string]float64, error) {
+	rows, err := d.conn.Query(`
+		SELECT t.name, AVG(a.score / a.max_score)
+		FROM attempts a
+		JOIN problem_topics pt ON pt.problem_id = a.problem_id
+		JOIN topics t ON t.id = pt.topic_id
+		WHERE a.user_id = ? AND a.max_score > 0
+		GROUP BY t.name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("topic scores: %w", err)
+	}
+	defer rows.Close()
+
+	weakness := make(map[string]float64)
+	for rows.Next() {
+		var name string
+		var avg float64
+		if err := rows.Scan(&name, &avg); err != nil {
+			return nil, fmt.Errorf("scan topic score: %w", err)
+		}
+		weakness[name] = 1 - avg
+	}
+	return weakness, rows.Err()
+}
+
+// topicsByProblem returns every problem's topic names keyed by problem ID.
+func (d *DB) topicsByProblem() (map[int][]string, error) {
+	rows, err := d.conn.Query(`
+		SELECT pt.problem_id, t.name
+		FROM problem_topics pt
+		JOIN topics t ON t.id = pt.topic_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("fetch topics: %w", err)
+	}
+	defer rows.Close()
+
+	topics := make(map[int][]string)
+	for rows.Next() {
+		var pid int
+		var name string
+		if err := rows.Scan(&pid, &name); err != nil {
+			return nil, fmt.Errorf("scan topic: %w", err)
+		}
+		topics[pid] = append(topics[pid], name)
+	}
+	return topics, rows.Err()
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+// openRandomTestDB returns a database with an easy array problem, a medium
+// graph problem and a hard graph problem, and two users.
+func openRandomTestDB(t *testing.T) (*DB, map[string]int, *User, *User) {
+	t.Helper()
+	d, err := Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	if _, err := d.ImportProblems([]ImportQuestion{
+		{Title: "Two Sum", FrontendID: json.RawMessage(`"1"`), ProblemSlug: "two-sum", Difficulty: "Easy", Topics: []string{"Array"}},
+		{Title: "Course Schedule", FrontendID: json.RawMessage(`"207"`), ProblemSlug: "course-schedule", Difficulty: "Medium", Topics: []string{"Graph"}},
+		{Title: "Alien Dictionary", FrontendID: json.RawMessage(`"269"`), ProblemSlug: "alien-dictionary", Difficulty: "Hard", Topics: []string{"Graph"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]int)
+	for _, slug := range []string{"two-sum", "course-schedule", "alien-dictionary"} {
+		p, err := d.GetProblemBySlug(slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[slug] = p.ID
+	}
+
+	alice, err := d.CreateUser("alice", "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := d.CreateUser("bob", "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, ids, alice, bob
+}
+
+func TestRandomProblem(t *testing.T) {
+	d, ids, alice, bob := openRandomTestDB(t)
+	for _, a := range []*Attempt{
+		{UserID: alice.ID, ProblemID: ids["course-schedule"], Answer: "a", Score: 10, MaxScore: 10},
+		{UserID: bob.ID, ProblemID: ids["two-sum"], Answer: "a", Score: 10, MaxScore: 10},
+	} {
+		if err := d.CreateAttempt(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		params RandomParams
+		// want are the problems that may be picked; none means no pick.
+		want []string
+	}{
+		{"any", RandomParams{}, []string{"two-sum", "course-schedule", "alien-dictionary"}},
+		{"difficulty", RandomParams{ListParams: ListParams{Difficulty: "Hard"}}, []string{"alien-dictionary"}},
+		{"topic", RandomParams{ListParams: ListParams{Topic: "Graph"}}, []string{"course-schedule", "alien-dictionary"}},
+		{"no match", RandomParams{ListParams: ListParams{Difficulty: "Easy", Topic: "Graph"}}, nil},
+		{"skip recent", RandomParams{ListParams: ListParams{Topic: "Graph"}, UserID: alice.ID, SkipRecentDays: 7}, []string{"alien-dictionary"}},
+		{"skip another user's recent", RandomParams{ListParams: ListParams{Topic: "Graph"}, UserID: bob.ID, SkipRecentDays: 7}, []string{"course-schedule", "alien-dictionary"}},
+		{"skip everything", RandomParams{ListParams: ListParams{Difficulty: "Medium"}, UserID: alice.ID, SkipRecentDays: 1}, nil},
+	}
+
+	for _, tt := range tests {
+		allowed := make(map[string]bool)
+		for _, slug := range tt.want {
+			allowed[slug] = true
+		}
+		seen := make(map[string]bool)
+		for range 50 {
+			p, err := d.RandomProblem(tt.params)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if p == nil {
+				if tt.want != nil {
+					t.Fatalf("%s: no problem picked", tt.name)
+				}
+				break
+			}
+			if !allowed[p.Slug] {
+				t.Fatalf("%s: picked %s, want one of %v", tt.name, p.Slug, tt.want)
+			}
+			if len(p.Topics) != 1 {
+				t.Errorf("%s: topics %v", tt.name, p.Topics)
+			}
+			seen[p.Slug] = true
+		}
+		if len(seen) != len(tt.want) {
+			t.Errorf("%s: picked %v in 50 tries, want each of %v", tt.name, seen, tt.want)
+		}
+	}
+}
+
+func TestRandomProblemWeighted(t *testing.T) {
+	d, ids, alice, bob := openRandomTestDB(t)
+	// Alice is weak on graphs and strong on arrays; bob has the opposite
+	// record, which must not affect alice's picks.
+	for _, a := range []*Attempt{
+		{UserID: alice.ID, ProblemID: ids["course-schedule"], Answer: "a", Score: 0, MaxScore: 10},
+		{UserID: alice.ID, ProblemID: ids["two-sum"], Answer: "a", Score: 10, MaxScore: 10},
+		{UserID: bob.ID, ProblemID: ids["course-schedule"], Answer: "a", Score: 10, MaxScore: 10},
+		{UserID: bob.ID, ProblemID: ids["two-sum"], Answer: "a", Score: 0, MaxScore: 10},
+	} {
+		if err := d.CreateAttempt(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	weakness, err := d.topicWeakness(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weakness["Graph"] != 1 || weakness["Array"] != 0 {
+		t.Errorf("topic weakness %v, want Graph 1 and Array 0", weakness)
+	}
+
+	// Graph problems weigh 4 and the array problem 1, so about 8 in 9
+	// picks are graphs.
+	graphs := 0
+	const draws = 900
+	for range draws {
+		p, err := d.RandomProblem(RandomParams{UserID: alice.ID, Weighted: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Slug != "two-sum" {
+			graphs++
+		}
+	}
+	if graphs < draws*3/4 || graphs == draws {
+		t.Errorf("%d of %d weighted picks were graph problems, want about %d", graphs, draws, draws*8/9)
+	}
+}
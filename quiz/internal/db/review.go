@@ -0,0 +1,135 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TimeFormat matches SQLite's datetime('now') so stored times compare
+// correctly against it.
+const TimeFormat = "2006-01-02 15:04:05"
+
+// ReviewState is a user's spaced-repetition schedule for one problem.
+type ReviewState struct {
+	UserID        int     `json:"-"`
+	ProblemID     int     `json:"problem_id"`
+	Ease          float64 `json:"ease"`
+	IntervalDays  int     `json:"interval_days"`
+	Repetitions   int     `json:"repetitions"`
+	DueAt         string  `json:"due_at"`
+	LastAttemptID int     `json:"last_attempt_id,omitempty"`
+	// LastScore is the last attempt's score as a fraction of its maximum.
+	LastScore float64 `json:"last_score"`
+}
+
+// DueReview is a scheduled problem that is due for review.
+type DueReview struct {
+	ProblemSummary
+	ReviewState
+}
+
+// GetReviewState returns the user's schedule for a problem, or nil if they
+// have never reviewed it.
+func (d *DB) GetReviewState(userID, problemID int) (*ReviewState, error) {
+	var s ReviewState
+	var lastAttempt sql.NullInt64
+	err := d.conn.QueryRow(`
+		SELECT user_id, problem_id, ease, interval_days, repetitions, due_at, last_attempt_id, last_score
+		FROM reviews WHERE user_id = ? AND problem_id = ?
+	`, userID, problemID).Scan(&s.UserID, &s.ProblemID, &s.Ease, &s.IntervalDays, &s.Repetitions, &s.DueAt, &lastAttempt, &s.LastScore)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get review state: %w", err)
+	}
+	s.LastAttemptID = int(lastAttempt.Int64)
+	return &s, nil
+}
+
+// SaveReviewState inserts or replaces the user's schedule for a problem.
+func (d *DB) SaveReviewState(s *ReviewState) error {
+	var lastAttempt any
+	if s.LastAttemptID > 0 {
+		lastAttempt = s.LastAttemptID
+	}
+	_, err := d.conn.Exec(`
+		INSERT INTO reviews (user_id, problem_id, ease, interval_days, repetitions, due_at, last_attempt_id, last_score)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, problem_id) DO UPDATE SET
+		    ease = excluded.ease,
+		    interval_days = excluded.interval_days,
+		    repetitions = excluded.repetitions,
+		    due_at = excluded.due_at,
+		    last_attempt_id = excluded.last_attempt_id,
+		    last_score = excluded.last_score,
+		    updated_at = datetime('now')
+	`, s.UserID, s.ProblemID, s.Ease, s.IntervalDays, s.Repetitions, s.DueAt, lastAttempt, s.LastScore)
+	if err != nil {
+		return fmt.Errorf("save review state: %w", err)
+	}
+	return nil
+}
+
+// ClearReviews deletes every schedule, e.g. before replaying attempts.
+func (d *DB) ClearReviews() error {
+	if _, err := d.conn.Exec("DELETE FROM reviews"); err != nil {
+		return fmt.Errorf("clear reviews: %w", err)
+	}
+	return nil
+}
+
+// ListDueReviews returns the user's problems due at or before now. The most
+// overdue come first; ties go to the problem with the lowest ease.
+func (d *DB) ListDueReviews(userID int, now time.Time, limit int) ([]DueReview, int, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	nowStr := now.UTC().Format(TimeFormat)
+
+	var total int
+	if err := d.conn.QueryRow("SELECT COUNT(*) FROM reviews WHERE user_id = ? AND due_at <= ?", userID, nowStr).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count due reviews: %w", err)
+	}
+
+	rows, err := d.conn.Query(`
+		SELECT p.id, p.source_id, p.slug, p.title, p.difficulty,
+		       r.ease, r.interval_days, r.repetitions, r.due_at, r.last_attempt_id, r.last_score
+		FROM reviews r JOIN problems p ON p.id = r.problem_id
+		WHERE r.user_id = ? AND r.due_at <= ?
+		ORDER BY r.due_at, r.ease, r.last_score
+		LIMIT ?
+	`, userID, nowStr, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list due reviews: %w", err)
+	}
+	defer rows.Close()
+
+	due := []DueReview{}
+	for rows.Next() {
+		var r DueReview
+		var lastAttempt sql.NullInt64
+		if err := rows.Scan(&r.ID, &r.SourceID, &r.Slug, &r.Title, &r.Difficulty,
+			&r.Ease, &r.IntervalDays, &r.Repetitions, &r.DueAt, &lastAttempt, &r.LastScore); err != nil {
+			return nil, 0, fmt.Errorf("scan due review: %w", err)
+		}
+		r.UserID = userID
+		r.ProblemID = r.ID
+		r.LastAttemptID = int(lastAttempt.Int64)
+		due = append(due, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("list due reviews: %w", err)
+	}
+
+	for i := range due {
+		topics, err := d.problemTopics(due[i].ID)
+		if err != nil {
+			return nil, 0, err
+		}
+		due[i].Topics = topics
+	}
+
+	return due, total, nil
+}
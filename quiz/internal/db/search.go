@@ -0,0 +1,81 @@
+package db
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Orders for ListProblems. SortRelevance ranks the results of a text
+// search best first; without one it is the same as SortNumber.
+const (
+	SortNumber    = "number"
+	SortRelevance = "relevance"
+)
+
+// searchRank orders search results by bm25 with problems_fts's columns
+// weighted: a match in the title or topics counts for more than one in
+// the description, and constraints mostly name variables.
+const searchRank = "bm25(problems_fts, 10.0, 1.0, 0.5, 1.0, 4.0)"
+
+// snippetStart and snippetEnd mark matches in snippets from SQLite; they
+// become <mark> tags once the rest of the text is escaped.
+const (
+	snippetStart = "\x02"
+	snippetEnd   = "\x03"
+)
+
+// snippetColumns are the problems_fts columns tried for a snippet, in
+// order: the title and topics are shown in results anyway.
+var snippetColumns = []int{1, 3, 2}
+
+// tagPattern matches HTML tags in hints, including one cut off by the end
+// of a snippet, but not one containing a match.
+var tagPattern = regexp.MustCompile(`</?[a-zA-Z][^>\x02\x03]*>?`)
+
+// ftsQuery turns what a user typed into an FTS5 query for problems
+// containing every word. Words in double quotes must appear together, and
+// the last word is matched as a prefix while it is still being typed.
+// Everything but letters and digits is dropped, so the input cannot use,
+// or break, FTS5 syntax. It returns "" if no words are left.
+func ftsQuery(q string) string {
+	var terms []string
+	prefix := false
+	for i, part := range strings.Split(q, `"`) {
+		quoted := i%2 == 1
+		var fields []string
+		if quoted {
+			fields = []string{part}
+		} else {
+			fields = strings.Fields(part)
+		}
+		for _, field := range fields {
+			words := strings.FieldsFunc(field, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+			})
+			if len(words) > 0 {
+				terms = append(terms, `"`+strings.Join(words, " ")+`"`)
+				prefix = !quoted
+			}
+		}
+	}
+	if len(terms) == 0 {
+		return ""
+	}
+	if prefix && !strings.HasSuffix(q, " ") {
+		terms[len(terms)-1] += "*"
+	}
+	return strings.Join(terms, " ")
+}
+
+// formatSnippet escapes a snippet for HTML, dropping any tags from hints,
+// and wraps its matches in <mark>. It returns "" if nothing matched.
+func formatSnippet(s string) string {
+	if !strings.Contains(s, snippetStart) {
+		return ""
+	}
+	s = html.EscapeString(tagPattern.ReplaceAllString(s, ""))
+	s = strings.ReplaceAll(s, snippetStart, "<mark>")
+	return strings.ReplaceAll(s, snippetEnd, "</mark>")
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFtsQuery(t *testing.T) {
+	tests := []struct {
+		name, q, want string
+	}{
+		{"words", "two sum", `"two" "sum"*`},
+		{"finished word", "two sum ", `"two" "sum"`},
+		{"phrase", `"two sum" array`, `"two sum" "array"*`},
+		{"phrase last", `"two sum"`, `"two sum"`},
+		{"unclosed quote", `two "sum`, `"two" "sum"`},
+		{"punctuation", "n-queens", `"n queens"*`},
+		{"operators", "a AND NOT b", `"a" "AND" "NOT" "b"*`},
+		{"syntax", `c++ (tree) OR *`, `"c" "tree" "OR"*`},
+		{"column filter", "title:sum", `"title sum"*`},
+		{"accents", "café", `"café"*`},
+		{"nothing searchable", `!!! "" *`, ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := ftsQuery(tt.q); got != tt.want {
+			t.Errorf("%s: ftsQuery(%q) = %q, want %q", tt.name, tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSnippet(t *testing.T) {
+	tests := []struct {
+		name, s, want string
+	}{
+		{"no match", "Return the indices.", ""},
+		{"match", "Return the \x02sum\x03 if x < y", "Return the <mark>sum</mark> if x &lt; y"},
+		{"tags dropped", "Use a <code>\x02hash\x03 map</code>.", "Use a <mark>hash</mark> map."},
+		{"tag cut off", "\x02map\x03 from <code cla", "<mark>map</mark> from "},
+		{"match in a tag kept", "<a href=\"\x02map\x03\">", "<mark>map</mark>&#34;&gt;"},
+	}
+	for _, tt := range tests {
+		if got := formatSnippet(tt.s); got != tt.want {
+			t.Errorf("%s: formatSnippet(%q) = %q, want %q", tt.name, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestListProblemsSearch(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := d.ImportProblems([]ImportQuestion{
+		{
+			Title: "Running Total", FrontendID: json.RawMessage(`"1"`), ProblemSlug: "running-total",
+			Description: "Return the sum of every prefix.",
+		},
+		{
+			Title: "Two Sum", FrontendID: json.RawMessage(`"2"`), ProblemSlug: "two-sum",
+			Description: "Return the indices of the two numbers that add up to target.",
+			Hints:       json.RawMessage(`["Use a <code>hash map</code> from value to index."]`),
+			Topics:      []string{"Hash Table"},
+		},
+		{
+			Title: "Valid Parentheses", FrontendID: json.RawMessage(`"3"`), ProblemSlug: "valid-parentheses",
+			Description: "Check that every bracket is closed in order.",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		params ListParams
+		want   []string
+		// snippets are by source ID, if set; a missing one should be empty.
+		snippets map[string]string
+	}{
+		{
+			name:     "number order",
+			params:   ListParams{Query: "sum"},
+			want:     []string{"1", "2"},
+			snippets: map[string]string{"1": "Return the <mark>sum</mark> of every prefix."},
+		},
+		// A match in the title counts for more than one in the description.
+		{name: "relevance", params: ListParams{Query: "sum", Sort: SortRelevance}, want: []string{"2", "1"}},
+		{name: "prefix", params: ListParams{Query: "su"}, want: []string{"1", "2"}},
+		{name: "stemmed", params: ListParams{Query: "brackets "}, want: []string{"3"}},
+		{
+			name:     "hint",
+			params:   ListParams{Query: "hash map"},
+			want:     []string{"2"},
+			snippets: map[string]string{"2": "Use a <mark>hash</mark> <mark>map</mark> from value to index."},
+		},
+		{name: "phrase", params: ListParams{Query: `"map hash"`}, want: []string{}},
+		{name: "title only", params: ListParams{Query: "valid"}, want: []string{"3"}, snippets: map[string]string{}},
+		{name: "topic filter", params: ListParams{Query: "return", Topic: "Hash Table"}, want: []string{"2"}},
+		{name: "OR is a word", params: ListParams{Query: "sum OR valid"}, want: []string{}},
+		{name: "NOT is a word", params: ListParams{Query: "NOT sum"}, want: []string{}},
+		{name: "brackets", params: ListParams{Query: "parentheses)("}, want: []string{"3"}},
+		{name: "only punctuation", params: ListParams{Query: `* "`}, want: []string{}},
+		{name: "ID", params: ListParams{Query: "#2"}, want: []string{"2"}},
+	}
+	for _, tt := range tests {
+		problems, total, err := d.ListProblems(tt.params)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		ids := []string{}
+		for _, p := range problems {
+			ids = append(ids, p.SourceID)
+			if tt.snippets != nil && p.Snippet != tt.snippets[p.SourceID] {
+				t.Errorf("%s: problem %s snippet %q, want %q", tt.name, p.SourceID, p.Snippet, tt.snippets[p.SourceID])
+			}
+		}
+		if !reflect.DeepEqual(ids, tt.want) || total != len(tt.want) {
+			t.Errorf("%s: problems %v of %d, want %v", tt.name, ids, total, tt.want)
+		}
+	}
+}
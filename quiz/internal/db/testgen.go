@@ -0,0 +1,51 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// TestGenerator is the Python source used to test solutions to a problem
+// on generated inputs: Generator defines generate(rng, size), returning
+// the arguments for one call, and optionally valid(args), which says
+// whether an input meets the problem's constraints. Reference is a
+// brute-force Solution whose results are taken as correct.
+type TestGenerator struct {
+	ProblemID int    `json:"problem_id"`
+	Generator string `json:"generator"`
+	Reference string `json:"reference"`
+	Model     string `json:"model"`
+	CreatedAt string `json:"created_at"`
+}
+
+// GetTestGenerator returns the problem's test generator, or nil if it has
+// none yet.
+func (d *DB) GetTestGenerator(problemID int) (*TestGenerator, error) {
+	g := TestGenerator{ProblemID: problemID}
+	err := d.conn.QueryRow(`
+		SELECT generator, reference, model, created_at FROM test_generators WHERE problem_id = ?
+	`, problemID).Scan(&g.Generator, &g.Reference, &g.Model, &g.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get test generator: %w", err)
+	}
+	return &g, nil
+}
+
+// StoreTestGenerator saves the problem's test generator, replacing any it
+// had, and fills in CreatedAt.
+func (d *DB) StoreTestGenerator(g *TestGenerator) error {
+	err := d.conn.QueryRow(`
+		INSERT INTO test_generators (problem_id, generator, reference, model) VALUES (?, ?, ?, ?)
+		ON CONFLICT (problem_id) DO UPDATE SET
+			generator = excluded.generator, reference = excluded.reference,
+			model = excluded.model, created_at = datetime('now')
+		RETURNING created_at
+	`, g.ProblemID, g.Generator, g.Reference, g.Model).Scan(&g.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("store test generator: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APIToken is a personal access token. The token itself is only known when
+// it is created.
+type APIToken struct {
+	ID     int      `json:"id"`
+	UserID int      `json:"-"`
+	Name   string   `json:"name"`
+	Prefix string   `json:"prefix"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is empty for tokens that never expire.
+	ExpiresAt  string `json:"expires_at,omitempty"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+	CreatedAt  string `json:"created_at"`
+}
+
+const apiTokenColumns = `
+	t.id, t.user_id, t.name, t.prefix, t.scopes,
+	IFNULL(t.expires_at, ''), IFNULL(t.last_used_at, ''), t.created_at
+`
+
+func scanAPIToken(row rowScanner, extra ...any) (*APIToken, error) {
+	var t APIToken
+	var scopes string
+	dest := append([]any{&t.ID, &t.UserID, &t.Name, &t.Prefix, &scopes,
+		&t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	return &t, nil
+}
+
+// CreateAPIToken stores t with the hash of its secret and fills in its ID
+// and CreatedAt.
+func (d *DB) CreateAPIToken(t *APIToken, tokenHash string) error {
+	var expires any
+	if t.ExpiresAt != "" {
+		expires = t.ExpiresAt
+	}
+	res, err := d.conn.Exec(`
+		INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, t.UserID, t.Name, tokenHash, t.Prefix, strings.Join(t.Scopes, " "), expires)
+	if err != nil {
+		return fmt.Errorf("insert api token: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("api token id: %w", err)
+	}
+	stored, err := scanAPIToken(d.conn.QueryRow(
+		"SELECT "+apiTokenColumns+" FROM api_tokens t WHERE t.id = ?", id))
+	if err != nil {
+		return fmt.Errorf("get api token: %w", err)
+	}
+	*t = *stored
+	return nil
+}
+
+// ListAPITokens returns the user's tokens, newest first.
+func (d *DB) ListAPITokens(userID int) ([]APIToken, error) {
+	rows, err := d.conn.Query(`
+		SELECT `+apiTokenColumns+`
+		FROM api_tokens t
+		WHERE t.user_id = ?
+		ORDER BY t.created_at DESC, t.id DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan api token: %w", err)
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAPIToken revokes one of the user's tokens. It reports whether the
+// token existed.
+func (d *DB) DeleteAPIToken(userID, id int) (bool, error) {
+	res, err := d.conn.Exec("DELETE FROM api_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, fmt.Errorf("delete api token: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete api token: %w", err)
+	}
+	return n > 0, nil
+}
+
+// APITokenUser returns an unexpired token and its user, or nils if there is
+// no such token, and records that the token was used.
+func (d *DB) APITokenUser(tokenHash string, now time.Time) (*User, *APIToken, error) {
+	var u User
+	nowStr := now.UTC().Format(TimeFormat)
+	t, err := scanAPIToken(d.conn.QueryRow(`
+		SELECT `+apiTokenColumns+`, u.id, u.username, u.created_at
+		FROM api_tokens t JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = ? AND (t.expires_at IS NULL OR t.expires_at > ?)
+	`, tokenHash, nowStr), &u.ID, &u.Username, &u.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("get api token: %w", err)
+	}
+
+	if _, err := d.conn.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", nowStr, t.ID); err != nil {
+		return nil, nil, fmt.Errorf("touch api token: %w", err)
+	}
+	t.LastUsedAt = nowStr
+	return &u, t, nil
+}
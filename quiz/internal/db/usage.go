@@ -0,0 +1,55 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// Usage is the LLM usage of one completion.
+type Usage struct {
+	// UserID is 0 for usage not attributed to a user.
+	UserID           int
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	CostUSD          float64
+}
+
+// UsageTotal sums usage over a period.
+type UsageTotal struct {
+	Tokens  int64   `json:"tokens"`
+	CostUSD float64 `json:"cost_usd"`
+}
+
+// RecordUsage stores the usage of one completion.
+func (d *DB) RecordUsage(u Usage) error {
+	_, err := d.conn.Exec(`
+		INSERT INTO llm_usage (user_id, model, prompt_tokens, completion_tokens, cost_usd)
+		VALUES (?, ?, ?, ?, ?)
+	`, nullID(u.UserID), u.Model, u.PromptTokens, u.CompletionTokens, u.CostUSD)
+	if err != nil {
+		return fmt.Errorf("insert usage: %w", err)
+	}
+	return nil
+}
+
+// UsageSince sums the user's usage since the given time. A userID of 0 sums
+// everyone's.
+func (d *DB) UsageSince(userID int, since time.Time) (UsageTotal, error) {
+	query := `
+		SELECT IFNULL(SUM(prompt_tokens + completion_tokens), 0), IFNULL(SUM(cost_usd), 0)
+		FROM llm_usage
+		WHERE created_at >= ?
+	`
+	args := []any{since.UTC().Format(TimeFormat)}
+	if userID != 0 {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+
+	var t UsageTotal
+	if err := d.conn.QueryRow(query, args...).Scan(&t.Tokens, &t.CostUSD); err != nil {
+		return UsageTotal{}, fmt.Errorf("sum usage: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUserExists is returned when creating a user whose name is taken.
+var ErrUserExists = errors.New("user already exists")
+
+// User is an account. Usernames are case-insensitive.
+type User struct {
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at"`
+}
+
+// CreateUser adds a user with an already hashed password.
+func (d *DB) CreateUser(username, passwordHash string) (*User, error) {
+	res, err := d.conn.Exec(`
+		INSERT INTO users (username, password_hash) VALUES (?, ?)
+		ON CONFLICT(username) DO NOTHING
+	`, username, passwordHash)
+	if err != nil {
+		return nil, fmt.Errorf("insert user: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("insert user: %w", err)
+	} else if n == 0 {
+		return nil, ErrUserExists
+	}
+
+	return d.GetUserByUsername(username)
+}
+
+// GetUserByUsername returns the user, or nil if there is none.
+func (d *DB) GetUserByUsername(username string) (*User, error) {
+	u, _, err := d.UserPasswordHash(username)
+	return u, err
+}
+
+// UserPasswordHash returns the user and their password hash, or a nil user
+// if there is none.
+func (d *DB) UserPasswordHash(username string) (*User, string, error) {
+	var u User
+	var hash string
+	err := d.conn.QueryRow(`
+		SELECT id, username, created_at, password_hash FROM users WHERE username = ?
+	`, username).Scan(&u.ID, &u.Username, &u.CreatedAt, &hash)
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("get user: %w", err)
+	}
+	return &u, hash, nil
+}
+
+// SetPasswordHash replaces a user's password hash and ends all of their
+// sessions.
+func (d *DB) SetPasswordHash(userID int, passwordHash string) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET password_hash = ? WHERE id = ?", passwordHash, userID); err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("delete sessions: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeleteUser removes a user along with their sessions, attempts and review
+// schedule. It reports whether the user existed.
+func (d *DB) DeleteUser(username string) (bool, error) {
+	res, err := d.conn.Exec("DELETE FROM users WHERE username = ?", username)
+	if err != nil {
+		return false, fmt.Errorf("delete user: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete user: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListUsers returns every user sorted by name.
+func (d *DB) ListUsers() ([]User, error) {
+	rows, err := d.conn.Query("SELECT id, username, created_at FROM users ORDER BY username")
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// ClaimUnownedAttempts gives every attempt without an owner to the user and
+// returns how many were claimed.
+func (d *DB) ClaimUnownedAttempts(userID int) (int, error) {
+	res, err := d.conn.Exec("UPDATE attempts SET user_id = ? WHERE user_id IS NULL", userID)
+	if err != nil {
+		return 0, fmt.Errorf("claim attempts: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("claim attempts: %w", err)
+	}
+	return int(n), nil
+}
+
+// CreateSession stores a session for the user, clearing out expired ones.
+func (d *DB) CreateSession(tokenHash string, userID int, expiresAt time.Time) error {
+	if _, err := d.conn.Exec("DELETE FROM sessions WHERE expires_at <= datetime('now')"); err != nil {
+		return fmt.Errorf("delete expired sessions: %w", err)
+	}
+	_, err := d.conn.Exec(`
+		INSERT INTO sessions (token_hash, user_id, expires_at) VALUES (?, ?, ?)
+	`, tokenHash, userID, expiresAt.UTC().Format(TimeFormat))
+	if err != nil {
+		return fmt.Errorf("insert session: %w", err)
+	}
+	return nil
+}
+
+// SessionUser returns the user of an unexpired session, or nil.
+func (d *DB) SessionUser(tokenHash string, now time.Time) (*User, error) {
+	var u User
+	err := d.conn.QueryRow(`
+		SELECT u.id, u.username, u.created_at
+		FROM sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = ? AND s.expires_at > ?
+	`, tokenHash, now.UTC().Format(TimeFormat)).Scan(&u.ID, &u.Username, &u.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get session: %w", err)
+	}
+	return &u, nil
+}
+
+// DeleteSession ends a session. Deleting an unknown session is not an error.
+func (d *DB) DeleteSession(tokenHash string) error {
+	if _, err := d.conn.Exec("DELETE FROM sessions WHERE token_hash = ?", tokenHash); err != nil {
+		return fmt.Errorf("delete session: %w", err)
+	}
+	return nil
+}
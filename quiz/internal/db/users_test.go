@@ -0,0 +1,105 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUsers(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	alice, err := d.CreateUser("alice", "hash1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.CreateUser("Alice", "hash2"); !errors.Is(err, ErrUserExists) {
+		t.Errorf("CreateUser in another case = %v, want ErrUserExists", err)
+	}
+	if _, err := d.CreateUser("bob", "hash3"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		wantID   int
+		wantHash string
+	}{
+		{"exact", "alice", alice.ID, "hash1"},
+		{"other case", "ALICE", alice.ID, "hash1"},
+		{"unknown", "carol", 0, ""},
+	}
+	for _, tt := range tests {
+		u, hash, err := d.UserPasswordHash(tt.username)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		id := 0
+		if u != nil {
+			id = u.ID
+		}
+		if id != tt.wantID || hash != tt.wantHash {
+			t.Errorf("%s: user %d hash %q, want %d %q", tt.name, id, hash, tt.wantID, tt.wantHash)
+		}
+	}
+
+	if users, err := d.ListUsers(); err != nil || len(users) != 2 || users[0].Username != "alice" {
+		t.Errorf("ListUsers = %v, %v", users, err)
+	}
+
+	if ok, err := d.DeleteUser("BOB"); !ok || err != nil {
+		t.Errorf("DeleteUser = %v, %v", ok, err)
+	}
+	if ok, err := d.DeleteUser("bob"); ok || err != nil {
+		t.Errorf("DeleteUser again = %v, %v", ok, err)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	d, _, user := openTestDB(t)
+	now := time.Now()
+
+	if err := d.CreateSession("live", user.ID, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateSession("stale", user.ID, now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		at    time.Time
+		want  bool
+	}{
+		{"live", "live", now, true},
+		{"live after it expires", "live", now.Add(2 * time.Hour), false},
+		{"expired", "stale", now, false},
+		{"unknown", "other", now, false},
+	}
+	for _, tt := range tests {
+		u, err := d.SessionUser(tt.token, tt.at)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if (u != nil) != tt.want {
+			t.Errorf("%s: user %v, want found = %v", tt.name, u, tt.want)
+		}
+	}
+
+	if err := d.SetPasswordHash(user.ID, "new"); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := d.SessionUser("live", now); u != nil || err != nil {
+		t.Errorf("session survived a password change: %v, %v", u, err)
+	}
+	if _, hash, _ := d.UserPasswordHash(user.Username); hash != "new" {
+		t.Errorf("password hash %q after SetPasswordHash", hash)
+	}
+}
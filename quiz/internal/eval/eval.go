@@ -0,0 +1,302 @@
+// Package eval measures how well the grader agrees with hand-labelled
+// answers, so that changes to the prompt, model or provider can be judged.
+//
+// A fixtures directory holds one YAML file per case naming a problem, an
+// answer and the points each criterion should get. Every case is graded one
+// or more times; the report gives per-criterion accuracy (runs that gave
+// the expected points) and agreement (cases where every run gave the same
+// points), and can be saved as a baseline to compare later runs against.
+package eval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/leettomato/quiz/internal/llm"
+	"github.com/leettomato/quiz/internal/rubric"
+)
+
+// Case is one labelled answer.
+type Case struct {
+	// Name defaults to the file name without its extension.
+	Name    string `yaml:"name"`
+	Problem string `yaml:"problem"`
+	// Rubric names the rubric to grade with; empty means the default.
+	Rubric string `yaml:"rubric"`
+	// Answer is given inline or as AnswerFile, relative to the case file.
+	Answer     string `yaml:"answer"`
+	AnswerFile string `yaml:"answer_file"`
+	// Expect maps criterion keys to the points they should get: 0 or 1 for
+	// pass/fail criteria. Criteria not listed are not scored.
+	Expect map[string]int `yaml:"expect"`
+}
+
+// LoadCases reads every .yaml or .yml file in dir as a case, sorted by
+// name.
+func LoadCases(dir string) ([]Case, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []Case
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		c, err := loadCase(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if c.Name == "" {
+			c.Name = strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		}
+		cases = append(cases, *c)
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("no cases in %s", dir)
+	}
+
+	sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	return cases, nil
+}
+
+func loadCase(path string) (*Case, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Case
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+
+	if c.Problem == "" {
+		return nil, errors.New("problem is required")
+	}
+	if len(c.Expect) == 0 {
+		return nil, errors.New("expect is required")
+	}
+	if c.AnswerFile != "" {
+		if c.Answer != "" {
+			return nil, errors.New("give answer or answer_file, not both")
+		}
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(path), c.AnswerFile))
+		if err != nil {
+			return nil, err
+		}
+		c.Answer = string(data)
+	}
+	if strings.TrimSpace(c.Answer) == "" {
+		return nil, errors.New("answer is empty")
+	}
+	return &c, nil
+}
+
+// Check reports expectations that rb cannot meet: unknown criteria or
+// points out of range.
+func (c *Case) Check(rb *rubric.Rubric) error {
+	maxPoints := make(map[string]int, len(rb.Criteria))
+	for _, cr := range rb.Criteria {
+		maxPoints[cr.Key] = cr.MaxPoints()
+	}
+	for key, points := range c.Expect {
+		limit, ok := maxPoints[key]
+		if !ok {
+			return fmt.Errorf("case %s: rubric %s has no criterion %q", c.Name, rb.Name, key)
+		}
+		if points < 0 || points > limit {
+			return fmt.Errorf("case %s: %s expects %d points, but the range is 0-%d", c.Name, key, points, limit)
+		}
+	}
+	return nil
+}
+
+// GradeFunc grades one case once.
+type GradeFunc func(ctx context.Context, c *Case) (*llm.GradingResult, error)
+
+// Report is the outcome of an evaluation. It is saved as JSON for use as a
+// baseline.
+type Report struct {
+	Model     string `json:"model"`
+	Provider  string `json:"provider"`
+	CreatedAt string `json:"created_at"`
+	Runs      int    `json:"runs"`
+	// Cases are in the order they were given.
+	Cases []CaseResult `json:"cases"`
+	// Criteria are sorted by key.
+	Criteria []CriterionStats `json:"criteria"`
+	Total    CriterionStats   `json:"total"`
+}
+
+// CaseResult is every run's points for one case.
+type CaseResult struct {
+	Name   string         `json:"name"`
+	Expect map[string]int `json:"expect"`
+	// Points holds each successful run's points by criterion key.
+	Points []map[string]int `json:"points"`
+	Errors []string         `json:"errors,omitempty"`
+}
+
+// CriterionStats summarises one criterion, or all of them, across cases.
+type CriterionStats struct {
+	Key string `json:"key"`
+	// Correct of Graded runs gave the expected points.
+	Graded  int `json:"graded"`
+	Correct int `json:"correct"`
+	// Unanimous of Compared cases graded more than once got the same points
+	// in every run.
+	Compared  int `json:"compared"`
+	Unanimous int `json:"unanimous"`
+}
+
+// Accuracy is the fraction of runs that gave the expected points.
+func (s CriterionStats) Accuracy() float64 {
+	return fraction(s.Correct, s.Graded)
+}
+
+// Agreement is the fraction of cases on which every run agreed.
+func (s CriterionStats) Agreement() float64 {
+	return fraction(s.Unanimous, s.Compared)
+}
+
+func fraction(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// Run grades every case runs times, with up to parallel gradings at once,
+// and summarises the results. Grading errors are recorded against the case
+// rather than stopping the run.
+func Run(ctx context.Context, cases []Case, runs, parallel int, grade GradeFunc) *Report {
+	runs, parallel = max(runs, 1), max(parallel, 1)
+	results := make([]CaseResult, len(cases))
+	for i, c := range cases {
+		results[i] = CaseResult{Name: c.Name, Expect: c.Expect}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i := range cases {
+		for range runs {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				result, err := grade(ctx, &cases[i])
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					results[i].Errors = append(results[i].Errors, err.Error())
+					return
+				}
+				points := make(map[string]int, len(result.Criteria))
+				for _, cr := range result.Criteria {
+					points[cr.Key] = cr.Points
+				}
+				results[i].Points = append(results[i].Points, points)
+			}()
+		}
+	}
+	wg.Wait()
+
+	rep := &Report{Runs: runs, Cases: results}
+	rep.summarise()
+	return rep
+}
+
+// summarise fills in the per-criterion and total statistics.
+func (r *Report) summarise() {
+	byKey := make(map[string]*CriterionStats)
+	for _, c := range r.Cases {
+		for key, want := range c.Expect {
+			s, ok := byKey[key]
+			if !ok {
+				s = &CriterionStats{Key: key}
+				byKey[key] = s
+			}
+			for _, p := range c.Points {
+				s.Graded++
+				if got, ok := p[key]; ok && got == want {
+					s.Correct++
+				}
+			}
+			if len(c.Points) > 1 {
+				s.Compared++
+				if c.unanimous(key) {
+					s.Unanimous++
+				}
+			}
+		}
+	}
+
+	r.Criteria = nil
+	r.Total = CriterionStats{Key: "total"}
+	for _, s := range byKey {
+		r.Criteria = append(r.Criteria, *s)
+		r.Total.Graded += s.Graded
+		r.Total.Correct += s.Correct
+		r.Total.Compared += s.Compared
+		r.Total.Unanimous += s.Unanimous
+	}
+	sort.Slice(r.Criteria, func(i, j int) bool { return r.Criteria[i].Key < r.Criteria[j].Key })
+}
+
+// unanimous reports whether every run gave the criterion the same points.
+func (c *CaseResult) unanimous(key string) bool {
+	for _, p := range c.Points[1:] {
+		if p[key] != c.Points[0][key] {
+			return false
+		}
+	}
+	return true
+}
+
+// majority is the points most runs gave the criterion, and whether there
+// were any runs. Ties go to the lower points.
+func (c *CaseResult) majority(key string) (int, bool) {
+	counts := make(map[int]int)
+	for _, p := range c.Points {
+		if v, ok := p[key]; ok {
+			counts[v]++
+		}
+	}
+	best, bestN := 0, 0
+	for v, n := range counts {
+		if n > bestN || (n == bestN && v < best) {
+			best, bestN = v, n
+		}
+	}
+	return best, bestN > 0
+}
+
+// pointsList formats every run's points for a criterion, e.g. "1 1 0".
+func (c *CaseResult) pointsList(key string) string {
+	parts := make([]string, len(c.Points))
+	for i, p := range c.Points {
+		if v, ok := p[key]; ok {
+			parts[i] = fmt.Sprint(v)
+		} else {
+			parts[i] = "-"
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,196 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// Save writes the report as JSON.
+func (r *Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// LoadReport reads a report saved with Save.
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// Write prints each case's points against the expected ones, then the
+// per-criterion summary.
+func (r *Report) Write(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CASE\tCRITERION\tEXPECTED\tGOT\t")
+	for _, c := range r.Cases {
+		for _, key := range sortedKeys(c.Expect) {
+			mark := "ok"
+			if got, ok := c.majority(key); !ok {
+				mark = "no result"
+			} else if got != c.Expect[key] {
+				mark = "WRONG"
+			} else if !c.unanimous(key) {
+				mark = "split"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", c.Name, key, c.Expect[key], c.pointsList(key), mark)
+		}
+		for _, e := range c.Errors {
+			fmt.Fprintf(tw, "%s\terror\t\t\t%s\n", c.Name, e)
+		}
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CRITERION\tACCURACY\tAGREEMENT\t")
+	for _, s := range append(r.Criteria, r.Total) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t\n", s.Key, ratio(s.Correct, s.Graded), ratio(s.Unanimous, s.Compared))
+	}
+	tw.Flush()
+
+	// Failed gradings are left out of accuracy, so call them out.
+	failed := 0
+	for _, c := range r.Cases {
+		failed += len(c.Errors)
+	}
+	if failed > 0 {
+		fmt.Fprintf(w, "\n%d of %d gradings failed\n", failed, r.Runs*len(r.Cases))
+	}
+}
+
+func ratio(n, d int) string {
+	if d == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d/%d (%.0f%%)", n, d, 100*fraction(n, d))
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Change is a case criterion whose majority grade moved onto or off the
+// expected points since the baseline.
+type Change struct {
+	Case   string
+	Key    string
+	Expect int
+	Before string
+	After  string
+}
+
+// Diff compares a report with a baseline.
+type Diff struct {
+	Baseline *Report
+	Report   *Report
+	// Regressions were right in the baseline and are wrong now; Fixes the
+	// other way round.
+	Regressions []Change
+	Fixes       []Change
+}
+
+// Compare diffs r against baseline, matching cases by name. Cases or
+// criteria missing from either report are ignored.
+func (r *Report) Compare(baseline *Report) *Diff {
+	d := &Diff{Baseline: baseline, Report: r}
+	before := make(map[string]*CaseResult, len(baseline.Cases))
+	for i := range baseline.Cases {
+		before[baseline.Cases[i].Name] = &baseline.Cases[i]
+	}
+
+	for i := range r.Cases {
+		now := &r.Cases[i]
+		was, ok := before[now.Name]
+		if !ok {
+			continue
+		}
+		for _, key := range sortedKeys(now.Expect) {
+			want := now.Expect[key]
+			wasGot, ok1 := was.majority(key)
+			nowGot, ok2 := now.majority(key)
+			if !ok1 || !ok2 {
+				continue
+			}
+			change := Change{Case: now.Name, Key: key, Expect: want,
+				Before: was.pointsList(key), After: now.pointsList(key)}
+			switch {
+			case wasGot == want && nowGot != want:
+				d.Regressions = append(d.Regressions, change)
+			case wasGot != want && nowGot == want:
+				d.Fixes = append(d.Fixes, change)
+			}
+		}
+	}
+	return d
+}
+
+// Write prints accuracy and agreement before and after for each criterion,
+// then the regressions and fixes.
+func (d *Diff) Write(w io.Writer) {
+	fmt.Fprintf(w, "Compared with baseline from %s (%s via %s, %d runs)\n\n",
+		d.Baseline.CreatedAt, d.Baseline.Model, d.Baseline.Provider, d.Baseline.Runs)
+
+	before := make(map[string]CriterionStats)
+	for _, s := range append(d.Baseline.Criteria, d.Baseline.Total) {
+		before[s.Key] = s
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CRITERION\tACCURACY\t\tAGREEMENT\t\t")
+	for _, s := range append(d.Report.Criteria, d.Report.Total) {
+		b, ok := before[s.Key]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", s.Key,
+			percent(b.Accuracy(), b.Graded), delta(b.Accuracy(), b.Graded, s.Accuracy(), s.Graded),
+			percent(b.Agreement(), b.Compared), delta(b.Agreement(), b.Compared, s.Agreement(), s.Compared))
+	}
+	tw.Flush()
+
+	for _, list := range []struct {
+		title   string
+		changes []Change
+	}{{"Regressions", d.Regressions}, {"Fixes", d.Fixes}} {
+		fmt.Fprintf(w, "\n%s: %d\n", list.title, len(list.changes))
+		for _, c := range list.changes {
+			fmt.Fprintf(w, "  %s %s: expected %d, was %s, now %s\n", c.Case, c.Key, c.Expect, c.Before, c.After)
+		}
+	}
+}
+
+func percent(v float64, n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", 100*v)
+}
+
+// delta formats the new value and its change, e.g. "-> 90% (+10)".
+func delta(before float64, nBefore int, after float64, nAfter int) string {
+	if nAfter == 0 {
+		return "-> -"
+	}
+	if nBefore == 0 {
+		return fmt.Sprintf("-> %.0f%%", 100*after)
+	}
+	return fmt.Sprintf("-> %.0f%% (%+.0f)", 100*after, 100*(after-before))
+}
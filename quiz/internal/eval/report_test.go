@@ -0,0 +1,142 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/leettomato/quiz/internal/llm"
+)
+
+// runFixture grades case a three times, giving y a split vote, and case b
+// three times, getting x wrong and failing once. It grades one at a time so
+// that the runs are listed in order.
+func runFixture(t *testing.T) *Report {
+	t.Helper()
+	cases := []Case{
+		{Name: "a", Expect: map[string]int{"x": 1, "y": 0}},
+		{Name: "b", Expect: map[string]int{"x": 0, "y": 1}},
+	}
+	runs := map[string][]map[string]int{
+		"a": {{"x": 1, "y": 0}, {"x": 1, "y": 0}, {"x": 1, "y": 1}},
+		"b": {{"x": 1, "y": 1}, nil, {"x": 1, "y": 1}},
+	}
+
+	var mu sync.Mutex
+	next := make(map[string]int)
+	grade := func(ctx context.Context, c *Case) (*llm.GradingResult, error) {
+		mu.Lock()
+		points := runs[c.Name][next[c.Name]]
+		next[c.Name]++
+		mu.Unlock()
+		if points == nil {
+			return nil, errors.New("bad output")
+		}
+		result := &llm.GradingResult{}
+		for _, key := range sortedKeys(points) {
+			result.Criteria = append(result.Criteria, llm.CriterionResult{Key: key, Points: points[key]})
+		}
+		return result, nil
+	}
+	return Run(t.Context(), cases, 3, 1, grade)
+}
+
+func TestRun(t *testing.T) {
+	rep := runFixture(t)
+
+	want := []CriterionStats{
+		{Key: "x", Graded: 5, Correct: 3, Compared: 2, Unanimous: 2},
+		{Key: "y", Graded: 5, Correct: 4, Compared: 2, Unanimous: 1},
+	}
+	if !reflect.DeepEqual(rep.Criteria, want) {
+		t.Errorf("criteria %+v, want %+v", rep.Criteria, want)
+	}
+	if total := (CriterionStats{Key: "total", Graded: 10, Correct: 7, Compared: 4, Unanimous: 3}); rep.Total != total {
+		t.Errorf("total %+v, want %+v", rep.Total, total)
+	}
+	if len(rep.Cases[1].Errors) != 1 || len(rep.Cases[1].Points) != 2 {
+		t.Errorf("case b has %d errors and %d results, want 1 and 2", len(rep.Cases[1].Errors), len(rep.Cases[1].Points))
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	var out strings.Builder
+	runFixture(t).Write(&out)
+
+	// Compare rows with their columns separated by single spaces.
+	rows := make(map[string]bool)
+	for _, line := range strings.Split(out.String(), "\n") {
+		rows[strings.Join(strings.Fields(line), " ")] = true
+	}
+	tests := []struct {
+		name string
+		row  string
+	}{
+		{"right every time", "a x 1 1 1 1 ok"},
+		{"right by majority", "a y 0 0 0 1 split"},
+		{"wrong", "b x 0 1 1 WRONG"},
+		{"error", "b error bad output"},
+		{"accuracy and agreement", "y 4/5 (80%) 1/2 (50%)"},
+		{"total", "total 7/10 (70%) 3/4 (75%)"},
+		{"failures", "1 of 6 gradings failed"},
+	}
+	for _, tt := range tests {
+		if !rows[tt.row] {
+			t.Errorf("%s: no row %q in\n%s", tt.name, tt.row, out.String())
+		}
+	}
+}
+
+func TestReportSaveLoad(t *testing.T) {
+	rep := runFixture(t)
+	rep.Model, rep.Provider, rep.CreatedAt = "model", "openai", "2026-01-01 00:00:00"
+
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := rep.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, rep) {
+		t.Errorf("loaded %+v, want %+v", loaded, rep)
+	}
+
+	if _, err := LoadReport(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadReport of a missing file succeeded")
+	}
+}
+
+func TestReportCompare(t *testing.T) {
+	// In the baseline a's y was wrong and b's x right, the other way round
+	// from now; c is no longer run.
+	baseline := &Report{Runs: 1, Cases: []CaseResult{
+		{Name: "a", Expect: map[string]int{"x": 1, "y": 0}, Points: []map[string]int{{"x": 1, "y": 1}}},
+		{Name: "b", Expect: map[string]int{"x": 0, "y": 1}, Points: []map[string]int{{"x": 0, "y": 1}}},
+		{Name: "c", Expect: map[string]int{"x": 1}, Points: []map[string]int{{"x": 1}}},
+	}}
+	baseline.summarise()
+
+	d := runFixture(t).Compare(baseline)
+	wantRegressions := []Change{{Case: "b", Key: "x", Expect: 0, Before: "0", After: "1 1"}}
+	wantFixes := []Change{{Case: "a", Key: "y", Expect: 0, Before: "1", After: "0 0 1"}}
+	if !reflect.DeepEqual(d.Regressions, wantRegressions) {
+		t.Errorf("regressions %+v, want %+v", d.Regressions, wantRegressions)
+	}
+	if !reflect.DeepEqual(d.Fixes, wantFixes) {
+		t.Errorf("fixes %+v, want %+v", d.Fixes, wantFixes)
+	}
+
+	var out strings.Builder
+	d.Write(&out)
+	for _, line := range []string{"Regressions: 1", "b x: expected 0, was 0, now 1 1", "Fixes: 1", "a y: expected 0, was 1, now 0 0 1"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("no %q in\n%s", line, out.String())
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/leettomato/quiz/internal/auth"
+	"github.com/leettomato/quiz/internal/db"
+)
+
+type AttemptsHandler struct {
+	db *db.DB
+}
+
+func NewAttemptsHandler(db *db.DB) *AttemptsHandler {
+	return &AttemptsHandler{db: db}
+}
+
+type AttemptListResponse struct {
+	Attempts []db.Attempt `json:"attempts"`
+	Total    int          `json:"total"`
+	Limit    int          `json:"limit"`
+	Offset   int          `json:"offset"`
+}
+
+// List returns all of the user's attempts, newest first.
+func (h *AttemptsHandler) List(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, 0)
+}
+
+// ListForProblem returns the user's attempts for a single problem, newest
+// first.
+func (h *AttemptsHandler) ListForProblem(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	problem, err := h.db.GetProblem(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if problem == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	h.list(w, r, id)
+}
+
+func (h *AttemptsHandler) list(w http.ResponseWriter, r *http.Request, problemID int) {
+	params := db.AttemptListParams{
+		UserID:    auth.UserFromContext(r.Context()).ID,
+		ProblemID: problemID,
+		Limit:     50,
+		Offset:    0,
+	}
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+			params.Limit = n
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			params.Offset = n
+		}
+	}
+
+	attempts, total, err := h.db.ListAttempts(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, AttemptListResponse{
+		Attempts: attempts,
+		Total:    total,
+		Limit:    params.Limit,
+		Offset:   params.Offset,
+	})
+}
+
+func (h *AttemptsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	attempt, err := h.db.GetAttempt(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Other users' attempts are reported as missing rather than forbidden.
+	if attempt == nil || attempt.UserID != auth.UserFromContext(r.Context()).ID {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, attempt)
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+func TestAttemptsHandler(t *testing.T) {
+	env := newTestEnv(t)
+	if _, err := env.db.ImportProblems([]db.ImportQuestion{{
+		Title: "Add Two Numbers", FrontendID: json.RawMessage(`"2"`), ProblemSlug: "add-two-numbers",
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	other, err := env.db.GetProblemBySlug("add-two-numbers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := env.db.CreateUser("bob", "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attempt := func(userID, problemID int) int {
+		a := &db.Attempt{UserID: userID, ProblemID: problemID, Answer: "answer", Rubric: "default", Score: 2, MaxScore: 4}
+		if err := env.db.CreateAttempt(a); err != nil {
+			t.Fatal(err)
+		}
+		return a.ID
+	}
+	first := attempt(env.user.ID, env.problem.ID)
+	second := attempt(env.user.ID, other.ID)
+	third := attempt(env.user.ID, env.problem.ID)
+	bobs := attempt(bob.ID, env.problem.ID)
+
+	h := NewAttemptsHandler(env.db)
+	problemID := strconv.Itoa(env.problem.ID)
+
+	lists := []struct {
+		name   string
+		query  string
+		id     string
+		status int
+		want   []int
+		total  int
+		limit  int
+		offset int
+	}{
+		{name: "all", want: []int{third, second, first}, total: 3, limit: 50},
+		{name: "first page", query: "?limit=2", want: []int{third, second}, total: 3, limit: 2},
+		{name: "second page", query: "?limit=2&offset=2", want: []int{first}, total: 3, limit: 2, offset: 2},
+		{name: "bad paging ignored", query: "?limit=500&offset=-1", want: []int{third, second, first}, total: 3, limit: 50},
+		{name: "one problem", id: problemID, want: []int{third, first}, total: 2, limit: 50},
+		{name: "one problem paged", id: problemID, query: "?limit=1&offset=1", want: []int{first}, total: 2, limit: 1, offset: 1},
+		{name: "missing problem", id: "999", status: http.StatusNotFound},
+		{name: "invalid problem", id: "x", status: http.StatusBadRequest},
+	}
+	for _, tt := range lists {
+		t.Run(tt.name, func(t *testing.T) {
+			r := env.request(http.MethodGet, "/api/attempts"+tt.query, nil)
+			w := httptest.NewRecorder()
+			if tt.id != "" {
+				r.SetPathValue("id", tt.id)
+				h.ListForProblem(w, r)
+			} else {
+				h.List(w, r)
+			}
+
+			if tt.status == 0 {
+				tt.status = http.StatusOK
+			}
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp AttemptListResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			got := []int{}
+			for _, a := range resp.Attempts {
+				got = append(got, a.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) || resp.Total != tt.total || resp.Limit != tt.limit || resp.Offset != tt.offset {
+				t.Errorf("attempts %v of %d (limit %d, offset %d), want %v of %d (limit %d, offset %d)",
+					got, resp.Total, resp.Limit, resp.Offset, tt.want, tt.total, tt.limit, tt.offset)
+			}
+		})
+	}
+
+	gets := []struct {
+		name   string
+		id     string
+		status int
+	}{
+		{"own attempt", strconv.Itoa(second), http.StatusOK},
+		// Another user's attempt looks the same as a missing one.
+		{"another user's attempt", strconv.Itoa(bobs), http.StatusNotFound},
+		{"missing attempt", strconv.Itoa(bobs + 1), http.StatusNotFound},
+		{"invalid id", "x", http.StatusBadRequest},
+	}
+	for _, tt := range gets {
+		r := env.request(http.MethodGet, "/api/attempts/"+tt.id, nil)
+		r.SetPathValue("id", tt.id)
+		w := httptest.NewRecorder()
+		h.Get(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var a db.Attempt
+		if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil {
+			t.Fatal(err)
+		}
+		if a.ID != second || a.ProblemSlug != "add-two-numbers" {
+			t.Errorf("%s: got attempt %d on %q", tt.name, a.ID, a.ProblemSlug)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/leettomato/quiz/internal/auth"
+)
+
+type AuthHandler struct {
+	sessions *auth.Sessions
+}
+
+func NewAuthHandler(sessions *auth.Sessions) *AuthHandler {
+	return &AuthHandler{sessions: sessions}
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Login checks the credentials and sets a session cookie.
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.sessions.Login(w, r, req.Username, req.Password)
+	var throttled *auth.ThrottledError
+	if errors.As(err, &throttled) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, user)
+}
+
+// Logout ends the current session.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if err := h.sessions.Logout(w, r); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Me returns the authenticated user.
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, auth.UserFromContext(r.Context()))
+}
@@ -1,34 +1,72 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/leettomato/quiz/internal/auth"
 	"github.com/leettomato/quiz/internal/db"
 	"github.com/leettomato/quiz/internal/llm"
+	"github.com/leettomato/quiz/internal/review"
+	"github.com/leettomato/quiz/internal/rubric"
+	"github.com/leettomato/quiz/internal/sandbox"
 )
 
 type GradingHandler struct {
-	db     *db.DB
-	client *llm.Client
+	db         *db.DB
+	client     *llm.Client
+	rubrics    *rubric.Set
+	maxSamples int
+	runner     *sandbox.Runner
 }
 
-func NewGradingHandler(db *db.DB, client *llm.Client) *GradingHandler {
-	return &GradingHandler{db: db, client: client}
+// NewGradingHandler returns a handler that allows up to maxSamples samples
+// per grade, and times code submitted with answers using runner.
+func NewGradingHandler(db *db.DB, client *llm.Client, rubrics *rubric.Set, maxSamples int, runner *sandbox.Runner) *GradingHandler {
+	return &GradingHandler{db: db, client: client, rubrics: rubrics, maxSamples: maxSamples, runner: runner}
 }
 
 type GradeRequest struct {
 	ProblemID int    `json:"problem_id"`
 	Answer    string `json:"answer"`
+	// Rubric names the rubric to grade against; empty means the default.
+	Rubric string `json:"rubric,omitempty"`
+	// NoCache asks for a fresh grade even if this answer was graded before.
+	NoCache bool `json:"no_cache,omitempty"`
+	// Samples above 1 grades that many times and combines the results by
+	// majority vote. Consensus grades are never cached.
+	Samples int `json:"samples,omitempty"`
+	// Code is an optional Python solution to go with the answer. It is
+	// timed on inputs of growing size, and the estimated complexity is
+	// given to the grader.
+	Code string `json:"code,omitempty"`
+}
+
+type RubricsResponse struct {
+	Rubrics []*rubric.Rubric `json:"rubrics"`
+	Default string           `json:"default"`
 }
 
 type GradeResponse struct {
-	ProblemID int               `json:"problem_id"`
-	Result    *llm.GradingResult `json:"result"`
+	ProblemID  int                `json:"problem_id"`
+	AttemptID  int                `json:"attempt_id"`
+	Result     *llm.GradingResult `json:"result"`
+	NextReview string             `json:"next_review,omitempty"`
+	// EvidenceError says why code submitted with the answer could not be
+	// timed, in which case the answer was graded without evidence.
+	EvidenceError string `json:"evidence_error,omitempty"`
 }
 
 func (h *GradingHandler) Smoke(w http.ResponseWriter, r *http.Request) {
-	reply, err := h.client.Ping()
+	reply, err := h.client.Ping(r.Context())
 	if err != nil {
 		writeJSON(w, map[string]any{"ok": false, "error": err.Error()})
 		return
@@ -36,36 +74,249 @@ func (h *GradingHandler) Smoke(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, map[string]any{"ok": true, "model_reply": reply})
 }
 
+// Rubrics lists the rubrics answers can be graded against.
+func (h *GradingHandler) Rubrics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, RubricsResponse{Rubrics: h.rubrics.List(), Default: h.rubrics.DefaultName()})
+}
+
 func (h *GradingHandler) Grade(w http.ResponseWriter, r *http.Request) {
+	req, problem, rb, ok := h.readRequest(w, r)
+	if !ok {
+		return
+	}
+
+	evidence, evidenceErr := h.evidence(r.Context(), problem, req.Code)
+	var result *llm.GradingResult
+	var err error
+	if req.Samples > 1 {
+		result, err = h.client.GradeConsensus(r.Context(), problem, req.Answer, evidence, rb, req.Samples, nil)
+	} else {
+		result, err = h.client.Grade(gradingContext(r, req), problem, req.Answer, evidence, rb)
+	}
+	if err != nil {
+		writeLLMError(w, "grading failed", err)
+		return
+	}
+
+	resp, err := h.record(r, req, result, evidenceErr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+// GradeStream grades like Grade but streams server-sent events: "evidence"
+// with {"state": "measuring"} and then {"state": "done", "summary"} or
+// {"state": "failed", "error"} around timing any submitted code, "progress" as output arrives, "criterion" as
+// each criterion completes (both carry llm.GradeProgress), a final "result"
+// with the GradeResponse, or "error" with {"error", "status"} if grading
+// fails after the stream has started. Consensus grades send "sample" with
+// {"done", "total"} as each sample finishes instead of progress and
+// criteria.
+func (h *GradingHandler) GradeStream(w http.ResponseWriter, r *http.Request) {
+	req, problem, rb, ok := h.readRequest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	send := func(event string, v any) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		rc.Flush()
+	}
+
+	// Timing code can take as long as generating tests for it, so the
+	// client hears about it before the stream goes quiet.
+	var evidence, evidenceErr string
+	if strings.TrimSpace(req.Code) != "" {
+		send("evidence", map[string]string{"state": "measuring"})
+		evidence, evidenceErr = h.evidence(r.Context(), problem, req.Code)
+		if evidenceErr != "" {
+			send("evidence", map[string]string{"state": "failed", "error": evidenceErr})
+		} else {
+			send("evidence", map[string]string{"state": "done", "summary": evidence})
+		}
+	}
+
+	var result *llm.GradingResult
+	var err error
+	if req.Samples > 1 {
+		result, err = h.client.GradeConsensus(r.Context(), problem, req.Answer, evidence, rb, req.Samples, func(done, total int) {
+			send("sample", map[string]int{"done": done, "total": total})
+		})
+	} else {
+		result, err = h.client.GradeStream(gradingContext(r, req), problem, req.Answer, evidence, rb, func(p llm.GradeProgress) {
+			if p.Criterion != "" {
+				send("criterion", p)
+			} else {
+				send("progress", p)
+			}
+		})
+	}
+	if err != nil {
+		send("error", map[string]any{"error": "grading failed: " + err.Error(), "status": llmErrorStatus(err)})
+		return
+	}
+
+	resp, err := h.record(r, req, result, evidenceErr)
+	if err != nil {
+		send("error", map[string]any{"error": err.Error(), "status": http.StatusInternalServerError})
+		return
+	}
+
+	send("result", resp)
+}
+
+// evidence times code submitted with an answer and describes the estimated
+// complexity for the grader. Answers are graded without evidence if there
+// is no code or it cannot be timed; in the latter case evidence also
+// returns why, for the client.
+func (h *GradingHandler) evidence(ctx context.Context, problem *db.Problem, code string) (summary, errMsg string) {
+	if strings.TrimSpace(code) == "" {
+		return "", ""
+	}
+	gen, err := testGenerator(ctx, h.db, h.client, h.runner, problem, false)
+	if err != nil {
+		log.Printf("benchmark problem %d: %v", problem.ID, err)
+		return "", "could not time the code: " + err.Error()
+	}
+	complexity, err := h.runner.Benchmark(ctx, problem, gen, code)
+	if err != nil {
+		log.Printf("benchmark problem %d: %v", problem.ID, err)
+		return "", "could not time the code: " + err.Error()
+	}
+	return complexity.Summary(), ""
+}
+
+// gradingContext returns the request context, set to bypass the grading
+// cache if the request asked for that.
+func gradingContext(r *http.Request, req GradeRequest) context.Context {
+	if req.NoCache {
+		return llm.WithoutCache(r.Context())
+	}
+	return r.Context()
+}
+
+// readRequest decodes and validates a GradeRequest and loads its problem
+// and rubric. It writes an error response and returns false if anything is
+// wrong.
+func (h *GradingHandler) readRequest(w http.ResponseWriter, r *http.Request) (GradeRequest, *db.Problem, *rubric.Rubric, bool) {
 	var req GradeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
-		return
+		return req, nil, nil, false
 	}
 
 	if req.ProblemID == 0 || req.Answer == "" {
 		http.Error(w, "problem_id and answer are required", http.StatusBadRequest)
-		return
+		return req, nil, nil, false
+	}
+	if len(req.Code) > maxCodeLength {
+		http.Error(w, "code is too long", http.StatusRequestEntityTooLarge)
+		return req, nil, nil, false
+	}
+	if req.Samples > h.maxSamples {
+		http.Error(w, fmt.Sprintf("samples must be at most %d", h.maxSamples), http.StatusBadRequest)
+		return req, nil, nil, false
+	}
+
+	rb, ok := h.rubrics.Get(req.Rubric)
+	if !ok {
+		http.Error(w, "unknown rubric", http.StatusBadRequest)
+		return req, nil, nil, false
 	}
 
 	problem, err := h.db.GetProblem(req.ProblemID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return req, nil, nil, false
 	}
 	if problem == nil {
 		http.Error(w, "problem not found", http.StatusNotFound)
-		return
+		return req, nil, nil, false
 	}
 
-	result, err := h.client.Grade(problem, req.Answer)
+	return req, problem, rb, true
+}
+
+// record stores the attempt for the request's user, schedules its review and
+// builds the response.
+func (h *GradingHandler) record(r *http.Request, req GradeRequest, result *llm.GradingResult, evidenceErr string) (GradeResponse, error) {
+	attempt, nextReview, err := recordAttempt(h.db, r, result, req.ProblemID, req.Answer, h.client.Model())
 	if err != nil {
-		http.Error(w, "grading failed: "+err.Error(), http.StatusInternalServerError)
-		return
+		return GradeResponse{}, err
+	}
+
+	return GradeResponse{
+		ProblemID:     req.ProblemID,
+		AttemptID:     attempt.ID,
+		Result:        result,
+		NextReview:    nextReview,
+		EvidenceError: evidenceErr,
+	}, nil
+}
+
+// recordAttempt stores the result as an attempt for the request's user,
+// which claims any hints they used and takes off their penalty, and
+// schedules its review. It returns the attempt and when its review is due.
+func recordAttempt(d *db.DB, r *http.Request, result *llm.GradingResult, problemID int, answer, model string) (*db.Attempt, string, error) {
+	attempt := newAttempt(r, result, problemID, answer, model)
+	if err := d.CreateAttempt(attempt); err != nil {
+		return nil, "", fmt.Errorf("save attempt: %w", err)
+	}
+	result.ApplyHints(attempt)
+	return attempt, scheduleReview(d, attempt), nil
+}
+
+// newAttempt builds the attempt for the request's user from a result.
+func newAttempt(r *http.Request, result *llm.GradingResult, problemID int, answer, model string) *db.Attempt {
+	attempt := result.ToAttempt(problemID, answer, model)
+	attempt.UserID = auth.UserFromContext(r.Context()).ID
+	return attempt
+}
+
+// scheduleReview updates the review schedule for a stored attempt and
+// returns when the review is due. The due date is empty if scheduling
+// failed: the attempt is already stored, so that shouldn't cost the user
+// their grade.
+func scheduleReview(d *db.DB, attempt *db.Attempt) string {
+	state, err := review.Record(d, attempt, time.Now())
+	if err != nil {
+		log.Printf("schedule review for attempt %d: %v", attempt.ID, err)
+		return ""
 	}
+	return state.DueAt
+}
 
-	writeJSON(w, GradeResponse{
-		ProblemID: req.ProblemID,
-		Result:    result,
-	})
+// llmErrorStatus maps an LLM error to the HTTP status returned to the client.
+func llmErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, llm.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, llm.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, llm.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, llm.ErrAuth), errors.Is(err, llm.ErrBadOutput):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// writeLLMError writes err with a status that reflects what went wrong,
+// passing through any Retry-After from a rate-limited upstream.
+func writeLLMError(w http.ResponseWriter, prefix string, err error) {
+	var apiErr *llm.APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+	}
+	http.Error(w, prefix+": "+err.Error(), llmErrorStatus(err))
 }
@@ -0,0 +1,399 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leettomato/quiz/internal/auth"
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/llm"
+	"github.com/leettomato/quiz/internal/llm/llmtest"
+	"github.com/leettomato/quiz/internal/rubric"
+	"github.com/leettomato/quiz/internal/sandbox"
+)
+
+// testEnv is a database with one user and one problem, and a client for a
+// fake LLM.
+type testEnv struct {
+	db      *db.DB
+	fake    *llmtest.Server
+	client  *llm.Client
+	rubrics *rubric.Set
+	user    *db.User
+	problem *db.Problem
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	d, err := db.Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	_, err = d.ImportProblems([]db.ImportQuestion{{
+		Title:       "Two Sum",
+		FrontendID:  json.RawMessage(`"1"`),
+		ProblemSlug: "two-sum",
+		Difficulty:  "Easy",
+		Description: "Return the indices of the two numbers that add up to target.",
+		Hints:       json.RawMessage(`["A brute force approach checks every pair."]`),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	problem, err := d.GetProblemBySlug("two-sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := d.CreateUser("alice", "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := llmtest.NewServer()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	p, err := llm.NewProvider(llm.ProviderOpenAI, srv.URL, "", llm.RetryPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rubrics, err := rubric.LoadSet("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testEnv{db: d, fake: fake, client: llm.NewClient(p, "fake"), rubrics: rubrics, user: user, problem: problem}
+}
+
+// request builds a request from the env's user with a JSON body.
+func (e *testEnv) request(method, path string, body any) *http.Request {
+	data, _ := json.Marshal(body)
+	r := httptest.NewRequest(method, path, strings.NewReader(string(data)))
+	return r.WithContext(auth.WithUser(r.Context(), e.user))
+}
+
+// attempts returns how many attempts are stored.
+func (e *testEnv) attempts(t *testing.T) int {
+	t.Helper()
+	attempts, err := e.db.AttemptsOldestFirst()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(attempts)
+}
+
+func TestLLMErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("chat completion: %w", llm.ErrRateLimited), http.StatusTooManyRequests},
+		{llm.ErrTimeout, http.StatusGatewayTimeout},
+		{fmt.Errorf("chat completion: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{llm.ErrUnavailable, http.StatusServiceUnavailable},
+		{llm.ErrAuth, http.StatusBadGateway},
+		{&llm.GradingOutputError{Attempts: 3}, http.StatusBadGateway},
+		{&llm.APIError{StatusCode: 400}, http.StatusInternalServerError},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := llmErrorStatus(tt.err); got != tt.want {
+			t.Errorf("llmErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWriteLLMErrorRetryAfter(t *testing.T) {
+	tests := []struct {
+		retryAfter time.Duration
+		want       string
+	}{
+		{0, ""},
+		{2 * time.Second, "2"},
+		{1500 * time.Millisecond, "2"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		writeLLMError(w, "grading failed", fmt.Errorf("chat completion: %w", &llm.APIError{StatusCode: 429, RetryAfter: tt.retryAfter}))
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != tt.want {
+			t.Errorf("retry after %v: status %d, Retry-After %q, want 429 and %q", tt.retryAfter, w.Code, w.Header().Get("Retry-After"), tt.want)
+		}
+		if !strings.HasPrefix(w.Body.String(), "grading failed: ") {
+			t.Errorf("body = %q, want the prefix", w.Body.String())
+		}
+	}
+}
+
+func TestGradingHandlerGrade(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       map[string]any
+		replies    []llmtest.Reply
+		timeout    time.Duration
+		status     int
+		retryAfter string
+		requests   int
+	}{
+		{
+			name:     "graded",
+			replies:  []llmtest.Reply{{Scores: map[string]any{"optimal_solution": false}}},
+			status:   http.StatusOK,
+			requests: 1,
+		},
+		{
+			name:     "repaired",
+			replies:  []llmtest.Reply{{Arguments: "{"}},
+			status:   http.StatusOK,
+			requests: 2,
+		},
+		{
+			name:       "rate limited",
+			replies:    []llmtest.Reply{{Status: 429, RetryAfter: 3 * time.Second}},
+			status:     http.StatusTooManyRequests,
+			retryAfter: "3",
+			requests:   1,
+		},
+		{
+			name:     "unavailable",
+			replies:  []llmtest.Reply{{Status: 503}},
+			status:   http.StatusServiceUnavailable,
+			requests: 1,
+		},
+		{
+			name:     "upstream authentication",
+			replies:  []llmtest.Reply{{Status: 401}},
+			status:   http.StatusBadGateway,
+			requests: 1,
+		},
+		{
+			name:     "invalid output",
+			replies:  []llmtest.Reply{{Arguments: "{"}, {Arguments: "{"}, {Arguments: "{"}},
+			status:   http.StatusBadGateway,
+			requests: 3,
+		},
+		{
+			name:     "upstream rejects the request",
+			replies:  []llmtest.Reply{{Status: 400}},
+			status:   http.StatusInternalServerError,
+			requests: 1,
+		},
+		{
+			name:     "timeout",
+			replies:  []llmtest.Reply{{Delay: time.Second}},
+			timeout:  20 * time.Millisecond,
+			status:   http.StatusGatewayTimeout,
+			requests: 1,
+		},
+		{
+			name:   "missing answer",
+			body:   map[string]any{"problem_id": 1},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown rubric",
+			body:   map[string]any{"problem_id": 1, "answer": "Use a hash map.", "rubric": "nope"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown problem",
+			body:   map[string]any{"problem_id": 999, "answer": "Use a hash map."},
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.fake.Enqueue(tt.replies...)
+			h := NewGradingHandler(env.db, env.client, env.rubrics, 1, nil)
+
+			body := tt.body
+			if body == nil {
+				body = map[string]any{"problem_id": env.problem.ID, "answer": "Use a hash map."}
+			}
+			r := env.request(http.MethodPost, "/api/grade", body)
+			if tt.timeout > 0 {
+				ctx, cancel := context.WithTimeout(r.Context(), tt.timeout)
+				defer cancel()
+				r = r.WithContext(ctx)
+			}
+			w := httptest.NewRecorder()
+			h.Grade(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+			if got := len(env.fake.Requests()); got != tt.requests {
+				t.Errorf("%d LLM requests, want %d", got, tt.requests)
+			}
+
+			if tt.status != http.StatusOK {
+				if n := env.attempts(t); n != 0 {
+					t.Errorf("%d attempts stored after an error", n)
+				}
+				return
+			}
+			var resp GradeResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			attempt, err := env.db.GetAttempt(resp.AttemptID)
+			if err != nil || attempt == nil {
+				t.Fatalf("attempt %d not stored: %v", resp.AttemptID, err)
+			}
+			if attempt.UserID != env.user.ID || attempt.Score != resp.Result.Score || resp.NextReview == "" {
+				t.Errorf("attempt = %+v, response = %+v", attempt, resp)
+			}
+		})
+	}
+}
+
+// readEvents parses a server-sent event stream into event names and data.
+func readEvents(t *testing.T, body string) (names []string, data []string) {
+	t.Helper()
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			names = append(names, name)
+		}
+		if d, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			data = append(data, d)
+		}
+	}
+	return names, data
+}
+
+func TestGradingHandlerGradeStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies []llmtest.Reply
+		// last is the final event, and status the status it carries for
+		// errors.
+		last     string
+		status   int
+		criteria int
+		attempts int
+		// code is submitted with the answer, and must be announced with
+		// evidence events before grading starts.
+		code string
+	}{
+		{name: "graded", last: "result", criteria: 4, attempts: 1},
+		{name: "graded with code", last: "result", criteria: 4, attempts: 1, code: "class Solution: pass"},
+		{name: "rate limited", replies: []llmtest.Reply{{Status: 429}}, last: "error", status: http.StatusTooManyRequests},
+		{name: "invalid output", replies: []llmtest.Reply{{Arguments: "{"}, {Arguments: "{"}, {Arguments: "{"}}, last: "error", status: http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.fake.Enqueue(tt.replies...)
+			// The runner has no interpreter, so code cannot be timed, the
+			// answer is graded without evidence and the client is told why.
+			runner := sandbox.NewRunner(filepath.Join(t.TempDir(), "python3"), time.Second, 64, 1)
+			h := NewGradingHandler(env.db, env.client, env.rubrics, 1, runner)
+
+			w := httptest.NewRecorder()
+			h.GradeStream(w, env.request(http.MethodPost, "/api/grade/stream", map[string]any{"problem_id": env.problem.ID, "answer": "Use a hash map.", "code": tt.code}))
+
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+				t.Fatalf("status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+			}
+			names, data := readEvents(t, w.Body.String())
+			if len(names) == 0 || names[len(names)-1] != tt.last {
+				t.Fatalf("events = %v, want them to end with %s", names, tt.last)
+			}
+			if tt.code != "" {
+				var failed struct{ State, Error string }
+				if len(names) < 2 || names[0] != "evidence" || names[1] != "evidence" || data[0] != `{"state":"measuring"}` ||
+					json.Unmarshal([]byte(data[1]), &failed) != nil || failed.State != "failed" || failed.Error == "" {
+					t.Errorf("stream starts with %v %v, want measuring and failed evidence events", names, data)
+				}
+				var resp GradeResponse
+				if tt.last == "result" && (json.Unmarshal([]byte(data[len(data)-1]), &resp) != nil || resp.EvidenceError == "") {
+					t.Errorf("result %s, want an evidence error", data[len(data)-1])
+				}
+			} else if names[0] == "evidence" {
+				t.Error("evidence events without code")
+			}
+			criteria := 0
+			for _, name := range names {
+				if name == "criterion" {
+					criteria++
+				}
+			}
+			if criteria != tt.criteria {
+				t.Errorf("%d criterion events, want %d", criteria, tt.criteria)
+			}
+			if tt.status != 0 {
+				var e struct{ Status int }
+				if err := json.Unmarshal([]byte(data[len(data)-1]), &e); err != nil || e.Status != tt.status {
+					t.Errorf("error event %s, want status %d", data[len(data)-1], tt.status)
+				}
+			}
+			if n := env.attempts(t); n != tt.attempts {
+				t.Errorf("%d attempts stored, want %d", n, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestGradingHandlerClaimsHints(t *testing.T) {
+	env := newTestEnv(t)
+	h := NewGradingHandler(env.db, env.client, env.rubrics, 1, nil)
+	for i, penalty := range []float64{0.1, 0.15} {
+		hint := db.Hint{UserID: env.user.ID, ProblemID: env.problem.ID, Level: i + 1, Source: db.HintStored, Content: "hint", Penalty: penalty}
+		if _, err := env.db.AddHint(&hint); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		hintsUsed int
+		score     float64
+	}{
+		{"pays for pending hints", 2, 3},
+		{"hints are claimed once", 0, 4},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.Grade(w, env.request(http.MethodPost, "/api/grade", map[string]any{"problem_id": env.problem.ID, "answer": "Use a hash map."}))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", tt.name, w.Code, w.Body)
+		}
+		var resp GradeResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		attempt, err := env.db.GetAttempt(resp.AttemptID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Result.HintsUsed != tt.hintsUsed || resp.Result.Score != tt.score ||
+			attempt.HintsUsed != tt.hintsUsed || attempt.Score != tt.score {
+			t.Errorf("%s: result has %d hints and score %v, attempt %d and %v; want %d and %v", tt.name,
+				resp.Result.HintsUsed, resp.Result.Score, attempt.HintsUsed, attempt.Score, tt.hintsUsed, tt.score)
+		}
+	}
+
+	pending, err := env.db.PendingHints(env.user.ID, env.problem.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("%d hints still pending", len(pending))
+	}
+}
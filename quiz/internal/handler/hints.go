@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/leettomato/quiz/internal/auth"
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/llm"
+)
+
+// hintTries is how many times Next works out the next hint if attempts keep
+// claiming the pending hints while it does.
+const hintTries = 3
+
+type HintsHandler struct {
+	db      *db.DB
+	client  *llm.Client
+	penalty float64
+}
+
+// NewHintsHandler returns a handler whose hints each cost penalty, a
+// fraction of the maximum score, off the next attempt.
+func NewHintsHandler(db *db.DB, client *llm.Client, penalty float64) *HintsHandler {
+	return &HintsHandler{db: db, client: client, penalty: penalty}
+}
+
+type HintRequest struct {
+	// Answer is what the user has written so far, so that generated hints
+	// can build on it. It may be empty.
+	Answer string `json:"answer"`
+}
+
+// HintsResponse lists the hints given since the user's last attempt on the
+// problem, how many there can be and what they will cost that attempt.
+type HintsResponse struct {
+	Hints    []db.Hint `json:"hints"`
+	MaxLevel int       `json:"max_level"`
+	Penalty  float64   `json:"penalty"`
+}
+
+// List returns the hints the user has taken on the problem since their
+// last attempt.
+func (h *HintsHandler) List(w http.ResponseWriter, r *http.Request) {
+	problem, ok := h.problem(w, r)
+	if !ok {
+		return
+	}
+
+	hints, err := h.db.PendingHints(auth.UserFromContext(r.Context()).ID, problem.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, hintsResponse(problem, hints))
+}
+
+// Next gives the user the next hint on the problem: the problem's stored
+// hints in order, then generated hints of increasing specificity that take
+// the user's answer so far into account. It returns every pending hint,
+// ending with the new one. If a concurrent request gave the same hint
+// first, that one is returned instead of storing another; if an attempt
+// claimed the pending hints, the next hint is worked out again.
+func (h *HintsHandler) Next(w http.ResponseWriter, r *http.Request) {
+	problem, ok := h.problem(w, r)
+	if !ok {
+		return
+	}
+
+	var req HintRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID := auth.UserFromContext(r.Context()).ID
+	for tries := 1; ; tries++ {
+		hints, err := h.db.PendingHints(userID, problem.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		level := len(hints) + 1
+		if level > maxHintLevel(problem) {
+			http.Error(w, "no more hints for this problem", http.StatusConflict)
+			return
+		}
+
+		hint := db.Hint{UserID: userID, ProblemID: problem.ID, Level: level, Penalty: h.penalty}
+		if level <= len(problem.Hints) {
+			hint.Source = db.HintStored
+			hint.Content = problem.Hints[level-1]
+		} else {
+			kind := llm.HintKinds[level-len(problem.Hints)-1]
+			given := make([]string, len(hints))
+			for i, g := range hints {
+				given[i] = g.Content
+			}
+			content, err := h.client.Hint(r.Context(), problem, kind, given, req.Answer)
+			if err != nil {
+				writeLLMError(w, "hint failed", err)
+				return
+			}
+			hint.Source, hint.Kind, hint.Content = db.HintLLM, kind.Name, content
+		}
+
+		added, err := h.db.AddHint(&hint)
+		if errors.Is(err, db.ErrHintStale) {
+			// An attempt claimed the pending hints meanwhile, so the next
+			// hint is another one.
+			if tries < hintTries {
+				continue
+			}
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !added {
+			// The hint was given while this one was being written;
+			// return the hints up to it.
+			if hints, err = h.db.PendingHints(userID, problem.ID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, hintsResponse(problem, hints[:min(level, len(hints))]))
+			return
+		}
+		writeJSON(w, hintsResponse(problem, append(hints, hint)))
+		return
+	}
+}
+
+// problem loads the problem named in the path, writing an error if there
+// is none.
+func (h *HintsHandler) problem(w http.ResponseWriter, r *http.Request) (*db.Problem, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	problem, err := h.db.GetProblem(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if problem == nil {
+		http.Error(w, "problem not found", http.StatusNotFound)
+		return nil, false
+	}
+	return problem, true
+}
+
+func maxHintLevel(problem *db.Problem) int {
+	return len(problem.Hints) + len(llm.HintKinds)
+}
+
+func hintsResponse(problem *db.Problem, hints []db.Hint) HintsResponse {
+	resp := HintsResponse{Hints: hints, MaxLevel: maxHintLevel(problem)}
+	for _, h := range hints {
+		resp.Penalty += h.Penalty
+	}
+	resp.Penalty = min(resp.Penalty, 1)
+	return resp
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/llm/llmtest"
+)
+
+func TestHintsHandlerNext(t *testing.T) {
+	tests := []struct {
+		name string
+		// given is how many hints the user already has; the problem has
+		// one stored hint.
+		given      int
+		replies    []llmtest.Reply
+		status     int
+		retryAfter string
+		source     string
+		content    string
+		requests   int
+	}{
+		{
+			name:    "stored hint first",
+			status:  http.StatusOK,
+			source:  db.HintStored,
+			content: "A brute force approach checks every pair.",
+		},
+		{
+			name:     "generated hint",
+			given:    1,
+			replies:  []llmtest.Reply{{Content: " Think about what to remember. "}},
+			status:   http.StatusOK,
+			source:   db.HintLLM,
+			content:  "Think about what to remember.",
+			requests: 1,
+		},
+		{
+			name:       "rate limited",
+			given:      1,
+			replies:    []llmtest.Reply{{Status: 429, RetryAfter: 2 * time.Second}},
+			status:     http.StatusTooManyRequests,
+			retryAfter: "2",
+			requests:   1,
+		},
+		{
+			name:     "unavailable",
+			given:    2,
+			replies:  []llmtest.Reply{{Status: 503}},
+			status:   http.StatusServiceUnavailable,
+			requests: 1,
+		},
+		{
+			name:     "empty reply",
+			given:    1,
+			replies:  []llmtest.Reply{{Content: "  "}},
+			status:   http.StatusBadGateway,
+			requests: 1,
+		},
+		{
+			name:   "no more hints",
+			given:  4,
+			status: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.fake.Enqueue(tt.replies...)
+			for i := range tt.given {
+				hint := db.Hint{UserID: env.user.ID, ProblemID: env.problem.ID, Level: i + 1, Source: db.HintStored, Content: "earlier hint"}
+				if _, err := env.db.AddHint(&hint); err != nil {
+					t.Fatal(err)
+				}
+			}
+			h := NewHintsHandler(env.db, env.client, 0.1)
+
+			r := env.request(http.MethodPost, "/api/problems/1/hints", HintRequest{Answer: "Loop over pairs."})
+			r.SetPathValue("id", strconv.Itoa(env.problem.ID))
+			w := httptest.NewRecorder()
+			h.Next(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+			if got := len(env.fake.Requests()); got != tt.requests {
+				t.Errorf("%d LLM requests, want %d", got, tt.requests)
+			}
+
+			pending, err := env.db.PendingHints(env.user.ID, env.problem.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.status != http.StatusOK {
+				if len(pending) != tt.given {
+					t.Errorf("%d hints pending after an error, want %d", len(pending), tt.given)
+				}
+				return
+			}
+
+			var resp HintsResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Hints) != tt.given+1 || len(pending) != tt.given+1 {
+				t.Fatalf("%d hints returned and %d pending, want %d", len(resp.Hints), len(pending), tt.given+1)
+			}
+			last := resp.Hints[len(resp.Hints)-1]
+			if last.Source != tt.source || last.Content != tt.content || last.Level != tt.given+1 || last.Penalty != 0.1 {
+				t.Errorf("new hint = %+v, want level %d from %s: %q", last, tt.given+1, tt.source, tt.content)
+			}
+		})
+	}
+}
+
+func TestHintsHandlerNextClaimedMeanwhile(t *testing.T) {
+	env := newTestEnv(t)
+	given := db.Hint{UserID: env.user.ID, ProblemID: env.problem.ID, Level: 1, Source: db.HintStored, Content: "earlier hint"}
+	if _, err := env.db.AddHint(&given); err != nil {
+		t.Fatal(err)
+	}
+	env.fake.Enqueue(llmtest.Reply{Content: "A level 2 hint.", Delay: 200 * time.Millisecond})
+
+	// An attempt claims the stored hint while the level 2 hint is being
+	// generated, so the next hint is the stored one again.
+	done := make(chan error)
+	go func() {
+		for len(env.fake.Requests()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		done <- env.db.CreateAttempt(&db.Attempt{UserID: env.user.ID, ProblemID: env.problem.ID,
+			Answer: "answer", Rubric: "default", Score: 4, MaxScore: 4})
+	}()
+
+	h := NewHintsHandler(env.db, env.client, 0.1)
+	r := env.request(http.MethodPost, "/api/problems/1/hints", HintRequest{})
+	r.SetPathValue("id", strconv.Itoa(env.problem.ID))
+	w := httptest.NewRecorder()
+	h.Next(w, r)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var resp HintsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	want := "A brute force approach checks every pair."
+	if len(resp.Hints) != 1 || resp.Hints[0].Level != 1 || resp.Hints[0].Content != want {
+		t.Errorf("hints %+v, want the stored hint at level 1", resp.Hints)
+	}
+}
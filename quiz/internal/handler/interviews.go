@@ -0,0 +1,303 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/leettomato/quiz/internal/auth"
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/llm"
+	"github.com/leettomato/quiz/internal/rubric"
+)
+
+// doneMessage stands in for the candidate's last message when they finish
+// an interview without saying anything.
+const doneMessage = "I'm done."
+
+type InterviewsHandler struct {
+	db      *db.DB
+	client  *llm.Client
+	rubrics *rubric.Set
+}
+
+func NewInterviewsHandler(db *db.DB, client *llm.Client, rubrics *rubric.Set) *InterviewsHandler {
+	return &InterviewsHandler{db: db, client: client, rubrics: rubrics}
+}
+
+type CreateInterviewRequest struct {
+	ProblemID int `json:"problem_id"`
+	// Rubric names the rubric to grade against; empty means the default.
+	Rubric string `json:"rubric,omitempty"`
+}
+
+// InterviewResponse is an interview with its transcript and, once graded,
+// its attempt.
+type InterviewResponse struct {
+	Interview *db.Interview         `json:"interview"`
+	Messages  []db.InterviewMessage `json:"messages"`
+	Attempt   *db.Attempt           `json:"attempt,omitempty"`
+}
+
+type InterviewMessageRequest struct {
+	Content string `json:"content"`
+	// Done ends the interview and asks for the grading.
+	Done bool `json:"done,omitempty"`
+}
+
+// InterviewMessageResponse is what one candidate message added: the
+// message, the interviewer's reply or, if the interview ended, the
+// grading.
+type InterviewMessageResponse struct {
+	Messages   []db.InterviewMessage `json:"messages"`
+	Status     string                `json:"status"`
+	AttemptID  int                   `json:"attempt_id,omitempty"`
+	Result     *llm.GradingResult    `json:"result,omitempty"`
+	NextReview string                `json:"next_review,omitempty"`
+}
+
+// Create starts an interview on a problem with the interviewer's opening
+// message.
+func (h *InterviewsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateInterviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ProblemID == 0 {
+		http.Error(w, "problem_id is required", http.StatusBadRequest)
+		return
+	}
+
+	rb, ok := h.rubrics.Get(req.Rubric)
+	if !ok {
+		http.Error(w, "unknown rubric", http.StatusBadRequest)
+		return
+	}
+	problem, err := h.db.GetProblem(req.ProblemID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if problem == nil {
+		http.Error(w, "problem not found", http.StatusNotFound)
+		return
+	}
+
+	iv := &db.Interview{
+		UserID:    auth.UserFromContext(r.Context()).ID,
+		ProblemID: problem.ID,
+		Rubric:    rb.Name,
+	}
+	messages := []db.InterviewMessage{{Role: "assistant", Content: llm.InterviewOpening(problem)}}
+	if err := h.db.CreateInterview(iv, messages); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(InterviewResponse{Interview: iv, Messages: messages})
+}
+
+// Get returns an interview with its transcript and, once graded, the
+// attempt it was graded as.
+func (h *InterviewsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	iv, ok := h.interview(w, r)
+	if !ok {
+		return
+	}
+
+	messages, err := h.db.ListInterviewMessages(iv.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := InterviewResponse{Interview: iv, Messages: messages}
+	if iv.AttemptID != 0 {
+		if resp.Attempt, err = h.db.GetAttempt(iv.AttemptID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// Post sends the candidate's message and returns the interviewer's reply,
+// or the grading if the candidate is done. Nothing is stored if the model
+// fails. A request that finishes the interview claims it before calling the
+// model, so that it is graded, stored and paid for only once.
+func (h *InterviewsHandler) Post(w http.ResponseWriter, r *http.Request) {
+	iv, ok := h.interview(w, r)
+	if !ok {
+		return
+	}
+	if iv.Status != db.InterviewOpen {
+		http.Error(w, "this interview is over", http.StatusConflict)
+		return
+	}
+	if iv.Grading {
+		http.Error(w, "this interview is being graded", http.StatusConflict)
+		return
+	}
+
+	var req InterviewMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	content := strings.TrimSpace(req.Content)
+	if content == "" && req.Done {
+		content = doneMessage
+	}
+	if content == "" {
+		http.Error(w, "content is required", http.StatusBadRequest)
+		return
+	}
+	if len(content) > maxQuestionLength {
+		http.Error(w, "content is too long", http.StatusBadRequest)
+		return
+	}
+
+	if req.Done {
+		claim, claimed, err := h.db.ClaimInterview(iv.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !claimed {
+			http.Error(w, "this interview is over or being graded", http.StatusConflict)
+			return
+		}
+		h.finish(w, r, iv, claim, content)
+		return
+	}
+
+	transcript, err := h.db.ListInterviewMessages(iv.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// A long interview can still be finished, just not continued.
+	if len(transcript)+2 > maxThreadMessages {
+		http.Error(w, "this interview is too long to continue; finish it to be graded", http.StatusConflict)
+		return
+	}
+
+	rb, problem, ok := h.load(w, iv)
+	if !ok {
+		return
+	}
+
+	question := db.InterviewMessage{InterviewID: iv.ID, Role: "user", Content: content}
+	transcript = append(transcript, question)
+	turn, err := h.client.Interview(r.Context(), problem, rb, transcript, false)
+	if err != nil {
+		writeLLMError(w, "interview failed", err)
+		return
+	}
+
+	added := []db.InterviewMessage{question, {InterviewID: iv.ID, Role: "assistant", Content: turn.Reply}}
+	if err := h.db.AddInterviewMessages(added); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, InterviewMessageResponse{Messages: added, Status: db.InterviewOpen})
+}
+
+// finish grades an interview the request has claimed, with the candidate's
+// last message, and stores the attempt. The claim is released if anything
+// fails, so that the candidate can try again.
+func (h *InterviewsHandler) finish(w http.ResponseWriter, r *http.Request, iv *db.Interview, claim, content string) {
+	finished := false
+	defer func() {
+		if finished {
+			return
+		}
+		if err := h.db.ReleaseInterview(iv.ID, claim); err != nil {
+			log.Printf("release interview %d: %v", iv.ID, err)
+		}
+	}()
+
+	rb, problem, ok := h.load(w, iv)
+	if !ok {
+		return
+	}
+	transcript, err := h.db.ListInterviewMessages(iv.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	question := db.InterviewMessage{InterviewID: iv.ID, Role: "user", Content: content}
+	transcript = append(transcript, question)
+	turn, err := h.client.Interview(r.Context(), problem, rb, transcript, true)
+	if err != nil {
+		writeLLMError(w, "interview failed", err)
+		return
+	}
+
+	attempt := newAttempt(r, turn.Result, iv.ProblemID, llm.InterviewAnswer(transcript), h.client.Model())
+	added := []db.InterviewMessage{question}
+	finished, err = h.db.FinishInterview(iv.ID, claim, attempt, added)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !finished {
+		http.Error(w, "this interview is over", http.StatusConflict)
+		return
+	}
+	turn.Result.ApplyHints(attempt)
+
+	writeJSON(w, InterviewMessageResponse{
+		Messages:   added,
+		Status:     db.InterviewGraded,
+		AttemptID:  attempt.ID,
+		Result:     turn.Result,
+		NextReview: scheduleReview(h.db, attempt),
+	})
+}
+
+// load returns the interview's rubric and problem, writing an error if
+// either is gone.
+func (h *InterviewsHandler) load(w http.ResponseWriter, iv *db.Interview) (*rubric.Rubric, *db.Problem, bool) {
+	rb, ok := h.rubrics.Get(iv.Rubric)
+	if !ok {
+		http.Error(w, "the rubric this interview uses is no longer available", http.StatusConflict)
+		return nil, nil, false
+	}
+	problem, err := h.db.GetProblem(iv.ProblemID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if problem == nil {
+		http.Error(w, "problem not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	return rb, problem, true
+}
+
+// interview loads the interview named in the path, writing 404 if it does
+// not exist or belongs to another user.
+func (h *InterviewsHandler) interview(w http.ResponseWriter, r *http.Request) (*db.Interview, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	iv, err := h.db.GetInterview(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if iv == nil || iv.UserID != auth.UserFromContext(r.Context()).ID {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+	return iv, true
+}
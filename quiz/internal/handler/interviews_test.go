@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/llm/llmtest"
+)
+
+func TestInterviewsHandlerPost(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       InterviewMessageRequest
+		replies    []llmtest.Reply
+		status     int
+		retryAfter string
+		// messages is how many messages the transcript has afterwards,
+		// starting from the opening one.
+		messages int
+		graded   bool
+	}{
+		{
+			name:     "interviewer replies",
+			body:     InterviewMessageRequest{Content: "I would use a hash map."},
+			replies:  []llmtest.Reply{{Content: "What is its complexity?"}},
+			status:   http.StatusOK,
+			messages: 3,
+		},
+		{
+			// The model is not offered the grading call until the
+			// candidate is done, so it can only reply.
+			name:     "interviewer tries to grade early",
+			body:     InterviewMessageRequest{Content: "I would use a hash map."},
+			replies:  []llmtest.Reply{{ToolCall: true, Content: "What is its complexity?"}},
+			status:   http.StatusOK,
+			messages: 3,
+		},
+		{
+			name:     "finished and graded",
+			body:     InterviewMessageRequest{Content: "It is O(n).", Done: true},
+			status:   http.StatusOK,
+			messages: 2,
+			graded:   true,
+		},
+		{
+			name:     "finished without a message",
+			body:     InterviewMessageRequest{Done: true},
+			replies:  []llmtest.Reply{{Arguments: "{"}},
+			status:   http.StatusOK,
+			messages: 2,
+			graded:   true,
+		},
+		{
+			name:       "rate limited",
+			body:       InterviewMessageRequest{Content: "I would use a hash map."},
+			replies:    []llmtest.Reply{{Status: 429, RetryAfter: time.Second}},
+			status:     http.StatusTooManyRequests,
+			retryAfter: "1",
+			messages:   1,
+		},
+		{
+			name:     "unavailable",
+			body:     InterviewMessageRequest{Content: "I would use a hash map."},
+			replies:  []llmtest.Reply{{Status: 500}},
+			status:   http.StatusServiceUnavailable,
+			messages: 1,
+		},
+		{
+			name:     "empty reply",
+			body:     InterviewMessageRequest{Content: "I would use a hash map."},
+			replies:  []llmtest.Reply{{Content: " "}},
+			status:   http.StatusBadGateway,
+			messages: 1,
+		},
+		{
+			name:     "grading never valid",
+			body:     InterviewMessageRequest{Done: true},
+			replies:  []llmtest.Reply{{Arguments: "{"}, {Arguments: "{"}, {Arguments: "{"}},
+			status:   http.StatusBadGateway,
+			messages: 1,
+		},
+		{
+			name:     "no content",
+			status:   http.StatusBadRequest,
+			messages: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.fake.Enqueue(tt.replies...)
+			iv := &db.Interview{UserID: env.user.ID, ProblemID: env.problem.ID, Rubric: env.rubrics.DefaultName()}
+			if err := env.db.CreateInterview(iv, []db.InterviewMessage{{Role: "assistant", Content: "Let's begin."}}); err != nil {
+				t.Fatal(err)
+			}
+			h := NewInterviewsHandler(env.db, env.client, env.rubrics)
+
+			r := env.request(http.MethodPost, "/api/interviews/1/messages", tt.body)
+			r.SetPathValue("id", strconv.Itoa(iv.ID))
+			w := httptest.NewRecorder()
+			h.Post(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+
+			transcript, err := env.db.ListInterviewMessages(iv.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(transcript) != tt.messages {
+				t.Errorf("%d messages stored, want %d", len(transcript), tt.messages)
+			}
+			stored, err := env.db.GetInterview(iv.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantStatus := db.InterviewOpen
+			if tt.graded {
+				wantStatus = db.InterviewGraded
+			}
+			if stored.Status != wantStatus || (stored.AttemptID != 0) != tt.graded || stored.Grading {
+				t.Errorf("interview is %s (grading %v) with attempt %d, want %s", stored.Status, stored.Grading,
+					stored.AttemptID, wantStatus)
+			}
+			if n := env.attempts(t); (n == 1) != tt.graded {
+				t.Errorf("%d attempts stored", n)
+			}
+
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp InterviewMessageResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status != wantStatus || (resp.Result != nil) != tt.graded || resp.AttemptID != stored.AttemptID {
+				t.Errorf("response = %+v, want status %s", resp, wantStatus)
+			}
+		})
+	}
+}
+
+func TestInterviewsHandlerPostWhileGrading(t *testing.T) {
+	env := newTestEnv(t)
+	iv := &db.Interview{UserID: env.user.ID, ProblemID: env.problem.ID, Rubric: env.rubrics.DefaultName()}
+	if err := env.db.CreateInterview(iv, []db.InterviewMessage{{Role: "assistant", Content: "Let's begin."}}); err != nil {
+		t.Fatal(err)
+	}
+	// Another request is grading the interview.
+	if _, claimed, err := env.db.ClaimInterview(iv.ID); err != nil || !claimed {
+		t.Fatalf("ClaimInterview = %v, %v", claimed, err)
+	}
+	h := NewInterviewsHandler(env.db, env.client, env.rubrics)
+
+	for _, body := range []InterviewMessageRequest{{Done: true}, {Content: "Wait, one more thing."}} {
+		r := env.request(http.MethodPost, "/api/interviews/1/messages", body)
+		r.SetPathValue("id", strconv.Itoa(iv.ID))
+		w := httptest.NewRecorder()
+		h.Post(w, r)
+
+		if w.Code != http.StatusConflict {
+			t.Errorf("%+v: status = %d, want %d: %s", body, w.Code, http.StatusConflict, w.Body)
+		}
+	}
+	if n := len(env.fake.Requests()); n != 0 {
+		t.Errorf("%d LLM requests made", n)
+	}
+	if n := env.attempts(t); n != 0 {
+		t.Errorf("%d attempts stored", n)
+	}
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/leettomato/quiz/internal/auth"
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/llm"
+	"github.com/leettomato/quiz/internal/rubric"
+)
+
+const (
+	// maxQuestionLength caps a follow-up question, in bytes.
+	maxQuestionLength = 4000
+	// maxThreadMessages caps a conversation, since every question replays
+	// the whole thread to the model.
+	maxThreadMessages = 40
+)
+
+type MessagesHandler struct {
+	db      *db.DB
+	client  *llm.Client
+	rubrics *rubric.Set
+}
+
+func NewMessagesHandler(db *db.DB, client *llm.Client, rubrics *rubric.Set) *MessagesHandler {
+	return &MessagesHandler{db: db, client: client, rubrics: rubrics}
+}
+
+type MessagesResponse struct {
+	Messages []db.AttemptMessage `json:"messages"`
+}
+
+type PostMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// List returns the follow-up conversation about one of the user's attempts.
+func (h *MessagesHandler) List(w http.ResponseWriter, r *http.Request) {
+	attempt, ok := h.attempt(w, r)
+	if !ok {
+		return
+	}
+
+	messages, err := h.db.ListAttemptMessages(attempt.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, MessagesResponse{Messages: messages})
+}
+
+// Post asks the grader a question about the attempt and returns the
+// question and the reply as stored. Nothing is stored if the model fails.
+func (h *MessagesHandler) Post(w http.ResponseWriter, r *http.Request) {
+	attempt, ok := h.attempt(w, r)
+	if !ok {
+		return
+	}
+
+	var req PostMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	question := strings.TrimSpace(req.Content)
+	if question == "" {
+		http.Error(w, "content is required", http.StatusBadRequest)
+		return
+	}
+	if len(question) > maxQuestionLength {
+		http.Error(w, "content is too long", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.db.ListAttemptMessages(attempt.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(history)+2 > maxThreadMessages {
+		http.Error(w, "this conversation is too long to continue", http.StatusConflict)
+		return
+	}
+
+	rb, ok := h.rubrics.Get(attempt.Rubric)
+	if !ok {
+		http.Error(w, "the rubric this attempt was graded with is no longer available", http.StatusConflict)
+		return
+	}
+	problem, err := h.db.GetProblem(attempt.ProblemID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if problem == nil {
+		http.Error(w, "problem not found", http.StatusNotFound)
+		return
+	}
+
+	reply, err := h.client.FollowUp(r.Context(), problem, attempt, rb, history, question)
+	if err != nil {
+		writeLLMError(w, "follow-up failed", err)
+		return
+	}
+
+	added := []db.AttemptMessage{
+		{AttemptID: attempt.ID, Role: "user", Content: question},
+		{AttemptID: attempt.ID, Role: "assistant", Content: reply},
+	}
+	if err := h.db.AddAttemptMessages(added); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, MessagesResponse{Messages: added})
+}
+
+// attempt loads the attempt named in the path, writing 404 if it does not
+// exist or belongs to another user.
+func (h *MessagesHandler) attempt(w http.ResponseWriter, r *http.Request) (*db.Attempt, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	attempt, err := h.db.GetAttempt(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if attempt == nil || attempt.UserID != auth.UserFromContext(r.Context()).ID {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+	return attempt, true
+}
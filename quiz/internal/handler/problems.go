@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/leettomato/quiz/internal/auth"
 	"github.com/leettomato/quiz/internal/db"
 )
 
@@ -28,10 +29,18 @@ func (h *ProblemsHandler) List(w http.ResponseWriter, r *http.Request) {
 		Query:      r.URL.Query().Get("q"),
 		Difficulty: r.URL.Query().Get("difficulty"),
 		Topic:      r.URL.Query().Get("topic"),
+		Sort:       r.URL.Query().Get("sort"),
 		Limit:      50,
 		Offset:     0,
 	}
 
+	switch params.Sort {
+	case "", db.SortNumber, db.SortRelevance:
+	default:
+		http.Error(w, "sort must be number or relevance", http.StatusBadRequest)
+		return
+	}
+
 	if v := r.URL.Query().Get("limit"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
 			params.Limit = n
@@ -57,6 +66,49 @@ func (h *ProblemsHandler) List(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// Random picks a random problem matching the list filters. Set
+// skip_recent_days to avoid problems the user attempted recently and
+// weighted=true to favour topics with their weak past scores.
+func (h *ProblemsHandler) Random(w http.ResponseWriter, r *http.Request) {
+	params := db.RandomParams{
+		ListParams: db.ListParams{
+			Query:      r.URL.Query().Get("q"),
+			Difficulty: r.URL.Query().Get("difficulty"),
+			Topic:      r.URL.Query().Get("topic"),
+		},
+		UserID: auth.UserFromContext(r.Context()).ID,
+	}
+
+	if v := r.URL.Query().Get("skip_recent_days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid skip_recent_days", http.StatusBadRequest)
+			return
+		}
+		params.SkipRecentDays = n
+	}
+	if v := r.URL.Query().Get("weighted"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "invalid weighted", http.StatusBadRequest)
+			return
+		}
+		params.Weighted = b
+	}
+
+	problem, err := h.db.RandomProblem(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if problem == nil {
+		http.Error(w, "no problems match", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, problem)
+}
+
 func (h *ProblemsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
@@ -75,6 +127,9 @@ func (h *ProblemsHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Hints are given out one at a time by the hint endpoint, so that they
+	// count against the next attempt.
+	problem.Hints = nil
 	writeJSON(w, problem)
 }
 
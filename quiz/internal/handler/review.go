@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/leettomato/quiz/internal/auth"
+	"github.com/leettomato/quiz/internal/db"
+)
+
+type ReviewHandler struct {
+	db *db.DB
+}
+
+func NewReviewHandler(db *db.DB) *ReviewHandler {
+	return &ReviewHandler{db: db}
+}
+
+type DueResponse struct {
+	Reviews []db.DueReview `json:"reviews"`
+	Total   int            `json:"total"`
+}
+
+// Due lists the user's problems due for review, most urgent first.
+func (h *ReviewHandler) Due(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+	}
+
+	reviews, total, err := h.db.ListDueReviews(auth.UserFromContext(r.Context()).ID, time.Now(), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, DueResponse{Reviews: reviews, Total: total})
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/llm"
+	"github.com/leettomato/quiz/internal/sandbox"
+)
+
+// maxCodeLength caps the size of code submitted to run.
+const maxCodeLength = 64 << 10
+
+// Generated tests run this many inputs unless asked for a number up to
+// maxTestCount.
+const (
+	defaultTestCount = 100
+	maxTestCount     = 500
+)
+
+type RunHandler struct {
+	db     *db.DB
+	runner *sandbox.Runner
+	client *llm.Client
+}
+
+func NewRunHandler(db *db.DB, runner *sandbox.Runner, client *llm.Client) *RunHandler {
+	return &RunHandler{db: db, runner: runner, client: client}
+}
+
+type RunRequest struct {
+	ProblemID int    `json:"problem_id"`
+	Code      string `json:"code"`
+}
+
+type TestsRequest struct {
+	ProblemID int    `json:"problem_id"`
+	Code      string `json:"code"`
+	// Count is how many inputs to generate; zero means the default.
+	Count int `json:"count,omitempty"`
+	// Seed makes the inputs repeatable; zero picks one at random.
+	Seed int64 `json:"seed,omitempty"`
+	// Regenerate replaces the problem's test generator, e.g. if its
+	// reference solution turns out to be wrong.
+	Regenerate bool `json:"regenerate,omitempty"`
+}
+
+// TestsResponse is the outcome of testing on generated inputs, with the
+// seed that generated them and when and by which model the generator was
+// written.
+type TestsResponse struct {
+	*sandbox.DiffResult
+	Seed               int64  `json:"seed"`
+	GeneratorModel     string `json:"generator_model"`
+	GeneratorCreatedAt string `json:"generator_created_at"`
+}
+
+// Run runs Python code against the problem's examples and reports a
+// verdict on each. Problems that cannot be run, such as design problems,
+// get 422.
+func (h *RunHandler) Run(w http.ResponseWriter, r *http.Request) {
+	var req RunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	problem, ok := h.problem(w, req.ProblemID, req.Code)
+	if !ok {
+		return
+	}
+
+	result, err := h.runner.Run(r.Context(), problem, req.Code)
+	if errors.Is(err, sandbox.ErrUnsupported) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "run failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, result)
+}
+
+// Tests runs Python code and a reference solution on inputs generated for
+// the problem, and reports the smallest inputs on which they disagree. The
+// generator and reference are written by the LLM the first time a problem
+// is tested, checked in the sandbox and stored.
+func (h *RunHandler) Tests(w http.ResponseWriter, r *http.Request) {
+	var req TestsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Count < 0 || req.Count > maxTestCount {
+		http.Error(w, "count must be between 1 and 500, or 0 for the default", http.StatusBadRequest)
+		return
+	}
+	if req.Count == 0 {
+		req.Count = defaultTestCount
+	}
+	if req.Seed == 0 {
+		req.Seed = rand.Int64N(1 << 31)
+	}
+	problem, ok := h.problem(w, req.ProblemID, req.Code)
+	if !ok {
+		return
+	}
+	if _, err := sandbox.ParseSignature(problem.Python3Snippet); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	gen, err := testGenerator(r.Context(), h.db, h.client, h.runner, problem, req.Regenerate)
+	if errors.Is(err, sandbox.ErrUnsupported) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		writeLLMError(w, "test generation failed", err)
+		return
+	}
+
+	result, err := h.runner.Differential(r.Context(), problem, gen, req.Code, req.Count, req.Seed)
+	if errors.Is(err, sandbox.ErrBadGenerator) {
+		// The generator passed its checks when it was stored, so the seed
+		// found a case they missed; regenerating should help.
+		http.Error(w, err.Error()+"; try again with regenerate", http.StatusBadGateway)
+		return
+	}
+	if err != nil {
+		http.Error(w, "run failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, TestsResponse{
+		DiffResult:         result,
+		Seed:               req.Seed,
+		GeneratorModel:     gen.Model,
+		GeneratorCreatedAt: gen.CreatedAt,
+	})
+}
+
+// Benchmark times Python code on generated inputs of growing size and
+// estimates its time complexity.
+func (h *RunHandler) Benchmark(w http.ResponseWriter, r *http.Request) {
+	var req RunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	problem, ok := h.problem(w, req.ProblemID, req.Code)
+	if !ok {
+		return
+	}
+	if _, err := sandbox.ParseSignature(problem.Python3Snippet); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	gen, err := testGenerator(r.Context(), h.db, h.client, h.runner, problem, false)
+	if errors.Is(err, sandbox.ErrUnsupported) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		writeLLMError(w, "test generation failed", err)
+		return
+	}
+
+	complexity, err := h.runner.Benchmark(r.Context(), problem, gen, req.Code)
+	switch {
+	case errors.Is(err, sandbox.ErrUnsupported):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, sandbox.ErrBadGenerator):
+		http.Error(w, err.Error()+"; regenerate the tests", http.StatusBadGateway)
+		return
+	case err != nil:
+		http.Error(w, "run failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, complexity)
+}
+
+// testGenerator returns the problem's stored test generator, asking the
+// LLM for one if it has none or regenerate is set. It returns
+// ErrUnsupported, before asking, if code cannot be run here.
+func testGenerator(ctx context.Context, d *db.DB, client *llm.Client, runner *sandbox.Runner, problem *db.Problem, regenerate bool) (*db.TestGenerator, error) {
+	if err := runner.Check(); err != nil {
+		return nil, err
+	}
+	if !regenerate {
+		gen, err := d.GetTestGenerator(problem.ID)
+		if err != nil || gen != nil {
+			return gen, err
+		}
+	}
+	gen, err := client.GenerateTests(ctx, problem, func(gen *db.TestGenerator) ([]string, error) {
+		return runner.CheckGenerator(ctx, problem, gen)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := d.StoreTestGenerator(gen); err != nil {
+		return nil, err
+	}
+	return gen, nil
+}
+
+// problem validates the code in a request and loads the problem it is
+// for, writing an error if either is missing.
+func (h *RunHandler) problem(w http.ResponseWriter, problemID int, code string) (*db.Problem, bool) {
+	if problemID == 0 || strings.TrimSpace(code) == "" {
+		http.Error(w, "problem_id and code are required", http.StatusBadRequest)
+		return nil, false
+	}
+	if len(code) > maxCodeLength {
+		http.Error(w, "code is too long", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+
+	problem, err := h.db.GetProblem(problemID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if problem == nil {
+		http.Error(w, "problem not found", http.StatusNotFound)
+		return nil, false
+	}
+	return problem, true
+}
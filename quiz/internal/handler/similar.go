@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/similar"
+)
+
+// Similar problems are listed up to this many at a time.
+const (
+	defaultSimilarLimit = 10
+	maxSimilarLimit     = 50
+)
+
+type SimilarHandler struct {
+	db    *db.DB
+	index *similar.Index
+}
+
+func NewSimilarHandler(db *db.DB, index *similar.Index) *SimilarHandler {
+	return &SimilarHandler{db: db, index: index}
+}
+
+// SimilarResponse lists problems like the one asked about, most similar
+// first, and names the embedding model that compared them.
+type SimilarResponse struct {
+	Problems []similar.Result `json:"problems"`
+	Model    string           `json:"model"`
+}
+
+// List returns the problems most similar to the one in the path, filtered
+// by the topic and difficulty query parameters like the problem list.
+func (h *SimilarHandler) List(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	problem, err := h.db.GetProblem(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if problem == nil {
+		http.Error(w, "problem not found", http.StatusNotFound)
+		return
+	}
+
+	params := db.ListParams{
+		Difficulty: r.URL.Query().Get("difficulty"),
+		Topic:      r.URL.Query().Get("topic"),
+	}
+	limit := defaultSimilarLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= maxSimilarLimit {
+			limit = n
+		}
+	}
+
+	results, err := h.index.Similar(r.Context(), problem.ID, params, limit)
+	if err != nil {
+		writeLLMError(w, "finding similar problems failed", err)
+		return
+	}
+	writeJSON(w, SimilarResponse{Problems: results, Model: h.index.Model()})
+}
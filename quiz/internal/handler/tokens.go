@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/leettomato/quiz/internal/auth"
+	"github.com/leettomato/quiz/internal/db"
+)
+
+type TokensHandler struct {
+	db     *db.DB
+	tokens *auth.Tokens
+}
+
+func NewTokensHandler(db *db.DB, tokens *auth.Tokens) *TokensHandler {
+	return &TokensHandler{db: db, tokens: tokens}
+}
+
+type CreateTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresIn is a duration such as "720h" or "90d"; empty never expires.
+	ExpiresIn string `json:"expires_in"`
+}
+
+type CreateTokenResponse struct {
+	// Token is the secret to send as "Authorization: Bearer". It is only
+	// returned here.
+	Token string       `json:"token"`
+	Info  *db.APIToken `json:"info"`
+}
+
+type TokenListResponse struct {
+	Tokens []db.APIToken `json:"tokens"`
+}
+
+// List returns the user's API tokens without their secrets.
+func (h *TokensHandler) List(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.db.ListAPITokens(auth.UserFromContext(r.Context()).ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, TokenListResponse{Tokens: tokens})
+}
+
+// Create issues a new API token for the user.
+func (h *TokensHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	ttl, err := auth.ParseTTL(req.ExpiresIn)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	secret, token, err := h.tokens.Create(auth.UserFromContext(r.Context()).ID, req.Name, req.Scopes, ttl)
+	if errors.Is(err, auth.ErrUnknownScope) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreateTokenResponse{Token: secret, Info: token})
+}
+
+// Revoke deletes one of the user's API tokens.
+func (h *TokensHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.db.DeleteAPIToken(auth.UserFromContext(r.Context()).ID, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/leettomato/quiz/internal/auth"
+	"github.com/leettomato/quiz/internal/budget"
+)
+
+type UsageHandler struct {
+	guard *budget.Guard
+}
+
+func NewUsageHandler(guard *budget.Guard) *UsageHandler {
+	return &UsageHandler{guard: guard}
+}
+
+// Get reports the user's LLM usage this day and month against their budget.
+func (h *UsageHandler) Get(w http.ResponseWriter, r *http.Request) {
+	report, err := h.guard.Report(auth.UserFromContext(r.Context()).ID, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, report)
+}
@@ -0,0 +1,276 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	anthropicVersion   = "2023-06-01"
+	anthropicMaxTokens = 4096
+)
+
+// AnthropicProvider talks to the native Anthropic Messages API. Tool calls
+// map to tool_use content blocks and tool results to tool_result blocks.
+type AnthropicProvider struct {
+	baseURL   string
+	apiKey    string
+	transport *transport
+}
+
+type anthropicRequest struct {
+	Model      string               `json:"model"`
+	MaxTokens  int                  `json:"max_tokens"`
+	System     string               `json:"system,omitempty"`
+	Messages   []anthropicMessage   `json:"messages"`
+	Tools      []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice *anthropicToolChoice `json:"tool_choice,omitempty"`
+	Stream     bool                 `json:"stream,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content []anthropicBlock `json:"content"`
+	Usage   anthropicUsage   `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (u anthropicUsage) toUsage() *Usage {
+	return &Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+// anthropicEvent covers the fields used from every streaming event type.
+type anthropicEvent struct {
+	Type         string         `json:"type"`
+	Index        int            `json:"index"`
+	ContentBlock anthropicBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+	// Message is set on message_start, carrying the input token count.
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	// Usage is set on message_delta, carrying the output token count.
+	Usage anthropicUsage `json:"usage"`
+}
+
+func (p *AnthropicProvider) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	resp, err := p.send(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	var ar anthropicResponse
+	if err := json.Unmarshal(respBody, &ar); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+
+	msg := ChatMessage{Role: "assistant"}
+	for _, b := range ar.Content {
+		switch b.Type {
+		case "text":
+			msg.Content += b.Text
+		case "tool_use":
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:       b.ID,
+				Type:     "function",
+				Function: FunctionCall{Name: b.Name, Arguments: string(b.Input)},
+			})
+		}
+	}
+
+	return &ChatResponse{Choices: []ChatChoice{{Message: msg}}, Usage: ar.Usage.toUsage()}, nil
+}
+
+func (p *AnthropicProvider) ChatCompletionStream(ctx context.Context, req ChatRequest, onDelta func(ChatDelta)) (*ChatResponse, error) {
+	resp, err := p.send(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	msg := ChatMessage{Role: "assistant"}
+	var usage anthropicUsage
+	// Content block index -> tool call index, for tool_use blocks only.
+	toolIndex := make(map[int]int)
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		var ev anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &ev); err != nil {
+			return nil, fmt.Errorf("unmarshal stream event: %w", err)
+		}
+
+		switch ev.Type {
+		case "message_start":
+			usage.InputTokens = ev.Message.Usage.InputTokens
+		case "message_delta":
+			usage.OutputTokens = ev.Usage.OutputTokens
+		case "content_block_start":
+			if ev.ContentBlock.Type == "tool_use" {
+				idx := len(toolIndex)
+				toolIndex[ev.Index] = idx
+				if err := mergeDelta(&msg, ChatDelta{ToolCalls: []ToolCallDelta{{
+					Index:    idx,
+					ID:       ev.ContentBlock.ID,
+					Type:     "function",
+					Function: FunctionCall{Name: ev.ContentBlock.Name},
+				}}}, onDelta); err != nil {
+					return nil, err
+				}
+			}
+		case "content_block_delta":
+			switch ev.Delta.Type {
+			case "text_delta":
+				mergeDelta(&msg, ChatDelta{Content: ev.Delta.Text}, onDelta)
+			case "input_json_delta":
+				if err := mergeDelta(&msg, ChatDelta{ToolCalls: []ToolCallDelta{{
+					Index:    toolIndex[ev.Index],
+					Function: FunctionCall{Arguments: ev.Delta.PartialJSON},
+				}}}, onDelta); err != nil {
+					return nil, err
+				}
+			}
+		case "error":
+			if ev.Error.Type == "overloaded_error" {
+				return nil, fmt.Errorf("%w: %s", ErrUnavailable, ev.Error.Message)
+			}
+			return nil, fmt.Errorf("LLM API error %s: %s", ev.Error.Type, ev.Error.Message)
+		case "message_stop":
+			return &ChatResponse{Choices: []ChatChoice{{Message: msg}}, Usage: usage.toUsage()}, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read stream: %w", err)
+	}
+
+	return &ChatResponse{Choices: []ChatChoice{{Message: msg}}, Usage: usage.toUsage()}, nil
+}
+
+func (p *AnthropicProvider) send(ctx context.Context, req ChatRequest, stream bool) (*response, error) {
+	body, err := json.Marshal(toAnthropicRequest(req, stream))
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	headers := map[string]string{"anthropic-version": anthropicVersion}
+	if p.apiKey != "" {
+		headers["x-api-key"] = p.apiKey
+	}
+
+	return p.transport.post(ctx, p.baseURL+"/messages", headers, body)
+}
+
+// toAnthropicRequest converts an OpenAI-style request. System messages are
+// joined into the system prompt and consecutive messages with the same role
+// are merged, since the Messages API requires alternating turns.
+func toAnthropicRequest(req ChatRequest, stream bool) anthropicRequest {
+	ar := anthropicRequest{
+		Model:     req.Model,
+		MaxTokens: anthropicMaxTokens,
+		Stream:    stream,
+	}
+
+	var system []string
+	for _, m := range req.Messages {
+		var role string
+		var blocks []anthropicBlock
+
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+			continue
+		case "tool":
+			role = "user"
+			blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content})
+		default:
+			role = m.Role
+			if m.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+			}
+		}
+
+		if n := len(ar.Messages); n > 0 && ar.Messages[n-1].Role == role {
+			ar.Messages[n-1].Content = append(ar.Messages[n-1].Content, blocks...)
+		} else {
+			ar.Messages = append(ar.Messages, anthropicMessage{Role: role, Content: blocks})
+		}
+	}
+	ar.System = strings.Join(system, "\n\n")
+
+	for _, t := range req.Tools {
+		ar.Tools = append(ar.Tools, anthropicTool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: t.Function.Parameters,
+		})
+	}
+	if req.ToolChoice != nil {
+		ar.ToolChoice = &anthropicToolChoice{Type: "tool", Name: req.ToolChoice.Function.Name}
+	}
+
+	return ar
+}
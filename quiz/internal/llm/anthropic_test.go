@@ -0,0 +1,211 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordingServer serves every request with status and body, and records
+// the last request's path, headers and body.
+type recordingServer struct {
+	*httptest.Server
+	path   string
+	header http.Header
+	body   []byte
+}
+
+func newRecordingServer(t *testing.T, status int, contentType, body string) *recordingServer {
+	t.Helper()
+	rs := &recordingServer{}
+	rs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs.path = r.URL.Path
+		rs.header = r.Header.Clone()
+		rs.body, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(rs.Close)
+	return rs
+}
+
+// toolConversation has system prompts, a forced tool, and an earlier tool
+// call whose result is followed by another user message.
+var toolConversation = ChatRequest{
+	Model: "model",
+	Messages: []ChatMessage{
+		{Role: "system", Content: "Be strict."},
+		{Role: "system", Content: "Use the tool."},
+		{Role: "user", Content: "Grade this."},
+		{Role: "assistant", Content: "Calling.", ToolCalls: []ToolCall{
+			{ID: "call_1", Type: "function", Function: FunctionCall{Name: "submit", Arguments: `{"score":`}},
+		}},
+		{Role: "tool", ToolCallID: "call_1", Content: "invalid arguments"},
+		{Role: "user", Content: "Try again."},
+	},
+	Tools: []Tool{{Type: "function", Function: ToolFunction{
+		Name:        "submit",
+		Description: "Submit a grade.",
+		Parameters:  map[string]any{"type": "object"},
+	}}},
+	ToolChoice: &ToolChoice{Type: "function", Function: ToolChoiceFunction{Name: "submit"}},
+}
+
+func TestAnthropicChatCompletion(t *testing.T) {
+	srv := newRecordingServer(t, http.StatusOK, "application/json", `{
+		"content": [
+			{"type": "text", "text": "Here is the grade."},
+			{"type": "tool_use", "id": "toolu_1", "name": "submit", "input": {"score": 3}}
+		],
+		"usage": {"input_tokens": 10, "output_tokens": 5}
+	}`)
+	p, err := NewProvider(ProviderAnthropic, srv.URL, "secret", RetryPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := p.ChatCompletion(context.Background(), toolConversation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if srv.path != "/messages" {
+		t.Errorf("path = %q, want /messages", srv.path)
+	}
+	if got := srv.header.Get("x-api-key"); got != "secret" {
+		t.Errorf("x-api-key = %q", got)
+	}
+	if got := srv.header.Get("anthropic-version"); got != anthropicVersion {
+		t.Errorf("anthropic-version = %q", got)
+	}
+
+	var sent map[string]any
+	if err := json.Unmarshal(srv.body, &sent); err != nil {
+		t.Fatal(err)
+	}
+	var want map[string]any
+	json.Unmarshal([]byte(`{
+		"model": "model",
+		"max_tokens": 4096,
+		"system": "Be strict.\n\nUse the tool.",
+		"messages": [
+			{"role": "user", "content": [{"type": "text", "text": "Grade this."}]},
+			{"role": "assistant", "content": [
+				{"type": "text", "text": "Calling."},
+				{"type": "tool_use", "id": "call_1", "name": "submit", "input": {}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "call_1", "content": "invalid arguments"},
+				{"type": "text", "text": "Try again."}
+			]}
+		],
+		"tools": [{"name": "submit", "description": "Submit a grade.", "input_schema": {"type": "object"}}],
+		"tool_choice": {"type": "tool", "name": "submit"}
+	}`), &want)
+	if !reflect.DeepEqual(sent, want) {
+		got, _ := json.MarshalIndent(sent, "", "  ")
+		t.Errorf("request body =\n%s", got)
+	}
+
+	wantMsg := ChatMessage{Role: "assistant", Content: "Here is the grade.", ToolCalls: []ToolCall{
+		{ID: "toolu_1", Type: "function", Function: FunctionCall{Name: "submit", Arguments: `{"score": 3}`}},
+	}}
+	if got := resp.Choices[0].Message; !reflect.DeepEqual(got, wantMsg) {
+		t.Errorf("message = %+v, want %+v", got, wantMsg)
+	}
+	if want := (&Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}); !reflect.DeepEqual(resp.Usage, want) {
+		t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+	}
+}
+
+func TestAnthropicChatCompletionStream(t *testing.T) {
+	event := func(typ, data string) string {
+		return "event: " + typ + "\ndata: " + data + "\n\n"
+	}
+	tests := []struct {
+		name    string
+		body    string
+		want    ChatMessage
+		usage   *Usage
+		deltas  int
+		wantErr error
+		errText string
+	}{
+		{
+			name: "text and tool use",
+			body: event("message_start", `{"type":"message_start","message":{"usage":{"input_tokens":12}}}`) +
+				event("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`) +
+				event("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Grading"}}`) +
+				event("ping", `{"type":"ping"}`) +
+				event("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" now."}}`) +
+				event("content_block_stop", `{"type":"content_block_stop","index":0}`) +
+				event("content_block_start", `{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"submit","input":{}}}`) +
+				event("content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"score\""}}`) +
+				event("content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":": 3}"}}`) +
+				event("content_block_stop", `{"type":"content_block_stop","index":1}`) +
+				event("message_delta", `{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":7}}`) +
+				event("message_stop", `{"type":"message_stop"}`),
+			want: ChatMessage{Role: "assistant", Content: "Grading now.", ToolCalls: []ToolCall{
+				{ID: "toolu_1", Type: "function", Function: FunctionCall{Name: "submit", Arguments: `{"score": 3}`}},
+			}},
+			usage:  &Usage{PromptTokens: 12, CompletionTokens: 7, TotalTokens: 19},
+			deltas: 5,
+		},
+		{
+			name: "overloaded",
+			body: event("message_start", `{"type":"message_start","message":{"usage":{"input_tokens":12}}}`) +
+				event("error", `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`),
+			wantErr: ErrUnavailable,
+			errText: "Overloaded",
+		},
+		{
+			name:    "other error event",
+			body:    event("error", `{"type":"error","error":{"type":"api_error","message":"Internal"}}`),
+			errText: "LLM API error api_error: Internal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newRecordingServer(t, http.StatusOK, "text/event-stream", tt.body)
+			p, err := NewProvider(ProviderAnthropic, srv.URL, "", RetryPolicy{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			deltas := 0
+			resp, err := p.ChatCompletionStream(context.Background(), toolConversation, func(ChatDelta) { deltas++ })
+			if !strings.Contains(string(srv.body), `"stream":true`) {
+				t.Errorf("request did not ask for a stream: %s", srv.body)
+			}
+			if tt.errText != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.errText)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resp.Choices[0].Message; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("message = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(resp.Usage, tt.usage) {
+				t.Errorf("usage = %+v, want %+v", resp.Usage, tt.usage)
+			}
+			if deltas != tt.deltas {
+				t.Errorf("onDelta called %d times, want %d", deltas, tt.deltas)
+			}
+		})
+	}
+}
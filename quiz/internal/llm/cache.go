@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+// GradingCache stores encoded grading results under a content key.
+// *db.DB implements it.
+type GradingCache interface {
+	// CachedGrading returns the result stored under key, or nil if there is
+	// none.
+	CachedGrading(key string) ([]byte, error)
+	StoreGrading(key string, problemID int, model string, result []byte) error
+}
+
+// SetCache makes Grade and GradeStream reuse results for answers they have
+// graded before. Results are keyed on the problem and its text, the
+// normalized answer, the evidence, the model and the system prompt and tool
+// schema, so re-importing a changed problem or editing a rubric or the
+// prompt invalidates them.
+func (c *Client) SetCache(cache GradingCache) {
+	c.cache = cache
+}
+
+type skipCacheKey struct{}
+
+// WithoutCache returns a context under which Grade and GradeStream always
+// ask the model. The fresh result still replaces any cached one.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipCacheKey{}, true)
+}
+
+// gradeCached returns the cached result for req if there is one, and
+// otherwise calls grade and caches what it returns. Either way the result
+// carries evidence, which the answer was graded with.
+func (c *Client) gradeCached(ctx context.Context, problem *db.Problem, answer, evidence string, req ChatRequest, grade func() (*GradingResult, error)) (*GradingResult, error) {
+	withEvidence := func() (*GradingResult, error) {
+		result, err := grade()
+		if result != nil {
+			result.Evidence = evidence
+		}
+		return result, err
+	}
+	if c.cache == nil {
+		return withEvidence()
+	}
+
+	key := gradingCacheKey(problem, answer, evidence, c.model, req)
+	if skip, _ := ctx.Value(skipCacheKey{}).(bool); !skip {
+		if result := c.lookup(key); result != nil {
+			result.Evidence = evidence
+			return result, nil
+		}
+	}
+
+	result, err := withEvidence()
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(result); err == nil {
+		if err := c.cache.StoreGrading(key, problem.ID, c.model, data); err != nil {
+			log.Printf("grading cache: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// lookup returns the cached result under key, or nil on a miss. A broken
+// cache is logged and treated as a miss so that grading still works.
+func (c *Client) lookup(key string) *GradingResult {
+	data, err := c.cache.CachedGrading(key)
+	if err != nil {
+		log.Printf("grading cache: %v", err)
+		return nil
+	}
+	if data == nil {
+		return nil
+	}
+	var result GradingResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		log.Printf("grading cache: decode %s: %v", key, err)
+		return nil
+	}
+	result.Cached = true
+	return &result
+}
+
+// gradingCacheKey hashes everything that determines a grading request: the
+// problem as the model is shown it, the answer, the evidence measured from
+// any code submitted with it, the model, and the prompt version, i.e. the
+// system prompt and tool schema built from the rubric.
+func gradingCacheKey(problem *db.Problem, answer, evidence, model string, req ChatRequest) string {
+	prompt := sha256.New()
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			prompt.Write([]byte(m.Content))
+		}
+	}
+	tools, _ := json.Marshal(req.Tools)
+	prompt.Write(tools)
+
+	problemSum := sha256.Sum256([]byte(buildProblemPrompt(problem)))
+	answerSum := sha256.Sum256([]byte(normalizeAnswer(answer)))
+	evidenceSum := sha256.Sum256([]byte(evidence))
+	key := sha256.Sum256(fmt.Appendf(nil, "%d\x00%x\x00%x\x00%x\x00%s\x00%x",
+		problem.ID, problemSum, answerSum, evidenceSum, model, prompt.Sum(nil)))
+	return hex.EncodeToString(key[:])
+}
+
+// normalizeAnswer removes differences that cannot change a grade: line
+// endings, trailing spaces and leading or trailing blank lines. Indentation
+// is kept, since it is meaningful in code.
+func normalizeAnswer(answer string) string {
+	lines := strings.Split(strings.ReplaceAll(answer, "\r\n", "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " \t\r")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/rubric"
+)
+
+func TestGradingCacheKey(t *testing.T) {
+	problem := &db.Problem{ID: 1, SourceID: "1", Title: "Two Sum", Difficulty: "Easy", Description: "Find two numbers adding up to target."}
+	key := func(p *db.Problem, answer, evidence, model string, rb *rubric.Rubric) string {
+		return gradingCacheKey(p, answer, evidence, model, gradingRequest(p, answer, evidence, rb))
+	}
+	base := key(problem, "Use a hash map.", "", "model", rubric.Default())
+
+	renamed := *problem
+	renamed.Title = "Two Sum II"
+	described := *problem
+	described.Description = "Find the indices of two numbers adding up to target."
+	constrained := *problem
+	constrained.Constraints = []string{"2 <= nums.length <= 10^4"}
+	other := *problem
+	other.ID = 2
+	instructed := *rubric.Default()
+	instructed.Instructions = "Be lenient."
+
+	tests := []struct {
+		name string
+		key  string
+		same bool
+	}{
+		{"same request", key(problem, "Use a hash map.", "", "model", rubric.Default()), true},
+		{"line endings and trailing space", key(problem, "\nUse a hash map. \r\n\n", "", "model", rubric.Default()), true},
+		{"different answer", key(problem, "Use two pointers.", "", "model", rubric.Default()), false},
+		{"different indentation", key(problem, "  Use a hash map.", "", "model", rubric.Default()), false},
+		{"evidence", key(problem, "Use a hash map.", "O(n)", "model", rubric.Default()), false},
+		{"different model", key(problem, "Use a hash map.", "", "other", rubric.Default()), false},
+		{"different rubric", key(problem, "Use a hash map.", "", "model", &instructed), false},
+		{"retitled problem", key(&renamed, "Use a hash map.", "", "model", rubric.Default()), false},
+		{"redescribed problem", key(&described, "Use a hash map.", "", "model", rubric.Default()), false},
+		{"new constraints", key(&constrained, "Use a hash map.", "", "model", rubric.Default()), false},
+		{"different problem", key(&other, "Use a hash map.", "", "model", rubric.Default()), false},
+	}
+	for _, tt := range tests {
+		if same := tt.key == base; same != tt.same {
+			t.Errorf("%s: same key = %v, want %v", tt.name, same, tt.same)
+		}
+	}
+}
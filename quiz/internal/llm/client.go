@@ -1,33 +1,46 @@
 package llm
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
 )
 
+// DefaultGradingAttempts is how many times Grade asks the model for a valid
+// submit_grading call before giving up.
+const DefaultGradingAttempts = 3
+
+// Client grades answers using a Provider and a default model.
 type Client struct {
-	baseURL    string
-	apiKey     string
-	model      string
-	httpClient *http.Client
+	provider           Provider
+	model              string
+	maxGradingAttempts int
+	cache              GradingCache
+	consensusModels    []string
 }
 
-func NewClient(baseURL, apiKey, model string) *Client {
+func NewClient(provider Provider, model string) *Client {
 	return &Client{
-		baseURL:    baseURL,
-		apiKey:     apiKey,
-		model:      model,
-		httpClient: &http.Client{},
+		provider:           provider,
+		model:              model,
+		maxGradingAttempts: DefaultGradingAttempts,
 	}
 }
 
+// SetMaxGradingAttempts sets how many times Grade asks the model for a
+// valid submit_grading call, including the first request.
+func (c *Client) SetMaxGradingAttempts(n int) {
+	c.maxGradingAttempts = max(n, 1)
+}
+
+// Model returns the default model used for requests.
+func (c *Client) Model() string {
+	return c.model
+}
+
 // Ping sends a simple message to verify the LLM connection works.
 // Returns the model's response text or an error.
-func (c *Client) Ping() (string, error) {
-	resp, err := c.ChatCompletion(ChatRequest{
+func (c *Client) Ping(ctx context.Context) (string, error) {
+	resp, err := c.ChatCompletion(ctx, ChatRequest{
 		Messages: []ChatMessage{
 			{Role: "user", Content: "Say hello in exactly one sentence."},
 		},
@@ -41,44 +54,23 @@ func (c *Client) Ping() (string, error) {
 	return resp.Choices[0].Message.Content, nil
 }
 
-func (c *Client) ChatCompletion(req ChatRequest) (*ChatResponse, error) {
+func (c *Client) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
 	if req.Model == "" {
 		req.Model = c.model
 	}
+	resp, err := c.provider.ChatCompletion(ctx, req)
+	reportUsage(ctx, req.Model, resp)
+	return resp, err
+}
 
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequest("POST", c.baseURL+"/chat/completions", bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	if c.apiKey != "" {
-		httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
-	}
-
-	resp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("do request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("LLM API error %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var chatResp ChatResponse
-	if err := json.Unmarshal(respBody, &chatResp); err != nil {
-		return nil, fmt.Errorf("unmarshal response: %w", err)
+// ChatCompletionStream sends req and calls onDelta for every chunk as it
+// arrives. It returns the fully assembled response, in the same shape
+// ChatCompletion would have returned.
+func (c *Client) ChatCompletionStream(ctx context.Context, req ChatRequest, onDelta func(ChatDelta)) (*ChatResponse, error) {
+	if req.Model == "" {
+		req.Model = c.model
 	}
-
-	return &chatResp, nil
+	resp, err := c.provider.ChatCompletionStream(ctx, req, onDelta)
+	reportUsage(ctx, req.Model, resp)
+	return resp, err
 }
@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/rubric"
+)
+
+// SetConsensusModels sets the models GradeConsensus rotates through. Empty
+// means every sample uses the client's default model.
+func (c *Client) SetConsensusModels(models []string) {
+	c.consensusModels = models
+}
+
+// GradeConsensus grades the answer samples times, concurrently, and
+// combines the results by majority vote on each criterion. Samples rotate
+// through the consensus models, so with three models and three samples each
+// model grades once. Ties go to the lower points.
+//
+// Each criterion's comment is taken from a sample that voted with the
+// majority, and the overall feedback from the sample that agrees with the
+// consensus on the most criteria. Samples that fail are left out; an error
+// is returned only if all of them fail. Consensus results are not cached.
+// onSample, if not nil, is called as each sample finishes. evidence is
+// given to every sample, as with Grade.
+func (c *Client) GradeConsensus(ctx context.Context, problem *db.Problem, answer, evidence string, rb *rubric.Rubric, samples int, onSample func(done, total int)) (*GradingResult, error) {
+	samples = max(samples, 1)
+	models := c.consensusModels
+	if len(models) == 0 {
+		models = []string{c.model}
+	}
+
+	results := make([]*GradingResult, samples)
+	errs := make([]error, samples)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	done := 0
+	for i := range samples {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := gradingRequest(problem, answer, evidence, rb)
+			req.Model = models[i%len(models)]
+			results[i], errs[i] = c.gradeWithRepair(req, rb, func(req ChatRequest) (*ChatResponse, error) {
+				return c.ChatCompletion(ctx, req)
+			})
+
+			if onSample != nil {
+				mu.Lock()
+				done++
+				onSample(done, samples)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var ok []*GradingResult
+	var usedModels []string
+	for i, r := range results {
+		if r != nil {
+			ok = append(ok, r)
+			usedModels = append(usedModels, models[i%len(models)])
+		}
+	}
+	if len(ok) == 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	result := combine(rb, ok)
+	result.Models = uniqueStrings(usedModels)
+	result.Evidence = evidence
+	return result, nil
+}
+
+// combine merges sample results by majority vote per criterion.
+func combine(rb *rubric.Rubric, samples []*GradingResult) *GradingResult {
+	out := &GradingResult{Rubric: rb.Name, MaxScore: rb.MaxScore(), Samples: len(samples)}
+
+	for i, crit := range rb.Criteria {
+		votes := make(map[int]int)
+		for _, s := range samples {
+			votes[s.Criteria[i].Points]++
+		}
+		points, n := -1, 0
+		for p, v := range votes {
+			if v > n || (v == n && p < points) {
+				points, n = p, v
+			}
+		}
+
+		cr := CriterionResult{
+			Key:       crit.Key,
+			Label:     crit.Label,
+			Points:    points,
+			MaxPoints: crit.MaxPoints(),
+			Weight:    crit.Weight,
+			Agreement: float64(n) / float64(len(samples)),
+		}
+		for _, s := range samples {
+			if s.Criteria[i].Points == points {
+				cr.Comment = s.Criteria[i].Comment
+				break
+			}
+		}
+		out.Criteria = append(out.Criteria, cr)
+		out.Score += cr.Weighted()
+	}
+
+	best := -1
+	for _, s := range samples {
+		agree := 0
+		for i, cr := range s.Criteria {
+			if cr.Points == out.Criteria[i].Points {
+				agree++
+			}
+		}
+		if agree > best {
+			best, out.OverallFeedback = agree, s.OverallFeedback
+		}
+	}
+	return out
+}
+
+func uniqueStrings(list []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package llm_test
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/leettomato/quiz/internal/llm/llmtest"
+)
+
+func TestGradeConsensus(t *testing.T) {
+	scaled := scaledRubric(t)
+	tests := []struct {
+		name    string
+		samples int
+		replies []llmtest.Reply
+		// points and agreement are by criterion key.
+		points    map[string]int
+		agreement map[string]float64
+		score     float64
+		feedback  string
+		// used is how many samples the result was built from.
+		used    int
+		wantErr bool
+	}{
+		{
+			name:    "majority",
+			samples: 3,
+			replies: []llmtest.Reply{
+				{Scores: map[string]any{"depth": 2, "correct": true}, Feedback: "one"},
+				{Scores: map[string]any{"depth": 2, "correct": false}, Feedback: "two"},
+				{Scores: map[string]any{"depth": 0, "correct": false}, Feedback: "three"},
+			},
+			points:    map[string]int{"depth": 2, "correct": 0},
+			agreement: map[string]float64{"depth": 2.0 / 3, "correct": 2.0 / 3},
+			score:     2,
+			// The second sample agrees with the consensus on both criteria.
+			feedback: "two",
+			used:     3,
+		},
+		{
+			name:    "tie goes to the lower points",
+			samples: 2,
+			replies: []llmtest.Reply{
+				{Scores: map[string]any{"depth": 2, "correct": true}},
+				{Scores: map[string]any{"depth": 1, "correct": true}},
+			},
+			points:    map[string]int{"depth": 1, "correct": 1},
+			agreement: map[string]float64{"depth": 0.5, "correct": 1},
+			score:     2,
+			used:      2,
+		},
+		{
+			name:    "failed samples are left out",
+			samples: 3,
+			replies: []llmtest.Reply{
+				{Status: 500},
+				{Scores: map[string]any{"depth": 0, "correct": false}, Feedback: "poor"},
+				{Scores: map[string]any{"depth": 0, "correct": true}, Feedback: "poor"},
+			},
+			points:    map[string]int{"depth": 0, "correct": 0},
+			agreement: map[string]float64{"depth": 1, "correct": 0.5},
+			score:     0,
+			feedback:  "poor",
+			used:      2,
+		},
+		{
+			name:    "every sample fails",
+			samples: 2,
+			replies: []llmtest.Reply{{Status: 500}, {Status: 500}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := fakeClient(t)
+			fake.Enqueue(tt.replies...)
+
+			var calls atomic.Int32
+			result, err := client.GradeConsensus(context.Background(), twoSum, "Use a hash map.", "", scaled, tt.samples, func(done, total int) {
+				calls.Add(1)
+				if total != tt.samples {
+					t.Errorf("onSample total = %d, want %d", total, tt.samples)
+				}
+			})
+			if int(calls.Load()) != tt.samples {
+				t.Errorf("onSample called %d times, want %d", calls.Load(), tt.samples)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("GradeConsensus succeeded with every sample failing")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			points := make(map[string]int)
+			agreement := make(map[string]float64)
+			for _, cr := range result.Criteria {
+				points[cr.Key], agreement[cr.Key] = cr.Points, cr.Agreement
+			}
+			if !reflect.DeepEqual(points, tt.points) || !reflect.DeepEqual(agreement, tt.agreement) {
+				t.Errorf("points %v agreement %v, want %v %v", points, agreement, tt.points, tt.agreement)
+			}
+			if result.Score != tt.score || result.Samples != tt.used {
+				t.Errorf("score %v from %d samples, want %v from %d", result.Score, result.Samples, tt.score, tt.used)
+			}
+			if tt.feedback != "" && result.OverallFeedback != tt.feedback {
+				t.Errorf("feedback %q, want %q", result.OverallFeedback, tt.feedback)
+			}
+		})
+	}
+}
+
+func TestGradeConsensusModels(t *testing.T) {
+	fake, client := fakeClient(t)
+	client.SetConsensusModels([]string{"a", "b"})
+
+	result, err := client.GradeConsensus(context.Background(), twoSum, "Use a hash map.", "", scaledRubric(t), 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Models, []string{"a", "b"}) {
+		t.Errorf("models %v, want [a b]", result.Models)
+	}
+
+	var models []string
+	for _, req := range fake.Requests() {
+		models = append(models, req.Model)
+	}
+	slices.Sort(models)
+	if !reflect.DeepEqual(models, []string{"a", "a", "b"}) {
+		t.Errorf("requested models %v, want a twice and b once", models)
+	}
+}
@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// EmbeddingRequest asks for a vector for each input text.
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type EmbeddingResponse struct {
+	Data  []EmbeddingData `json:"data"`
+	Model string          `json:"model"`
+	Usage *Usage          `json:"usage,omitempty"`
+}
+
+// EmbeddingData is the vector for the input at Index.
+type EmbeddingData struct {
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// Embedder is implemented by providers with an embeddings endpoint.
+type Embedder interface {
+	Embeddings(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error)
+}
+
+// Embeddings posts req to /embeddings.
+func (p *OpenAIProvider) Embeddings(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+
+	resp, err := p.transport.post(ctx, p.baseURL+"/embeddings", headers, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	var embResp EmbeddingResponse
+	if err := json.Unmarshal(respBody, &embResp); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	return &embResp, nil
+}
+
+// Embed returns a vector for each text from the embedding model, in the
+// same order. The provider must implement Embedder.
+func (c *Client) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	e, ok := c.provider.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("the LLM provider has no embeddings endpoint")
+	}
+	resp, err := e.Embeddings(ctx, EmbeddingRequest{Model: model, Input: texts})
+	if resp != nil && resp.Usage != nil {
+		reportUsage(ctx, model, &ChatResponse{Usage: resp.Usage})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("%w: embedding index %d out of range", ErrBadOutput, d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("%w: no embedding for input %d", ErrBadOutput, i)
+		}
+	}
+	return vectors, nil
+}
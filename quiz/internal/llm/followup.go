@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/rubric"
+)
+
+// followUpInstructions is the result of the replayed grading call. It tells
+// the model that the grading is done and questions follow.
+const followUpInstructions = "Grading recorded. The candidate may now ask questions about their grade. " +
+	"Answer in plain text as their interviewer: refer to the problem, their answer and your grading, " +
+	"explain your reasoning and how they could improve, and stay consistent with the scores unless the " +
+	"candidate shows you misread their answer. Do not call submit_grading again."
+
+// FollowUp answers a question about a graded attempt. It replays the
+// original grading conversation, with the grading call rebuilt from the
+// stored criteria, then the earlier follow-up messages and the question,
+// and returns the model's reply.
+func (c *Client) FollowUp(ctx context.Context, problem *db.Problem, attempt *db.Attempt, rb *rubric.Rubric, history []db.AttemptMessage, question string) (string, error) {
+	req := gradingRequest(problem, attempt.Answer, attempt.Evidence, rb)
+	// The tool stays defined so that providers accept the replayed call,
+	// but the model is no longer forced to call it.
+	req.ToolChoice = nil
+
+	req.Messages = append(req.Messages,
+		ChatMessage{Role: "assistant", ToolCalls: []ToolCall{{
+			ID:       "call_grading",
+			Type:     "function",
+			Function: FunctionCall{Name: gradingToolName, Arguments: gradingArguments(attempt, rb)},
+		}}},
+		ChatMessage{Role: "tool", ToolCallID: "call_grading", Content: followUpInstructions},
+	)
+	for _, m := range history {
+		req.Messages = append(req.Messages, ChatMessage{Role: m.Role, Content: m.Content})
+	}
+	req.Messages = append(req.Messages, ChatMessage{Role: "user", Content: question})
+
+	resp, err := c.ChatCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("chat completion: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("%w: no choices in response", ErrBadOutput)
+	}
+	reply := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if reply == "" {
+		return "", fmt.Errorf("%w: the model replied without text", ErrBadOutput)
+	}
+	return reply, nil
+}
+
+// gradingArguments rebuilds the submit_grading arguments a stored attempt
+// was graded with against rb. Scores are booleans for rb's pass/fail
+// criteria and levels otherwise, as its tool schema requires.
+func gradingArguments(attempt *db.Attempt, rb *rubric.Rubric) string {
+	passFail := make(map[string]bool, len(rb.Criteria))
+	for _, c := range rb.Criteria {
+		passFail[c.Key] = c.PassFail()
+	}
+
+	args := make(map[string]any, len(attempt.Criteria)+1)
+	for _, c := range attempt.Criteria {
+		var score any = c.Points
+		if passFail[c.Key] {
+			score = c.Points > 0
+		}
+		args[c.Key] = map[string]any{"score": score, "comment": c.Comment}
+	}
+	args["overall_feedback"] = attempt.OverallFeedback
+
+	data, _ := json.Marshal(args)
+	return string(data)
+}
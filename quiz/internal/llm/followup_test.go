@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/rubric"
+)
+
+func TestGradingArguments(t *testing.T) {
+	rb, err := rubric.Parse([]byte(`
+name: mixed
+criteria:
+  - key: correct
+    description: Is the approach correct?
+  - key: tested
+    description: Did they test it?
+    scale: [{label: no}, {label: yes}]
+  - key: depth
+    description: How deep is the analysis?
+    scale: [{label: none}, {label: some}, {label: full}]
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempt := &db.Attempt{
+		OverallFeedback: "Good.",
+		Criteria: []db.Criterion{
+			{Key: "correct", Points: 1, MaxPoints: 1, Comment: "Works."},
+			{Key: "tested", Points: 1, MaxPoints: 1, Comment: "Tested."},
+			{Key: "depth", Points: 2, MaxPoints: 2, Comment: "Thorough."},
+		},
+	}
+
+	args := gradingArguments(attempt, rb)
+
+	var got map[string]any
+	if err := json.Unmarshal([]byte(args), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"correct":          map[string]any{"score": true, "comment": "Works."},
+		"tested":           map[string]any{"score": float64(1), "comment": "Tested."},
+		"depth":            map[string]any{"score": float64(2), "comment": "Thorough."},
+		"overall_feedback": "Good.",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("arguments = %s", args)
+	}
+	if problems := validateSchema(gradingTool(rb).Function.Parameters, got, ""); len(problems) > 0 {
+		t.Errorf("replayed call does not match the tool schema: %v", problems)
+	}
+}
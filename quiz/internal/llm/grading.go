@@ -1,81 +1,120 @@
 package llm
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/rubric"
 )
 
-var gradingTool = Tool{
-	Type: "function",
-	Function: ToolFunction{
-		Name:        "submit_grading",
-		Description: "Submit the structured grading result for a candidate's coding interview answer.",
-		Parameters: map[string]any{
-			"type": "object",
+// gradingToolName is the function the model must call with its grades.
+const gradingToolName = "submit_grading"
+
+// gradingTool builds the submit_grading schema for a rubric: one object per
+// criterion with a score and comment, plus overall feedback. Pass/fail
+// criteria are scored with a boolean and scaled ones with an integer level.
+func gradingTool(rb *rubric.Rubric) Tool {
+	properties := make(map[string]any, len(rb.Criteria)+1)
+	required := make([]string, 0, len(rb.Criteria)+1)
+
+	for _, c := range rb.Criteria {
+		score := map[string]any{"type": "boolean", "description": "true if the answer meets this criterion"}
+		if !c.PassFail() {
+			levels := make([]int, len(c.Scale))
+			var desc strings.Builder
+			desc.WriteString("The level the answer reaches:")
+			for i, l := range c.Scale {
+				levels[i] = i
+				fmt.Fprintf(&desc, " %d = %s", i, l.Label)
+				if l.Description != "" {
+					fmt.Fprintf(&desc, " (%s)", l.Description)
+				}
+				if i < len(c.Scale)-1 {
+					desc.WriteString(";")
+				}
+			}
+			score = map[string]any{"type": "integer", "enum": levels, "description": desc.String()}
+		}
+
+		properties[c.Key] = map[string]any{
+			"type":        "object",
+			"description": c.Description,
 			"properties": map[string]any{
-				"pattern_identified": map[string]any{
-					"type":        "object",
-					"description": "Did the candidate identify the correct algorithmic pattern (e.g., hash map, two-pointer, BFS, DP, sliding window)?",
-					"properties": map[string]any{
-						"score":   map[string]any{"type": "boolean", "description": "true if the candidate identified the correct pattern"},
-						"comment": map[string]any{"type": "string", "description": "Brief explanation of what pattern was expected and what the candidate identified"},
-					},
-					"required": []string{"score", "comment"},
-				},
-				"solution_works": map[string]any{
-					"type":        "object",
-					"description": "Would the candidate's described approach produce correct results for all valid inputs?",
-					"properties": map[string]any{
-						"score":   map[string]any{"type": "boolean", "description": "true if the approach would produce correct results"},
-						"comment": map[string]any{"type": "string", "description": "Brief explanation of correctness, noting any edge cases missed"},
-					},
-					"required": []string{"score", "comment"},
-				},
-				"complexity_analysis": map[string]any{
-					"type":        "object",
-					"description": "Did the candidate state correct time AND space complexity for their approach?",
-					"properties": map[string]any{
-						"score":   map[string]any{"type": "boolean", "description": "true if both time and space complexity are correctly stated"},
-						"comment": map[string]any{"type": "string", "description": "Brief explanation of expected vs stated complexity"},
-					},
-					"required": []string{"score", "comment"},
-				},
-				"optimal_solution": map[string]any{
-					"type":        "object",
-					"description": "Is the candidate's solution optimal (best known time complexity for this problem)?",
-					"properties": map[string]any{
-						"score":   map[string]any{"type": "boolean", "description": "true if the solution achieves optimal time complexity"},
-						"comment": map[string]any{"type": "string", "description": "Brief explanation of what optimal looks like and how the candidate's approach compares"},
-					},
-					"required": []string{"score", "comment"},
-				},
-				"overall_feedback": map[string]any{
-					"type":        "string",
-					"description": "2-3 sentence constructive summary of the candidate's answer, highlighting strengths and areas for improvement.",
-				},
+				"score":   score,
+				"comment": map[string]any{"type": "string", "description": "Brief explanation of the score"},
+			},
+			"required": []string{"score", "comment"},
+		}
+		required = append(required, c.Key)
+	}
+
+	properties["overall_feedback"] = map[string]any{
+		"type":        "string",
+		"description": "2-3 sentence constructive summary of the candidate's answer, highlighting strengths and areas for improvement.",
+	}
+	required = append(required, "overall_feedback")
+
+	return Tool{
+		Type: "function",
+		Function: ToolFunction{
+			Name:        gradingToolName,
+			Description: "Submit the structured grading result for a candidate's interview answer.",
+			Parameters: map[string]any{
+				"type":       "object",
+				"properties": properties,
+				"required":   required,
 			},
-			"required": []string{"pattern_identified", "solution_works", "complexity_analysis", "optimal_solution", "overall_feedback"},
 		},
-	},
+	}
 }
 
-func buildSystemPrompt() string {
-	return `You are an expert coding interview grader. You evaluate candidate answers to LeetCode-style problems.
+func buildSystemPrompt(rb *rubric.Rubric) string {
+	var b strings.Builder
+	b.WriteString("You are an expert coding interview grader. You evaluate candidate answers to LeetCode-style problems.\n")
+	if rb.Instructions != "" {
+		b.WriteString("\n" + strings.TrimSpace(rb.Instructions) + "\n")
+	}
 
-The candidate provides a text/pseudocode solution outline — NOT runnable code. Your job is to assess their understanding of the problem, their approach, and their analysis.
+	b.WriteString("\nGrade strictly but fairly against these criteria:\n")
+	writeCriteria(&b, rb)
+
+	b.WriteString("\nYou MUST call the submit_grading function with your assessment.")
+	return b.String()
+}
 
-Grade strictly but fairly:
-- "Pattern identified" means they named or clearly described the correct algorithmic technique (e.g., "use a hash map to store complements" for Two Sum).
-- "Solution works" means their described steps would produce correct output for all valid inputs, including edge cases.
-- "Complexity analysis" requires BOTH time and space complexity to be correctly stated.
-- "Optimal solution" means they achieve the best known time complexity. A correct but suboptimal approach (e.g., O(n²) brute force when O(n) exists) should fail this criterion.
+// writeCriteria lists the rubric's criteria, one per line.
+func writeCriteria(b *strings.Builder, rb *rubric.Rubric) {
+	for _, c := range rb.Criteria {
+		fmt.Fprintf(b, "- %q: %s", c.Label, c.Description)
+		if c.Guidance != "" {
+			b.WriteString(" " + c.Guidance)
+		}
+		if !c.PassFail() {
+			fmt.Fprintf(b, " Score it from 0 to %d on the scale given in the tool schema.", c.MaxPoints())
+		}
+		b.WriteString("\n")
+	}
+}
 
-You MUST call the submit_grading function with your assessment.`
+// buildUserPrompt formats the problem and the answer, followed by evidence
+// from running the candidate's code if there is any.
+func buildUserPrompt(problem *db.Problem, answer, evidence string) string {
+	prompt := buildProblemPrompt(problem) + "---\n\n## Candidate's Answer\n\n" + answer
+	if evidence != "" {
+		prompt += "\n\n---\n\n## Measured Performance\n\n" + evidence +
+			"\nCompare the complexity the candidate states with this measurement when grading their " +
+			"complexity analysis and whether the solution is optimal. Timings are noisy and hide constant " +
+			"factors, so treat them as evidence rather than proof."
+	}
+	return prompt
 }
 
-func buildUserPrompt(problem *db.Problem, answer string) string {
+// buildProblemPrompt formats the problem statement: description, examples,
+// constraints and the function signature.
+func buildProblemPrompt(problem *db.Problem) string {
 	prompt := fmt.Sprintf(`## Problem: %s (#%s) [%s]
 
 ### Description
@@ -104,43 +143,221 @@ func buildUserPrompt(problem *db.Problem, answer string) string {
 		prompt += "### Python3 Function Signature\n```python\n" + problem.Python3Snippet + "\n```\n\n"
 	}
 
-	prompt += "---\n\n## Candidate's Answer\n\n" + answer
-
 	return prompt
 }
 
-// Grade sends the candidate's answer to the LLM for structured grading.
-func (c *Client) Grade(problem *db.Problem, answer string) (*GradingResult, error) {
-	req := ChatRequest{
+func gradingRequest(problem *db.Problem, answer, evidence string, rb *rubric.Rubric) ChatRequest {
+	return ChatRequest{
 		Messages: []ChatMessage{
-			{Role: "system", Content: buildSystemPrompt()},
-			{Role: "user", Content: buildUserPrompt(problem, answer)},
+			{Role: "system", Content: buildSystemPrompt(rb)},
+			{Role: "user", Content: buildUserPrompt(problem, answer, evidence)},
 		},
-		Tools: []Tool{gradingTool},
+		Tools: []Tool{gradingTool(rb)},
 		ToolChoice: &ToolChoice{
 			Type:     "function",
-			Function: ToolChoiceFunction{Name: "submit_grading"},
+			Function: ToolChoiceFunction{Name: gradingToolName},
 		},
 	}
+}
+
+// GradingOutputError is returned when the model fails to produce a valid
+// submit_grading call within the allowed number of attempts.
+type GradingOutputError struct {
+	Attempts int
+	// Problems describes what was wrong with the last attempt.
+	Problems []string
+}
+
+func (e *GradingOutputError) Error() string {
+	return fmt.Sprintf("invalid grading output after %d attempts: %s", e.Attempts, strings.Join(e.Problems, "; "))
+}
+
+func (e *GradingOutputError) Unwrap() error {
+	return ErrBadOutput
+}
 
-	resp, err := c.ChatCompletion(req)
-	if err != nil {
-		return nil, fmt.Errorf("chat completion: %w", err)
+// checkGradingCall extracts the grading result from resp, validating the
+// tool arguments against the rubric's schema. It returns the problems found
+// if the call is missing or invalid.
+func checkGradingCall(resp *ChatResponse, rb *rubric.Rubric) (*GradingResult, []string) {
+	if len(resp.Choices) == 0 {
+		return nil, []string{"no choices in response"}
+	}
+
+	msg := resp.Choices[0].Message
+	if len(msg.ToolCalls) == 0 {
+		return nil, []string{"no submit_grading tool call in response"}
 	}
 
+	call := msg.ToolCalls[0].Function
+	if call.Name != gradingToolName {
+		return nil, []string{fmt.Sprintf("called unknown tool %q, expected %q", call.Name, gradingToolName)}
+	}
+
+	var args any
+	if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+		return nil, []string{fmt.Sprintf("arguments are not valid JSON: %v", err)}
+	}
+	if problems := validateSchema(gradingTool(rb).Function.Parameters, args, ""); len(problems) > 0 {
+		return nil, problems
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(call.Arguments), &raw); err != nil {
+		return nil, []string{fmt.Sprintf("unmarshal grading result: %v", err)}
+	}
+
+	result := &GradingResult{Rubric: rb.Name, MaxScore: rb.MaxScore()}
+	for _, c := range rb.Criteria {
+		cr, err := criterionResult(c, raw[c.Key])
+		if err != nil {
+			return nil, []string{fmt.Sprintf("$.%s: %v", c.Key, err)}
+		}
+		result.Criteria = append(result.Criteria, cr)
+		result.Score += cr.Weighted()
+	}
+	if err := json.Unmarshal(raw["overall_feedback"], &result.OverallFeedback); err != nil {
+		return nil, []string{fmt.Sprintf("$.overall_feedback: %v", err)}
+	}
+	return result, nil
+}
+
+// criterionResult converts the model's {score, comment} object for c, where
+// score is a boolean for pass/fail criteria and a level otherwise.
+func criterionResult(c rubric.Criterion, raw json.RawMessage) (CriterionResult, error) {
+	var v struct {
+		Score   json.RawMessage `json:"score"`
+		Comment string          `json:"comment"`
+	}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return CriterionResult{}, err
+	}
+
+	cr := CriterionResult{
+		Key:       c.Key,
+		Label:     c.Label,
+		MaxPoints: c.MaxPoints(),
+		Weight:    c.Weight,
+		Comment:   v.Comment,
+	}
+	if c.PassFail() {
+		var pass bool
+		if err := json.Unmarshal(v.Score, &pass); err != nil {
+			return CriterionResult{}, fmt.Errorf("score: %w", err)
+		}
+		if pass {
+			cr.Points = 1
+		}
+	} else {
+		var level float64
+		if err := json.Unmarshal(v.Score, &level); err != nil {
+			return CriterionResult{}, fmt.Errorf("score: %w", err)
+		}
+		if level != float64(int(level)) || level < 0 || int(level) > cr.MaxPoints {
+			return CriterionResult{}, fmt.Errorf("score %v is not a level from 0 to %d", level, cr.MaxPoints)
+		}
+		cr.Points = int(level)
+	}
+	return cr, nil
+}
+
+// repairMessages continues the conversation after an invalid response,
+// answering each tool call with the validation problems so the model can
+// correct its arguments.
+func repairMessages(resp *ChatResponse, problems []string) []ChatMessage {
+	feedback := "Your submit_grading call was invalid:\n- " + strings.Join(problems, "\n- ") +
+		"\n\nCall submit_grading again with arguments that match its schema."
+	return feedbackMessages(resp, feedback)
+}
+
+// feedbackMessages continues the conversation after resp with feedback,
+// sent as the result of its first tool call or as a user message if it
+// made none.
+func feedbackMessages(resp *ChatResponse, feedback string) []ChatMessage {
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
+		return []ChatMessage{{Role: "user", Content: feedback}}
 	}
 
 	msg := resp.Choices[0].Message
 	if len(msg.ToolCalls) == 0 {
-		return nil, fmt.Errorf("no tool calls in response (content: %s)", msg.Content)
+		var out []ChatMessage
+		if msg.Content != "" {
+			out = append(out, ChatMessage{Role: "assistant", Content: msg.Content})
+		}
+		return append(out, ChatMessage{Role: "user", Content: feedback})
 	}
 
-	var result GradingResult
-	if err := json.Unmarshal([]byte(msg.ToolCalls[0].Function.Arguments), &result); err != nil {
-		return nil, fmt.Errorf("unmarshal grading result: %w", err)
+	out := []ChatMessage{{Role: "assistant", Content: msg.Content, ToolCalls: msg.ToolCalls}}
+	for i, tc := range msg.ToolCalls {
+		content := feedback
+		if i > 0 {
+			content = "Ignored: only the first tool call is used."
+		}
+		out = append(out, ChatMessage{Role: "tool", ToolCallID: tc.ID, Content: content})
+	}
+	return out
+}
+
+// gradeWithRepair runs req through complete, and while the response is not
+// a valid grading call for rb, feeds the problems back to the model and
+// tries again, up to the client's attempt limit.
+func (c *Client) gradeWithRepair(req ChatRequest, rb *rubric.Rubric, complete func(ChatRequest) (*ChatResponse, error)) (*GradingResult, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := complete(req)
+		if err != nil {
+			return nil, fmt.Errorf("chat completion: %w", err)
+		}
+
+		result, problems := checkGradingCall(resp, rb)
+		if len(problems) == 0 {
+			return result, nil
+		}
+		if attempt >= c.maxGradingAttempts {
+			return nil, &GradingOutputError{Attempts: attempt, Problems: problems}
+		}
+
+		req.Messages = append(req.Messages, repairMessages(resp, problems)...)
 	}
+}
 
-	return &result, nil
+// Grade sends the candidate's answer to the LLM for structured grading
+// against rb, or returns the cached result if the client has a cache.
+// evidence, if not empty, describes what was measured by running code
+// submitted with the answer.
+func (c *Client) Grade(ctx context.Context, problem *db.Problem, answer, evidence string, rb *rubric.Rubric) (*GradingResult, error) {
+	req := gradingRequest(problem, answer, evidence, rb)
+	return c.gradeCached(ctx, problem, answer, evidence, req, func() (*GradingResult, error) {
+		return c.gradeWithRepair(req, rb, func(req ChatRequest) (*ChatResponse, error) {
+			return c.ChatCompletion(ctx, req)
+		})
+	})
+}
+
+// ToAttempt converts a grading result into an attempt ready to be stored.
+// model is the client's model; consensus grades record their own models.
+func (r *GradingResult) ToAttempt(problemID int, answer, model string) *db.Attempt {
+	if r.Samples > 1 {
+		model = fmt.Sprintf("%s (consensus of %d)", strings.Join(r.Models, ", "), r.Samples)
+	}
+	a := &db.Attempt{
+		ProblemID:       problemID,
+		Answer:          answer,
+		Rubric:          r.Rubric,
+		OverallFeedback: r.OverallFeedback,
+		Model:           model,
+		Score:           r.Score,
+		MaxScore:        r.MaxScore,
+		Evidence:        r.Evidence,
+	}
+	for _, c := range r.Criteria {
+		a.Criteria = append(a.Criteria, db.Criterion{
+			Key:       c.Key,
+			Label:     c.Label,
+			Points:    c.Points,
+			MaxPoints: c.MaxPoints,
+			Weight:    c.Weight,
+			Comment:   c.Comment,
+		})
+	}
+	return a
 }
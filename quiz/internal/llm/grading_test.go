@@ -0,0 +1,340 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/llm"
+	"github.com/leettomato/quiz/internal/llm/llmtest"
+	"github.com/leettomato/quiz/internal/rubric"
+)
+
+var twoSum = &db.Problem{ID: 1, SourceID: "1", Title: "Two Sum", Difficulty: "Easy", Description: "Find two numbers adding up to target."}
+
+// fakeClient returns a client for a fake server that retries nothing, so
+// that each scripted reply is one attempt.
+func fakeClient(t *testing.T) (*llmtest.Server, *llm.Client) {
+	t.Helper()
+	fake := llmtest.NewServer()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	p, err := llm.NewProvider(llm.ProviderOpenAI, srv.URL, "", llm.RetryPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, llm.NewClient(p, "fake")
+}
+
+func scaledRubric(t *testing.T) *rubric.Rubric {
+	t.Helper()
+	rb, err := rubric.Parse([]byte(`
+name: scaled
+criteria:
+  - key: depth
+    description: How deep is the analysis?
+    weight: 2
+    scale: [{label: none}, {label: some}, {label: full}]
+  - key: correct
+    description: Is the approach correct?
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rb
+}
+
+// lastFeedback returns the last message in req, which answers the
+// previous attempt.
+func lastFeedback(req llm.ChatRequest) llm.ChatMessage {
+	return req.Messages[len(req.Messages)-1]
+}
+
+func TestGrade(t *testing.T) {
+	scaled := scaledRubric(t)
+	tests := []struct {
+		name    string
+		rubric  *rubric.Rubric
+		replies []llmtest.Reply
+		// points are the expected points by criterion key; criteria not
+		// listed get full marks.
+		points   map[string]int
+		score    float64
+		feedback string
+		requests int
+		// repair, if set, is expected in the feedback sent before the
+		// second attempt, with its role.
+		repair     string
+		repairRole string
+		wantErr    error
+	}{
+		{
+			name:     "full marks",
+			rubric:   rubric.Default(),
+			score:    4,
+			requests: 1,
+		},
+		{
+			name:     "failed criterion and feedback",
+			rubric:   rubric.Default(),
+			replies:  []llmtest.Reply{{Scores: map[string]any{"optimal_solution": false}, Feedback: "Too slow."}},
+			points:   map[string]int{"optimal_solution": 0},
+			score:    3,
+			feedback: "Too slow.",
+			requests: 1,
+		},
+		{
+			name:     "partial level",
+			rubric:   scaled,
+			replies:  []llmtest.Reply{{Scores: map[string]any{"depth": 1}}},
+			points:   map[string]int{"depth": 1},
+			score:    2,
+			requests: 1,
+		},
+		{
+			name:       "repairs malformed JSON",
+			rubric:     rubric.Default(),
+			replies:    []llmtest.Reply{{Arguments: `{"pattern_identified": {"score": tru`}},
+			score:      4,
+			requests:   2,
+			repair:     "arguments are not valid JSON",
+			repairRole: "tool",
+		},
+		{
+			name:       "repairs a wrong score type",
+			rubric:     rubric.Default(),
+			replies:    []llmtest.Reply{{Scores: map[string]any{"solution_works": "yes"}}},
+			score:      4,
+			requests:   2,
+			repair:     "solution_works",
+			repairRole: "tool",
+		},
+		{
+			name:       "repairs a level off the scale",
+			rubric:     scaled,
+			replies:    []llmtest.Reply{{Scores: map[string]any{"depth": 5}}, {Scores: map[string]any{"depth": 0}}},
+			points:     map[string]int{"depth": 0},
+			score:      1,
+			requests:   2,
+			repair:     "depth",
+			repairRole: "tool",
+		},
+		{
+			name:       "repairs a missing tool call",
+			rubric:     rubric.Default(),
+			replies:    []llmtest.Reply{{NoToolCall: true, Content: "Looks good to me."}},
+			score:      4,
+			requests:   2,
+			repair:     "no submit_grading tool call",
+			repairRole: "user",
+		},
+		{
+			name:     "gives up after the last attempt",
+			rubric:   rubric.Default(),
+			replies:  []llmtest.Reply{{Arguments: "{"}, {Arguments: "{"}, {Arguments: "{"}},
+			requests: 3,
+			wantErr:  llm.ErrBadOutput,
+		},
+		{
+			name:     "rate limited",
+			rubric:   rubric.Default(),
+			replies:  []llmtest.Reply{{Status: 429, Error: "slow down"}},
+			requests: 1,
+			wantErr:  llm.ErrRateLimited,
+		},
+		{
+			name:     "authentication",
+			rubric:   rubric.Default(),
+			replies:  []llmtest.Reply{{Status: 401, Error: "bad key"}},
+			requests: 1,
+			wantErr:  llm.ErrAuth,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name
+			if stream {
+				name += " streamed"
+			}
+			t.Run(name, func(t *testing.T) {
+				fake, client := fakeClient(t)
+				fake.Enqueue(tt.replies...)
+
+				var result *llm.GradingResult
+				var err error
+				if stream {
+					result, err = client.GradeStream(context.Background(), twoSum, "Use a hash map.", "", tt.rubric, nil)
+				} else {
+					result, err = client.Grade(context.Background(), twoSum, "Use a hash map.", "", tt.rubric)
+				}
+
+				requests := fake.Requests()
+				if len(requests) != tt.requests {
+					t.Errorf("%d requests, want %d", len(requests), tt.requests)
+				}
+				for _, req := range requests {
+					if req.Stream != stream {
+						t.Errorf("request stream = %v, want %v", req.Stream, stream)
+					}
+				}
+				if tt.repair != "" && len(requests) > 1 {
+					msg := lastFeedback(requests[1])
+					if msg.Role != tt.repairRole || !strings.Contains(msg.Content, tt.repair) {
+						t.Errorf("repair message = %s %q, want %s containing %q", msg.Role, msg.Content, tt.repairRole, tt.repair)
+					}
+				}
+
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("err = %v, want %v", err, tt.wantErr)
+					}
+					var outErr *llm.GradingOutputError
+					if errors.Is(tt.wantErr, llm.ErrBadOutput) && (!errors.As(err, &outErr) || outErr.Attempts != tt.requests) {
+						t.Errorf("err = %v, want a GradingOutputError after %d attempts", err, tt.requests)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if result.Score != tt.score || result.MaxScore != tt.rubric.MaxScore() {
+					t.Errorf("score = %v/%v, want %v/%v", result.Score, result.MaxScore, tt.score, tt.rubric.MaxScore())
+				}
+				if len(result.Criteria) != len(tt.rubric.Criteria) {
+					t.Fatalf("%d criteria, want %d", len(result.Criteria), len(tt.rubric.Criteria))
+				}
+				for i, c := range tt.rubric.Criteria {
+					want, ok := tt.points[c.Key]
+					if !ok {
+						want = c.MaxPoints()
+					}
+					if got := result.Criteria[i]; got.Key != c.Key || got.Points != want {
+						t.Errorf("criterion %d = %s with %d points, want %s with %d", i, got.Key, got.Points, c.Key, want)
+					}
+				}
+				if tt.feedback != "" && result.OverallFeedback != tt.feedback {
+					t.Errorf("feedback = %q, want %q", result.OverallFeedback, tt.feedback)
+				}
+			})
+		}
+	}
+}
+
+func TestGradeEvidence(t *testing.T) {
+	fake, client := fakeClient(t)
+	evidence := "Measured time complexity: O(n^2)\n"
+
+	result, err := client.Grade(context.Background(), twoSum, "Use a hash map.", evidence, rubric.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Evidence != evidence {
+		t.Errorf("evidence = %q, want %q", result.Evidence, evidence)
+	}
+	prompt := fake.Requests()[0].Messages[1].Content
+	if !strings.Contains(prompt, "## Measured Performance") || !strings.Contains(prompt, evidence) {
+		t.Errorf("user prompt does not carry the evidence:\n%s", prompt)
+	}
+}
+
+func TestGradeStreamProgress(t *testing.T) {
+	fake, client := fakeClient(t)
+	// The first attempt is invalid, so criteria stream in again with the
+	// repaired call.
+	fake.Enqueue(llmtest.Reply{Scores: map[string]any{"optimal_solution": "no"}})
+	rb := rubric.Default()
+
+	var received int
+	reported := make(map[string]int)
+	result, err := client.GradeStream(context.Background(), twoSum, "Use a hash map.", "", rb, func(p llm.GradeProgress) {
+		if p.Criterion == "" {
+			received = p.ReceivedChars
+			return
+		}
+		if p.Result == nil || p.Result.Key != p.Criterion {
+			t.Errorf("progress for %s has result %+v", p.Criterion, p.Result)
+		}
+		reported[p.Criterion]++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Valid criteria are reported by both attempts, optimal_solution only
+	// by the repaired one.
+	want := map[string]int{"pattern_identified": 2, "solution_works": 2, "complexity_analysis": 2, "optimal_solution": 1}
+	if !reflect.DeepEqual(reported, want) {
+		t.Errorf("reported %v, want %v", reported, want)
+	}
+	if received == 0 {
+		t.Error("no received_chars progress")
+	}
+	if result.Score != rb.MaxScore() {
+		t.Errorf("score = %v, want %v", result.Score, rb.MaxScore())
+	}
+}
+
+// memoryCache is a GradingCache in a map.
+type memoryCache map[string][]byte
+
+func (m memoryCache) CachedGrading(key string) ([]byte, error) {
+	return m[key], nil
+}
+
+func (m memoryCache) StoreGrading(key string, problemID int, model string, result []byte) error {
+	m[key] = result
+	return nil
+}
+
+func TestGradeCache(t *testing.T) {
+	fake, client := fakeClient(t)
+	client.SetCache(memoryCache{})
+	rb := rubric.Default()
+	ctx := context.Background()
+	// The same problem after an import changed its description.
+	updated := *twoSum
+	updated.Description = "Find the indices of two numbers adding up to target."
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		problem  *db.Problem
+		answer   string
+		evidence string
+		cached   bool
+	}{
+		{"first grading", ctx, twoSum, "Use a hash map.", "O(n)", false},
+		{"same answer", ctx, twoSum, "Use a hash map.  \r\n", "O(n)", true},
+		{"different evidence", ctx, twoSum, "Use a hash map.", "O(n^2)", false},
+		{"no evidence", ctx, twoSum, "Use a hash map.", "", false},
+		{"no evidence again", ctx, twoSum, "Use a hash map.", "", true},
+		{"different answer", ctx, twoSum, "Sort and use two pointers.", "O(n)", false},
+		{"problem updated", ctx, &updated, "Use a hash map.", "O(n)", false},
+		{"problem updated again", ctx, &updated, "Use a hash map.", "O(n)", true},
+		{"cache skipped", llm.WithoutCache(ctx), twoSum, "Use a hash map.", "O(n)", false},
+	}
+
+	requests := 0
+	for _, tt := range tests {
+		result, err := client.Grade(tt.ctx, tt.problem, tt.answer, tt.evidence, rb)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !tt.cached {
+			requests++
+		}
+		if result.Cached != tt.cached || len(fake.Requests()) != requests {
+			t.Errorf("%s: cached = %v after %d requests, want %v after %d", tt.name, result.Cached, len(fake.Requests()), tt.cached, requests)
+		}
+		if result.Evidence != tt.evidence {
+			t.Errorf("%s: evidence = %q, want %q", tt.name, result.Evidence, tt.evidence)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+// HintKind is a level of generated hint. Kinds are given in order, each
+// more specific than the last.
+type HintKind struct {
+	Name        string
+	instruction string
+}
+
+// HintKinds are the generated hints that follow a problem's stored hints.
+var HintKinds = []HintKind{
+	{"pattern", "Nudge the candidate towards the right general pattern or technique (for example, " +
+		"\"think about what you need to remember as you scan the input\"), without naming a specific " +
+		"data structure or algorithm."},
+	{"data_structure", "Name the data structure or algorithm that fits the problem and say briefly " +
+		"why, without explaining how to apply it step by step."},
+	{"key_insight", "State the key insight that makes the efficient solution work, in a sentence or " +
+		"two. Do not write code or a full walkthrough."},
+}
+
+func buildHintPrompt(kind HintKind) string {
+	return "You are a coding interview coach helping a candidate who is stuck on a LeetCode-style problem. " +
+		"Give exactly one hint, in plain text of at most three sentences.\n\n" +
+		kind.instruction + "\n\n" +
+		"Build on the hints already given rather than repeating them. Look at what the candidate has " +
+		"written so far: if they are on the right track, help them take the next step from where they " +
+		"are; if they are heading the wrong way, gently steer them. Never give the complete solution."
+}
+
+func buildHintUserPrompt(problem *db.Problem, given []string, answer string) string {
+	var b strings.Builder
+	b.WriteString(buildProblemPrompt(problem))
+	b.WriteString("---\n\n## Hints Already Given\n\n")
+	if len(given) == 0 {
+		b.WriteString("(none)\n")
+	}
+	for i, h := range given {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, h)
+	}
+	b.WriteString("\n## Candidate's Work So Far\n\n")
+	if strings.TrimSpace(answer) == "" {
+		b.WriteString("(nothing yet)")
+	} else {
+		b.WriteString(answer)
+	}
+	return b.String()
+}
+
+// Hint generates a hint of the given kind, building on the hints already
+// given and the candidate's answer so far, which may be empty.
+func (c *Client) Hint(ctx context.Context, problem *db.Problem, kind HintKind, given []string, answer string) (string, error) {
+	resp, err := c.ChatCompletion(ctx, ChatRequest{
+		Messages: []ChatMessage{
+			{Role: "system", Content: buildHintPrompt(kind)},
+			{Role: "user", Content: buildHintUserPrompt(problem, given, answer)},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("chat completion: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("%w: no choices in response", ErrBadOutput)
+	}
+	hint := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if hint == "" {
+		return "", fmt.Errorf("%w: the model replied without text", ErrBadOutput)
+	}
+	return hint, nil
+}
+
+// ApplyHints copies the hints a claimed when the result was stored as a,
+// and the score after their penalty, into the result.
+func (r *GradingResult) ApplyHints(a *db.Attempt) {
+	r.HintsUsed = a.HintsUsed
+	r.HintPenalty = a.HintPenalty
+	r.Score = a.Score
+}
@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/rubric"
+)
+
+// InterviewTurn is the interviewer's response to the candidate: either a
+// reply, or the grading once the interview is over.
+type InterviewTurn struct {
+	Reply  string
+	Result *GradingResult
+}
+
+// InterviewOpening is the interviewer's first message, which starts every
+// interview without a call to the model.
+func InterviewOpening(problem *db.Problem) string {
+	return fmt.Sprintf("Let's work through %s. Take a moment to read the problem and ask me anything "+
+		"that's unclear. When you're ready, walk me through your approach, and tell me when you're done "+
+		"so I can give you feedback.", problem.Title)
+}
+
+func buildInterviewPrompt(rb *rubric.Rubric) string {
+	var b strings.Builder
+	b.WriteString(`You are an experienced software engineer running a coding interview on a LeetCode-style problem. The candidate explains their solution in text or pseudocode, in conversation with you.
+
+- Answer clarifying questions about the problem, its input and its constraints briefly and accurately, but never give away the algorithm, the key insight or code.
+- If the candidate is stuck, ask a leading question rather than telling them what to do.
+- Once they have an approach, probe it: ask for its time and space complexity, how it handles edge cases (empty input, duplicates, extremes of the constraints), and whether it can be improved.
+- Keep each reply short, usually one or two questions, as a real interviewer would.
+`)
+	if rb.Instructions != "" {
+		b.WriteString("\n" + strings.TrimSpace(rb.Instructions) + "\n")
+	}
+
+	b.WriteString("\nOnce the candidate says they are done, you will be given the submit_grading function to call. " +
+		"Grade their final approach as it emerged over the whole conversation, strictly but fairly, against these criteria:\n")
+	writeCriteria(&b, rb)
+	return b.String()
+}
+
+// interviewRequest builds the conversation so far: the interviewer prompt,
+// the problem, then the transcript. finish offers and forces the grading
+// call; without it the model has no tools and can only reply.
+func interviewRequest(problem *db.Problem, rb *rubric.Rubric, transcript []db.InterviewMessage, finish bool) ChatRequest {
+	req := ChatRequest{
+		Messages: []ChatMessage{
+			{Role: "system", Content: buildInterviewPrompt(rb)},
+			{Role: "user", Content: "This is the problem you are interviewing the candidate on. The conversation with the candidate follows.\n\n" +
+				buildProblemPrompt(problem)},
+		},
+	}
+	for _, m := range transcript {
+		req.Messages = append(req.Messages, ChatMessage{Role: m.Role, Content: m.Content})
+	}
+	if finish {
+		req.Tools = []Tool{gradingTool(rb)}
+		req.ToolChoice = &ToolChoice{Type: "function", Function: ToolChoiceFunction{Name: gradingToolName}}
+	}
+	return req
+}
+
+// Interview continues a mock interview whose transcript ends with the
+// candidate's latest message. The model replies as the interviewer, or, if
+// finish is set because the candidate said they are done, grades the
+// interview. Only the candidate can end an interview: the model is not
+// offered the grading call until then, and any tool call it makes anyway is
+// ignored. A grading call is validated and repaired like Grade's.
+// Interviews are never cached.
+func (c *Client) Interview(ctx context.Context, problem *db.Problem, rb *rubric.Rubric, transcript []db.InterviewMessage, finish bool) (*InterviewTurn, error) {
+	req := interviewRequest(problem, rb, transcript, finish)
+	complete := func(req ChatRequest) (*ChatResponse, error) {
+		return c.ChatCompletion(ctx, req)
+	}
+	if finish {
+		result, err := c.gradeWithRepair(req, rb, complete)
+		if err != nil {
+			return nil, err
+		}
+		return &InterviewTurn{Result: result}, nil
+	}
+
+	resp, err := complete(req)
+	if err != nil {
+		return nil, fmt.Errorf("chat completion: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%w: no choices in response", ErrBadOutput)
+	}
+	reply := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if reply == "" {
+		return nil, fmt.Errorf("%w: the model replied without text", ErrBadOutput)
+	}
+	return &InterviewTurn{Reply: reply}, nil
+}
+
+// InterviewAnswer formats a transcript as the answer of the attempt an
+// interview is graded as.
+func InterviewAnswer(transcript []db.InterviewMessage) string {
+	var b strings.Builder
+	for i, m := range transcript {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		speaker := "Candidate"
+		if m.Role == "assistant" {
+			speaker = "Interviewer"
+		}
+		fmt.Fprintf(&b, "%s: %s", speaker, m.Content)
+	}
+	return b.String()
+}
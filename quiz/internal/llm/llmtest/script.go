@@ -0,0 +1,76 @@
+package llmtest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Script configures a Server from a YAML file, for `quiz fake-llm`:
+//
+//	latency: 200ms
+//	error_rate: 0.1
+//	replies:            # used once each, in order
+//	  - status: 429
+//	    retry_after: 1s
+//	rules:              # first match wins; unmatched requests get full marks
+//	  - match: "nested loops"
+//	    scores: {optimal_solution: false}
+//	    feedback: Too slow.
+type Script struct {
+	Latency       time.Duration `yaml:"latency"`
+	ErrorRate     float64       `yaml:"error_rate"`
+	MalformedRate float64       `yaml:"malformed_rate"`
+	Seed          int64         `yaml:"seed"`
+	Replies       []Reply       `yaml:"replies"`
+	Rules         []ScriptRule  `yaml:"rules"`
+}
+
+// ScriptRule is a Rule as written in a script, with Match as a regular
+// expression.
+type ScriptRule struct {
+	Match string `yaml:"match"`
+	Times int    `yaml:"times"`
+	Reply `yaml:",inline"`
+}
+
+// LoadScript reads a script file. Unknown fields are rejected.
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Script
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Load adds the script's replies, rules and faults to the server.
+func (s *Server) Load(script *Script) error {
+	for i, r := range script.Rules {
+		rule := Rule{Reply: r.Reply, Times: r.Times}
+		if r.Match != "" {
+			re, err := regexp.Compile(r.Match)
+			if err != nil {
+				return fmt.Errorf("rule %d: %w", i+1, err)
+			}
+			rule.Match = re
+		}
+		s.AddRule(rule)
+	}
+	s.Enqueue(script.Replies...)
+	s.SetFaults(Faults{
+		Latency:       script.Latency,
+		ErrorRate:     script.ErrorRate,
+		MalformedRate: script.MalformedRate,
+	}, script.Seed)
+	return nil
+}
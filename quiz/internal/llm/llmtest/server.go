@@ -0,0 +1,447 @@
+// Package llmtest is a fake OpenAI-compatible chat completions server for
+// local development and tests that must not depend on a real model.
+//
+// Every request is answered with a Reply. Scripted replies queued with
+// Enqueue are used first, in order; then the first Rule whose pattern
+// matches the last user message; then a default reply. Requests that force
+// a tool with tool_choice get a call to it with arguments filled in from
+// its JSON schema: booleans are true, integers are the largest enum value,
+// strings are placeholders. For the grading tool that means full marks
+// unless a reply says otherwise. Requests that leave the choice to the
+// model get text, unless the reply sets ToolCall, in which case the first
+// tool is called.
+//
+// Latency, error responses and malformed tool arguments can be scripted
+// per reply or injected at random with Faults.
+//
+// Embedding requests get bag-of-words vectors, so that texts sharing words
+// come out similar.
+package llmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leettomato/quiz/internal/llm"
+)
+
+// Reply describes how the server answers one request.
+type Reply struct {
+	// Delay is waited before answering.
+	Delay time.Duration `yaml:"delay"`
+	// Status, if set and not 200, makes the reply an API error with Error
+	// as its message. RetryAfter sets the Retry-After header.
+	Status     int           `yaml:"status"`
+	Error      string        `yaml:"error"`
+	RetryAfter time.Duration `yaml:"retry_after"`
+	// Content is the assistant's text for requests that are not answered
+	// with a tool call. NoToolCall answers with text even when a tool is
+	// forced; ToolCall calls a tool even when the choice is left to the
+	// model.
+	Content    string `yaml:"content"`
+	NoToolCall bool   `yaml:"no_tool_call"`
+	ToolCall   bool   `yaml:"tool_call"`
+	// Scores overrides the score of grading criteria by key. Values are
+	// sent as given, so a wrong type can be used to provoke validation
+	// errors.
+	Scores map[string]any `yaml:"scores"`
+	// Feedback overrides the overall_feedback argument.
+	Feedback string `yaml:"feedback"`
+	// Arguments, if set, are sent verbatim as the tool call arguments, e.g.
+	// to return malformed JSON.
+	Arguments string `yaml:"arguments"`
+}
+
+// Rule answers requests whose last user message matches Match.
+type Rule struct {
+	Match *regexp.Regexp
+	Reply Reply
+	// Times limits how many requests the rule answers; 0 means no limit.
+	Times int
+
+	used int
+}
+
+// Faults are injected at random into every reply.
+type Faults struct {
+	// Latency is added to every reply.
+	Latency time.Duration
+	// ErrorRate is the fraction of requests answered with a 503.
+	ErrorRate float64
+	// MalformedRate is the fraction of tool calls whose arguments are cut
+	// off halfway.
+	MalformedRate float64
+}
+
+// Server is the fake endpoint. It serves POST requests to any path ending
+// in /chat/completions or /embeddings, so it works with base URLs with or
+// without /v1.
+type Server struct {
+	mu       sync.Mutex
+	script   []Reply
+	rules    []*Rule
+	faults   Faults
+	rand     *rand.Rand
+	requests []llm.ChatRequest
+}
+
+// NewServer returns a server that answers everything with the default
+// reply until given a script or rules.
+func NewServer() *Server {
+	return &Server{rand: rand.New(rand.NewSource(1))}
+}
+
+// Enqueue adds replies to be used, once each, before any rule.
+func (s *Server) Enqueue(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, replies...)
+}
+
+// AddRule adds a rule, tried after the rules added before it.
+func (s *Server) AddRule(r Rule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, &r)
+}
+
+// SetFaults injects faults at random, seeded so that a run can be repeated.
+func (s *Server) SetFaults(f Faults, seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = f
+	s.rand = rand.New(rand.NewSource(seed))
+}
+
+// Requests returns every request received so far.
+func (s *Server) Requests() []llm.ChatRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]llm.ChatRequest(nil), s.requests...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/embeddings") {
+		serveEmbeddings(w, r)
+		return
+	}
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		writeError(w, http.StatusNotFound, "not_found_error", "only POST .../chat/completions and .../embeddings are served")
+		return
+	}
+
+	var req llm.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON: "+err.Error())
+		return
+	}
+
+	reply, truncate := s.next(req)
+	if reply.Delay > 0 {
+		select {
+		case <-time.After(reply.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if reply.Status != 0 && reply.Status != http.StatusOK {
+		if reply.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(reply.RetryAfter.Seconds())))
+		}
+		msg := reply.Error
+		if msg == "" {
+			msg = http.StatusText(reply.Status)
+		}
+		writeError(w, reply.Status, "api_error", msg)
+		return
+	}
+
+	msg := replyMessage(req, reply)
+	if truncate {
+		for i := range msg.ToolCalls {
+			args := msg.ToolCalls[i].Function.Arguments
+			msg.ToolCalls[i].Function.Arguments = args[:len(args)/2]
+		}
+	}
+	usage := estimateUsage(req, msg)
+
+	if req.Stream {
+		streamReply(w, msg, req.StreamOptions != nil && req.StreamOptions.IncludeUsage, usage)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(llm.ChatResponse{
+		Choices: []llm.ChatChoice{{Message: msg}},
+		Usage:   usage,
+	})
+}
+
+// next records req and picks its reply, applying random faults. It reports
+// whether tool arguments should be cut off.
+func (s *Server) next(req llm.ChatRequest) (Reply, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+
+	var reply Reply
+	if len(s.script) > 0 {
+		reply, s.script = s.script[0], s.script[1:]
+	} else {
+		text := lastUserMessage(req)
+		for _, rule := range s.rules {
+			if rule.Match != nil && !rule.Match.MatchString(text) {
+				continue
+			}
+			if rule.Times > 0 && rule.used >= rule.Times {
+				continue
+			}
+			rule.used++
+			reply = rule.Reply
+			break
+		}
+	}
+
+	reply.Delay += s.faults.Latency
+	if s.faults.ErrorRate > 0 && s.rand.Float64() < s.faults.ErrorRate && reply.Status == 0 {
+		reply.Status = http.StatusServiceUnavailable
+		reply.Error = "injected fault: service unavailable"
+	}
+	truncate := s.faults.MalformedRate > 0 && s.rand.Float64() < s.faults.MalformedRate
+	return reply, truncate
+}
+
+func lastUserMessage(req llm.ChatRequest) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			return req.Messages[i].Content
+		}
+	}
+	return ""
+}
+
+// replyMessage builds the assistant message for req: a tool call if the
+// request forces one or the reply asks for one, otherwise text.
+func replyMessage(req llm.ChatRequest, reply Reply) llm.ChatMessage {
+	tool, ok := chosenTool(req, reply.ToolCall)
+	if !ok || reply.NoToolCall {
+		content := reply.Content
+		if content == "" {
+			content = "Hello from the fake LLM."
+		}
+		return llm.ChatMessage{Role: "assistant", Content: content}
+	}
+
+	args := reply.Arguments
+	if args == "" {
+		value, _ := fill(tool.Function.Parameters, "").(map[string]any)
+		for key, score := range reply.Scores {
+			if criterion, ok := value[key].(map[string]any); ok {
+				criterion["score"] = score
+			}
+		}
+		if reply.Feedback != "" {
+			value["overall_feedback"] = reply.Feedback
+		}
+		data, _ := json.Marshal(value)
+		args = string(data)
+	}
+
+	return llm.ChatMessage{
+		Role: "assistant",
+		ToolCalls: []llm.ToolCall{{
+			ID:       "call_fake",
+			Type:     "function",
+			Function: llm.FunctionCall{Name: tool.Function.Name, Arguments: args},
+		}},
+	}
+}
+
+// chosenTool returns the tool named by tool_choice. Without tool_choice it
+// returns the first tool if auto is set, and false otherwise.
+func chosenTool(req llm.ChatRequest, auto bool) (llm.Tool, bool) {
+	if len(req.Tools) == 0 {
+		return llm.Tool{}, false
+	}
+	if req.ToolChoice != nil {
+		for _, t := range req.Tools {
+			if t.Function.Name == req.ToolChoice.Function.Name {
+				return t, true
+			}
+		}
+		return req.Tools[0], true
+	}
+	return req.Tools[0], auto
+}
+
+// fill returns a value that satisfies schema, preferring the best score
+// where there is a choice.
+func fill(schema map[string]any, name string) any {
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		best := enum[0]
+		for _, v := range enum {
+			if n, ok := v.(float64); ok {
+				if b, ok := best.(float64); !ok || n > b {
+					best = v
+				}
+			}
+		}
+		return best
+	}
+
+	switch schema["type"] {
+	case "object":
+		out := make(map[string]any)
+		props, _ := schema["properties"].(map[string]any)
+		for key, p := range props {
+			if sub, ok := p.(map[string]any); ok {
+				out[key] = fill(sub, key)
+			}
+		}
+		return out
+	case "array":
+		items, _ := schema["items"].(map[string]any)
+		if items == nil {
+			return []any{}
+		}
+		return []any{fill(items, name)}
+	case "boolean":
+		return true
+	case "integer":
+		return max(1, intValue(schema["minimum"]))
+	case "number":
+		return 1.0
+	}
+	if name == "" {
+		return "fake"
+	}
+	return "Fake " + strings.ReplaceAll(name, "_", " ") + "."
+}
+
+func intValue(v any) int {
+	n, _ := v.(float64)
+	return int(n)
+}
+
+// estimateUsage counts roughly four characters per token, so that usage
+// accounting has something to record.
+func estimateUsage(req llm.ChatRequest, msg llm.ChatMessage) *llm.Usage {
+	prompt := 0
+	for _, m := range req.Messages {
+		prompt += len(m.Content)
+	}
+	completion := len(msg.Content)
+	for _, tc := range msg.ToolCalls {
+		completion += len(tc.Function.Arguments)
+	}
+	u := &llm.Usage{PromptTokens: prompt/4 + 1, CompletionTokens: completion/4 + 1}
+	u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	return u
+}
+
+// streamReply sends msg as server-sent events, splitting content and tool
+// arguments into small chunks the way real servers do.
+func streamReply(w http.ResponseWriter, msg llm.ChatMessage, includeUsage bool, usage *llm.Usage) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	rc := http.NewResponseController(w)
+
+	send := func(chunk llm.ChatStreamChunk) {
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		rc.Flush()
+	}
+	delta := func(d llm.ChatDelta) {
+		send(llm.ChatStreamChunk{Choices: []llm.ChatStreamChoice{{Delta: d}}})
+	}
+
+	delta(llm.ChatDelta{Role: "assistant"})
+	for _, part := range chunks(msg.Content, 16) {
+		delta(llm.ChatDelta{Content: part})
+	}
+	for i, tc := range msg.ToolCalls {
+		delta(llm.ChatDelta{ToolCalls: []llm.ToolCallDelta{{
+			Index: i, ID: tc.ID, Type: tc.Type,
+			Function: llm.FunctionCall{Name: tc.Function.Name},
+		}}})
+		for _, part := range chunks(tc.Function.Arguments, 32) {
+			delta(llm.ChatDelta{ToolCalls: []llm.ToolCallDelta{{
+				Index: i, Function: llm.FunctionCall{Arguments: part},
+			}}})
+		}
+	}
+	if includeUsage {
+		send(llm.ChatStreamChunk{Choices: []llm.ChatStreamChoice{}, Usage: usage})
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	rc.Flush()
+}
+
+func chunks(s string, size int) []string {
+	var out []string
+	for len(s) > size {
+		out = append(out, s[:size])
+		s = s[size:]
+	}
+	if s != "" {
+		out = append(out, s)
+	}
+	return out
+}
+
+// writeError writes an error in the OpenAI format.
+func writeError(w http.ResponseWriter, status int, typ, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]string{"type": typ, "message": message},
+	})
+}
+
+// embeddingDims is the length of fake embeddings.
+const embeddingDims = 64
+
+// serveEmbeddings answers with each input's words hashed into a vector of
+// unit length. Faults and scripts do not apply.
+func serveEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req llm.EmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON: "+err.Error())
+		return
+	}
+
+	resp := llm.EmbeddingResponse{Model: req.Model, Usage: &llm.Usage{}}
+	for i, text := range req.Input {
+		v := make([]float32, embeddingDims)
+		words := strings.Fields(strings.ToLower(text))
+		for _, word := range words {
+			h := fnv.New32a()
+			h.Write([]byte(strings.Trim(word, ".,;:!?()\"'")))
+			v[h.Sum32()%embeddingDims]++
+		}
+		var norm float64
+		for _, x := range v {
+			norm += float64(x * x)
+		}
+		if norm > 0 {
+			for j := range v {
+				v[j] /= float32(math.Sqrt(norm))
+			}
+		}
+		resp.Data = append(resp.Data, llm.EmbeddingData{Index: i, Embedding: v})
+		resp.Usage.PromptTokens += len(words)
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,181 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// OllamaProvider talks to Ollama's native /api/chat endpoint. Ollama does
+// not support forcing a tool choice, so graders rely on the system prompt.
+type OllamaProvider struct {
+	baseURL   string
+	transport *transport
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []Tool          `json:"tools,omitempty"`
+	Stream   bool            `json:"stream"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaResponse struct {
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error"`
+	// Token counts, set once done.
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+func (r *ollamaResponse) usage() *Usage {
+	return &Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+func (p *OllamaProvider) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	resp, err := p.send(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	var or ollamaResponse
+	if err := json.Unmarshal(respBody, &or); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	if or.Error != "" {
+		return nil, fmt.Errorf("LLM API error: %s", or.Error)
+	}
+
+	msg := ChatMessage{Role: "assistant", Content: or.Message.Content}
+	for i, tc := range or.Message.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+			ID:       fmt.Sprintf("call_%d", i),
+			Type:     "function",
+			Function: FunctionCall{Name: tc.Function.Name, Arguments: string(tc.Function.Arguments)},
+		})
+	}
+
+	return &ChatResponse{Choices: []ChatChoice{{Message: msg}}, Usage: or.usage()}, nil
+}
+
+// ChatCompletionStream reads Ollama's newline-delimited JSON stream. Ollama
+// sends each tool call whole rather than as argument fragments.
+func (p *OllamaProvider) ChatCompletionStream(ctx context.Context, req ChatRequest, onDelta func(ChatDelta)) (*ChatResponse, error) {
+	resp, err := p.send(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	msg := ChatMessage{Role: "assistant"}
+	var usage *Usage
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return nil, fmt.Errorf("unmarshal stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("LLM API error: %s", chunk.Error)
+		}
+
+		delta := ChatDelta{Content: chunk.Message.Content}
+		for _, tc := range chunk.Message.ToolCalls {
+			idx := len(msg.ToolCalls) + len(delta.ToolCalls)
+			delta.ToolCalls = append(delta.ToolCalls, ToolCallDelta{
+				Index:    idx,
+				ID:       fmt.Sprintf("call_%d", idx),
+				Type:     "function",
+				Function: FunctionCall{Name: tc.Function.Name, Arguments: string(tc.Function.Arguments)},
+			})
+		}
+		if delta.Content != "" || len(delta.ToolCalls) > 0 {
+			if err := mergeDelta(&msg, delta, onDelta); err != nil {
+				return nil, err
+			}
+		}
+
+		if chunk.Done {
+			usage = chunk.usage()
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read stream: %w", err)
+	}
+
+	return &ChatResponse{Choices: []ChatChoice{{Message: msg}}, Usage: usage}, nil
+}
+
+func (p *OllamaProvider) send(ctx context.Context, req ChatRequest, stream bool) (*response, error) {
+	body, err := json.Marshal(toOllamaRequest(req, stream))
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	return p.transport.post(ctx, p.baseURL+"/api/chat", nil, body)
+}
+
+// toOllamaRequest converts an OpenAI-style request. Tool call arguments
+// become JSON objects, and tool results are tagged with the name of the tool
+// whose call they answer.
+func toOllamaRequest(req ChatRequest, stream bool) ollamaRequest {
+	or := ollamaRequest{Model: req.Model, Tools: req.Tools, Stream: stream}
+
+	toolNames := make(map[string]string)
+	for _, m := range req.Messages {
+		om := ollamaMessage{Role: m.Role, Content: m.Content}
+		for _, tc := range m.ToolCalls {
+			toolNames[tc.ID] = tc.Function.Name
+
+			var call ollamaToolCall
+			call.Function.Name = tc.Function.Name
+			call.Function.Arguments = json.RawMessage(tc.Function.Arguments)
+			if !json.Valid(call.Function.Arguments) {
+				call.Function.Arguments = json.RawMessage("{}")
+			}
+			om.ToolCalls = append(om.ToolCalls, call)
+		}
+		if m.Role == "tool" {
+			om.ToolName = toolNames[m.ToolCallID]
+		}
+		or.Messages = append(or.Messages, om)
+	}
+
+	return or
+}
@@ -0,0 +1,151 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOllamaChatCompletion(t *testing.T) {
+	srv := newRecordingServer(t, http.StatusOK, "application/json", `{
+		"message": {
+			"role": "assistant",
+			"content": "",
+			"tool_calls": [{"function": {"name": "submit", "arguments": {"score": 3}}}]
+		},
+		"done": true,
+		"prompt_eval_count": 20,
+		"eval_count": 4
+	}`)
+	p, err := NewProvider(ProviderOllama, srv.URL, "", RetryPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := p.ChatCompletion(context.Background(), toolConversation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if srv.path != "/api/chat" {
+		t.Errorf("path = %q, want /api/chat", srv.path)
+	}
+	var sent map[string]any
+	if err := json.Unmarshal(srv.body, &sent); err != nil {
+		t.Fatal(err)
+	}
+	var want map[string]any
+	json.Unmarshal([]byte(`{
+		"model": "model",
+		"stream": false,
+		"messages": [
+			{"role": "system", "content": "Be strict."},
+			{"role": "system", "content": "Use the tool."},
+			{"role": "user", "content": "Grade this."},
+			{"role": "assistant", "content": "Calling.", "tool_calls": [{"function": {"name": "submit", "arguments": {}}}]},
+			{"role": "tool", "content": "invalid arguments", "tool_name": "submit"},
+			{"role": "user", "content": "Try again."}
+		],
+		"tools": [{"type": "function", "function": {"name": "submit", "description": "Submit a grade.", "parameters": {"type": "object"}}}]
+	}`), &want)
+	if !reflect.DeepEqual(sent, want) {
+		got, _ := json.MarshalIndent(sent, "", "  ")
+		t.Errorf("request body =\n%s", got)
+	}
+
+	wantMsg := ChatMessage{Role: "assistant", ToolCalls: []ToolCall{
+		{ID: "call_0", Type: "function", Function: FunctionCall{Name: "submit", Arguments: `{"score": 3}`}},
+	}}
+	if got := resp.Choices[0].Message; !reflect.DeepEqual(got, wantMsg) {
+		t.Errorf("message = %+v, want %+v", got, wantMsg)
+	}
+	if want := (&Usage{PromptTokens: 20, CompletionTokens: 4, TotalTokens: 24}); !reflect.DeepEqual(resp.Usage, want) {
+		t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+	}
+}
+
+func TestOllamaChatCompletionError(t *testing.T) {
+	srv := newRecordingServer(t, http.StatusOK, "application/json", `{"error": "model \"model\" not found"}`)
+	p, err := NewProvider(ProviderOllama, srv.URL, "", RetryPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.ChatCompletion(context.Background(), toolConversation)
+	if err == nil || !strings.Contains(err.Error(), `model "model" not found`) {
+		t.Errorf("err = %v, want the error from the body", err)
+	}
+}
+
+func TestOllamaChatCompletionStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    ChatMessage
+		usage   *Usage
+		deltas  int
+		errText string
+	}{
+		{
+			name: "content and whole tool calls",
+			body: `{"message":{"role":"assistant","content":"Grad"},"done":false}` + "\n" +
+				`{"message":{"role":"assistant","content":"ing."},"done":false}` + "\n\n" +
+				`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"submit","arguments":{"score":3}}},{"function":{"name":"note","arguments":{}}}]},"done":false}` + "\n" +
+				`{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":8,"eval_count":2}` + "\n" +
+				`{"message":{"role":"assistant","content":"after done"},"done":false}` + "\n",
+			want: ChatMessage{Role: "assistant", Content: "Grading.", ToolCalls: []ToolCall{
+				{ID: "call_0", Type: "function", Function: FunctionCall{Name: "submit", Arguments: `{"score":3}`}},
+				{ID: "call_1", Type: "function", Function: FunctionCall{Name: "note", Arguments: `{}`}},
+			}},
+			usage:  &Usage{PromptTokens: 8, CompletionTokens: 2, TotalTokens: 10},
+			deltas: 3,
+		},
+		{
+			name: "error mid-stream",
+			body: `{"message":{"role":"assistant","content":"Grad"},"done":false}` + "\n" +
+				`{"error":"out of memory"}` + "\n",
+			errText: "LLM API error: out of memory",
+		},
+		{
+			name:    "truncated line",
+			body:    `{"message":{"role":"assistant","content":"Gr`,
+			errText: "unmarshal stream chunk",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newRecordingServer(t, http.StatusOK, "application/x-ndjson", tt.body)
+			p, err := NewProvider(ProviderOllama, srv.URL, "", RetryPolicy{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			deltas := 0
+			resp, err := p.ChatCompletionStream(context.Background(), toolConversation, func(ChatDelta) { deltas++ })
+			if !strings.Contains(string(srv.body), `"stream":true`) {
+				t.Errorf("request did not ask for a stream: %s", srv.body)
+			}
+			if tt.errText != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.errText)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resp.Choices[0].Message; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("message = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(resp.Usage, tt.usage) {
+				t.Errorf("usage = %+v, want %+v", resp.Usage, tt.usage)
+			}
+			if deltas != tt.deltas {
+				t.Errorf("onDelta called %d times, want %d", deltas, tt.deltas)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// OpenAIProvider talks to an OpenAI-compatible /chat/completions endpoint,
+// such as a LiteLLM proxy.
+type OpenAIProvider struct {
+	baseURL   string
+	apiKey    string
+	transport *transport
+}
+
+func (p *OpenAIProvider) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	resp, err := p.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	var chatResp ChatResponse
+	if err := json.Unmarshal(respBody, &chatResp); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+
+	return &chatResp, nil
+}
+
+func (p *OpenAIProvider) ChatCompletionStream(ctx context.Context, req ChatRequest, onDelta func(ChatDelta)) (*ChatResponse, error) {
+	req.Stream = true
+	req.StreamOptions = &StreamOptions{IncludeUsage: true}
+
+	resp, err := p.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	return readStream(resp.Body, onDelta)
+}
+
+// send posts req to /chat/completions and returns the response if it has a
+// 200 status. The caller must close it.
+func (p *OpenAIProvider) send(ctx context.Context, req ChatRequest) (*response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+
+	return p.transport.post(ctx, p.baseURL+"/chat/completions", headers, body)
+}
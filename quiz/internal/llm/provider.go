@@ -0,0 +1,40 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Provider sends chat requests to a model backend. Requests and responses
+// use the OpenAI chat completion types; providers for other APIs translate
+// to and from them.
+type Provider interface {
+	ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	ChatCompletionStream(ctx context.Context, req ChatRequest, onDelta func(ChatDelta)) (*ChatResponse, error)
+}
+
+// Provider names accepted by NewProvider.
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+)
+
+// NewProvider returns the named provider, using policy for timeouts and
+// retries. An empty name selects the OpenAI-compatible provider.
+func NewProvider(name, baseURL, apiKey string, policy RetryPolicy) (Provider, error) {
+	t := &transport{httpClient: &http.Client{}, policy: policy}
+
+	switch name {
+	case "", ProviderOpenAI:
+		return &OpenAIProvider{baseURL: baseURL, apiKey: apiKey, transport: t}, nil
+	case ProviderAnthropic:
+		return &AnthropicProvider{baseURL: baseURL, apiKey: apiKey, transport: t}, nil
+	case ProviderOllama:
+		return &OllamaProvider{baseURL: baseURL, transport: t}, nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q (want %s, %s or %s)",
+			name, ProviderOpenAI, ProviderAnthropic, ProviderOllama)
+	}
+}
@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestProviderErrorStatus(t *testing.T) {
+	kinds := []error{ErrAuth, ErrRateLimited, ErrUnavailable}
+	tests := []struct {
+		status int
+		// want is the kind of error, or nil for none of them.
+		want error
+	}{
+		{400, nil},
+		{401, ErrAuth},
+		{403, ErrAuth},
+		{404, nil},
+		{429, ErrRateLimited},
+		{500, ErrUnavailable},
+		{503, ErrUnavailable},
+		{529, ErrUnavailable},
+	}
+
+	for _, name := range []string{ProviderOpenAI, ProviderAnthropic, ProviderOllama} {
+		for _, tt := range tests {
+			srv := newRecordingServer(t, tt.status, "application/json", `{"error":"details"}`)
+			p, err := NewProvider(name, srv.URL, "key", RetryPolicy{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, stream := range []bool{false, true} {
+				if stream {
+					_, err = p.ChatCompletionStream(context.Background(), toolConversation, nil)
+				} else {
+					_, err = p.ChatCompletion(context.Background(), toolConversation)
+				}
+
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Body != `{"error":"details"}` {
+					t.Errorf("%s %d (stream %v): err = %v, want an APIError with the status and body", name, tt.status, stream, err)
+					continue
+				}
+				for _, kind := range kinds {
+					if errors.Is(err, kind) != (kind == tt.want) {
+						t.Errorf("%s %d (stream %v): errors.Is(err, %v) = %v", name, tt.status, stream, kind, !(kind == tt.want))
+					}
+				}
+			}
+		}
+	}
+}
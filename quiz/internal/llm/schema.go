@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// validateSchema checks value (as decoded by encoding/json) against the
+// subset of JSON Schema used by tool definitions: type, properties,
+// required, items and enum. It returns one message per violation, each
+// prefixed with the path to the offending value.
+func validateSchema(schema map[string]any, value any, path string) []string {
+	if path == "" {
+		path = "$"
+	}
+
+	var problems []string
+
+	if t, ok := schema["type"].(string); ok && !matchesType(t, value) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", path, t, jsonType(value))}
+	}
+
+	if enum := enumValues(schema["enum"]); enum != nil && !slices.Contains(enum, value) {
+		problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range requiredNames(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+
+		props, _ := schema["properties"].(map[string]any)
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if sub, ok := props[name].(map[string]any); ok {
+				problems = append(problems, validateSchema(sub, v[name], path+"."+name)...)
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				problems = append(problems, validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+
+	return problems
+}
+
+// requiredNames accepts both []string (as written in Go tool definitions)
+// and []any (as decoded from JSON).
+func requiredNames(v any) []string {
+	switch r := v.(type) {
+	case []string:
+		return r
+	case []any:
+		var names []string
+		for _, n := range r {
+			if s, ok := n.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// enumValues normalizes an enum written in Go ([]string, []int) or decoded
+// from JSON ([]any) to the types encoding/json decodes values into.
+func enumValues(v any) []any {
+	switch e := v.(type) {
+	case []any:
+		return e
+	case []string:
+		out := make([]any, len(e))
+		for i, s := range e {
+			out[i] = s
+		}
+		return out
+	case []int:
+		out := make([]any, len(e))
+		for i, n := range e {
+			out[i] = float64(n)
+		}
+		return out
+	}
+	return nil
+}
+
+func matchesType(t string, value any) bool {
+	switch t {
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonType(value) == t
+	}
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
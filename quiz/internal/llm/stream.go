@@ -0,0 +1,190 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/rubric"
+)
+
+// readStream parses an OpenAI-style server-sent event stream, terminated by
+// "data: [DONE]" or EOF.
+func readStream(r io.Reader, onDelta func(ChatDelta)) (*ChatResponse, error) {
+	var msg ChatMessage
+	var usage *Usage
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk ChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("unmarshal stream chunk: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			if err := mergeDelta(&msg, choice.Delta, onDelta); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read stream: %w", err)
+	}
+
+	return &ChatResponse{Choices: []ChatChoice{{Message: msg}}, Usage: usage}, nil
+}
+
+// maxStreamToolCalls caps the tool call index a stream may use. Calls may
+// arrive out of order, so the message grows to the highest index seen; the
+// cap stops a bad endpoint from making it huge.
+const maxStreamToolCalls = 16
+
+// mergeDelta appends a streamed delta to msg and passes it on to onDelta,
+// which may be nil. It fails on a tool call index outside
+// [0, maxStreamToolCalls).
+func mergeDelta(msg *ChatMessage, delta ChatDelta, onDelta func(ChatDelta)) error {
+	if delta.Role != "" {
+		msg.Role = delta.Role
+	}
+	msg.Content += delta.Content
+
+	for _, tc := range delta.ToolCalls {
+		if tc.Index < 0 || tc.Index >= maxStreamToolCalls {
+			return fmt.Errorf("%w: tool call index %d in stream", ErrBadOutput, tc.Index)
+		}
+		for len(msg.ToolCalls) <= tc.Index {
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{})
+		}
+		call := &msg.ToolCalls[tc.Index]
+		if tc.ID != "" {
+			call.ID = tc.ID
+		}
+		if tc.Type != "" {
+			call.Type = tc.Type
+		}
+		if tc.Function.Name != "" {
+			call.Function.Name = tc.Function.Name
+		}
+		call.Function.Arguments += tc.Function.Arguments
+	}
+
+	if onDelta != nil {
+		onDelta(delta)
+	}
+	return nil
+}
+
+// GradeProgress reports streaming progress while grading.
+type GradeProgress struct {
+	// ReceivedChars is the length of the tool call arguments received so far.
+	ReceivedChars int `json:"received_chars"`
+	// Criterion and Result are set when a criterion has been fully received.
+	Criterion string           `json:"criterion,omitempty"`
+	Result    *CriterionResult `json:"result,omitempty"`
+}
+
+// GradeStream grades like Grade but streams the response, calling
+// onProgress as tool call arguments arrive and once for each criterion as
+// soon as it is complete. If a repair attempt is needed, criteria are
+// reported again as the corrected call streams in. A cached result is
+// returned without any progress.
+func (c *Client) GradeStream(ctx context.Context, problem *db.Problem, answer, evidence string, rb *rubric.Rubric, onProgress func(GradeProgress)) (*GradingResult, error) {
+	criteria := make(map[string]rubric.Criterion, len(rb.Criteria))
+	for _, c := range rb.Criteria {
+		criteria[c.Key] = c
+	}
+
+	req := gradingRequest(problem, answer, evidence, rb)
+	return c.gradeCached(ctx, problem, answer, evidence, req, func() (*GradingResult, error) {
+		return c.gradeWithRepair(req, rb, func(req ChatRequest) (*ChatResponse, error) {
+			return c.streamGrading(ctx, req, criteria, onProgress)
+		})
+	})
+}
+
+// streamGrading streams one grading request, reporting progress and each
+// criterion as it completes.
+func (c *Client) streamGrading(ctx context.Context, req ChatRequest, criteria map[string]rubric.Criterion, onProgress func(GradeProgress)) (*ChatResponse, error) {
+	var buf strings.Builder
+	seen := make(map[string]bool)
+
+	return c.ChatCompletionStream(ctx, req, func(delta ChatDelta) {
+		if len(delta.ToolCalls) == 0 || onProgress == nil {
+			return
+		}
+		for _, tc := range delta.ToolCalls {
+			if tc.Index == 0 {
+				buf.WriteString(tc.Function.Arguments)
+			}
+		}
+
+		onProgress(GradeProgress{ReceivedChars: buf.Len()})
+		for _, p := range completeObjects(buf.String()) {
+			crit, ok := criteria[p.key]
+			if !ok || seen[p.key] {
+				continue
+			}
+			result, err := criterionResult(crit, p.value)
+			if err != nil {
+				continue
+			}
+			seen[p.key] = true
+			onProgress(GradeProgress{ReceivedChars: buf.Len(), Criterion: p.key, Result: &result})
+		}
+	})
+}
+
+type partialProperty struct {
+	key   string
+	value json.RawMessage
+}
+
+// completeObjects returns, in order, the object-valued properties that have
+// been fully received in a partial submit_grading arguments string.
+func completeObjects(partial string) []partialProperty {
+	var found []partialProperty
+
+	dec := json.NewDecoder(strings.NewReader(partial))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return found
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		key, ok := tok.(string)
+		if !ok {
+			break
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			break
+		}
+		if len(value) > 0 && value[0] == '{' {
+			found = append(found, partialProperty{key, value})
+		}
+	}
+	return found
+}
@@ -0,0 +1,200 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseServer serves every request with body, written in the given pieces
+// with a flush and a pause after each so that the client reads them
+// separately. It records the decoded request in *got if got is not nil.
+func sseServer(t *testing.T, got *ChatRequest, pieces ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got != nil {
+			if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+				t.Errorf("decode request: %v", err)
+			}
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, p := range pieces {
+			w.Write([]byte(p))
+			w.(http.Flusher).Flush()
+			time.Sleep(time.Millisecond)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestChatCompletionStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []string
+		// want is the assembled message, and deltas how many times onDelta
+		// is called.
+		want    ChatMessage
+		usage   *Usage
+		deltas  int
+		wantErr string
+	}{
+		{
+			name: "chunks split mid-line",
+			pieces: []string{
+				`data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"Hel`,
+				`lo"}}]}` + "\n\n" + `da`,
+				`ta: {"choices":[{"index":0,"delta":{"content":", world"}}]}` + "\n",
+				"\ndata: [DONE]\n\n",
+			},
+			want:   ChatMessage{Role: "assistant", Content: "Hello, world"},
+			deltas: 2,
+		},
+		{
+			name: "interleaved tool call indices",
+			pieces: []string{
+				`data: {"choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"a","type":"function","function":{"name":"first","arguments":""}}]}}]}` + "\n\n",
+				`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"b","type":"function","function":{"name":"second","arguments":"{\"y\":"}}]}}]}` + "\n\n",
+				`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"x\":"}}]}}]}` + "\n\n",
+				`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"2}"}},{"index":0,"function":{"arguments":"1}"}}]}}]}` + "\n\n",
+				"data: [DONE]\n\n",
+			},
+			want: ChatMessage{Role: "assistant", ToolCalls: []ToolCall{
+				{ID: "a", Type: "function", Function: FunctionCall{Name: "first", Arguments: `{"x":1}`}},
+				{ID: "b", Type: "function", Function: FunctionCall{Name: "second", Arguments: `{"y":2}`}},
+			}},
+			deltas: 4,
+		},
+		{
+			name: "higher tool call index first",
+			pieces: []string{
+				`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"b","function":{"name":"second","arguments":"{}"}}]}}]}` + "\n\n",
+				`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"a","function":{"name":"first","arguments":"{}"}}]}}]}` + "\n\n",
+				"data: [DONE]\n\n",
+			},
+			want: ChatMessage{ToolCalls: []ToolCall{
+				{ID: "a", Function: FunctionCall{Name: "first", Arguments: "{}"}},
+				{ID: "b", Function: FunctionCall{Name: "second", Arguments: "{}"}},
+			}},
+			deltas: 2,
+		},
+		{
+			name: "tool call index too high",
+			pieces: []string{
+				`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"a","function":{"name":"first","arguments":"{}"}}]}}]}` + "\n\n",
+				`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":2000000000,"function":{"arguments":"{}"}}]}}]}` + "\n\n",
+				"data: [DONE]\n\n",
+			},
+			deltas:  1,
+			wantErr: "tool call index 2000000000",
+		},
+		{
+			name: "negative tool call index",
+			pieces: []string{
+				`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":-1,"function":{"arguments":"{}"}}]}}]}` + "\n\n",
+			},
+			wantErr: "tool call index -1",
+		},
+		{
+			name: "done ends the stream",
+			pieces: []string{
+				`data: {"choices":[{"index":0,"delta":{"content":"kept"}}]}` + "\n\n",
+				"data: [DONE]\n\n",
+				`data: {"choices":[{"index":0,"delta":{"content":" ignored"}}]}` + "\n\n",
+				"data: not json\n\n",
+			},
+			want:   ChatMessage{Content: "kept"},
+			deltas: 1,
+		},
+		{
+			name: "usage, comments and other choices",
+			pieces: []string{
+				": keep-alive\n\nevent: message\n",
+				`data: {"choices":[{"index":0,"delta":{"content":"a"}},{"index":1,"delta":{"content":"b"}}]}` + "\n\n",
+				`data: {"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}` + "\n\n",
+				"data: [DONE]\n\n",
+			},
+			want:   ChatMessage{Content: "a"},
+			usage:  &Usage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4},
+			deltas: 1,
+		},
+		{
+			name: "ends without done",
+			pieces: []string{
+				`data: {"choices":[{"index":0,"delta":{"content":"partial"}}]}` + "\n\n",
+			},
+			want:   ChatMessage{Content: "partial"},
+			deltas: 1,
+		},
+		{
+			name: "ends mid-event",
+			pieces: []string{
+				`data: {"choices":[{"index":0,"delta":{"content":"a"}}]}` + "\n\n",
+				`data: {"choices":[{"index":0,"delta":{"cont`,
+			},
+			deltas:  1,
+			wantErr: "unmarshal stream chunk",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req ChatRequest
+			srv := sseServer(t, &req, tt.pieces...)
+			p, err := NewProvider(ProviderOpenAI, srv.URL, "", RetryPolicy{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			deltas := 0
+			resp, err := p.ChatCompletionStream(context.Background(), ChatRequest{Model: "m"}, func(ChatDelta) { deltas++ })
+			if !req.Stream || req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+				t.Errorf("request did not ask for a stream with usage: %+v", req)
+			}
+			if deltas != tt.deltas {
+				t.Errorf("onDelta called %d times, want %d", deltas, tt.deltas)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resp.Choices[0].Message; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("message = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(resp.Usage, tt.usage) {
+				t.Errorf("usage = %+v, want %+v", resp.Usage, tt.usage)
+			}
+		})
+	}
+}
+
+func TestCompleteObjects(t *testing.T) {
+	tests := []struct {
+		partial string
+		want    []string
+	}{
+		{``, nil},
+		{`{"a": {"points": 1`, nil},
+		{`{"a": {"points": 1}, "b": {"po`, []string{"a"}},
+		{`{"a": {"points": 1}, "note": "x", "b": {"points": 2}}`, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		var keys []string
+		for _, p := range completeObjects(tt.partial) {
+			keys = append(keys, p.key)
+		}
+		if !reflect.DeepEqual(keys, tt.want) {
+			t.Errorf("completeObjects(%q) = %v, want %v", tt.partial, keys, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+const testGeneratorToolName = "submit_test_generator"
+
+func testGeneratorTool() Tool {
+	return Tool{
+		Type: "function",
+		Function: ToolFunction{
+			Name:        testGeneratorToolName,
+			Description: "Submit an input generator and a brute-force reference solution for the problem.",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"generator": map[string]any{
+						"type":        "string",
+						"description": "Python source defining generate(rng, size), which returns a dict of arguments for one call, valid(args) and benchmark(rng, n)",
+					},
+					"reference": map[string]any{
+						"type":        "string",
+						"description": "Python source of a class Solution solving the problem by brute force",
+					},
+				},
+				"required": []string{"generator", "reference"},
+			},
+		},
+	}
+}
+
+const testGeneratorPrompt = `You write test tooling for LeetCode-style problems in Python 3. Solutions will be checked by running them and a reference solution on many random inputs and comparing the results.
+
+Write two pieces of code:
+
+1. generator: a function generate(rng, size) that returns one random input, as a dict mapping each parameter of the method in the signature to a value. rng is a random.Random; size runs from 1 to 10, and the input's length or magnitude should grow with it, staying small enough for a brute-force solution to finish instantly. Values must be plain JSON data: linked lists and binary trees are given as lists in LeetCode's level order. Every input must satisfy the problem's constraints and guarantees (for example "exactly one solution exists"), so that the correct output is unambiguous. Include edge cases such as empty or single-element inputs where the constraints allow them. Also define valid(args), returning whether an input dict meets every constraint and guarantee; it is used to shrink failing inputs to minimal counterexamples, so it must reject anything generate would never produce. Finally define benchmark(rng, n), which returns a valid input whose size is about n, for n up to 131072, ignoring the size limits in the constraints if need be; it is used to time solutions, so it must be fast and should avoid inputs on which a solution could finish early.
+
+2. reference: a complete class Solution with the same method as the signature, solving the problem in the simplest way that is obviously correct, by brute force if need be. Efficiency does not matter.
+
+Both run with the typing and collections names, ListNode and TreeNode already defined, and may import from the standard library. Neither should print anything.
+
+You MUST call the submit_test_generator function with your code.`
+
+// GenerateTests asks the model for a test generator for the problem. Each
+// proposal is tried with check, which returns what is wrong with it; the
+// problems are fed back to the model until it gets one right or runs out
+// of attempts.
+func (c *Client) GenerateTests(ctx context.Context, problem *db.Problem, check func(*db.TestGenerator) ([]string, error)) (*db.TestGenerator, error) {
+	req := ChatRequest{
+		Messages: []ChatMessage{
+			{Role: "system", Content: testGeneratorPrompt},
+			{Role: "user", Content: buildProblemPrompt(problem)},
+		},
+		Tools: []Tool{testGeneratorTool()},
+		ToolChoice: &ToolChoice{
+			Type:     "function",
+			Function: ToolChoiceFunction{Name: testGeneratorToolName},
+		},
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.ChatCompletion(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("chat completion: %w", err)
+		}
+
+		gen, problems := testGeneratorCall(resp)
+		if gen != nil {
+			gen.ProblemID, gen.Model = problem.ID, c.model
+			if problems, err = check(gen); err != nil {
+				return nil, err
+			}
+			if len(problems) == 0 {
+				return gen, nil
+			}
+		}
+		if attempt >= c.maxGradingAttempts {
+			return nil, fmt.Errorf("%w: no working test generator after %d attempts: %s",
+				ErrBadOutput, attempt, strings.Join(problems, "; "))
+		}
+
+		feedback := "Your code did not work:\n- " + strings.Join(problems, "\n- ") +
+			"\n\nCall submit_test_generator again with corrected code."
+		req.Messages = append(req.Messages, feedbackMessages(resp, feedback)...)
+	}
+}
+
+// testGeneratorCall extracts the generator from resp, or returns what is
+// wrong with the call.
+func testGeneratorCall(resp *ChatResponse) (*db.TestGenerator, []string) {
+	if len(resp.Choices) == 0 {
+		return nil, []string{"no choices in response"}
+	}
+	msg := resp.Choices[0].Message
+	if len(msg.ToolCalls) == 0 || msg.ToolCalls[0].Function.Name != testGeneratorToolName {
+		return nil, []string{"no submit_test_generator tool call in response"}
+	}
+
+	var args struct {
+		Generator string `json:"generator"`
+		Reference string `json:"reference"`
+	}
+	if err := json.Unmarshal([]byte(msg.ToolCalls[0].Function.Arguments), &args); err != nil {
+		return nil, []string{fmt.Sprintf("arguments are not valid JSON: %v", err)}
+	}
+	var problems []string
+	if !strings.Contains(args.Generator, "def generate") {
+		problems = append(problems, "generator does not define generate(rng, size)")
+	}
+	if !strings.Contains(args.Reference, "class Solution") {
+		problems = append(problems, "reference does not define class Solution")
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return &db.TestGenerator{Generator: args.Generator, Reference: args.Reference}, nil
+}
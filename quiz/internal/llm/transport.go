@@ -0,0 +1,216 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Error kinds returned (wrapped) by providers and the grader. Use errors.Is
+// to tell them apart.
+var (
+	ErrRateLimited = errors.New("rate limited by LLM API")
+	ErrAuth        = errors.New("LLM API authentication failed")
+	ErrUnavailable = errors.New("LLM API unavailable")
+	ErrTimeout     = errors.New("LLM request timed out")
+	ErrBadOutput   = errors.New("LLM returned unusable output")
+)
+
+// APIError is a non-200 response from an LLM API.
+type APIError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is the delay the server asked for, if any.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("LLM API error %d: %s", e.StatusCode, e.Body)
+}
+
+// Unwrap classifies the status code as one of the Err kinds.
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrAuth
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= 500:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// RetryPolicy controls timeouts and retries for LLM HTTP calls.
+type RetryPolicy struct {
+	// Timeout bounds each attempt, including reading a streamed body.
+	// Zero means no timeout beyond the caller's context.
+	Timeout time.Duration
+	// MaxRetries is how many times a failed attempt is retried.
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry; it doubles each
+	// retry up to MaxDelay, with full jitter. A server asking to wait longer
+	// than MaxDelay with Retry-After is not retried.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Timeout:    2 * time.Minute,
+		MaxRetries: 3,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   30 * time.Second,
+	}
+}
+
+// transport posts JSON to an LLM API, applying a RetryPolicy.
+type transport struct {
+	httpClient *http.Client
+	policy     RetryPolicy
+	// sleep waits between attempts, returning early with an error if ctx
+	// is done. Nil means sleepContext; tests replace it.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// response is a successful HTTP response. Close releases the body and the
+// per-attempt timeout.
+type response struct {
+	*http.Response
+	cancel context.CancelFunc
+}
+
+func (r *response) Close() {
+	r.Body.Close()
+	r.cancel()
+}
+
+// post sends body to url, retrying rate limits, server errors and connection
+// failures with exponential backoff. It waits as long as Retry-After asks
+// when present, but returns the error at once if that is longer than the
+// policy's MaxDelay.
+func (t *transport) post(ctx context.Context, url string, headers map[string]string, body []byte) (*response, error) {
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		resp, err := t.try(ctx, url, headers, body)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+
+		if attempt >= t.policy.MaxRetries || !retryable(ctx, err) {
+			return nil, lastErr
+		}
+
+		delay := t.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			// Retrying any sooner would only be refused again.
+			if apiErr.RetryAfter > t.policy.MaxDelay {
+				return nil, lastErr
+			}
+			delay = apiErr.RetryAfter
+		}
+
+		sleep := t.sleep
+		if sleep == nil {
+			sleep = sleepContext
+		}
+		if sleep(ctx, delay) != nil {
+			return nil, lastErr
+		}
+	}
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (t *transport) try(ctx context.Context, url string, headers map[string]string, body []byte) (*response, error) {
+	cancel := context.CancelFunc(func() {})
+	if t.policy.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.policy.Timeout)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := t.httpClient.Do(httpReq)
+	if err != nil {
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("do request: %w: %w", ErrTimeout, err)
+		}
+		return nil, fmt.Errorf("do request: %w: %w", ErrUnavailable, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		cancel()
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(respBody),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	return &response{Response: resp, cancel: cancel}, nil
+}
+
+// retryable reports whether err is worth another attempt. Nothing is retried
+// once the caller's own context is done.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrUnavailable) || errors.Is(err, ErrTimeout)
+}
+
+func (t *transport) backoff(attempt int) time.Duration {
+	d := t.policy.BaseDelay << attempt
+	if d <= 0 || d > t.policy.MaxDelay {
+		d = t.policy.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d) + 1
+}
+
+// parseRetryAfter accepts either delay-seconds or an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
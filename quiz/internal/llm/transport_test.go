@@ -0,0 +1,256 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// reply is one response from a scriptedServer.
+type reply struct {
+	status     int
+	retryAfter string
+}
+
+// scriptedServer answers its nth request with replies[n], repeating the
+// last reply once they run out, and counts requests.
+func scriptedServer(t *testing.T, replies ...reply) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(n.Add(1)) - 1
+		rep := replies[min(i, len(replies)-1)]
+		if rep.retryAfter != "" {
+			w.Header().Set("Retry-After", rep.retryAfter)
+		}
+		w.WriteHeader(rep.status)
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &n
+}
+
+// recordSleeps returns a sleep function for a transport that returns at
+// once, and the delays it was asked for.
+func recordSleeps() (func(context.Context, time.Duration) error, *[]time.Duration) {
+	var slept []time.Duration
+	return func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}, &slept
+}
+
+func TestTransportRetries(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}
+	tests := []struct {
+		name     string
+		replies  []reply
+		requests int
+		wantErr  error
+		// sleeps are the exact delays expected, or nil to only check that
+		// each is a backoff within its attempt's bound.
+		sleeps    []time.Duration
+		numSleeps int
+	}{
+		{
+			name:      "server errors then success",
+			replies:   []reply{{status: 500}, {status: 502}, {status: 200}},
+			requests:  3,
+			numSleeps: 2,
+		},
+		{
+			name:      "gives up after the last retry",
+			replies:   []reply{{status: 503}},
+			requests:  4,
+			wantErr:   ErrUnavailable,
+			numSleeps: 3,
+		},
+		{
+			name:      "rate limit without retry-after backs off",
+			replies:   []reply{{status: 429}, {status: 200}},
+			requests:  2,
+			numSleeps: 1,
+		},
+		{
+			name:     "waits the full retry-after",
+			replies:  []reply{{status: 429, retryAfter: "2"}, {status: 503, retryAfter: "1"}, {status: 200}},
+			requests: 3,
+			sleeps:   []time.Duration{2 * time.Second, time.Second},
+		},
+		{
+			name:     "retry-after beyond the maximum delay is returned",
+			replies:  []reply{{status: 429, retryAfter: "120"}},
+			requests: 1,
+			wantErr:  ErrRateLimited,
+		},
+		{
+			name:     "authentication is not retried",
+			replies:  []reply{{status: 401}},
+			requests: 1,
+			wantErr:  ErrAuth,
+		},
+		{
+			name:     "bad request is not retried",
+			replies:  []reply{{status: 400}, {status: 200}},
+			requests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := scriptedServer(t, tt.replies...)
+			sleep, slept := recordSleeps()
+			tr := &transport{httpClient: srv.Client(), policy: policy, sleep: sleep}
+
+			resp, err := tr.post(context.Background(), srv.URL, nil, []byte(`{}`))
+			if err == nil {
+				resp.Close()
+			}
+
+			if got := int(requests.Load()); got != tt.requests {
+				t.Errorf("%d requests, want %d", got, tt.requests)
+			}
+			last := tt.replies[min(tt.requests, len(tt.replies))-1]
+			switch {
+			case last.status == http.StatusOK && err != nil:
+				t.Errorf("err = %v, want success", err)
+			case last.status != http.StatusOK && err == nil:
+				t.Errorf("succeeded, want status %d", last.status)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.sleeps != nil {
+				if len(*slept) != len(tt.sleeps) {
+					t.Fatalf("slept %v, want %v", *slept, tt.sleeps)
+				}
+				for i := range tt.sleeps {
+					if (*slept)[i] != tt.sleeps[i] {
+						t.Errorf("slept %v, want %v", *slept, tt.sleeps)
+					}
+				}
+				return
+			}
+			if len(*slept) != tt.numSleeps {
+				t.Fatalf("slept %v, want %d backoffs", *slept, tt.numSleeps)
+			}
+			for i, d := range *slept {
+				if d <= 0 || d > policy.BaseDelay<<i {
+					t.Errorf("backoff %d = %v, want it in (0, %v]", i, d, policy.BaseDelay<<i)
+				}
+			}
+		})
+	}
+}
+
+func TestTransportRetryAfterOnError(t *testing.T) {
+	srv, _ := scriptedServer(t, reply{status: 429, retryAfter: "120"})
+	sleep, _ := recordSleeps()
+	tr := &transport{httpClient: srv.Client(), policy: DefaultRetryPolicy(), sleep: sleep}
+
+	_, err := tr.post(context.Background(), srv.URL, nil, []byte(`{}`))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 120*time.Second {
+		t.Errorf("err = %v, want an APIError with the server's Retry-After", err)
+	}
+}
+
+func TestTransportConnectionFailures(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer slow.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		policy  RetryPolicy
+		wantErr error
+	}{
+		{"refused", closed.URL, RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, ErrUnavailable},
+		{"attempt timeout", slow.URL, RetryPolicy{Timeout: 20 * time.Millisecond, MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sleep, slept := recordSleeps()
+			tr := &transport{httpClient: &http.Client{}, policy: tt.policy, sleep: sleep}
+			_, err := tr.post(context.Background(), tt.url, nil, []byte(`{}`))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(*slept) != tt.policy.MaxRetries {
+				t.Errorf("retried %d times, want %d", len(*slept), tt.policy.MaxRetries)
+			}
+		})
+	}
+}
+
+func TestTransportStopsWhenContextDone(t *testing.T) {
+	srv, requests := scriptedServer(t, reply{status: 503})
+	ctx, cancel := context.WithCancel(context.Background())
+	tr := &transport{httpClient: srv.Client(), policy: DefaultRetryPolicy(), sleep: func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}}
+
+	_, err := tr.post(ctx, srv.URL, nil, []byte(`{}`))
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("err = %v, want the last attempt's error", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("%d requests, want 1", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tr := &transport{policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}}
+	for attempt := range 70 {
+		bound := min(tr.policy.BaseDelay<<min(attempt, 20), tr.policy.MaxDelay)
+		for range 20 {
+			if d := tr.backoff(attempt); d <= 0 || d > bound {
+				t.Fatalf("backoff(%d) = %v, want it in (0, %v]", attempt, d, bound)
+			}
+		}
+	}
+	if d := (&transport{}).backoff(3); d != 0 {
+		t.Errorf("backoff with no delays = %v, want 0", d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"0", 0},
+		{"-3", 0},
+		{"soon", 0},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	// HTTP dates have whole seconds, so the delay is within a second.
+	date := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got <= 88*time.Second || got > 90*time.Second {
+		t.Errorf("parseRetryAfter(%q) = %v, want about 90s", date, got)
+	}
+	if got := parseRetryAfter(strconv.Itoa(3600)); got != time.Hour {
+		t.Errorf("parseRetryAfter(3600) = %v, want 1h", got)
+	}
+}
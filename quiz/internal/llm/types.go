@@ -41,31 +41,107 @@ type ToolChoiceFunction struct {
 }
 
 type ChatRequest struct {
-	Model      string      `json:"model"`
+	Model      string        `json:"model"`
 	Messages   []ChatMessage `json:"messages"`
-	Tools      []Tool      `json:"tools,omitempty"`
-	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+	Tools      []Tool        `json:"tools,omitempty"`
+	ToolChoice *ToolChoice   `json:"tool_choice,omitempty"`
+	Stream     bool          `json:"stream,omitempty"`
+	// StreamOptions asks for a final usage chunk when streaming.
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type ChatResponse struct {
 	Choices []ChatChoice `json:"choices"`
+	// Usage is nil if the server did not report it.
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// Usage is the token count the server reported for one completion.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 type ChatChoice struct {
 	Message ChatMessage `json:"message"`
 }
 
+// Streaming chat completion types. Each server-sent chunk carries a delta
+// that is appended to the message built so far.
+
+type ChatStreamChunk struct {
+	Choices []ChatStreamChoice `json:"choices"`
+	// Usage is only set on the final chunk, and only when requested with
+	// StreamOptions.
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+type ChatStreamChoice struct {
+	Index        int       `json:"index"`
+	Delta        ChatDelta `json:"delta"`
+	FinishReason string    `json:"finish_reason,omitempty"`
+}
+
+type ChatDelta struct {
+	Role      string          `json:"role,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+// ToolCallDelta is a fragment of a tool call. Index identifies which call it
+// belongs to; Arguments are concatenated across fragments.
+type ToolCallDelta struct {
+	Index    int          `json:"index"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
 // Grading result types.
 
+// CriterionResult is the grade for one rubric criterion.
 type CriterionResult struct {
-	Score   bool   `json:"score"`
-	Comment string `json:"comment"`
+	Key       string  `json:"key"`
+	Label     string  `json:"label"`
+	Points    int     `json:"points"`
+	MaxPoints int     `json:"max_points"`
+	Weight    float64 `json:"weight"`
+	Comment   string  `json:"comment"`
+	// Agreement is the fraction of consensus samples that gave Points. It
+	// is zero for single-sample grades.
+	Agreement float64 `json:"agreement,omitempty"`
+}
+
+// Weighted is the criterion's contribution to the overall score.
+func (c CriterionResult) Weighted() float64 {
+	return c.Weight * float64(c.Points) / float64(c.MaxPoints)
 }
 
+// GradingResult is a graded answer. Criteria are in rubric order, and Score
+// is the sum of their weighted scores out of MaxScore.
 type GradingResult struct {
-	PatternIdentified  CriterionResult `json:"pattern_identified"`
-	SolutionWorks      CriterionResult `json:"solution_works"`
-	ComplexityAnalysis CriterionResult `json:"complexity_analysis"`
-	OptimalSolution    CriterionResult `json:"optimal_solution"`
-	OverallFeedback    string          `json:"overall_feedback"`
+	Rubric          string            `json:"rubric"`
+	Criteria        []CriterionResult `json:"criteria"`
+	OverallFeedback string            `json:"overall_feedback"`
+	Score           float64           `json:"score"`
+	MaxScore        float64           `json:"max_score"`
+	// Cached is set when the result was reused from an earlier grading of
+	// the same answer rather than produced by a new LLM call.
+	Cached bool `json:"cached"`
+	// Samples and Models are set for consensus grades: how many samples
+	// succeeded and which models produced them.
+	Samples int      `json:"samples,omitempty"`
+	Models  []string `json:"models,omitempty"`
+	// HintsUsed and HintPenalty are set when hints were taken before the
+	// answer; Score is already reduced by HintPenalty, a fraction of
+	// MaxScore.
+	HintsUsed   int     `json:"hints_used,omitempty"`
+	HintPenalty float64 `json:"hint_penalty,omitempty"`
+	// Evidence is the measurements the grader was given, if any.
+	Evidence string `json:"evidence,omitempty"`
 }
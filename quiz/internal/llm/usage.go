@@ -0,0 +1,25 @@
+package llm
+
+import "context"
+
+// UsageHook receives the model and usage of a completed request.
+type UsageHook func(model string, usage Usage)
+
+type usageHookKey struct{}
+
+// WithUsageHook returns a context under which every completion the Client
+// makes reports its usage to hook, including grading attempts that are
+// later repaired or rejected.
+func WithUsageHook(ctx context.Context, hook UsageHook) context.Context {
+	return context.WithValue(ctx, usageHookKey{}, hook)
+}
+
+// reportUsage passes the response's usage to the context's hook, if both
+// are present.
+func reportUsage(ctx context.Context, model string, resp *ChatResponse) {
+	hook, _ := ctx.Value(usageHookKey{}).(UsageHook)
+	if hook == nil || resp == nil || resp.Usage == nil {
+		return
+	}
+	hook(model, *resp.Usage)
+}
@@ -0,0 +1,121 @@
+// Package review schedules problems for spaced repetition using SM-2.
+//
+// Each graded attempt is turned into an SM-2 quality grade (0-5) from the
+// fraction of its rubric's maximum score earned. A quality of 3 or more
+// counts as a successful recall and grows the interval; anything lower
+// resets it. Attempts before a review is due leave the schedule alone, but
+// a failed one still lowers the ease.
+package review
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+const (
+	initialEase = 2.5
+	minEase     = 1.3
+	passQuality = 3
+)
+
+// Quality converts a score fraction between 0 and 1 into an SM-2 quality
+// grade.
+func Quality(fraction float64) int {
+	return int(math.Round(5 * min(max(fraction, 0), 1)))
+}
+
+// Next returns the schedule after a review of the given quality at time now.
+// prev may be nil for a problem the user has never reviewed. A review before
+// prev is due keeps its repetitions, interval and due date, and changes the
+// ease only if it failed, so practising early cannot push a problem out.
+func Next(prev *db.ReviewState, userID, problemID, quality int, now time.Time) db.ReviewState {
+	s := db.ReviewState{UserID: userID, ProblemID: problemID, Ease: initialEase}
+	if prev != nil {
+		s = *prev
+	}
+
+	if prev != nil && !isDue(prev, now) {
+		if quality < passQuality {
+			s.Ease = nextEase(s.Ease, quality)
+		}
+		return s
+	}
+
+	if quality < passQuality {
+		s.Repetitions = 0
+		s.IntervalDays = 1
+	} else {
+		s.Repetitions++
+		switch s.Repetitions {
+		case 1:
+			s.IntervalDays = 1
+		case 2:
+			s.IntervalDays = 6
+		default:
+			s.IntervalDays = int(math.Round(float64(s.IntervalDays) * s.Ease))
+		}
+	}
+
+	s.Ease = nextEase(s.Ease, quality)
+	s.DueAt = now.UTC().AddDate(0, 0, s.IntervalDays).Format(db.TimeFormat)
+	return s
+}
+
+// nextEase returns the SM-2 ease after a review of the given quality.
+func nextEase(ease float64, quality int) float64 {
+	q := float64(5 - quality)
+	return max(ease+0.1-q*(0.08+q*0.02), minEase)
+}
+
+// isDue reports whether s's review is due at now. A due date that cannot be
+// read counts as due.
+func isDue(s *db.ReviewState, now time.Time) bool {
+	due, err := time.Parse(db.TimeFormat, s.DueAt)
+	return err != nil || !now.Before(due)
+}
+
+// Record updates the attempt owner's schedule for its problem and stores it.
+func Record(d *db.DB, a *db.Attempt, now time.Time) (*db.ReviewState, error) {
+	if a.UserID == 0 {
+		return nil, fmt.Errorf("attempt %d has no owner", a.ID)
+	}
+
+	prev, err := d.GetReviewState(a.UserID, a.ProblemID)
+	if err != nil {
+		return nil, err
+	}
+
+	next := Next(prev, a.UserID, a.ProblemID, Quality(a.ScoreFraction()), now)
+	next.LastAttemptID = a.ID
+	next.LastScore = a.ScoreFraction()
+	if err := d.SaveReviewState(&next); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// Rebuild discards every schedule and replays all owned attempts in order.
+// It returns the number of attempts replayed.
+func Rebuild(d *db.DB) (int, error) {
+	attempts, err := d.AttemptsOldestFirst()
+	if err != nil {
+		return 0, err
+	}
+	if err := d.ClearReviews(); err != nil {
+		return 0, err
+	}
+
+	for i := range attempts {
+		at, err := time.Parse(db.TimeFormat, attempts[i].CreatedAt)
+		if err != nil {
+			return i, fmt.Errorf("attempt %d: parse created_at: %w", attempts[i].ID, err)
+		}
+		if _, err := Record(d, &attempts[i], at); err != nil {
+			return i, fmt.Errorf("attempt %d: %w", attempts[i].ID, err)
+		}
+	}
+	return len(attempts), nil
+}
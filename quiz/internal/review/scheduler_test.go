@@ -0,0 +1,168 @@
+package review
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+func TestQuality(t *testing.T) {
+	tests := []struct {
+		fraction float64
+		want     int
+	}{
+		{-0.5, 0},
+		{0, 0},
+		{0.29, 1},
+		{0.5, 3},
+		{0.6, 3},
+		{0.95, 5},
+		{1, 5},
+		{1.5, 5},
+	}
+	for _, tt := range tests {
+		if got := Quality(tt.fraction); got != tt.want {
+			t.Errorf("Quality(%v) = %d, want %d", tt.fraction, got, tt.want)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Each review happens when the previous one fell due.
+	tests := []struct {
+		name     string
+		quality  int
+		interval int
+		reps     int
+		ease     float64
+	}{
+		{"first pass", 5, 1, 1, 2.6},
+		{"second pass", 4, 6, 2, 2.6},
+		{"third pass grows by the ease", 4, 16, 3, 2.6},
+		{"hard pass lowers the ease", 3, 42, 4, 2.46},
+		{"failure resets", 1, 1, 0, 1.92},
+		{"pass after a failure starts over", 5, 1, 1, 2.02},
+	}
+
+	var prev *db.ReviewState
+	now := start
+	for _, tt := range tests {
+		s := Next(prev, 1, 2, tt.quality, now)
+		if s.IntervalDays != tt.interval || s.Repetitions != tt.reps || math.Abs(s.Ease-tt.ease) > 1e-9 {
+			t.Fatalf("%s: interval %d, reps %d, ease %.2f; want %d, %d, %.2f",
+				tt.name, s.IntervalDays, s.Repetitions, s.Ease, tt.interval, tt.reps, tt.ease)
+		}
+		if want := now.AddDate(0, 0, tt.interval).Format(db.TimeFormat); s.DueAt != want {
+			t.Fatalf("%s: due %s, want %s", tt.name, s.DueAt, want)
+		}
+		prev = &s
+		now = now.AddDate(0, 0, s.IntervalDays)
+	}
+}
+
+func TestNextMinEase(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var prev *db.ReviewState
+	for range 10 {
+		s := Next(prev, 1, 2, 0, now)
+		prev = &s
+		now = now.AddDate(0, 0, 1)
+	}
+	if prev.Ease != minEase {
+		t.Errorf("ease %v after repeated failures, want %v", prev.Ease, minEase)
+	}
+}
+
+func TestNextBeforeDue(t *testing.T) {
+	due := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	prev := &db.ReviewState{
+		UserID: 1, ProblemID: 2, Ease: 2.5, IntervalDays: 6, Repetitions: 2,
+		DueAt: due.Format(db.TimeFormat),
+	}
+
+	tests := []struct {
+		name    string
+		quality int
+		at      time.Time
+		// advanced is whether the schedule moves on; otherwise it stays as
+		// it was, with ease.
+		advanced bool
+		ease     float64
+	}{
+		{"early pass", 5, due.Add(-time.Hour), false, 2.5},
+		{"early failure", 1, due.Add(-time.Hour), false, 1.96},
+		{"pass when due", 5, due, true, 2.6},
+		{"failure when due", 1, due, true, 1.96},
+		{"pass when overdue", 4, due.AddDate(0, 0, 3), true, 2.5},
+	}
+	for _, tt := range tests {
+		s := Next(prev, 1, 2, tt.quality, tt.at)
+		if math.Abs(s.Ease-tt.ease) > 1e-9 {
+			t.Errorf("%s: ease %.2f, want %.2f", tt.name, s.Ease, tt.ease)
+		}
+		kept := s.Repetitions == prev.Repetitions && s.IntervalDays == prev.IntervalDays && s.DueAt == prev.DueAt
+		if kept == tt.advanced {
+			t.Errorf("%s: schedule %+v, advanced = %v", tt.name, s, tt.advanced)
+		}
+	}
+	if prev.Ease != 2.5 {
+		t.Error("Next changed prev")
+	}
+}
+
+func TestRecord(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if _, err := d.ImportProblems([]db.ImportQuestion{{Title: "Two Sum", ProblemSlug: "two-sum"}}); err != nil {
+		t.Fatal(err)
+	}
+	problem, err := d.GetProblemBySlug("two-sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := d.CreateUser("alice", "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	record := func(score float64, at time.Time) *db.ReviewState {
+		t.Helper()
+		a := &db.Attempt{UserID: user.ID, ProblemID: problem.ID, Answer: "a", Score: score, MaxScore: 10}
+		if err := d.CreateAttempt(a); err != nil {
+			t.Fatal(err)
+		}
+		s, err := Record(d, a, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.LastAttemptID != a.ID || s.LastScore != score/10 {
+			t.Errorf("last attempt %d score %v, want %d %v", s.LastAttemptID, s.LastScore, a.ID, score/10)
+		}
+		return s
+	}
+
+	first := record(10, now)
+	if first.Repetitions != 1 {
+		t.Fatalf("repetitions %d after the first pass, want 1", first.Repetitions)
+	}
+	// Practising again straight away keeps the schedule.
+	if again := record(10, now.Add(time.Minute)); again.Repetitions != 1 || again.DueAt != first.DueAt {
+		t.Errorf("early pass moved the schedule to %+v", again)
+	}
+	if due := record(10, now.AddDate(0, 0, 1)); due.Repetitions != 2 || due.IntervalDays != 6 {
+		t.Errorf("pass when due gave %+v, want the second repetition", due)
+	}
+
+	if _, err := Record(d, &db.Attempt{ID: 99, ProblemID: problem.ID}, now); err == nil {
+		t.Error("Record accepted an attempt with no owner")
+	}
+}
@@ -0,0 +1,271 @@
+// Package rubric defines grading rubrics: the criteria an answer is scored
+// on, their weights and optional partial-credit scales.
+//
+// Rubrics are read from YAML or JSON files. A criterion without a scale is
+// pass/fail and worth 0 or 1 point; a criterion with a scale of n levels is
+// worth 0 to n-1 points, one per level in order. Each criterion contributes
+// weight × points/max points to the answer's score.
+package rubric
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultName is the name of the built-in rubric.
+const DefaultName = "default"
+
+//go:embed default.yaml
+var defaultYAML []byte
+
+// Rubric is a named set of grading criteria.
+type Rubric struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description"`
+	// Instructions are added to the grader's system prompt.
+	Instructions string      `json:"instructions,omitempty" yaml:"instructions"`
+	Criteria     []Criterion `json:"criteria" yaml:"criteria"`
+}
+
+// Criterion is one thing an answer is graded on.
+type Criterion struct {
+	// Key names the criterion in the grading tool's arguments.
+	Key   string `json:"key" yaml:"key"`
+	Label string `json:"label" yaml:"label"`
+	// Description is the question the grader answers for this criterion.
+	Description string `json:"description" yaml:"description"`
+	// Guidance is optional extra direction for the grader's system prompt.
+	Guidance string  `json:"guidance,omitempty" yaml:"guidance"`
+	Weight   float64 `json:"weight" yaml:"weight"`
+	// Scale lists partial-credit levels from worst to best. Empty means
+	// pass/fail.
+	Scale []Level `json:"scale,omitempty" yaml:"scale"`
+}
+
+// Level is one step on a partial-credit scale.
+type Level struct {
+	Label       string `json:"label" yaml:"label"`
+	Description string `json:"description,omitempty" yaml:"description"`
+}
+
+// PassFail reports whether the criterion is scored as a boolean.
+func (c *Criterion) PassFail() bool {
+	return len(c.Scale) == 0
+}
+
+// MaxPoints is the highest number of points the criterion can award.
+func (c *Criterion) MaxPoints() int {
+	if c.PassFail() {
+		return 1
+	}
+	return len(c.Scale) - 1
+}
+
+// MaxScore is the sum of the criteria weights, i.e. a perfect score.
+func (r *Rubric) MaxScore() float64 {
+	var total float64
+	for _, c := range r.Criteria {
+		total += c.Weight
+	}
+	return total
+}
+
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// reservedKeys are grading tool arguments that are not criteria.
+var reservedKeys = map[string]bool{"overall_feedback": true}
+
+// validate checks the rubric and fills in defaults: a weight of 1 and a
+// label derived from the key.
+func (r *Rubric) validate() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(r.Criteria) == 0 {
+		return errors.New("at least one criterion is required")
+	}
+
+	seen := make(map[string]bool)
+	for i := range r.Criteria {
+		c := &r.Criteria[i]
+		if !keyPattern.MatchString(c.Key) {
+			return fmt.Errorf("criterion %d: key %q must be lower_snake_case", i+1, c.Key)
+		}
+		if reservedKeys[c.Key] || seen[c.Key] {
+			return fmt.Errorf("criterion %q: duplicate or reserved key", c.Key)
+		}
+		seen[c.Key] = true
+
+		if c.Description == "" {
+			return fmt.Errorf("criterion %q: description is required", c.Key)
+		}
+		if c.Weight < 0 {
+			return fmt.Errorf("criterion %q: weight must not be negative", c.Key)
+		}
+		if c.Weight == 0 {
+			c.Weight = 1
+		}
+		if len(c.Scale) == 1 {
+			return fmt.Errorf("criterion %q: a scale needs at least two levels", c.Key)
+		}
+		if c.Label == "" {
+			c.Label = labelFromKey(c.Key)
+		}
+	}
+	return nil
+}
+
+func labelFromKey(key string) string {
+	words := strings.Split(key, "_")
+	for i, w := range words {
+		if w != "" {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Parse reads a rubric in the given format, "yaml" or "json". Unknown fields
+// are rejected so that typos do not silently change grading.
+func Parse(data []byte, format string) (*Rubric, error) {
+	var r Rubric
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&r); err != nil {
+			return nil, fmt.Errorf("parse rubric: %w", err)
+		}
+	case "yaml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&r); err != nil {
+			return nil, fmt.Errorf("parse rubric: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown rubric format %q", format)
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, fmt.Errorf("rubric %s: %w", r.Name, err)
+	}
+	return &r, nil
+}
+
+// LoadFile reads a rubric from a .yaml, .yml or .json file.
+func LoadFile(path string) (*Rubric, error) {
+	format, ok := formatForPath(path)
+	if !ok {
+		return nil, fmt.Errorf("%s: rubric files must be .yaml, .yml or .json", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+func formatForPath(path string) (string, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml", true
+	case ".json":
+		return "json", true
+	}
+	return "", false
+}
+
+// Default returns the built-in rubric: pattern, correctness, complexity
+// analysis and optimality, each pass/fail.
+func Default() *Rubric {
+	r, err := Parse(defaultYAML, "yaml")
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Set is the collection of rubrics available for grading.
+type Set struct {
+	byName      map[string]*Rubric
+	defaultName string
+}
+
+// LoadSet returns the built-in rubric plus every rubric file in dir, which
+// may be empty or missing. A file may replace the built-in rubric by using
+// its name. defaultName picks the rubric used when none is requested; empty
+// means DefaultName.
+func LoadSet(dir, defaultName string) (*Set, error) {
+	if defaultName == "" {
+		defaultName = DefaultName
+	}
+	s := &Set{byName: map[string]*Rubric{DefaultName: Default()}, defaultName: defaultName}
+
+	if dir != "" {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("read rubrics: %w", err)
+		}
+
+		fromFile := make(map[string]string)
+		for _, e := range entries {
+			if _, ok := formatForPath(e.Name()); e.IsDir() || !ok {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			r, err := LoadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if prev, ok := fromFile[r.Name]; ok {
+				return nil, fmt.Errorf("rubric %q is defined in both %s and %s", r.Name, prev, path)
+			}
+			fromFile[r.Name] = path
+			s.byName[r.Name] = r
+		}
+	}
+
+	if _, ok := s.byName[defaultName]; !ok {
+		return nil, fmt.Errorf("default rubric %q not found", defaultName)
+	}
+	return s, nil
+}
+
+// Get returns the named rubric, or the default one if name is empty.
+func (s *Set) Get(name string) (*Rubric, bool) {
+	if name == "" {
+		name = s.defaultName
+	}
+	r, ok := s.byName[name]
+	return r, ok
+}
+
+// List returns every rubric sorted by name.
+func (s *Set) List() []*Rubric {
+	list := make([]*Rubric, 0, len(s.byName))
+	for _, r := range s.byName {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// DefaultName returns the name of the rubric used when none is requested.
+func (s *Set) DefaultName() string {
+	return s.defaultName
+}
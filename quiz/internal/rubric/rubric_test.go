@@ -0,0 +1,169 @@
+package rubric
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		data    string
+		wantErr string
+		// check is called on a successfully parsed rubric.
+		check func(t *testing.T, r *Rubric)
+	}{
+		{
+			name:   "yaml with defaults",
+			format: "yaml",
+			data: `
+name: short
+criteria:
+  - key: big_o
+    description: Is the complexity right?
+  - key: clarity
+    description: Is it clear?
+    weight: 2
+    scale:
+      - label: Unclear
+      - label: Mostly clear
+      - label: Clear
+`,
+			check: func(t *testing.T, r *Rubric) {
+				bigO, clarity := r.Criteria[0], r.Criteria[1]
+				if bigO.Label != "Big O" || bigO.Weight != 1 || !bigO.PassFail() || bigO.MaxPoints() != 1 {
+					t.Errorf("pass/fail criterion %+v", bigO)
+				}
+				if clarity.PassFail() || clarity.MaxPoints() != 2 {
+					t.Errorf("scaled criterion %+v", clarity)
+				}
+				if r.MaxScore() != 3 {
+					t.Errorf("MaxScore = %v, want 3", r.MaxScore())
+				}
+			},
+		},
+		{
+			name:   "json",
+			format: "json",
+			data:   `{"name": "j", "criteria": [{"key": "works", "label": "Works", "description": "Does it work?", "weight": 0.5}]}`,
+			check: func(t *testing.T, r *Rubric) {
+				if r.Criteria[0].Label != "Works" || r.MaxScore() != 0.5 {
+					t.Errorf("criteria %+v", r.Criteria)
+				}
+			},
+		},
+		{
+			name:    "unknown yaml field",
+			format:  "yaml",
+			data:    "name: x\ncriteria:\n  - key: a\n    description: d\n    wieght: 2\n",
+			wantErr: "wieght",
+		},
+		{
+			name:    "unknown json field",
+			format:  "json",
+			data:    `{"name": "x", "criteria": [{"key": "a", "description": "d", "wieght": 2}]}`,
+			wantErr: "wieght",
+		},
+		{name: "no name", format: "yaml", data: "criteria:\n  - key: a\n    description: d\n", wantErr: "name is required"},
+		{name: "no criteria", format: "yaml", data: "name: x\n", wantErr: "at least one criterion"},
+		{name: "bad key", format: "yaml", data: "name: x\ncriteria:\n  - key: Big-O\n    description: d\n", wantErr: "lower_snake_case"},
+		{
+			name:    "duplicate key",
+			format:  "yaml",
+			data:    "name: x\ncriteria:\n  - key: a\n    description: d\n  - key: a\n    description: e\n",
+			wantErr: "duplicate or reserved",
+		},
+		{name: "reserved key", format: "yaml", data: "name: x\ncriteria:\n  - key: overall_feedback\n    description: d\n", wantErr: "duplicate or reserved"},
+		{name: "no description", format: "yaml", data: "name: x\ncriteria:\n  - key: a\n", wantErr: "description is required"},
+		{name: "negative weight", format: "yaml", data: "name: x\ncriteria:\n  - key: a\n    description: d\n    weight: -1\n", wantErr: "must not be negative"},
+		{
+			name:    "one level scale",
+			format:  "yaml",
+			data:    "name: x\ncriteria:\n  - key: a\n    description: d\n    scale:\n      - label: Only\n",
+			wantErr: "at least two levels",
+		},
+		{name: "unknown format", format: "toml", data: "", wantErr: "unknown rubric format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse([]byte(tt.data), tt.format)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, r)
+		})
+	}
+}
+
+func TestDefault(t *testing.T) {
+	r := Default()
+	if r.Name != DefaultName || len(r.Criteria) != 4 || r.MaxScore() != 4 {
+		t.Errorf("default rubric %s has %d criteria worth %v", r.Name, len(r.Criteria), r.MaxScore())
+	}
+}
+
+func TestLoadSet(t *testing.T) {
+	write := func(t *testing.T, dir, name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const short = "name: short\ncriteria:\n  - key: a\n    description: d\n"
+
+	t.Run("files and default", func(t *testing.T) {
+		dir := t.TempDir()
+		write(t, dir, "short.yml", short)
+		write(t, dir, "notes.txt", "not a rubric")
+		s, err := LoadSet(dir, "short")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r, ok := s.Get(""); !ok || r.Name != "short" {
+			t.Errorf("Get(\"\") = %v, %v; want short", r, ok)
+		}
+		if _, ok := s.Get(DefaultName); !ok {
+			t.Error("built-in rubric missing")
+		}
+		if list := s.List(); len(list) != 2 || list[0].Name != DefaultName || list[1].Name != "short" {
+			t.Errorf("List = %v", list)
+		}
+	})
+
+	t.Run("shipped rubrics", func(t *testing.T) {
+		if _, err := LoadSet(filepath.Join("..", "..", "rubrics"), ""); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("missing dir", func(t *testing.T) {
+		if _, err := LoadSet(filepath.Join(t.TempDir(), "none"), ""); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("duplicate name", func(t *testing.T) {
+		dir := t.TempDir()
+		write(t, dir, "a.yaml", short)
+		write(t, dir, "b.json", `{"name": "short", "criteria": [{"key": "a", "description": "d"}]}`)
+		if _, err := LoadSet(dir, ""); err == nil || !strings.Contains(err.Error(), "defined in both") {
+			t.Errorf("LoadSet error %v, want a duplicate name", err)
+		}
+	})
+
+	t.Run("unknown default", func(t *testing.T) {
+		if _, err := LoadSet(t.TempDir(), "missing"); err == nil {
+			t.Error("LoadSet accepted an unknown default")
+		}
+	})
+}
@@ -0,0 +1,267 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+// Benchmark inputs grow geometrically up to maxBenchmarkSize. A size is the
+// last one tried once a call takes maxCallTime, or once the next would run
+// past the time limit.
+const (
+	maxBenchmarkSize = 1 << 17
+	maxCallTime      = 0.5
+	// minBenchmarkPoints is how many sizes are needed for a fit.
+	minBenchmarkPoints = 5
+)
+
+// Point is the time one call took on an input of size N, the fastest of
+// several measurements.
+type Point struct {
+	N       int     `json:"n"`
+	Seconds float64 `json:"seconds"`
+}
+
+// Fit is how well a complexity class explains the timings: the root mean
+// square of the relative errors of the best curve a + b·f(n).
+type Fit struct {
+	Class string  `json:"class"`
+	Error float64 `json:"error"`
+}
+
+// Complexity is an empirical estimate of a solution's time complexity.
+type Complexity struct {
+	// Class is the best fitting complexity class, e.g. "O(n log n)", or
+	// empty if there were too few timings to tell.
+	Class  string  `json:"class,omitempty"`
+	Points []Point `json:"points"`
+	// Fits are the classes' fits, best first. Classes that grow too fast
+	// to fit at all are left out.
+	Fits []Fit `json:"fits"`
+	// Error says why timing stopped early, e.g. "raised an exception at
+	// n = 8: ..."; timings up to that point are kept.
+	Error string `json:"error,omitempty"`
+}
+
+type complexityClass struct {
+	name string
+	f    func(n float64) float64
+}
+
+var complexityClasses = []complexityClass{
+	{"O(1)", func(n float64) float64 { return 1 }},
+	{"O(log n)", func(n float64) float64 { return math.Log2(n + 1) }},
+	{"O(n)", func(n float64) float64 { return n }},
+	{"O(n log n)", func(n float64) float64 { return n * math.Log2(n+1) }},
+	{"O(n²)", func(n float64) float64 { return n * n }},
+	{"O(2ⁿ)", math.Exp2},
+}
+
+// Benchmark times code on inputs of growing size made by the generator's
+// benchmark function, and fits the timings to common complexity classes.
+// Generators without a benchmark function give ErrUnsupported; failures of
+// the function are returned as ErrBadGenerator.
+func (r *Runner) Benchmark(ctx context.Context, problem *db.Problem, gen *db.TestGenerator, code string) (*Complexity, error) {
+	if err := r.Check(); err != nil {
+		return nil, err
+	}
+	sig, err := ParseSignature(problem.Python3Snippet)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(gen.Generator, "def benchmark") {
+		return nil, fmt.Errorf("%w: the problem's test generator cannot make large inputs; regenerate it", ErrUnsupported)
+	}
+
+	sizes := benchmarkSizes()
+	input, err := json.Marshal(map[string]any{
+		"mode":      "benchmark",
+		"code":      code,
+		"generator": gen.Generator,
+		"signature": sig,
+		"sizes":     sizes,
+		"seed":      1,
+		// Leave time to start the interpreter and load the code.
+		"budget":   max(r.timeout-2*time.Second, r.timeout/2).Seconds(),
+		"max_call": maxCallTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	stdout, stderr, runErr := r.exec(ctx, input)
+
+	c := &Complexity{Points: []Point{}, Fits: []Fit{}}
+	for _, line := range bytes.Split(stdout, []byte("\n")) {
+		var rec struct {
+			N          int     `json:"n"`
+			Seconds    float64 `json:"seconds"`
+			Error      string  `json:"error"`
+			SetupError string  `json:"setup_error"`
+		}
+		if json.Unmarshal(line, &rec) != nil {
+			continue
+		}
+		switch {
+		case rec.SetupError != "":
+			return nil, fmt.Errorf("%w: %s", ErrBadGenerator, rec.SetupError)
+		case rec.Error != "" && rec.N > 0:
+			c.Error = fmt.Sprintf("raised an exception at n = %d:\n%s", rec.N, rec.Error)
+		case rec.Error != "":
+			c.Error = "could not be loaded:\n" + rec.Error
+			return c, nil
+		case len(c.Points) < len(sizes) && rec.N == sizes[len(c.Points)]:
+			// The harness times the sizes in order, each once.
+			c.Points = append(c.Points, Point{N: rec.N, Seconds: rec.Seconds})
+		}
+	}
+	if runErr != nil && !errors.Is(runErr, context.DeadlineExceeded) && !isCPULimit(runErr) && c.Error == "" {
+		msg := strings.TrimSpace(string(stderr))
+		if msg == "" {
+			msg = runErr.Error()
+		}
+		c.Error = "stopped: " + msg
+	}
+
+	if len(c.Points) >= minBenchmarkPoints {
+		c.Fits = fitComplexity(c.Points)
+		c.Class = bestClass(c.Fits)
+	}
+	return c, nil
+}
+
+// bestClass picks the simplest class that fits about as well as the best.
+// Every class can fit at least as well as O(1), so without the tolerance
+// noise would favour the more complex ones.
+func bestClass(fits []Fit) string {
+	if len(fits) == 0 {
+		return ""
+	}
+	best := fits[0].Error
+	for _, class := range complexityClasses {
+		for _, fit := range fits {
+			if fit.Class == class.name && fit.Error <= best*1.25+0.05 {
+				return class.name
+			}
+		}
+	}
+	return fits[0].Class
+}
+
+// benchmarkSizes returns sizes growing by about √2 each step.
+func benchmarkSizes() []int {
+	var sizes []int
+	for k := 0; ; k++ {
+		n := int(math.Round(math.Pow(2, float64(k)/2)))
+		if n > maxBenchmarkSize {
+			return sizes
+		}
+		if len(sizes) == 0 || n != sizes[len(sizes)-1] {
+			sizes = append(sizes, n)
+		}
+	}
+}
+
+// fitComplexity fits t ≈ a + b·f(n), with a, b ≥ 0, for each class by least
+// squares on relative errors, so that small and large sizes count alike,
+// and returns the fits best first.
+func fitComplexity(points []Point) []Fit {
+	fits := []Fit{}
+	for _, class := range complexityClasses {
+		if e := fitError(points, class.f); !math.IsInf(e, 1) {
+			fits = append(fits, Fit{Class: class.name, Error: e})
+		}
+	}
+	sort.SliceStable(fits, func(i, j int) bool { return fits[i].Error < fits[j].Error })
+	return fits
+}
+
+func fitError(points []Point, f func(float64) float64) float64 {
+	// Weighted least squares with weights 1/t² for the residuals of
+	// a + b·x, where x = f(n).
+	var sw, sx, sxx, st, sxt float64
+	xs := make([]float64, len(points))
+	for i, p := range points {
+		x := f(float64(p.N))
+		if x > 1e100 || p.Seconds <= 0 {
+			return math.Inf(1)
+		}
+		w := 1 / (p.Seconds * p.Seconds)
+		xs[i] = x
+		sw += w
+		sx += w * x
+		sxx += w * x * x
+		st += w * p.Seconds
+		sxt += w * x * p.Seconds
+	}
+
+	a, b := st/sw, 0.0
+	if d := sw*sxx - sx*sx; d > 1e-9*sw*sxx {
+		a, b = (sxx*st-sx*sxt)/d, (sw*sxt-sx*st)/d
+		if a < 0 {
+			a, b = 0, sxt/sxx
+		}
+		if b < 0 {
+			a, b = st/sw, 0
+		}
+	}
+
+	var sum float64
+	for i, p := range points {
+		rel := (p.Seconds - a - b*xs[i]) / p.Seconds
+		sum += rel * rel
+	}
+	return math.Sqrt(sum / float64(len(points)))
+}
+
+// Summary describes the measurements for the grader: a sample of the
+// timings and how well the likeliest classes fit them.
+func (c *Complexity) Summary() string {
+	var b strings.Builder
+	b.WriteString("The candidate's code was run on generated inputs of growing size n. " +
+		"Times are the fastest of several runs of one call:\n\n")
+	step := max(1, len(c.Points)/8)
+	for i, p := range c.Points {
+		if i%step == 0 || i == len(c.Points)-1 {
+			fmt.Fprintf(&b, "- n = %d: %s\n", p.N, FormatSeconds(p.Seconds))
+		}
+	}
+	b.WriteString("\n")
+	if c.Class == "" {
+		b.WriteString("There were too few timings to estimate the complexity.\n")
+	} else {
+		var fits []string
+		for _, f := range c.Fits {
+			fits = append(fits, fmt.Sprintf("%s %.0f%%", f.Class, 100*f.Error))
+		}
+		fmt.Fprintf(&b, "Estimated complexity: %s. RMS relative error of each class's best fit: %s.\n",
+			c.Class, strings.Join(fits, ", "))
+	}
+	if len(c.Points) > 0 && c.Points[len(c.Points)-1].N < maxBenchmarkSize && c.Error == "" {
+		fmt.Fprintf(&b, "Timing stopped at n = %d because calls were getting too slow.\n", c.Points[len(c.Points)-1].N)
+	}
+	if c.Error != "" {
+		fmt.Fprintf(&b, "The code %s\n", strings.TrimSpace(c.Error))
+	}
+	return b.String()
+}
+
+// FormatSeconds formats a timing with a unit suited to its size.
+func FormatSeconds(s float64) string {
+	switch {
+	case s >= 1:
+		return fmt.Sprintf("%.2f s", s)
+	case s >= 1e-3:
+		return fmt.Sprintf("%.2f ms", s*1e3)
+	default:
+		return fmt.Sprintf("%.2f µs", s*1e6)
+	}
+}
@@ -0,0 +1,190 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+// timings returns points for the benchmark sizes up to maxN that take
+// a + b·f(n) seconds, each off by noise as a fraction.
+func timings(maxN int, a, b float64, f func(float64) float64, noise float64) []Point {
+	var points []Point
+	for i, n := range benchmarkSizes() {
+		if n > maxN {
+			break
+		}
+		// Alternate the noise so that it does not look like growth.
+		wobble := 1 + noise*float64(1-2*(i%2))
+		points = append(points, Point{N: n, Seconds: (a + b*f(float64(n))) * wobble})
+	}
+	return points
+}
+
+func TestFitComplexity(t *testing.T) {
+	log2 := func(n float64) float64 { return math.Log2(n + 1) }
+	linear := func(n float64) float64 { return n }
+	tests := []struct {
+		name   string
+		points []Point
+		want   string
+	}{
+		{"constant", timings(1<<17, 1e-6, 0, linear, 0.05), "O(1)"},
+		{"logarithmic", timings(1<<17, 1e-7, 1e-7, log2, 0.02), "O(log n)"},
+		{"linear", timings(1<<17, 1e-6, 1e-8, linear, 0.05), "O(n)"},
+		{"n log n", timings(1<<17, 1e-6, 1e-8, func(n float64) float64 { return n * log2(n) }, 0.02), "O(n log n)"},
+		{"quadratic", timings(2048, 1e-6, 1e-8, func(n float64) float64 { return n * n }, 0.05), "O(n²)"},
+		{"exponential", timings(24, 1e-6, 1e-8, math.Exp2, 0.05), "O(2ⁿ)"},
+		// Start-up cost hides the growth at small sizes.
+		{"linear with overhead", timings(1<<17, 1e-4, 1e-9, linear, 0.05), "O(n)"},
+	}
+
+	for _, tt := range tests {
+		fits := fitComplexity(tt.points)
+		if got := bestClass(fits); got != tt.want {
+			t.Errorf("%s: best class %s, want %s (fits %v)", tt.name, got, tt.want, fits)
+		}
+		for i := 1; i < len(fits); i++ {
+			if fits[i].Error < fits[i-1].Error {
+				t.Errorf("%s: fits not best first: %v", tt.name, fits)
+			}
+		}
+	}
+}
+
+func TestBestClass(t *testing.T) {
+	tests := []struct {
+		name string
+		fits []Fit
+		want string
+	}{
+		{"none", nil, ""},
+		{"simplest within tolerance", []Fit{{"O(n log n)", 0.04}, {"O(n)", 0.08}, {"O(1)", 0.9}}, "O(n)"},
+		{"simpler but worse", []Fit{{"O(n²)", 0.02}, {"O(n log n)", 0.2}}, "O(n²)"},
+	}
+	for _, tt := range tests {
+		if got := bestClass(tt.fits); got != tt.want {
+			t.Errorf("%s: bestClass = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBenchmarkSizes(t *testing.T) {
+	sizes := benchmarkSizes()
+	if sizes[0] != 1 || sizes[len(sizes)-1] > maxBenchmarkSize || len(sizes) < minBenchmarkPoints {
+		t.Fatalf("sizes %v", sizes)
+	}
+	for i := 1; i < len(sizes); i++ {
+		if sizes[i] <= sizes[i-1] || float64(sizes[i]) > 2*float64(sizes[i-1]) {
+			t.Errorf("size %d follows %d", sizes[i], sizes[i-1])
+		}
+	}
+}
+
+func TestComplexitySummary(t *testing.T) {
+	points := timings(1<<17, 1e-6, 1e-8, func(n float64) float64 { return n }, 0)
+	tests := []struct {
+		name string
+		c    *Complexity
+		want []string
+	}{
+		{
+			name: "estimated",
+			c:    &Complexity{Class: "O(n)", Points: points, Fits: []Fit{{"O(n)", 0.01}, {"O(n log n)", 0.12}}},
+			want: []string{"- n = 1: 1.01 µs", "- n = 131072: 1.31 ms", "Estimated complexity: O(n).", "O(n) 1%, O(n log n) 12%"},
+		},
+		{
+			name: "too few timings",
+			c:    &Complexity{Points: points[:2]},
+			want: []string{"too few timings", "Timing stopped at n = 2 because calls were getting too slow."},
+		},
+		{
+			name: "raised",
+			c:    &Complexity{Points: points[:2], Error: "raised an exception at n = 3:\nIndexError\n"},
+			want: []string{"The code raised an exception at n = 3:\nIndexError\n"},
+		},
+	}
+	for _, tt := range tests {
+		summary := tt.c.Summary()
+		for _, want := range tt.want {
+			if !strings.Contains(summary, want) {
+				t.Errorf("%s: no %q in summary:\n%s", tt.name, want, summary)
+			}
+		}
+	}
+}
+
+func TestFormatSeconds(t *testing.T) {
+	tests := map[float64]string{2.5: "2.50 s", 0.0125: "12.50 ms", 3e-7: "0.30 µs"}
+	for s, want := range tests {
+		if got := FormatSeconds(s); got != want {
+			t.Errorf("FormatSeconds(%v) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestBenchmark(t *testing.T) {
+	runner := NewRunner("python3", 5*time.Second, 256, 1)
+	if err := runner.Check(); err != nil {
+		t.Skip(err)
+	}
+	gen := &db.TestGenerator{Generator: sumGenerator + `
+def benchmark(rng, n):
+    return {"nums": [rng.randint(-5, 5) for _ in range(n)]}
+`}
+
+	if _, err := runner.Benchmark(context.Background(), sumProblem, &db.TestGenerator{Generator: sumGenerator}, sumReference); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Benchmark without a benchmark function = %v, want ErrUnsupported", err)
+	}
+
+	c, err := runner.Benchmark(context.Background(), sumProblem, gen, sumReference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Error != "" || len(c.Points) < minBenchmarkPoints || c.Class == "" {
+		t.Errorf("complexity %+v, want a class fitted to timings", c)
+	}
+
+	c, err = runner.Benchmark(context.Background(), sumProblem, gen, solution("return nums[7]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(c.Error, "raised an exception at n = 1:") {
+		t.Errorf("error %q, want an exception at the first size", c.Error)
+	}
+
+	// Quadratic code that writes timings of a constant-time solution
+	// straight to file descriptor 1.
+	forger := `import os, sys
+
+for n in ` + strings.Join(strings.Fields(fmt.Sprint(benchmarkSizes())), ", ") + `:
+    os.write(1, ('{"n": %d, "seconds": 1e-9}\n' % n).encode())
+    sys.__stdout__.write('{"n": %d, "seconds": 1e-9}\n' % n)
+sys.__stdout__.flush()
+
+class Solution:
+    def total(self, nums):
+        return sum(sum(nums) for _ in nums) // max(len(nums), 1)
+`
+	c, err = runner.Benchmark(context.Background(), sumProblem, gen, forger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Error != "" || len(c.Points) < minBenchmarkPoints {
+		t.Fatalf("complexity %+v, want timings of the code", c)
+	}
+	for i, p := range c.Points {
+		if p.N != benchmarkSizes()[i] || p.Seconds == 1e-9 {
+			t.Fatalf("points %v include forged timings", c.Points)
+		}
+	}
+	if c.Class == "O(1)" {
+		t.Errorf("quadratic code estimated as %s from forged timings", c.Class)
+	}
+}
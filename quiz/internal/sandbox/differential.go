@@ -0,0 +1,251 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+// Limits on differential testing. Generated inputs grow from size 1 to
+// maxInputSize, which the generator is asked to keep small enough for a
+// brute-force reference.
+const (
+	maxInputSize = 10
+	// maxShrunk is how many failing inputs are shrunk; later ones are
+	// reported as found.
+	maxShrunk = 5
+	// MaxCounterexamples is how many counterexamples a result keeps.
+	MaxCounterexamples = 3
+)
+
+// ErrBadGenerator is returned when a test generator or its reference
+// solution fails, as opposed to the code under test.
+var ErrBadGenerator = errors.New("test generator failed")
+
+// Counterexample is an input on which a solution disagrees with the
+// reference. Input is formatted like an example's, e.g. "nums = [1,2]",
+// and Expected and Got are JSON.
+type Counterexample struct {
+	Input    string `json:"input"`
+	Expected string `json:"expected,omitempty"`
+	Got      string `json:"got,omitempty"`
+	// Status is StatusFail, StatusError or StatusTimeout; Error is the
+	// traceback of an exception.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// DiffResult is the outcome of checking a solution against a reference on
+// generated inputs.
+type DiffResult struct {
+	// Checked counts the inputs the solution was run on, Passed those on
+	// which it agreed with the reference and Failed the rest.
+	Checked int `json:"checked"`
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	// Counterexamples are the smallest of the failing inputs, after
+	// shrinking if the generator allows it.
+	Counterexamples []Counterexample `json:"counterexamples"`
+	// TimedOut is set if the time limit ran out before every input was
+	// checked.
+	TimedOut bool `json:"timed_out,omitempty"`
+	// Error is set if the code could not be loaded, e.g. a syntax error.
+	Error string `json:"error,omitempty"`
+}
+
+// Differential runs code and the generator's reference solution on count
+// inputs made by its generator from seed, and reports where they disagree.
+// Failures of the generator or reference are returned as ErrBadGenerator,
+// and ErrUnsupported if code cannot be run here.
+func (r *Runner) Differential(ctx context.Context, problem *db.Problem, gen *db.TestGenerator, code string, count int, seed int64) (*DiffResult, error) {
+	if err := r.Check(); err != nil {
+		return nil, err
+	}
+	sig, err := ParseSignature(problem.Python3Snippet)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := json.Marshal(map[string]any{
+		"mode":         "differential",
+		"code":         code,
+		"reference":    gen.Reference,
+		"generator":    gen.Generator,
+		"signature":    sig,
+		"count":        count,
+		"seed":         seed,
+		"max_size":     maxInputSize,
+		"max_failures": maxShrunk,
+	})
+	if err != nil {
+		return nil, err
+	}
+	stdout, stderr, runErr := r.exec(ctx, input)
+	timedOut := errors.Is(runErr, context.DeadlineExceeded) || isCPULimit(runErr)
+
+	result := &DiffResult{Counterexamples: []Counterexample{}}
+	var failures []Counterexample
+	var running map[string]json.RawMessage
+	for _, line := range bytes.Split(stdout, []byte("\n")) {
+		var rec struct {
+			Case       *int                       `json:"case"`
+			Status     string                     `json:"status"`
+			Args       map[string]json.RawMessage `json:"args"`
+			Expected   string                     `json:"expected"`
+			Got        string                     `json:"got"`
+			Error      string                     `json:"error"`
+			SetupError string                     `json:"setup_error"`
+		}
+		if json.Unmarshal(line, &rec) != nil {
+			continue
+		}
+		switch {
+		case rec.SetupError != "":
+			return nil, fmt.Errorf("%w: %s", ErrBadGenerator, rec.SetupError)
+		case rec.Case == nil:
+			result.Error = rec.Error
+			return result, nil
+		case *rec.Case != result.Checked:
+			// The harness announces each input in turn, then reports on it.
+			continue
+		case rec.Status == "start":
+			running = rec.Args
+			continue
+		case running == nil:
+			continue
+		}
+		running = nil
+		result.Checked++
+		if rec.Status == StatusPass {
+			result.Passed++
+			continue
+		}
+		result.Failed++
+		failures = append(failures, Counterexample{
+			Input:    formatArgs(sig, rec.Args),
+			Expected: rec.Expected,
+			Got:      rec.Got,
+			Status:   rec.Status,
+			Error:    rec.Error,
+		})
+	}
+
+	switch {
+	case running != nil && timedOut:
+		// The solution ran out of time on the input it was given last.
+		result.Checked++
+		result.Failed++
+		failures = append(failures, Counterexample{
+			Input:  formatArgs(sig, running),
+			Status: StatusTimeout,
+			Error:  fmt.Sprintf("time limit of %s exceeded", r.timeout),
+		})
+		result.TimedOut = true
+	case timedOut:
+		result.TimedOut = true
+	case result.Checked < count && runErr != nil:
+		msg := strings.TrimSpace(string(stderr))
+		if msg == "" {
+			msg = runErr.Error()
+		}
+		return nil, fmt.Errorf("harness stopped after %d of %d inputs: %s", result.Checked, count, msg)
+	}
+
+	// Shrinking often brings different inputs down to the same one.
+	sort.SliceStable(failures, func(i, j int) bool {
+		return len(failures[i].Input) < len(failures[j].Input)
+	})
+	seen := make(map[string]bool)
+	for _, f := range failures {
+		if len(result.Counterexamples) < MaxCounterexamples && !seen[f.Input] {
+			result.Counterexamples = append(result.Counterexamples, f)
+			seen[f.Input] = true
+		}
+	}
+	return result, nil
+}
+
+// CheckGenerator tries out a test generator before it is stored: its
+// reference must pass the problem's examples, agree with itself on
+// generated inputs and run on benchmark inputs. It returns what is wrong,
+// if anything.
+func (r *Runner) CheckGenerator(ctx context.Context, problem *db.Problem, gen *db.TestGenerator) ([]string, error) {
+	var problems []string
+	run, err := r.Run(ctx, problem, gen.Reference)
+	if err != nil && !errors.Is(err, ErrUnsupported) {
+		return nil, err
+	}
+	if run != nil {
+		if run.Error != "" {
+			problems = append(problems, "the reference solution does not load:\n"+run.Error)
+		}
+		for _, c := range run.Cases {
+			switch c.Status {
+			case StatusFail:
+				problems = append(problems, fmt.Sprintf("the reference solution returns %s instead of %s on example %d (%s)",
+					c.Got, c.Expected, c.Example, c.Input))
+			case StatusError, StatusTimeout:
+				if run.Error == "" {
+					problems = append(problems, fmt.Sprintf("the reference solution fails on example %d (%s):\n%s",
+						c.Example, c.Input, c.Error))
+				}
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return problems, nil
+	}
+
+	diff, err := r.Differential(ctx, problem, gen, gen.Reference, 20, 1)
+	if errors.Is(err, ErrBadGenerator) {
+		return []string{strings.TrimPrefix(err.Error(), ErrBadGenerator.Error()+": ")}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case diff.TimedOut:
+		problems = append(problems, "the reference solution is too slow on the generated inputs; keep them smaller")
+	case diff.Failed > 0:
+		// The reference disagrees with itself, so its results depend on
+		// more than the input.
+		problems = append(problems, fmt.Sprintf("the reference solution gives different results on the same input (%s)",
+			diff.Counterexamples[0].Input))
+	}
+	if len(problems) > 0 || !strings.Contains(gen.Generator, "def benchmark") {
+		return problems, nil
+	}
+
+	// The reference is slow, so this only tries the smaller sizes.
+	bench, err := r.Benchmark(ctx, problem, gen, gen.Reference)
+	if errors.Is(err, ErrBadGenerator) {
+		return []string{strings.TrimPrefix(err.Error(), ErrBadGenerator.Error()+": ")}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(bench.Error, "raised") {
+		problems = append(problems, "the reference solution "+bench.Error+
+			"\nbenchmark must return valid inputs")
+	}
+	return problems, nil
+}
+
+// formatArgs writes arguments the way examples do, in parameter order.
+func formatArgs(sig *Signature, args map[string]json.RawMessage) string {
+	parts := make([]string, len(sig.Params))
+	for i, p := range sig.Params {
+		var value bytes.Buffer
+		if json.Compact(&value, args[p.Name]) != nil {
+			value.Write(args[p.Name])
+		}
+		parts[i] = p.Name + " = " + value.String()
+	}
+	return strings.Join(parts, ", ")
+}
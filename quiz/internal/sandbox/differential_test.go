@@ -0,0 +1,203 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+// sumProblem asks for the sum of a list.
+var sumProblem = &db.Problem{
+	Python3Snippet: "class Solution:\n    def total(self, nums: List[int]) -> int:\n        ",
+	Examples:       []any{map[string]any{"example_num": 1.0, "example_text": "Input: nums = [1,2]\nOutput: 3"}},
+}
+
+const (
+	sumGenerator = `
+def generate(rng, size):
+    return {"nums": [rng.randint(-5, 5) for _ in range(size)]}
+
+def valid(args):
+    return all(-5 <= x <= 5 for x in args["nums"])
+`
+	sumReference = `
+class Solution:
+    def total(self, nums):
+        return sum(nums)
+`
+)
+
+// solution returns a Solution whose total method has the given body.
+func solution(body string) string {
+	return "class Solution:\n    def total(self, nums):\n        " + body + "\n"
+}
+
+func TestDifferential(t *testing.T) {
+	runner := NewRunner("python3", 2*time.Second, 256, 1)
+	if err := runner.Check(); err != nil {
+		t.Skip(err)
+	}
+	gen := &db.TestGenerator{Generator: sumGenerator, Reference: sumReference}
+
+	tests := []struct {
+		name string
+		gen  *db.TestGenerator
+		code string
+		// failed is whether some input should fail, with counterexample
+		// status; shrunk matches the input it should shrink to, if set.
+		failed bool
+		status string
+		shrunk string
+		// loadError and timedOut are expected in the result, badGenerator
+		// as the error.
+		loadError    bool
+		timedOut     bool
+		badGenerator bool
+	}{
+		{name: "agrees", gen: gen, code: solution("return sum(nums)")},
+		{
+			name: "wrong on negatives", gen: gen, code: solution("return sum(x for x in nums if x > 0)"),
+			failed: true, status: StatusFail,
+		},
+		{
+			name: "wrong on one value", gen: gen, code: solution("return sum(nums) + nums.count(3)"),
+			failed: true, status: StatusFail, shrunk: `^nums = \[3\]$`,
+		},
+		{
+			name: "raises", gen: gen, code: solution("return nums[1] + sum(nums[:1]) + sum(nums[2:])"),
+			failed: true, status: StatusError, shrunk: `^nums = \[-?\d\]$`,
+		},
+		{
+			// Wrong, but writes passing records straight to file descriptor 1.
+			name: "forged passes", gen: gen,
+			code: "import os\nFORGED = ''.join('{\"case\": %d, \"status\": \"pass\"}\\n' % i for i in range(20)).encode()\nos.write(1, FORGED)\n" +
+				solution("os.write(1, FORGED); return sum(nums) + 1"),
+			failed: true, status: StatusFail,
+		},
+		{name: "does not load", gen: gen, code: "class Solution:\n    def total(self, nums)\n", loadError: true},
+		{
+			name: "too slow", gen: gen, code: solution("while True: pass"),
+			failed: true, status: StatusTimeout, timedOut: true,
+		},
+		{
+			name: "generator fails", gen: &db.TestGenerator{Generator: "def generate(rng, size):\n    return {}\n", Reference: sumReference},
+			code: solution("return sum(nums)"), badGenerator: true,
+		},
+		{
+			name: "reference fails", gen: &db.TestGenerator{Generator: sumGenerator, Reference: solution("raise ValueError()")},
+			code: solution("return sum(nums)"), badGenerator: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := runner.Differential(context.Background(), sumProblem, tt.gen, tt.code, 20, 1)
+			if tt.badGenerator {
+				if !errors.Is(err, ErrBadGenerator) {
+					t.Fatalf("err = %v, want ErrBadGenerator", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (result.Error != "") != tt.loadError {
+				t.Fatalf("load error %q, want one = %v", result.Error, tt.loadError)
+			}
+			if tt.loadError {
+				return
+			}
+			if result.TimedOut != tt.timedOut {
+				t.Errorf("timed out = %v, want %v", result.TimedOut, tt.timedOut)
+			}
+			if !tt.timedOut && result.Checked != 20 {
+				t.Errorf("checked %d inputs, want 20", result.Checked)
+			}
+			if result.Passed+result.Failed != result.Checked || (result.Failed > 0) != tt.failed {
+				t.Errorf("%d passed and %d failed of %d checked, want failures = %v",
+					result.Passed, result.Failed, result.Checked, tt.failed)
+			}
+			if !tt.failed {
+				return
+			}
+			if n := len(result.Counterexamples); n == 0 || n > MaxCounterexamples {
+				t.Fatalf("%d counterexamples", n)
+			}
+			c := result.Counterexamples[0]
+			if c.Status != tt.status {
+				t.Errorf("counterexample %+v, want status %s", c, tt.status)
+			}
+			if tt.shrunk != "" && !regexp.MustCompile(tt.shrunk).MatchString(c.Input) {
+				t.Errorf("counterexample input %q, want it shrunk to %q", c.Input, tt.shrunk)
+			}
+			for i := 1; i < len(result.Counterexamples); i++ {
+				if len(result.Counterexamples[i].Input) < len(c.Input) {
+					t.Errorf("counterexamples not smallest first: %+v", result.Counterexamples)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckGenerator(t *testing.T) {
+	runner := NewRunner("python3", 5*time.Second, 256, 1)
+	if err := runner.Check(); err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name      string
+		generator string
+		reference string
+		// want is part of the one problem expected, or empty for none.
+		want string
+	}{
+		{name: "good", generator: sumGenerator, reference: sumReference},
+		{name: "wrong on the examples", generator: sumGenerator, reference: solution("return 0"), want: "returns 0 instead of 3"},
+		{name: "does not load", generator: sumGenerator, reference: "class Solution(:\n", want: "does not load"},
+		{
+			name: "not deterministic", generator: sumGenerator,
+			// It gets the example right, so only differential testing
+			// catches it.
+			reference: "import random\n" + solution("return sum(nums) if len(nums) == 2 else random.randint(0, 1000000)"),
+			want:      "different results on the same input",
+		},
+		{name: "generator fails", generator: "def generate(rng, size):\n    raise ValueError('no')\n", reference: sumReference, want: "generator failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems, err := runner.CheckGenerator(context.Background(), sumProblem, &db.TestGenerator{Generator: tt.generator, Reference: tt.reference})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if len(problems) > 0 {
+					t.Errorf("problems %q, want none", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.Contains(problems[0], tt.want) {
+				t.Errorf("problems %q, want one mentioning %q", problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatArgs(t *testing.T) {
+	sig := &Signature{Params: []Param{{Name: "nums", Type: "List[int]"}, {Name: "target", Type: "int"}}}
+	args := map[string]json.RawMessage{
+		"target": json.RawMessage(`9`),
+		"nums": json.RawMessage(`[ 2, 7,
+			11 ]`),
+	}
+	if got, want := formatArgs(sig, args), "nums = [2,7,11], target = 9"; got != want {
+		t.Errorf("formatArgs = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Example is one of a problem's examples, parsed into arguments and the
+// expected return value. Arguments and Expected are JSON, which LeetCode's
+// example literals are.
+type Example struct {
+	Num      int
+	Input    string
+	Output   string
+	Args     map[string]json.RawMessage
+	Expected json.RawMessage
+	// Skip says why the example cannot be run, if it cannot.
+	Skip string
+}
+
+var exampleRe = regexp.MustCompile(`(?s)Input:\s*(.*?)\s*Output:\s*(.*?)\s*(?:Explanation:|$)`)
+
+// ParseExamples parses the examples stored with a problem, each a map with
+// "example_num" and "example_text" such as
+//
+//	Input: nums = [2,7,11,15], target = 9
+//	Output: [0,1]
+//
+// Examples that cannot be parsed, or whose inputs do not match sig's
+// parameters, are returned with Skip set.
+func ParseExamples(examples []any, sig *Signature) []Example {
+	var out []Example
+	for i, raw := range examples {
+		m, _ := raw.(map[string]any)
+		text, _ := m["example_text"].(string)
+		num := i + 1
+		if n, ok := m["example_num"].(float64); ok {
+			num = int(n)
+		}
+
+		ex := Example{Num: num}
+		if err := ex.parse(text, sig); err != nil {
+			ex.Skip = err.Error()
+		}
+		out = append(out, ex)
+	}
+	return out
+}
+
+func (ex *Example) parse(text string, sig *Signature) error {
+	match := exampleRe.FindStringSubmatch(text)
+	if match == nil {
+		return errors.New("no Input and Output in the example")
+	}
+	ex.Input = match[1]
+	ex.Output, _, _ = strings.Cut(match[2], "\n")
+	ex.Output = strings.TrimSpace(ex.Output)
+
+	ex.Args = make(map[string]json.RawMessage)
+	for _, part := range splitTopLevel(ex.Input) {
+		name, value, ok := strings.Cut(part, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" {
+			return fmt.Errorf("cannot read input %q", part)
+		}
+		if !json.Valid([]byte(value)) {
+			return fmt.Errorf("input %s is not a literal the harness can read: %s", name, value)
+		}
+		ex.Args[name] = json.RawMessage(value)
+	}
+	if !json.Valid([]byte(ex.Output)) {
+		return fmt.Errorf("output is not a literal the harness can read: %s", ex.Output)
+	}
+	ex.Expected = json.RawMessage(ex.Output)
+
+	if len(ex.Args) != len(sig.Params) {
+		return fmt.Errorf("the example's inputs do not match the parameters of %s", sig.Method)
+	}
+	for _, p := range sig.Params {
+		if _, ok := ex.Args[p.Name]; !ok {
+			return fmt.Errorf("the example has no input for parameter %s", p.Name)
+		}
+	}
+	return nil
+}
+
+// splitTopLevel splits s at commas that are not inside brackets or string
+// literals.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	inString, escaped := false, false
+	for i, r := range s {
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == '"':
+				inString = false
+			}
+		case r == '"':
+			inString = true
+		case r == '[' || r == '(' || r == '{':
+			depth++
+		case r == ']' || r == ')' || r == '}':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(s[start:]); rest != "" {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
@@ -0,0 +1,115 @@
+package sandbox
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitTopLevel(t *testing.T) {
+	tests := []struct {
+		name, s string
+		want    []string
+	}{
+		{"flat", "nums = [2,7,11,15], target = 9", []string{"nums = [2,7,11,15]", " target = 9"}},
+		{"nested brackets", "grid = [[1,2],[3,4]], k = 1", []string{"grid = [[1,2],[3,4]]", " k = 1"}},
+		{"braces and parentheses", "a = {1,2}, b = (3,4)", []string{"a = {1,2}", " b = (3,4)"}},
+		{"quoted commas", `s = "a,b", t = "]"`, []string{`s = "a,b"`, ` t = "]"`}},
+		{"escaped quotes", `s = "say \"hi, there\"", n = 1`, []string{`s = "say \"hi, there\""`, ` n = 1`}},
+		{"bracket in a string", `words = ["[", "a,b"], n = 2`, []string{`words = ["[", "a,b"]`, ` n = 2`}},
+		{"trailing comma", "a = 1, ", []string{"a = 1"}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		if got := splitTopLevel(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: splitTopLevel(%q) = %q, want %q", tt.name, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseExamples(t *testing.T) {
+	twoSum := &Signature{Method: "twoSum", Params: []Param{{Name: "nums", Type: "List[int]"}, {Name: "target", Type: "int"}}}
+	reverse := &Signature{Method: "reverseList", Params: []Param{{Name: "head", Type: "Optional[ListNode]"}}}
+	words := &Signature{Method: "join", Params: []Param{{Name: "words", Type: "List[str]"}, {Name: "sep", Type: "str"}}}
+
+	tests := []struct {
+		name string
+		sig  *Signature
+		text string
+		// args and expected are JSON; skip is part of the reason the
+		// example is skipped, if it should be.
+		args     map[string]string
+		expected string
+		skip     string
+	}{
+		{
+			name:     "explanation",
+			sig:      twoSum,
+			text:     "Input: nums = [2,7,11,15], target = 9\nOutput: [0,1]\nExplanation: Because nums[0] + nums[1] == 9, we return [0, 1].",
+			args:     map[string]string{"nums": "[2,7,11,15]", "target": "9"},
+			expected: "[0,1]",
+		},
+		{
+			name:     "text after the output",
+			sig:      twoSum,
+			text:     "Input:\n  nums = [3,3], target = 6\nOutput:   [0,1]\n\nNote that there may be other answers.",
+			args:     map[string]string{"nums": "[3,3]", "target": "6"},
+			expected: "[0,1]",
+		},
+		{
+			name:     "linked list",
+			sig:      reverse,
+			text:     "Input: head = [1,2,3,4,5]\nOutput: [5,4,3,2,1]",
+			args:     map[string]string{"head": "[1,2,3,4,5]"},
+			expected: "[5,4,3,2,1]",
+		},
+		{
+			name:     "strings with commas",
+			sig:      words,
+			text:     `Input: words = ["a,b","c"], sep = ", "` + "\nOutput: \"a,b, c\"",
+			args:     map[string]string{"words": `["a,b","c"]`, "sep": `", "`},
+			expected: `"a,b, c"`,
+		},
+		{name: "no input", sig: twoSum, text: "Output: [0,1]", skip: "no Input and Output"},
+		{name: "not a literal", sig: twoSum, text: "Input: nums = [1,2], target = 'x'\nOutput: [0,1]", skip: "input target is not a literal"},
+		{name: "output not a literal", sig: twoSum, text: "Input: nums = [1,2], target = 3\nOutput: None", skip: "output is not a literal"},
+		{name: "no name", sig: twoSum, text: "Input: [1,2], 3\nOutput: [0,1]", skip: "cannot read input"},
+		{name: "missing parameter", sig: twoSum, text: "Input: nums = [1,2]\nOutput: [0,1]", skip: "do not match the parameters"},
+		{name: "wrong parameter", sig: twoSum, text: "Input: nums = [1,2], k = 3\nOutput: [0,1]", skip: "no input for parameter target"},
+	}
+
+	for _, tt := range tests {
+		examples := ParseExamples([]any{map[string]any{"example_num": 3.0, "example_text": tt.text}}, tt.sig)
+		if len(examples) != 1 {
+			t.Fatalf("%s: %d examples", tt.name, len(examples))
+		}
+		ex := examples[0]
+		if ex.Num != 3 {
+			t.Errorf("%s: example number %d, want 3", tt.name, ex.Num)
+		}
+		if tt.skip != "" {
+			if !strings.Contains(ex.Skip, tt.skip) {
+				t.Errorf("%s: skipped for %q, want %q", tt.name, ex.Skip, tt.skip)
+			}
+			continue
+		}
+		if ex.Skip != "" {
+			t.Errorf("%s: skipped: %s", tt.name, ex.Skip)
+			continue
+		}
+		args := make(map[string]string)
+		for name, v := range ex.Args {
+			args[name] = string(v)
+		}
+		if !reflect.DeepEqual(args, tt.args) || string(ex.Expected) != tt.expected {
+			t.Errorf("%s: args %v expected %s, want %v %s", tt.name, args, ex.Expected, tt.args, tt.expected)
+		}
+	}
+
+	// Examples without a number are numbered by position, and ones that
+	// are not maps are skipped.
+	examples := ParseExamples([]any{"Input: x", map[string]any{"example_text": "Input: nums = [1], target = 1\nOutput: [0]"}}, twoSum)
+	if len(examples) != 2 || examples[0].Skip == "" || examples[1].Num != 2 || examples[1].Skip != "" {
+		t.Errorf("examples %+v", examples)
+	}
+}
@@ -0,0 +1,5 @@
+package sandbox
+
+// ExecCommand is the hidden quiz subcommand that confines itself and then
+// executes the interpreter. The binary's main must hand it to ExecMain.
+const ExecCommand = "sandbox-exec"
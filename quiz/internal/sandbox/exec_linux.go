@@ -0,0 +1,167 @@
+package sandbox
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// noNamespaces is set once starting a process in new namespaces has
+// failed, e.g. in containers that forbid unprivileged user namespaces.
+var noNamespaces atomic.Bool
+
+// ExecMain implements ExecCommand: it confines itself to the paths and
+// limits given as flags and applies the seccomp filter, then executes the
+// command after "--". It does not return, and exits without running the
+// command if any of that fails.
+func ExecMain(args []string) {
+	fs := flag.NewFlagSet(ExecCommand, flag.ExitOnError)
+	cpu := fs.Int("cpu", 10, "CPU time limit in seconds")
+	memory := fs.Int64("memory", 256<<20, "Address space limit in bytes")
+	var read, write pathList
+	fs.Var(&read, "read", "Path the command may read and execute beneath (repeatable)")
+	fs.Var(&write, "write", "Path the command may read and change beneath (repeatable)")
+	fs.Parse(args)
+	argv := fs.Args()
+	if len(argv) == 0 {
+		fmt.Fprintln(os.Stderr, "sandbox: no command")
+		os.Exit(1)
+	}
+
+	// The exec arguments are built up front: once the address space is
+	// limited, the runtime may be unable to grow the heap.
+	path, err := syscall.BytePtrFromString(argv[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(1)
+	}
+	argvp, err := syscall.SlicePtrFromStrings(argv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(1)
+	}
+	envp, err := syscall.SlicePtrFromStrings(os.Environ())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The filter applies to this thread, which then becomes the
+	// interpreter.
+	runtime.LockOSThread()
+	if err := enter(*cpu, *memory, read, write); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(1)
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_EXECVE,
+		uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&argvp[0])), uintptr(unsafe.Pointer(&envp[0])))
+	fmt.Fprintf(os.Stderr, "sandbox: exec %s: %v\n", argv[0], errno)
+	os.Exit(1)
+}
+
+// enter restricts the filesystem, installs the seccomp filter and then
+// sets the resource limits, last because the address space limit can leave
+// the runtime out of memory.
+func enter(cpu int, memory int64, read, write []string) error {
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("no_new_privs: %w", err)
+	}
+	if err := restrictFilesystem(read, write); err != nil {
+		return err
+	}
+	if err := installFilter(); err != nil {
+		return err
+	}
+
+	limits := [...]struct {
+		resource int
+		value    uint64
+	}{
+		{syscall.RLIMIT_CPU, uint64(cpu)},
+		{syscall.RLIMIT_AS, uint64(memory)},
+		{syscall.RLIMIT_FSIZE, 1 << 20},
+		{syscall.RLIMIT_NOFILE, 64},
+		{syscall.RLIMIT_CORE, 0},
+	}
+	for _, l := range limits {
+		if err := syscall.Setrlimit(l.resource, &syscall.Rlimit{Cur: l.value, Max: l.value}); err != nil {
+			return fmt.Errorf("setrlimit %d: %w", l.resource, err)
+		}
+	}
+	return nil
+}
+
+// pathList is a flag that can be given more than once.
+type pathList []string
+
+func (l *pathList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *pathList) Set(path string) error {
+	*l = append(*l, path)
+	return nil
+}
+
+// confine runs cmd in its own process group, killed as a whole when the
+// command is cancelled, and in new user and network namespaces unless
+// those are unavailable.
+func confine(cmd *exec.Cmd, namespaces bool) {
+	attr := &syscall.SysProcAttr{Setpgid: true, Pdeathsig: syscall.SIGKILL}
+	if namespaces && !noNamespaces.Load() {
+		uid, gid := os.Getuid(), os.Getgid()
+		attr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+	}
+	cmd.SysProcAttr = attr
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+}
+
+// runConfined runs the command, falling back to one without namespaces if the
+// kernel refuses them. Networking is still denied by the seccomp filter.
+func runConfined(command func(namespaces bool) *exec.Cmd) error {
+	cmd := command(true)
+	err := cmd.Start()
+	if err != nil && cmd.SysProcAttr.Cloneflags != 0 && isNamespaceError(err) {
+		if !noNamespaces.Swap(true) {
+			log.Printf("sandbox: cannot create namespaces (%v); relying on the seccomp filter", err)
+		}
+		cmd = command(false)
+		err = cmd.Start()
+	}
+	if err != nil {
+		return err
+	}
+	return cmd.Wait()
+}
+
+func isNamespaceError(err error) bool {
+	return errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EINVAL) ||
+		errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EACCES)
+}
+
+// isCPULimit reports whether the process was killed for exceeding its CPU
+// time limit.
+func isCPULimit(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	ws, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && ws.Signaled() && (ws.Signal() == syscall.SIGXCPU || ws.Signal() == syscall.SIGKILL)
+}
@@ -0,0 +1,91 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+// TestMain lets the test binary act as the sandbox helper, which the runner
+// starts by executing itself.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == ExecCommand {
+		ExecMain(os.Args[2:])
+	}
+	os.Exit(m.Run())
+}
+
+// probeProblem has one example, which passes if the solution returns
+// "denied".
+var probeProblem = &db.Problem{
+	Python3Snippet: "class Solution:\n    def probe(self, n: int) -> str:\n        ",
+	Examples:       []any{map[string]any{"example_num": 1.0, "example_text": "Input: n = 1\nOutput: \"denied\""}},
+}
+
+func TestConfinement(t *testing.T) {
+	runner := NewRunner("python3", 10*time.Second, 256, 1)
+	if err := runner.Check(); err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name string
+		// attempt is a statement that should fail with OSError.
+		attempt string
+		// allowed is set if the statement should succeed instead.
+		allowed bool
+	}{
+		{name: "read outside", attempt: `open("/etc/passwd").read()`},
+		{name: "read own source", attempt: fmt.Sprintf("open(%q).read()", mustGetwd(t)+"/exec_linux_test.go")},
+		{name: "write outside", attempt: `open("/tmp/quiz-sandbox-escape", "w").write("x")`},
+		{name: "write scratch directory", attempt: `open("scratch", "w").write("x")`, allowed: true},
+		{name: "socket", attempt: `import socket; socket.socket(socket.AF_INET, socket.SOCK_STREAM)`},
+		{name: "unix socket", attempt: `import socket; socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)`},
+		{name: "start process", attempt: `import subprocess; subprocess.run(["/bin/true"])`},
+		{name: "signal parent", attempt: `import os; os.kill(os.getppid(), 0)`},
+		{name: "signal every process", attempt: `import os; os.kill(-1, 0)`},
+		{name: "signal parent group", attempt: `import os; os.killpg(os.getpgid(os.getppid()), 0)`},
+		{name: "signal parent pidfd", attempt: `import os; os.pidfd_open(os.getppid())`},
+		{name: "signal self", attempt: `import os; os.kill(os.getpid(), 0)`, allowed: true},
+		{name: "signal own group", attempt: `import os; os.kill(0, 0)`, allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := fmt.Sprintf(`class Solution:
+    def probe(self, n: int) -> str:
+        try:
+            %s
+        except OSError:
+            return "denied"
+        return "allowed"
+`, tt.attempt)
+
+			result, err := runner.Run(context.Background(), probeProblem, code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := result.Cases[0]
+			want := `"denied"`
+			if tt.allowed {
+				want = `"allowed"`
+			}
+			if c.Got != want {
+				t.Errorf("got %s (%s: %s), want %s", c.Got, c.Status, c.Error, want)
+			}
+		})
+	}
+}
+
+func mustGetwd(t *testing.T) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wd
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// ExecMain implements ExecCommand, which is only supported on Linux.
+func ExecMain(args []string) {
+	fmt.Fprintln(os.Stderr, "sandbox: only supported on Linux")
+	os.Exit(1)
+}
+
+func checkConfinement() error {
+	return fmt.Errorf("%w: running code is only supported on Linux", ErrUnsupported)
+}
+
+func confine(cmd *exec.Cmd, namespaces bool) {}
+
+func runConfined(command func(namespaces bool) *exec.Cmd) error {
+	return fmt.Errorf("%w: running code is only supported on Linux", ErrUnsupported)
+}
+
+func isCPULimit(err error) bool {
+	return false
+}
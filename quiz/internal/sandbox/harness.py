@@ -0,0 +1,353 @@
+# Runs a candidate's Solution against parsed examples, against a reference
+# solution on generated inputs, or on inputs of growing size to time it. The job is read as JSON from stdin
+# before any candidate code runs; one JSON line per example or input is
+# written to stdout, or a single line with "error" if the code cannot be
+# loaded. The candidate's own output is captured, not passed through, and
+# anything it writes to file descriptor 1 directly goes to stderr, so that
+# it cannot pass for the harness's records.
+import contextlib
+import copy
+import gc
+import io
+import json
+import math
+import os
+import random
+import sys
+import time
+import traceback
+from collections import *
+from typing import *
+
+OUTPUT_LIMIT = 2000
+
+
+class ListNode:
+    def __init__(self, val=0, next=None):
+        self.val = val
+        self.next = next
+
+
+class TreeNode:
+    def __init__(self, val=0, left=None, right=None):
+        self.val = val
+        self.left = left
+        self.right = right
+
+
+def to_list_node(values):
+    head = None
+    for v in reversed(values or []):
+        head = ListNode(v, head)
+    return head
+
+
+def from_list_node(node):
+    out, seen = [], set()
+    while node is not None and id(node) not in seen:
+        seen.add(id(node))
+        out.append(node.val)
+        node = node.next
+    return out
+
+
+def to_tree_node(values):
+    if not values or values[0] is None:
+        return None
+    root = TreeNode(values[0])
+    queue, i = deque([root]), 1
+    while queue and i < len(values):
+        node = queue.popleft()
+        for side in ("left", "right"):
+            if i < len(values) and values[i] is not None:
+                child = TreeNode(values[i])
+                setattr(node, side, child)
+                queue.append(child)
+            i += 1
+    return root
+
+
+def from_tree_node(root):
+    out, queue = [], deque([root])
+    while queue:
+        node = queue.popleft()
+        if node is None:
+            out.append(None)
+            continue
+        out.append(node.val)
+        queue.append(node.left)
+        queue.append(node.right)
+    while out and out[-1] is None:
+        out.pop()
+    return out
+
+
+def convert_in(value, typ):
+    if "ListNode" in typ:
+        return to_list_node(value)
+    if "TreeNode" in typ:
+        return to_tree_node(value)
+    return value
+
+
+def convert_out(value):
+    if isinstance(value, ListNode):
+        return from_list_node(value)
+    if isinstance(value, TreeNode):
+        return from_tree_node(value)
+    if isinstance(value, (list, tuple)):
+        return [convert_out(v) for v in value]
+    return value
+
+
+def same(got, want):
+    if isinstance(got, bool) or isinstance(want, bool):
+        return got is want
+    if isinstance(got, (int, float)) and isinstance(want, (int, float)):
+        return math.isclose(got, want, rel_tol=1e-5, abs_tol=1e-5)
+    if isinstance(got, list) and isinstance(want, list):
+        return len(got) == len(want) and all(same(g, w) for g, w in zip(got, want))
+    return got == want
+
+
+def emit(out, record):
+    out.write(json.dumps(record) + "\n")
+    out.flush()
+
+
+def dumps(value):
+    return json.dumps(value, separators=(",", ":"))
+
+
+def exec_code(code, filename):
+    scope = dict(globals())
+    scope["__name__"] = "solution"
+    with contextlib.redirect_stdout(io.StringIO()):
+        exec(compile(code, filename, "exec"), scope)
+    return scope
+
+
+def load(code, filename, sig):
+    return getattr(exec_code(code, filename)["Solution"](), sig["method"])
+
+
+def call(method, sig, args):
+    """Calls method with JSON arguments and returns its result as JSON."""
+    args = {p["name"]: convert_in(copy.deepcopy(args[p["name"]]), p["type"]) for p in sig["params"]}
+    got = method(**args)
+    # Methods that return nothing modify their first argument in place.
+    if sig["returns"] == "None" and sig["params"]:
+        got = args[sig["params"][0]["name"]]
+    return convert_out(got)
+
+
+def run_examples(job, out):
+    sig = job["signature"]
+    try:
+        method = load(job["code"], "solution.py", sig)
+    except BaseException:
+        emit(out, {"error": traceback.format_exc(limit=-3)[-OUTPUT_LIMIT:]})
+        return
+
+    for case in job["cases"]:
+        record = {"index": case["index"]}
+        captured = io.StringIO()
+        try:
+            with contextlib.redirect_stdout(captured):
+                got = call(method, sig, case["args"])
+            record["got"] = dumps(got)
+            record["status"] = "pass" if same(got, case["expected"]) else "fail"
+        except BaseException:
+            record["status"] = "error"
+            record["error"] = traceback.format_exc(limit=-3)[-OUTPUT_LIMIT:]
+        record["stdout"] = captured.getvalue()[:OUTPUT_LIMIT]
+        emit(out, record)
+
+
+def check(method, reference, sig, args):
+    """Returns None if method agrees with reference on args, and otherwise
+    the record of the mismatch. Raises if the reference fails."""
+    with contextlib.redirect_stdout(io.StringIO()):
+        expected = call(reference, sig, args)
+    record = {"args": args, "expected": dumps(expected)}
+    try:
+        with contextlib.redirect_stdout(io.StringIO()):
+            got = call(method, sig, args)
+    except BaseException:
+        record["status"] = "error"
+        record["error"] = traceback.format_exc(limit=-3)[-OUTPUT_LIMIT:]
+        return record
+    if same(got, expected):
+        return None
+    record["status"] = "fail"
+    record["got"] = dumps(got)
+    return record
+
+
+def shrink(method, reference, sig, record, valid, budget=200):
+    """Shrinks the failing input in record by removing elements from list
+    and string arguments while the input stays valid and the solutions
+    still disagree, and returns the record for the smallest input found."""
+    changed = True
+    while changed and budget > 0:
+        changed = False
+        for name in sorted(record["args"]):
+            value = record["args"][name]
+            if not isinstance(value, (list, str)):
+                continue
+            chunk = len(value) // 2
+            while chunk >= 1 and budget > 0:
+                start = 0
+                while start < len(value) and budget > 0:
+                    smaller = value[:start] + value[start + chunk:]
+                    args = dict(record["args"], **{name: smaller})
+                    budget -= 1
+                    try:
+                        failed = valid(copy.deepcopy(args)) and check(method, reference, sig, args)
+                    except BaseException:
+                        failed = None
+                    if failed:
+                        record, value, changed = failed, smaller, True
+                    else:
+                        start += chunk
+                chunk //= 2
+    return record
+
+
+def run_differential(job, out):
+    sig = job["signature"]
+    try:
+        method = load(job["code"], "solution.py", sig)
+    except BaseException:
+        emit(out, {"error": traceback.format_exc(limit=-3)[-OUTPUT_LIMIT:]})
+        return
+    try:
+        reference = load(job["reference"], "reference.py", sig)
+        scope = exec_code(job["generator"], "generator.py")
+        generate, valid = scope["generate"], scope.get("valid")
+    except BaseException:
+        emit(out, {"setup_error": traceback.format_exc(limit=-3)[-OUTPUT_LIMIT:]})
+        return
+
+    names = sorted(p["name"] for p in sig["params"])
+    rng = random.Random(job["seed"])
+    count, max_size, failures = job["count"], job["max_size"], 0
+    for i in range(count):
+        size = 1 + i * max_size // count
+        try:
+            with contextlib.redirect_stdout(io.StringIO()):
+                args = json.loads(json.dumps(generate(rng, size)))
+            if not isinstance(args, dict) or sorted(args) != names:
+                raise ValueError("generate returned %r, not a dict with keys %s" % (args, names))
+            if valid and not valid(copy.deepcopy(args)):
+                raise ValueError("valid rejects the generated input %s" % dumps(args))
+        except BaseException:
+            emit(out, {"setup_error": "generator failed:\n" + traceback.format_exc(limit=-3)[-OUTPUT_LIMIT:]})
+            return
+
+        # Announce the input first, so that it is known if the candidate
+        # runs out of time on it.
+        emit(out, {"case": i, "status": "start", "args": args})
+        try:
+            record = check(method, reference, sig, args)
+        except BaseException:
+            emit(out, {"setup_error": "reference failed on %s:\n%s" % (
+                dumps(args), traceback.format_exc(limit=-3)[-OUTPUT_LIMIT:])})
+            return
+        if record is None:
+            emit(out, {"case": i, "status": "pass"})
+            continue
+        failures += 1
+        # Inputs can only be shrunk if the generator says which are valid.
+        if valid and failures <= job["max_failures"]:
+            record = shrink(method, reference, sig, record, valid)
+        record["case"] = i
+        emit(out, record)
+
+
+def time_call(method, sig, args, min_time=0.005):
+    """Returns the fastest time per call of method on args, calling it
+    enough times per measurement to time it accurately."""
+    best, number = math.inf, 1
+    for _ in range(3):
+        while True:
+            prepared = [{p["name"]: convert_in(copy.deepcopy(args[p["name"]]), p["type"]) for p in sig["params"]}
+                        for _ in range(number)]
+            gc.disable()
+            try:
+                start = time.perf_counter()
+                for a in prepared:
+                    method(**a)
+                elapsed = time.perf_counter() - start
+            finally:
+                gc.enable()
+            if elapsed >= min_time or number >= 10000:
+                break
+            number *= 10
+        best = min(best, elapsed / number)
+        if elapsed > 10 * min_time and number == 1:
+            # Slow calls are timed once.
+            break
+    return best
+
+
+def run_benchmark(job, out):
+    sig = job["signature"]
+    try:
+        method = load(job["code"], "solution.py", sig)
+    except BaseException:
+        emit(out, {"error": traceback.format_exc(limit=-3)[-OUTPUT_LIMIT:]})
+        return
+    try:
+        benchmark = exec_code(job["generator"], "generator.py")["benchmark"]
+    except BaseException:
+        emit(out, {"setup_error": traceback.format_exc(limit=-3)[-OUTPUT_LIMIT:]})
+        return
+
+    names = sorted(p["name"] for p in sig["params"])
+    rng = random.Random(job["seed"])
+    deadline = time.monotonic() + job["budget"]
+    for n in job["sizes"]:
+        try:
+            with contextlib.redirect_stdout(io.StringIO()):
+                args = json.loads(json.dumps(benchmark(rng, n)))
+            if not isinstance(args, dict) or sorted(args) != names:
+                raise ValueError("benchmark returned %r, not a dict with keys %s" % (args, names))
+        except BaseException:
+            emit(out, {"setup_error": "benchmark generator failed:\n" + traceback.format_exc(limit=-3)[-OUTPUT_LIMIT:]})
+            return
+        try:
+            with contextlib.redirect_stdout(io.StringIO()):
+                seconds = time_call(method, sig, args)
+        except BaseException:
+            emit(out, {"n": n, "error": traceback.format_exc(limit=-3)[-OUTPUT_LIMIT:]})
+            return
+        emit(out, {"n": n, "seconds": seconds})
+        # Stop before the next size, which may take several times as long,
+        # could run past the budget.
+        if seconds > job["max_call"] or time.monotonic() + 10 * seconds > deadline:
+            return
+
+
+def private_stdout():
+    """Returns a file writing to a private copy of stdout and points file
+    descriptor 1, and so sys.stdout and sys.__stdout__, at stderr."""
+    sys.stdout.flush()
+    out = os.fdopen(os.dup(1), "w")
+    os.dup2(2, 1)
+    return out
+
+
+def main():
+    job = json.load(sys.stdin)
+    out = private_stdout()
+    mode = job.get("mode")
+    if mode == "differential":
+        run_differential(job, out)
+    elif mode == "benchmark":
+        run_benchmark(job, out)
+    else:
+        run_examples(job, out)
+
+
+main()
@@ -0,0 +1,127 @@
+package sandbox
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// systemPaths are readable by every run, if they exist, for the dynamic
+// loader and the C library.
+var systemPaths = []string{"/lib", "/lib64", "/usr/lib", "/usr/lib64", "/etc/ld.so.cache"}
+
+// landlockRulePathBeneath is LANDLOCK_RULE_PATH_BENEATH, which
+// golang.org/x/sys does not define.
+const landlockRulePathBeneath = 1
+
+const (
+	// readAccess lets a process read and execute files beneath a path.
+	readAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR
+	// fileAccess is the part of any access that applies to a file rather
+	// than a directory.
+	fileAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+)
+
+// landlockABI returns the version of Landlock the kernel supports, or an
+// error wrapping ErrUnsupported if it has none.
+func landlockABI() (int, error) {
+	v, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 || int(v) < 1 {
+		return 0, fmt.Errorf("%w: the kernel does not provide Landlock (%v), so code cannot be confined to its directory", ErrUnsupported, errno)
+	}
+	return int(v), nil
+}
+
+// checkConfinement reports whether runs can be confined, with an error
+// wrapping ErrUnsupported if they cannot.
+func checkConfinement() error {
+	_, err := landlockABI()
+	return err
+}
+
+// restrictFilesystem confines the calling thread, and what it executes, to
+// reading beneath the read paths and systemPaths and to changing files
+// beneath the write paths. It also denies TCP and signals to processes
+// outside the sandbox where the kernel can.
+func restrictFilesystem(read, write []string) error {
+	abi, err := landlockABI()
+	if err != nil {
+		return err
+	}
+
+	// The handled rights are the ones this ABI version knows; any not
+	// granted by a rule below are denied.
+	attr := unix.LandlockRulesetAttr{Access_fs: 1<<13 - 1}
+	if abi >= 2 {
+		attr.Access_fs |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		attr.Access_fs |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	if abi >= 4 {
+		attr.Access_net = unix.LANDLOCK_ACCESS_NET_BIND_TCP | unix.LANDLOCK_ACCESS_NET_CONNECT_TCP
+	}
+	if abi >= 5 {
+		attr.Access_fs |= unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+	if abi >= 6 {
+		attr.Scoped = unix.LANDLOCK_SCOPE_ABSTRACT_UNIX_SOCKET | unix.LANDLOCK_SCOPE_SIGNAL
+	}
+	size := unsafe.Sizeof(attr)
+	if abi < 6 {
+		size = unsafe.Offsetof(attr.Scoped)
+	}
+	if abi < 4 {
+		size = unsafe.Offsetof(attr.Access_net)
+	}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), size, 0)
+	if errno != 0 {
+		return fmt.Errorf("create landlock ruleset: %w", errno)
+	}
+	defer unix.Close(int(fd))
+
+	for _, p := range append(read, systemPaths...) {
+		if err := addPathRule(int(fd), p, readAccess&attr.Access_fs); err != nil {
+			return err
+		}
+	}
+	for _, p := range write {
+		if err := addPathRule(int(fd), p, attr.Access_fs); err != nil {
+			return err
+		}
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("landlock restrict self: %w", errno)
+	}
+	return nil
+}
+
+// addPathRule allows access beneath path, or to it if it is a file. Paths
+// that do not exist are skipped.
+func addPathRule(ruleset int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err == unix.ENOENT {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer unix.Close(fd)
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= fileAccess
+	}
+	rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), landlockRulePathBeneath,
+		uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("landlock rule for %s: %w", path, errno)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+// Package sandbox runs candidates' Python solutions against a problem's
+// examples in a confined subprocess.
+//
+// The examples' inputs and expected outputs are parsed out of their text,
+// and the candidate's Solution is driven by an embedded harness built
+// around the method in the problem's snippet. On Linux the interpreter runs
+// under a helper that uses Landlock to limit it to reading the
+// interpreter's own files and the system libraries and to writing a scratch
+// working directory. The helper caps CPU time, memory, file size and open
+// files with rlimits, and installs a seccomp filter that denies networking,
+// process creation, signals to other processes and other privileged calls,
+// in a new network namespace where the kernel allows unprivileged ones. The
+// interpreter gets an empty environment. Where Landlock is unavailable, nothing is run.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+//go:embed harness.py
+var harness string
+
+// ErrUnsupported is returned for problems that cannot be run, and on
+// platforms where code cannot be run safely.
+var ErrUnsupported = errors.New("cannot run this problem")
+
+// outputLimit caps how much of the subprocess's output is kept.
+const outputLimit = 1 << 20
+
+// Case statuses.
+const (
+	StatusPass    = "pass"
+	StatusFail    = "fail"
+	StatusError   = "error"
+	StatusSkipped = "skipped"
+	StatusTimeout = "timeout"
+)
+
+// CaseResult is the verdict on one example. Expected and Got are JSON.
+type CaseResult struct {
+	Example  int    `json:"example"`
+	Status   string `json:"status"`
+	Input    string `json:"input"`
+	Expected string `json:"expected"`
+	Got      string `json:"got,omitempty"`
+	// Error is the traceback of an exception, or why the example was
+	// skipped.
+	Error  string `json:"error,omitempty"`
+	Stdout string `json:"stdout,omitempty"`
+}
+
+// Result is the outcome of running a solution against every example.
+type Result struct {
+	Cases  []CaseResult `json:"cases"`
+	Passed int          `json:"passed"`
+	// Run counts the examples that were run, that is, not skipped.
+	Run int `json:"run"`
+	// Error is set if the code could not be loaded, e.g. a syntax error.
+	Error string `json:"error,omitempty"`
+}
+
+// Runner runs solutions with the given Python interpreter and limits.
+type Runner struct {
+	python  string
+	timeout time.Duration
+	memory  int64
+	sem     chan struct{}
+
+	once   sync.Once
+	interp string
+	// readable are the interpreter's own files and standard library.
+	readable []string
+	err      error
+}
+
+// NewRunner returns a runner that allows each run timeout of wall time (and
+// as much CPU time) and memoryMB of address space, with at most
+// maxConcurrent runs at once. python is the interpreter to use, e.g.
+// "python3".
+func NewRunner(python string, timeout time.Duration, memoryMB, maxConcurrent int) *Runner {
+	return &Runner{
+		python:  python,
+		timeout: timeout,
+		memory:  int64(memoryMB) << 20,
+		sem:     make(chan struct{}, max(maxConcurrent, 1)),
+	}
+}
+
+// Check returns an error wrapping ErrUnsupported if code cannot be run
+// here, because the interpreter is missing or cannot be confined.
+func (r *Runner) Check() error {
+	r.once.Do(func() {
+		if r.err = checkConfinement(); r.err != nil {
+			return
+		}
+		r.interp, r.readable, r.err = r.resolve()
+	})
+	return r.err
+}
+
+// Run runs code against the problem's examples. It returns ErrUnsupported
+// if the problem has no runnable examples or code cannot be run here; a
+// solution that fails to load or crashes is reported in the result, not as
+// an error.
+func (r *Runner) Run(ctx context.Context, problem *db.Problem, code string) (*Result, error) {
+	if err := r.Check(); err != nil {
+		return nil, err
+	}
+	sig, err := ParseSignature(problem.Python3Snippet)
+	if err != nil {
+		return nil, err
+	}
+	examples := ParseExamples(problem.Examples, sig)
+
+	type jobCase struct {
+		Index    int                        `json:"index"`
+		Args     map[string]json.RawMessage `json:"args"`
+		Expected json.RawMessage            `json:"expected"`
+	}
+	job := struct {
+		Code      string     `json:"code"`
+		Signature *Signature `json:"signature"`
+		Cases     []jobCase  `json:"cases"`
+	}{Code: code, Signature: sig}
+
+	result := &Result{}
+	for i, ex := range examples {
+		cr := CaseResult{Example: ex.Num, Input: ex.Input, Expected: ex.Output}
+		if ex.Skip != "" {
+			cr.Status, cr.Error = StatusSkipped, ex.Skip
+		} else {
+			job.Cases = append(job.Cases, jobCase{Index: i, Args: ex.Args, Expected: ex.Expected})
+			result.Run++
+		}
+		result.Cases = append(result.Cases, cr)
+	}
+	if result.Run == 0 {
+		return nil, fmt.Errorf("%w: the problem has no examples that can be run", ErrUnsupported)
+	}
+
+	input, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, stderr, runErr := r.exec(ctx, input)
+
+	// Fill in the examples the harness reported on; any it did not reach
+	// ran out of time or were cut short by a crash.
+	reported := make(map[int]bool)
+	for _, line := range bytes.Split(stdout, []byte("\n")) {
+		var rec struct {
+			Index  *int   `json:"index"`
+			Status string `json:"status"`
+			Got    string `json:"got"`
+			Error  string `json:"error"`
+			Stdout string `json:"stdout"`
+		}
+		if json.Unmarshal(line, &rec) != nil {
+			continue
+		}
+		if rec.Index == nil {
+			result.Error = rec.Error
+			continue
+		}
+		// The harness reports each example once.
+		if *rec.Index < 0 || *rec.Index >= len(result.Cases) || reported[*rec.Index] {
+			continue
+		}
+		cr := &result.Cases[*rec.Index]
+		cr.Status, cr.Got, cr.Error, cr.Stdout = rec.Status, rec.Got, rec.Error, rec.Stdout
+		reported[*rec.Index] = true
+	}
+
+	missing := StatusError
+	reason := strings.TrimSpace(string(stderr))
+	switch {
+	case errors.Is(runErr, context.DeadlineExceeded), isCPULimit(runErr):
+		missing, reason = StatusTimeout, fmt.Sprintf("time limit of %s exceeded", r.timeout)
+	case reason == "" && runErr != nil:
+		reason = runErr.Error()
+	case reason == "":
+		reason = "the harness stopped before reaching this example"
+	}
+	for _, c := range job.Cases {
+		if reported[c.Index] {
+			continue
+		}
+		cr := &result.Cases[c.Index]
+		cr.Status = missing
+		if result.Error == "" {
+			cr.Error = reason
+		}
+	}
+	if result.Error != "" {
+		for i := range result.Cases {
+			if result.Cases[i].Status == StatusError {
+				result.Cases[i].Error = "the solution could not be loaded"
+			}
+		}
+	}
+
+	for _, c := range result.Cases {
+		if c.Status == StatusPass {
+			result.Passed++
+		}
+	}
+	return result, nil
+}
+
+// exec runs the harness on input under the sandbox helper, waiting its turn
+// if the maximum number of runs are already going.
+func (r *Runner) exec(ctx context.Context, input []byte) (stdout, stderr []byte, err error) {
+	select {
+	case r.sem <- struct{}{}:
+		defer func() { <-r.sem }()
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+
+	if err := r.Check(); err != nil {
+		return nil, nil, err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, nil, err
+	}
+	dir, err := os.MkdirTemp("", "quiz-run-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cpu := int(r.timeout.Seconds() + 0.999)
+	args := []string{ExecCommand, "--cpu", strconv.Itoa(cpu), "--memory", strconv.FormatInt(r.memory, 10), "--write", dir}
+	for _, p := range r.readable {
+		args = append(args, "--read", p)
+	}
+	args = append(args, "--", r.interp, "-I", "-S", "-c", harness)
+
+	var out, errOut limitedBuffer
+	command := func(namespaces bool) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, self, args...)
+		cmd.Dir = dir
+		cmd.Env = []string{"PATH=/usr/bin:/bin", "HOME=" + dir, "LANG=C.UTF-8", "PYTHONDONTWRITEBYTECODE=1"}
+		cmd.Stdin = bytes.NewReader(input)
+		cmd.Stdout, cmd.Stderr = &out, &errOut
+		confine(cmd, namespaces)
+		return cmd
+	}
+
+	err = runConfined(command)
+	if ctx.Err() == context.DeadlineExceeded {
+		err = context.DeadlineExceeded
+	}
+	return out.Bytes(), errOut.Bytes(), err
+}
+
+// pathsScript prints the interpreter's executable and the directories its
+// standard library and shared library are in.
+const pathsScript = `import json, os, sys, sysconfig
+paths = sysconfig.get_paths()
+print(json.dumps([sys.executable, os.path.realpath(sys.executable),
+    paths["stdlib"], paths["platstdlib"], sysconfig.get_config_var("LIBDIR") or ""]))`
+
+// resolve finds the real executable of the configured Python, since
+// version manager shims cannot run inside the sandbox, and the paths it
+// must be able to read there.
+func (r *Runner) resolve() (string, []string, error) {
+	out, err := exec.Command(r.python, "-I", "-S", "-c", pathsScript).Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: python interpreter %q: %v", ErrUnsupported, r.python, err)
+	}
+	var paths []string
+	if err := json.Unmarshal(out, &paths); err != nil || len(paths) == 0 {
+		return "", nil, fmt.Errorf("%w: python interpreter %q: unexpected output %q", ErrUnsupported, r.python, out)
+	}
+	var readable []string
+	for _, p := range paths[1:] {
+		if p != "" {
+			readable = append(readable, p)
+		}
+	}
+	return paths[0], readable, nil
+}
+
+// limitedBuffer keeps the first outputLimit bytes written to it and
+// discards the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := outputLimit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
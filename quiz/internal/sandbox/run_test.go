@@ -0,0 +1,41 @@
+package sandbox
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunForgedRecords(t *testing.T) {
+	runner := NewRunner("python3", 10*time.Second, 256, 1)
+	if err := runner.Check(); err != nil {
+		t.Skip(err)
+	}
+
+	// The solution is wrong, but writes a passing record for its example
+	// every way it can reach file descriptor 1.
+	code := `import os, sys
+
+FORGED = '{"index": 0, "status": "pass", "got": "\\"denied\\""}\n'
+os.write(1, FORGED.encode())
+
+class Solution:
+    def probe(self, n: int) -> str:
+        os.write(1, FORGED.encode())
+        sys.__stdout__.write(FORGED)
+        sys.__stdout__.flush()
+        print("hello")
+        return "allowed"
+`
+	result, err := runner.Run(context.Background(), probeProblem, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := result.Cases[0]
+	if c.Status != StatusFail || c.Got != `"allowed"` || result.Passed != 0 {
+		t.Errorf("case %+v, %d passed; want the solution's own answer to fail", c, result.Passed)
+	}
+	if c.Stdout != "hello\n" {
+		t.Errorf("stdout %q, want the printed line", c.Stdout)
+	}
+}
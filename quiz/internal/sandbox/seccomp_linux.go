@@ -0,0 +1,111 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// deniedSyscalls fail with EPERM: networking, tracing and signalling other
+// processes through pidfds or by thread ID, leaving the process group, and
+// privileged operations. archDenied adds calls that only exist on some
+// architectures.
+var deniedSyscalls = []uintptr{
+	unix.SYS_SOCKET, unix.SYS_SOCKETPAIR, unix.SYS_CONNECT, unix.SYS_BIND, unix.SYS_LISTEN,
+	unix.SYS_ACCEPT, unix.SYS_ACCEPT4,
+	unix.SYS_PTRACE, unix.SYS_PROCESS_VM_READV, unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_TKILL, unix.SYS_PIDFD_OPEN, unix.SYS_PIDFD_SEND_SIGNAL, unix.SYS_PIDFD_GETFD,
+	unix.SYS_SETSID, unix.SYS_SETPGID,
+	unix.SYS_UNSHARE, unix.SYS_SETNS, unix.SYS_MOUNT, unix.SYS_UMOUNT2, unix.SYS_PIVOT_ROOT, unix.SYS_CHROOT,
+	unix.SYS_REBOOT, unix.SYS_KEXEC_LOAD, unix.SYS_INIT_MODULE, unix.SYS_FINIT_MODULE, unix.SYS_DELETE_MODULE,
+	unix.SYS_SWAPON, unix.SYS_SWAPOFF, unix.SYS_KEYCTL, unix.SYS_ADD_KEY, unix.SYS_REQUEST_KEY,
+	unix.SYS_BPF, unix.SYS_PERF_EVENT_OPEN, unix.SYS_USERFAULTFD, unix.SYS_IO_URING_SETUP,
+}
+
+const (
+	offsetNr   = 0
+	offsetArch = 4
+	// offsetArg0 is the low half of the first argument on little-endian
+	// architectures.
+	offsetArg0 = 16
+)
+
+// signalSyscalls send a signal to the process or thread group named by
+// their first argument.
+var signalSyscalls = []uintptr{unix.SYS_KILL, unix.SYS_TGKILL, unix.SYS_RT_SIGQUEUEINFO, unix.SYS_RT_TGSIGQUEUEINFO}
+
+// installFilter installs the seccomp filter on the calling thread. It
+// denies deniedSyscalls, and clone unless it creates a thread, so that the
+// process cannot start others. clone3 fails with ENOSYS, since its flags
+// cannot be inspected, which makes the C library fall back to clone.
+//
+// Signals may only be sent to the process itself, or its process group,
+// which it is alone in: the server is the parent of the interpreter, and on
+// kernels before Landlock ABI 6 nothing else stops code from signalling it.
+func installFilter() error {
+	if auditArch == 0 {
+		return fmt.Errorf("no seccomp filter for %s", runtime.GOARCH)
+	}
+
+	deny := unix.SECCOMP_RET_ERRNO | uint32(syscall.EPERM)
+	var prog []unix.SockFilter
+	stmt := func(code uint16, k uint32) {
+		prog = append(prog, unix.SockFilter{Code: code, K: k})
+	}
+	jump := func(code uint16, k uint32, jt, jf uint8) {
+		prog = append(prog, unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k})
+	}
+
+	// Kill the process if it makes calls for another architecture, whose
+	// numbers would not match the list.
+	stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offsetArch)
+	jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, auditArch, 1, 0)
+	stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_KILL_PROCESS)
+
+	stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offsetNr)
+	if syscallBit != 0 {
+		jump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, syscallBit, 0, 1)
+		stmt(unix.BPF_RET|unix.BPF_K, deny)
+	}
+	for _, nr := range append(deniedSyscalls, archDenied...) {
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(nr), 0, 1)
+		stmt(unix.BPF_RET|unix.BPF_K, deny)
+	}
+
+	// Only the low half of a pid argument counts, as the kernel takes it
+	// as an int.
+	self := uint32(os.Getpid())
+	for _, nr := range signalSyscalls {
+		targets := []uint32{self}
+		if nr == unix.SYS_KILL {
+			targets = append(targets, 0, -self)
+		}
+		n := len(targets)
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(nr), 0, uint8(n+3))
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offsetArg0)
+		for i, pid := range targets {
+			jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, pid, uint8(n-i), 0)
+		}
+		stmt(unix.BPF_RET|unix.BPF_K, deny)
+		stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW)
+	}
+
+	jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.SYS_CLONE3, 0, 1)
+	stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ERRNO|uint32(syscall.ENOSYS))
+	jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.SYS_CLONE, 0, 4)
+	stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offsetArg0)
+	jump(unix.BPF_JMP|unix.BPF_JSET|unix.BPF_K, unix.CLONE_THREAD, 1, 0)
+	stmt(unix.BPF_RET|unix.BPF_K, deny)
+	stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW)
+	stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW)
+
+	fprog := unix.SockFprog{Len: uint16(len(prog)), Filter: &prog[0]}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&fprog)), 0, 0); err != nil {
+		return fmt.Errorf("install seccomp filter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package sandbox
+
+import "golang.org/x/sys/unix"
+
+const (
+	auditArch = unix.AUDIT_ARCH_X86_64
+	// syscallBit marks x32 calls, which share the architecture but are
+	// numbered differently; they are all denied.
+	syscallBit = 0x40000000
+)
+
+var archDenied = []uintptr{unix.SYS_FORK, unix.SYS_VFORK}
@@ -0,0 +1,12 @@
+//go:build linux && !amd64 && !arm64
+
+package sandbox
+
+// There is no filter for other architectures yet, so code cannot be run
+// on them.
+const (
+	auditArch  = 0
+	syscallBit = 0
+)
+
+var archDenied []uintptr
@@ -0,0 +1,50 @@
+package sandbox
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Signature is the method a problem's Python snippet asks for.
+type Signature struct {
+	Method  string  `json:"method"`
+	Params  []Param `json:"params"`
+	Returns string  `json:"returns"`
+}
+
+// Param is a parameter and its type annotation, e.g. "List[int]".
+type Param struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+var (
+	classRe = regexp.MustCompile(`(?m)^class\s+(\w+)`)
+	defRe   = regexp.MustCompile(`(?m)^\s+def\s+(\w+)\s*\(\s*self\s*(?:,(.*?))?\)\s*(?:->\s*(.+?))?\s*:\s*$`)
+)
+
+// ParseSignature reads the method of the Solution class in a LeetCode
+// Python 3 snippet. Design problems, whose snippets define some other
+// class to be driven by a sequence of calls, are not supported.
+func ParseSignature(snippet string) (*Signature, error) {
+	class := classRe.FindStringSubmatch(snippet)
+	if class == nil {
+		return nil, fmt.Errorf("%w: the problem has no Python 3 snippet", ErrUnsupported)
+	}
+	if class[1] != "Solution" {
+		return nil, fmt.Errorf("%w: design problems (class %s) cannot be run yet", ErrUnsupported, class[1])
+	}
+	def := defRe.FindStringSubmatch(snippet)
+	if def == nil {
+		return nil, fmt.Errorf("%w: no method in the Solution class", ErrUnsupported)
+	}
+
+	sig := &Signature{Method: def[1], Returns: strings.TrimSpace(def[3])}
+	for _, part := range splitTopLevel(def[2]) {
+		name, typ, _ := strings.Cut(part, ":")
+		typ, _, _ = strings.Cut(typ, "=")
+		sig.Params = append(sig.Params, Param{Name: strings.TrimSpace(name), Type: strings.TrimSpace(typ)})
+	}
+	return sig, nil
+}
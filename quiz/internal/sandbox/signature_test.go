@@ -0,0 +1,79 @@
+package sandbox
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSignature(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet string
+		want    *Signature
+		// unsupported is set if the snippet should give ErrUnsupported.
+		unsupported bool
+	}{
+		{
+			name:    "list and int",
+			snippet: "class Solution:\n    def twoSum(self, nums: List[int], target: int) -> List[int]:\n        ",
+			want: &Signature{Method: "twoSum", Returns: "List[int]",
+				Params: []Param{{Name: "nums", Type: "List[int]"}, {Name: "target", Type: "int"}}},
+		},
+		{
+			name: "linked list",
+			snippet: "# Definition for singly-linked list.\n# class ListNode:\n#     def __init__(self, val=0, next=None):\n" +
+				"#         self.val = val\n#         self.next = next\n" +
+				"class Solution:\n    def reverseList(self, head: Optional[ListNode]) -> Optional[ListNode]:\n        ",
+			want: &Signature{Method: "reverseList", Returns: "Optional[ListNode]",
+				Params: []Param{{Name: "head", Type: "Optional[ListNode]"}}},
+		},
+		{
+			name: "trees",
+			snippet: "# Definition for a binary tree node.\n# class TreeNode:\n#     def __init__(self, val=0, left=None, right=None):\n" +
+				"class Solution:\n    def mergeTrees(self, root1: Optional[TreeNode], root2: Optional[TreeNode]) -> Optional[TreeNode]:\n        ",
+			want: &Signature{Method: "mergeTrees", Returns: "Optional[TreeNode]",
+				Params: []Param{{Name: "root1", Type: "Optional[TreeNode]"}, {Name: "root2", Type: "Optional[TreeNode]"}}},
+		},
+		{
+			name:    "commas in a type and a default",
+			snippet: "class Solution:\n    def rotate(self, grid: List[List[int]], counts: Dict[str, int], k: int = 1) -> None:\n        \"\"\"\n        Do not return anything.\n        \"\"\"\n",
+			want: &Signature{Method: "rotate", Returns: "None",
+				Params: []Param{{Name: "grid", Type: "List[List[int]]"}, {Name: "counts", Type: "Dict[str, int]"}, {Name: "k", Type: "int"}}},
+		},
+		{
+			name:    "no annotations",
+			snippet: "class Solution:\n    def add(self, a, b):\n        ",
+			want:    &Signature{Method: "add", Params: []Param{{Name: "a"}, {Name: "b"}}},
+		},
+		{
+			name:    "no parameters",
+			snippet: "class Solution:\n    def answer(self) -> int:\n        ",
+			want:    &Signature{Method: "answer", Returns: "int"},
+		},
+		{
+			name:        "design problem",
+			snippet:     "class LRUCache:\n\n    def __init__(self, capacity: int):\n        \n\n    def get(self, key: int) -> int:\n        ",
+			unsupported: true,
+		},
+		{name: "no snippet", snippet: "", unsupported: true},
+		{name: "no method", snippet: "class Solution:\n    pass\n", unsupported: true},
+	}
+
+	for _, tt := range tests {
+		sig, err := ParseSignature(tt.snippet)
+		if tt.unsupported {
+			if !errors.Is(err, ErrUnsupported) {
+				t.Errorf("%s: err = %v, want ErrUnsupported", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(sig, tt.want) {
+			t.Errorf("%s: signature %+v, want %+v", tt.name, sig, tt.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package similar finds problems like a given one by comparing embedding
+// vectors of their titles and descriptions.
+package similar
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/llm"
+)
+
+// embedBatch is how many texts are sent per embeddings request, and
+// maxTextLength caps each in bytes to stay within model input limits.
+const (
+	embedBatch    = 64
+	maxTextLength = 8000
+)
+
+// Embedder turns texts into vectors. Vectors from different models are
+// never compared.
+type Embedder interface {
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// corpusEmbedder is an Embedder whose vectors depend on every problem, so
+// all of them are computed again whenever any is missing.
+type corpusEmbedder interface {
+	Embedder
+	Fit(texts []string)
+}
+
+type llmEmbedder struct {
+	client *llm.Client
+	model  string
+}
+
+// NewLLMEmbedder returns an Embedder using the model through client's
+// embeddings endpoint.
+func NewLLMEmbedder(client *llm.Client, model string) Embedder {
+	return &llmEmbedder{client: client, model: model}
+}
+
+func (e *llmEmbedder) Model() string {
+	return e.model
+}
+
+func (e *llmEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.client.Embed(ctx, e.model, texts)
+}
+
+// Index keeps every problem's vector stored and searches them.
+type Index struct {
+	db       *db.DB
+	embedder Embedder
+	// mu keeps concurrent requests from embedding the same problems.
+	mu sync.Mutex
+}
+
+func NewIndex(db *db.DB, embedder Embedder) *Index {
+	return &Index{db: db, embedder: embedder}
+}
+
+// Model returns the name of the embedding model the index uses.
+func (ix *Index) Model() string {
+	return ix.embedder.Model()
+}
+
+// Local reports whether vectors are computed without calling the LLM
+// provider.
+func (ix *Index) Local() bool {
+	_, ok := ix.embedder.(corpusEmbedder)
+	return ok
+}
+
+// Result is a problem and how similar it is to the one searched for, as
+// the cosine of the angle between their vectors.
+type Result struct {
+	db.ProblemSummary
+	Similarity float64 `json:"similarity"`
+}
+
+// Sync computes vectors for problems that have none under the index's
+// model, such as new problems and those whose text has changed, and
+// returns how many it stored.
+func (ix *Index) Sync(ctx context.Context) (int, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	missing, err := ix.db.UnembeddedProblems(ix.Model())
+	if err != nil || len(missing) == 0 {
+		return 0, err
+	}
+	all, err := ix.db.ProblemTexts()
+	if err != nil {
+		return 0, err
+	}
+
+	texts := all
+	if ce, ok := ix.embedder.(corpusEmbedder); ok {
+		docs := make([]string, len(all))
+		for i, t := range all {
+			docs[i] = problemText(t)
+		}
+		ce.Fit(docs)
+	} else {
+		want := make(map[int]bool, len(missing))
+		for _, id := range missing {
+			want[id] = true
+		}
+		texts = nil
+		for _, t := range all {
+			if want[t.ID] {
+				texts = append(texts, t)
+			}
+		}
+	}
+
+	stored := 0
+	for start := 0; start < len(texts); start += embedBatch {
+		batch := texts[start:min(start+embedBatch, len(texts))]
+		inputs := make([]string, len(batch))
+		for i, t := range batch {
+			inputs[i] = problemText(t)
+		}
+		vectors, err := ix.embedder.Embed(ctx, inputs)
+		if err != nil {
+			return stored, fmt.Errorf("embed problems: %w", err)
+		}
+
+		embeddings := make([]db.Embedding, len(batch))
+		for i, t := range batch {
+			embeddings[i] = db.Embedding{ProblemID: t.ID, Vector: vectors[i]}
+		}
+		if err := ix.db.StoreEmbeddings(ix.Model(), embeddings); err != nil {
+			return stored, err
+		}
+		stored += len(batch)
+	}
+	return stored, nil
+}
+
+// Similar returns up to limit problems most similar to the given one,
+// best first, among those matching params' filters. It syncs the index
+// first, so the first search can take a while.
+func (ix *Index) Similar(ctx context.Context, problemID int, params db.ListParams, limit int) ([]Result, error) {
+	if _, err := ix.Sync(ctx); err != nil {
+		return nil, err
+	}
+	target, err := ix.db.GetEmbedding(problemID, ix.Model())
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, fmt.Errorf("problem %d has no embedding", problemID)
+	}
+	candidates, err := ix.db.ListEmbeddings(ix.Model(), params)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[int]float64, len(candidates))
+	var ids []int
+	for _, c := range candidates {
+		if c.ProblemID != problemID {
+			scores[c.ProblemID] = cosine(target, c.Vector)
+			ids = append(ids, c.ProblemID)
+		}
+	}
+	sort.SliceStable(ids, func(i, j int) bool { return scores[ids[i]] > scores[ids[j]] })
+	ids = ids[:min(limit, len(ids))]
+
+	summaries, err := ix.db.ProblemSummaries(ids)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(summaries))
+	for i, s := range summaries {
+		results[i] = Result{ProblemSummary: s, Similarity: scores[s.ID]}
+	}
+	return results, nil
+}
+
+// problemText is what a problem is embedded from.
+func problemText(t db.ProblemText) string {
+	text := t.Title + "\n\n" + t.Description
+	if len(text) > maxTextLength {
+		text = strings.ToValidUTF8(text[:maxTextLength], "")
+	}
+	return text
+}
+
+// cosine returns the cosine similarity of a and b, or 0 if they differ in
+// length or either is zero.
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
@@ -0,0 +1,173 @@
+package similar
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/leettomato/quiz/internal/db"
+)
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		name, text string
+		want       []string
+	}{
+		{"words and pairs", "The Sums of two arrays, given nums!", []string{"sum", "two", "array", "num", "sum two", "two array", "array num"}},
+		{"not plurals", "a bus, a class, x", []string{"bus", "class", "bus class"}},
+		{"only stop words", "Return it if it is there.", nil},
+	}
+	for _, tt := range tests {
+		if got := terms(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: terms = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTFIDF(t *testing.T) {
+	texts := []string{
+		"Find two numbers in the array that add up to the target.",
+		"Find three numbers in the array that add up to zero.",
+		"Reverse a linked list in place.",
+		"Reverse a linked list in place.",
+		"The",
+	}
+	tfidf := NewTFIDF()
+	tfidf.Fit(texts)
+	vectors, err := tfidf.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, v := range vectors[:4] {
+		if len(v) != tfidfDims {
+			t.Fatalf("vector %d has %d dimensions, want %d", i, len(v), tfidfDims)
+		}
+		if n := cosine(v, v); math.Abs(n-1) > 1e-6 {
+			t.Errorf("vector %d: cosine with itself %v", i, n)
+		}
+	}
+	if s := cosine(vectors[2], vectors[3]); math.Abs(s-1) > 1e-6 {
+		t.Errorf("the same text has similarity %v, want 1", s)
+	}
+	if related, unrelated := cosine(vectors[0], vectors[1]), cosine(vectors[0], vectors[2]); related <= unrelated {
+		t.Errorf("similarity %v to a related text and %v to an unrelated one", related, unrelated)
+	}
+	if s := cosine(vectors[0], vectors[4]); s != 0 {
+		t.Errorf("a text of stop words has similarity %v, want 0", s)
+	}
+}
+
+func TestCosine(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"same direction", []float32{1, 2}, []float32{2, 4}, 1},
+		{"opposite", []float32{1, 0}, []float32{-3, 0}, -1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"different lengths", []float32{1, 0}, []float32{1, 0, 0}, 0},
+		{"zero", []float32{0, 0}, []float32{1, 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := cosine(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: cosine = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// countingEmbedder embeds every text as the same vector and records how
+// many texts it was given.
+type countingEmbedder struct {
+	texts int
+}
+
+func (e *countingEmbedder) Model() string { return "counting" }
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.texts += len(texts)
+	vectors := make([][]float32, len(texts))
+	for i := range vectors {
+		vectors[i] = []float32{1, 0}
+	}
+	return vectors, nil
+}
+
+func TestIndex(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	question := func(id, slug, title, difficulty, description string) db.ImportQuestion {
+		return db.ImportQuestion{
+			Title: title, FrontendID: json.RawMessage(id), ProblemSlug: slug,
+			Difficulty: difficulty, Description: description,
+		}
+	}
+	questions := []db.ImportQuestion{
+		question("1", "two-sum", "Two Sum", "Easy", "Find two numbers in the array that add up to the target."),
+		question("2", "reverse-list", "Reverse Linked List", "Easy", "Reverse a singly linked list."),
+		question("3", "three-sum", "Three Sum", "Medium", "Find three numbers in the array that add up to zero."),
+		question("4", "four-sum", "Four Sum", "Medium", "Find four numbers in the array that add up to the target."),
+	}
+	if _, err := d.ImportProblems(questions); err != nil {
+		t.Fatal(err)
+	}
+	twoSum, err := d.GetProblemBySlug("two-sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ix := NewIndex(d, NewTFIDF())
+	if !ix.Local() || ix.Model() != "tfidf" {
+		t.Errorf("index of model %q local = %v", ix.Model(), ix.Local())
+	}
+	tests := []struct {
+		name   string
+		params db.ListParams
+		limit  int
+		want   []string
+	}{
+		{name: "best first", limit: 10, want: []string{"four-sum", "three-sum", "reverse-list"}},
+		{name: "limit", limit: 1, want: []string{"four-sum"}},
+		{name: "filtered", params: db.ListParams{Difficulty: "Easy"}, limit: 10, want: []string{"reverse-list"}},
+	}
+	for _, tt := range tests {
+		results, err := ix.Similar(context.Background(), twoSum.ID, tt.params, tt.limit)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var slugs []string
+		for i, r := range results {
+			slugs = append(slugs, r.Slug)
+			if i > 0 && r.Similarity > results[i-1].Similarity {
+				t.Errorf("%s: results not best first: %+v", tt.name, results)
+			}
+		}
+		if !reflect.DeepEqual(slugs, tt.want) {
+			t.Errorf("%s: similar problems %v, want %v", tt.name, slugs, tt.want)
+		}
+	}
+	if n, err := ix.Sync(context.Background()); n != 0 || err != nil {
+		t.Errorf("Sync after a search stored %d vectors (err %v), want none", n, err)
+	}
+
+	// An embedder that is not fitted to the corpus is only given the
+	// problems it has no vectors for.
+	embedder := &countingEmbedder{}
+	ix = NewIndex(d, embedder)
+	if n, err := ix.Sync(context.Background()); n != 4 || err != nil {
+		t.Fatalf("first Sync stored %d vectors (err %v), want 4", n, err)
+	}
+	if _, err := d.ImportProblems([]db.ImportQuestion{question("5", "two-sum-ii", "Two Sum II", "Medium", "The array is sorted.")}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := ix.Sync(context.Background()); n != 1 || err != nil || embedder.texts != 5 {
+		t.Errorf("second Sync stored %d vectors (err %v) from %d texts in all, want 1 from 5", n, err, embedder.texts)
+	}
+}
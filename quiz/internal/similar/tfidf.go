@@ -0,0 +1,109 @@
+package similar
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// tfidfDims is the length of TF-IDF vectors: terms are hashed into this
+// many buckets, so that vectors have a fixed size however large the
+// vocabulary.
+const tfidfDims = 1024
+
+// stopWords are too common in problem statements to tell problems apart.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "can": true, "each": true, "for": true, "from": true, "given": true,
+	"has": true, "have": true, "if": true, "in": true, "is": true, "it": true, "its": true,
+	"of": true, "on": true, "or": true, "return": true, "such": true, "that": true,
+	"the": true, "their": true, "there": true, "this": true, "to": true, "which": true,
+	"with": true, "you": true, "your": true,
+}
+
+// TFIDF embeds texts locally as TF-IDF weights of their words and pairs of
+// adjacent words, fitted to the problems' texts.
+type TFIDF struct {
+	docs int
+	df   map[string]int
+}
+
+func NewTFIDF() *TFIDF {
+	return &TFIDF{df: map[string]int{}}
+}
+
+func (t *TFIDF) Model() string {
+	return "tfidf"
+}
+
+// Fit counts how many texts each term appears in.
+func (t *TFIDF) Fit(texts []string) {
+	t.docs = len(texts)
+	t.df = map[string]int{}
+	for _, text := range texts {
+		seen := map[string]bool{}
+		for _, term := range terms(text) {
+			if !seen[term] {
+				seen[term] = true
+				t.df[term]++
+			}
+		}
+	}
+}
+
+// Embed returns unit vectors of the texts' term weights, (1 + log tf) ×
+// idf, summed into hashed buckets.
+func (t *TFIDF) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		tf := map[string]int{}
+		for _, term := range terms(text) {
+			tf[term]++
+		}
+
+		v := make([]float32, tfidfDims)
+		for term, n := range tf {
+			idf := math.Log(float64(t.docs+1)/float64(t.df[term]+1)) + 1
+			h := fnv.New32a()
+			h.Write([]byte(term))
+			v[h.Sum32()%tfidfDims] += float32((1 + math.Log(float64(n))) * idf)
+		}
+
+		var norm float64
+		for _, x := range v {
+			norm += float64(x) * float64(x)
+		}
+		if norm > 0 {
+			for j := range v {
+				v[j] = float32(float64(v[j]) / math.Sqrt(norm))
+			}
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+// terms splits text into lower-case words, dropping stop words and crudely
+// removing plurals, followed by each pair of adjacent words.
+func terms(text string) []string {
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len(w) < 2 || stopWords[w] {
+			continue
+		}
+		if len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") {
+			w = w[:len(w)-1]
+		}
+		words = append(words, w)
+	}
+
+	terms := words
+	for i := 1; i < len(words); i++ {
+		terms = append(terms, words[i-1]+" "+words[i])
+	}
+	return terms
+}
@@ -1,20 +1,34 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/leettomato/quiz/internal/auth"
+	"github.com/leettomato/quiz/internal/budget"
 	"github.com/leettomato/quiz/internal/config"
 	"github.com/leettomato/quiz/internal/db"
+	"github.com/leettomato/quiz/internal/eval"
 	"github.com/leettomato/quiz/internal/handler"
 	"github.com/leettomato/quiz/internal/llm"
+	"github.com/leettomato/quiz/internal/llm/llmtest"
+	"github.com/leettomato/quiz/internal/review"
+	"github.com/leettomato/quiz/internal/rubric"
+	"github.com/leettomato/quiz/internal/sandbox"
+	"github.com/leettomato/quiz/internal/similar"
+
+	"golang.org/x/term"
 )
 
 func main() {
@@ -28,6 +42,29 @@ func main() {
 		runServer()
 	case "grade":
 		runGrade(os.Args[2:])
+	case "migrate":
+		runMigrate(os.Args[2:])
+	case "import":
+		runImport(os.Args[2:])
+	case "review":
+		runReview(os.Args[2:])
+	case "random":
+		runRandom(os.Args[2:])
+	case "similar":
+		runSimilar(os.Args[2:])
+	case "user":
+		runUser(os.Args[2:])
+	case "token":
+		runToken(os.Args[2:])
+	case "eval":
+		runEval(os.Args[2:])
+	case "run":
+		runRun(os.Args[2:])
+	case "fake-llm":
+		runFakeLLM(os.Args[2:])
+	case sandbox.ExecCommand:
+		// Used by the sandbox to confine a solution before running it.
+		sandbox.ExecMain(os.Args[2:])
 	default:
 		printUsage()
 		os.Exit(1)
@@ -40,6 +77,16 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "Commands:")
 	fmt.Fprintln(os.Stderr, "  server    Start the web server")
 	fmt.Fprintln(os.Stderr, "  grade     Grade an answer via CLI")
+	fmt.Fprintln(os.Stderr, "  run       Run a Python solution against a problem's examples")
+	fmt.Fprintln(os.Stderr, "  migrate   Apply database schema migrations")
+	fmt.Fprintln(os.Stderr, "  import    Import problems from merged_problems.json")
+	fmt.Fprintln(os.Stderr, "  review    List problems due for review")
+	fmt.Fprintln(os.Stderr, "  random    Pick a random problem")
+	fmt.Fprintln(os.Stderr, "  similar   List problems similar to one, embedding any new problems first")
+	fmt.Fprintln(os.Stderr, "  user      Manage user accounts (add, remove, passwd, list)")
+	fmt.Fprintln(os.Stderr, "  token     Manage personal API tokens (create, list, revoke)")
+	fmt.Fprintln(os.Stderr, "  eval      Measure grading accuracy against labelled answers")
+	fmt.Fprintln(os.Stderr, "  fake-llm  Serve a fake OpenAI-compatible LLM for offline development")
 }
 
 func runServer() {
@@ -56,25 +103,93 @@ func runServer() {
 	}
 	defer database.Close()
 
-	llmClient := llm.NewClient(cfg.LLMBaseURL, cfg.LLMAPIKey, cfg.LLMModel)
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
+		os.Exit(1)
+	}
+	llmClient.SetCache(database)
+
+	rubrics, err := rubric.LoadSet(cfg.RubricsDir, cfg.DefaultRubric)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Rubric error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := seedInitialUser(database, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Initial user error: %v\n", err)
+		os.Exit(1)
+	}
+
+	guard := budget.NewGuard(database, cfg.UserBudget, cfg.GlobalBudget, cfg.LLMPricing,
+		budget.NewRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst))
 
+	runner := newRunner(cfg)
+	runLimiter := budget.NewRateLimiter(cfg.RunRateLimitPerMinute, cfg.RunRateLimitBurst)
+	sessions := auth.NewSessions(database, cfg.SessionTTL, auth.NewThrottle(cfg.LoginMaxFailures, cfg.LoginLockout, cfg.ClientIPHeader))
+	tokens := auth.NewTokens(database)
+	authHandler := handler.NewAuthHandler(sessions)
+	tokensHandler := handler.NewTokensHandler(database, tokens)
 	problemsHandler := handler.NewProblemsHandler(database)
-	gradingHandler := handler.NewGradingHandler(database, llmClient)
+	gradingHandler := handler.NewGradingHandler(database, llmClient, rubrics, cfg.LLMMaxSamples, runner)
+	hintsHandler := handler.NewHintsHandler(database, llmClient, cfg.HintPenalty)
+	attemptsHandler := handler.NewAttemptsHandler(database)
+	messagesHandler := handler.NewMessagesHandler(database, llmClient, rubrics)
+	interviewsHandler := handler.NewInterviewsHandler(database, llmClient, rubrics)
+	reviewHandler := handler.NewReviewHandler(database)
+	usageHandler := handler.NewUsageHandler(guard)
+	runHandler := handler.NewRunHandler(database, runner, llmClient)
+	similarIndex := newSimilarIndex(cfg, database, llmClient)
+	similarHandler := handler.NewSimilarHandler(database, similarIndex)
+	similarList := similarHandler.List
+	if !similarIndex.Local() {
+		// Embedding new problems costs tokens.
+		similarList = guard.Limit(similarList)
+	}
 
 	mux := http.NewServeMux()
 
-	// API routes
-	mux.HandleFunc("GET /api/problems", problemsHandler.List)
-	mux.HandleFunc("GET /api/problems/{id}", problemsHandler.Get)
-	mux.HandleFunc("GET /api/topics", problemsHandler.Topics)
-	mux.HandleFunc("POST /api/grade", gradingHandler.Grade)
-	mux.HandleFunc("GET /api/smoke", gradingHandler.Smoke)
+	// API routes. API tokens are limited to the scope each route names;
+	// sessions can use every route. Routes that call the LLM are subject to
+	// the budgets and rate limit; routes that run code have their own rate
+	// limit.
+	mux.HandleFunc("POST /api/login", authHandler.Login)
+	mux.HandleFunc("POST /api/logout", authHandler.Logout)
+	mux.HandleFunc("GET /api/me", authHandler.Me)
+	mux.HandleFunc("GET /api/tokens", auth.RequireSession(tokensHandler.List))
+	mux.HandleFunc("POST /api/tokens", auth.RequireSession(tokensHandler.Create))
+	mux.HandleFunc("DELETE /api/tokens/{id}", auth.RequireSession(tokensHandler.Revoke))
+	mux.HandleFunc("GET /api/problems", auth.RequireScope(auth.ScopeProblemsRead, problemsHandler.List))
+	mux.HandleFunc("GET /api/problems/random", auth.RequireScope(auth.ScopeProblemsRead, problemsHandler.Random))
+	mux.HandleFunc("GET /api/problems/{id}", auth.RequireScope(auth.ScopeProblemsRead, problemsHandler.Get))
+	mux.HandleFunc("GET /api/problems/{id}/hints", auth.RequireScope(auth.ScopeProblemsRead, hintsHandler.List))
+	mux.HandleFunc("POST /api/problems/{id}/hint", auth.RequireScope(auth.ScopeGrade, guard.Limit(hintsHandler.Next)))
+	mux.HandleFunc("GET /api/problems/{id}/similar", auth.RequireScope(auth.ScopeProblemsRead, similarList))
+	mux.HandleFunc("GET /api/problems/{id}/attempts", auth.RequireScope(auth.ScopeAttemptsRead, attemptsHandler.ListForProblem))
+	mux.HandleFunc("GET /api/topics", auth.RequireScope(auth.ScopeProblemsRead, problemsHandler.Topics))
+	mux.HandleFunc("POST /api/grade", auth.RequireScope(auth.ScopeGrade, guard.Limit(gradingHandler.Grade)))
+	mux.HandleFunc("POST /api/grade/stream", auth.RequireScope(auth.ScopeGrade, guard.Limit(gradingHandler.GradeStream)))
+	mux.HandleFunc("POST /api/run", auth.RequireScope(auth.ScopeGrade, runLimiter.Limit(runHandler.Run)))
+	mux.HandleFunc("POST /api/run/tests", auth.RequireScope(auth.ScopeGrade, guard.Limit(runLimiter.Limit(runHandler.Tests))))
+	mux.HandleFunc("POST /api/run/benchmark", auth.RequireScope(auth.ScopeGrade, guard.Limit(runLimiter.Limit(runHandler.Benchmark))))
+	mux.HandleFunc("GET /api/rubrics", auth.RequireScope(auth.ScopeProblemsRead, gradingHandler.Rubrics))
+	mux.HandleFunc("GET /api/smoke", auth.RequireScope(auth.ScopeGrade, guard.Limit(gradingHandler.Smoke)))
+	mux.HandleFunc("GET /api/usage", auth.RequireScope(auth.ScopeGrade, usageHandler.Get))
+	mux.HandleFunc("GET /api/attempts", auth.RequireScope(auth.ScopeAttemptsRead, attemptsHandler.List))
+	mux.HandleFunc("GET /api/attempts/{id}", auth.RequireScope(auth.ScopeAttemptsRead, attemptsHandler.Get))
+	mux.HandleFunc("GET /api/attempts/{id}/messages", auth.RequireScope(auth.ScopeAttemptsRead, messagesHandler.List))
+	mux.HandleFunc("POST /api/attempts/{id}/messages", auth.RequireScope(auth.ScopeGrade, guard.Limit(messagesHandler.Post)))
+	mux.HandleFunc("POST /api/interviews", auth.RequireScope(auth.ScopeGrade, interviewsHandler.Create))
+	mux.HandleFunc("GET /api/interviews/{id}", auth.RequireScope(auth.ScopeAttemptsRead, interviewsHandler.Get))
+	mux.HandleFunc("POST /api/interviews/{id}/messages", auth.RequireScope(auth.ScopeGrade, guard.Limit(interviewsHandler.Post)))
+	mux.HandleFunc("GET /api/review/due", auth.RequireScope(auth.ScopeAttemptsRead, reviewHandler.Due))
 
 	// SPA static files
 	mux.Handle("/", handler.SPAHandler(cfg.StaticDir))
 
-	// Wrap with auth
-	authed := auth.BasicAuth(cfg.AuthPassword)(mux)
+	// Wrap with auth: bearer tokens first, then sessions.
+	// Logging in and out needs no credentials.
+	authed := tokens.Middleware()(sessions.Middleware("/api/login", "/api/logout")(mux))
 
 	addr := ":" + cfg.Port
 	log.Printf("Starting server on %s", addr)
@@ -83,21 +198,57 @@ func runServer() {
 	}
 }
 
+func newLLMClient(cfg *config.Config) (*llm.Client, error) {
+	policy := llm.DefaultRetryPolicy()
+	policy.Timeout = cfg.LLMTimeout
+	policy.MaxRetries = cfg.LLMMaxRetries
+
+	provider, err := llm.NewProvider(cfg.LLMProvider, cfg.LLMBaseURL, cfg.LLMAPIKey, policy)
+	if err != nil {
+		return nil, err
+	}
+	client := llm.NewClient(provider, cfg.LLMModel)
+	client.SetMaxGradingAttempts(cfg.LLMGradingAttempts)
+	client.SetConsensusModels(cfg.LLMConsensusModels)
+	return client, nil
+}
+
+// newSimilarIndex returns the index of problem embeddings, computed with
+// EMBEDDING_MODEL through the LLM provider if it is set and by TF-IDF
+// otherwise.
+func newSimilarIndex(cfg *config.Config, database *db.DB, client *llm.Client) *similar.Index {
+	if cfg.EmbeddingModel == "" {
+		return similar.NewIndex(database, similar.NewTFIDF())
+	}
+	return similar.NewIndex(database, similar.NewLLMEmbedder(client, cfg.EmbeddingModel))
+}
+
 func runGrade(args []string) {
 	fs := flag.NewFlagSet("grade", flag.ExitOnError)
 	problemSlug := fs.String("problem", "", "Problem slug (e.g., two-sum)")
 	problemID := fs.Int("problem-id", 0, "Problem database ID")
 	answerFile := fs.String("answer", "", "Path to answer file (reads stdin if omitted)")
+	rubricName := fs.String("rubric", "", "Rubric name or path to a rubric file (default from DEFAULT_RUBRIC)")
+	userName := fs.String("user", "", "User to record the attempt for (default from QUIZ_USER)")
+	noCache := fs.Bool("no-cache", false, "Grade again even if this answer was graded before")
+	samples := fs.Int("samples", 1, "Grade this many times and combine the results by majority vote")
+	codeFile := fs.String("code", "", "Path to a Python solution to time and give the grader as evidence")
 	fs.Parse(args)
 
 	if *problemSlug == "" && *problemID == 0 {
-		fmt.Fprintln(os.Stderr, "Usage: quiz grade --problem <slug> [--answer <file>]")
+		fmt.Fprintln(os.Stderr, "Usage: quiz grade --problem <slug> [--answer <file>] [--code <file.py>]")
 		fmt.Fprintln(os.Stderr, "       quiz grade --problem-id <id> [--answer <file>]")
 		os.Exit(1)
 	}
 
 	cfg := config.LoadForCLI()
 
+	rb, err := loadRubric(cfg, *rubricName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading rubric: %v\n", err)
+		os.Exit(1)
+	}
+
 	database, err := db.Open(cfg.DBPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
@@ -105,6 +256,12 @@ func runGrade(args []string) {
 	}
 	defer database.Close()
 
+	user, err := resolveUser(database, cfg, *userName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	var problem *db.Problem
 	if *problemID > 0 {
 		problem, err = database.GetProblem(*problemID)
@@ -147,39 +304,1070 @@ func runGrade(args []string) {
 		os.Exit(1)
 	}
 
-	fmt.Fprintf(os.Stderr, "Grading with %s via %s...\n\n", cfg.LLMModel, cfg.LLMBaseURL)
+	client, err := newLLMClient(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
+		os.Exit(1)
+	}
+	client.SetCache(database)
+
+	// The CLI is subject to the same budgets as the server, but the rate
+	// limit only means anything within one process.
+	guard := budget.NewGuard(database, cfg.UserBudget, cfg.GlobalBudget, cfg.LLMPricing, nil)
+	if err := guard.Check(user.ID, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var evidence string
+	if *codeFile != "" {
+		data, err := os.ReadFile(*codeFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading code: %v\n", err)
+			os.Exit(1)
+		}
+		runner := newRunner(cfg)
+		gen, err := testGenerator(cfg, database, runner, problem, user.Username, false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating tests: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "Timing the code on growing inputs...")
+		complexity, err := runner.Benchmark(context.Background(), problem, gen, string(data))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error timing code: %v\n", err)
+			os.Exit(1)
+		}
+		printComplexity(os.Stderr, complexity)
+		fmt.Fprintln(os.Stderr)
+		evidence = complexity.Summary()
+	}
+
+	model := cfg.LLMModel
+	if *samples > 1 {
+		if len(cfg.LLMConsensusModels) > 0 {
+			model = strings.Join(cfg.LLMConsensusModels, ", ")
+		}
+		model = fmt.Sprintf("%d samples of %s", *samples, model)
+	}
+	fmt.Fprintf(os.Stderr, "Grading with %s via %s (%s), rubric %s...\n\n", model, cfg.LLMBaseURL, cfg.LLMProvider, rb.Name)
 
-	client := llm.NewClient(cfg.LLMBaseURL, cfg.LLMAPIKey, cfg.LLMModel)
-	result, err := client.Grade(problem, answer)
+	ctx := guard.Context(context.Background(), user.ID)
+	if *noCache {
+		ctx = llm.WithoutCache(ctx)
+	}
+	var result *llm.GradingResult
+	if *samples > 1 {
+		result, err = client.GradeConsensus(ctx, problem, answer, evidence, rb, *samples, nil)
+	} else {
+		result, err = client.Grade(ctx, problem, answer, evidence, rb)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error grading: %v\n", err)
 		os.Exit(1)
 	}
+	if result.Cached {
+		fmt.Fprintln(os.Stderr, "Cached result from an identical earlier answer; use --no-cache to grade again.")
+		fmt.Fprintln(os.Stderr)
+	}
 
+	// Hints taken in the web UI count against the next attempt, wherever it
+	// is graded. Saving the attempt claims them, so the result is printed
+	// after that.
+	attempt := result.ToAttempt(problem.ID, answer, client.Model())
+	attempt.UserID = user.ID
+	if err := database.CreateAttempt(attempt); err != nil {
+		printResult(result)
+		fmt.Fprintf(os.Stderr, "Warning: could not save attempt: %v\n", err)
+		return
+	}
+	result.ApplyHints(attempt)
 	printResult(result)
+
+	state, err := review.Record(database, attempt, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not schedule review: %v\n", err)
+		return
+	}
+	fmt.Printf("\nNext review: %s (in %d days)\n", state.DueAt, state.IntervalDays)
 }
 
-func printResult(r *llm.GradingResult) {
-	criteria := []struct {
-		name   string
-		result llm.CriterionResult
-	}{
-		{"Pattern Identified", r.PatternIdentified},
-		{"Solution Works", r.SolutionWorks},
-		{"Complexity Analysis", r.ComplexityAnalysis},
-		{"Optimal Solution", r.OptimalSolution},
-	}
-
-	score := 0
-	for _, c := range criteria {
-		icon := "\u2717"
-		if c.result.Score {
-			icon = "\u2713"
-			score++
+func newRunner(cfg *config.Config) *sandbox.Runner {
+	return sandbox.NewRunner(cfg.RunPython, cfg.RunTimeout, cfg.RunMemoryMB, cfg.RunMaxConcurrent)
+}
+
+func runRun(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	problemSlug := fs.String("problem", "", "Problem slug (e.g., two-sum)")
+	problemID := fs.Int("problem-id", 0, "Problem database ID")
+	codeFile := fs.String("code", "", "Path to the Python solution (reads stdin if omitted)")
+	tests := fs.Int("tests", 0, "Also compare against a reference solution on this many generated inputs")
+	seed := fs.Int64("seed", 1, "Seed for generated inputs")
+	regenerate := fs.Bool("regenerate", false, "Ask the LLM for a new test generator even if the problem has one")
+	benchmark := fs.Bool("benchmark", false, "Also time the solution on growing inputs and estimate its complexity")
+	userName := fs.String("user", "", "User whose LLM budget test generation uses (default from QUIZ_USER)")
+	fs.Parse(args)
+
+	if *problemSlug == "" && *problemID == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: quiz run --problem <slug> [--code <file.py>] [--tests <n>] [--benchmark]")
+		fmt.Fprintln(os.Stderr, "       quiz run --problem-id <id> [--code <file.py>]")
+		os.Exit(1)
+	}
+
+	cfg := config.LoadForCLI()
+
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	var problem *db.Problem
+	if *problemID > 0 {
+		problem, err = database.GetProblem(*problemID)
+	} else {
+		problem, err = database.GetProblemBySlug(*problemSlug)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching problem: %v\n", err)
+		os.Exit(1)
+	}
+	if problem == nil {
+		fmt.Fprintln(os.Stderr, "Problem not found")
+		os.Exit(1)
+	}
+
+	var data []byte
+	if *codeFile != "" {
+		data, err = os.ReadFile(*codeFile)
+	} else {
+		fmt.Fprintln(os.Stderr, "Reading code from stdin (Ctrl+D to finish)...")
+		data, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading code: %v\n", err)
+		os.Exit(1)
+	}
+
+	runner := newRunner(cfg)
+	fmt.Fprintf(os.Stderr, "Running against the examples of %s (#%s)...\n\n", problem.Title, problem.SourceID)
+	result, err := runner.Run(context.Background(), problem, string(data))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	printRunResult(result)
+	failed := result.Error != "" || result.Passed < result.Run
+
+	var gen *db.TestGenerator
+	if (*tests > 0 || *benchmark) && result.Error == "" {
+		gen, err = testGenerator(cfg, database, runner, problem, *userName, *regenerate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating tests: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *tests > 0 && gen != nil {
+		fmt.Fprintf(os.Stderr, "\nComparing with the reference solution on %d generated inputs (seed %d)...\n\n", *tests, *seed)
+		diff, err := runner.Differential(context.Background(), problem, gen, string(data), *tests, *seed)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printDiffResult(diff)
+		failed = failed || diff.Failed > 0 || diff.Error != ""
+	}
+	if *benchmark && gen != nil {
+		fmt.Fprintln(os.Stderr, "\nTiming on growing inputs...")
+		fmt.Fprintln(os.Stderr)
+		complexity, err := runner.Benchmark(context.Background(), problem, gen, string(data))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
-		fmt.Printf("[%s] %s\n    %s\n\n", icon, c.name, c.result.Comment)
+		printComplexity(os.Stdout, complexity)
+	}
+	if failed {
+		os.Exit(1)
 	}
+}
 
-	fmt.Printf("Score: %s/4\n\n", strconv.Itoa(score))
-	fmt.Printf("Overall Feedback:\n%s\n", r.OverallFeedback)
+// testGenerator returns the problem's stored test generator, asking the
+// LLM for one if it has none or regenerate is set, as long as code can be
+// run here.
+func testGenerator(cfg *config.Config, database *db.DB, runner *sandbox.Runner, problem *db.Problem, userName string, regenerate bool) (*db.TestGenerator, error) {
+	if err := runner.Check(); err != nil {
+		return nil, err
+	}
+	if !regenerate {
+		gen, err := database.GetTestGenerator(problem.ID)
+		if err != nil || gen != nil {
+			return gen, err
+		}
+	}
+
+	user, err := resolveUser(database, cfg, userName)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newLLMClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	guard := budget.NewGuard(database, cfg.UserBudget, cfg.GlobalBudget, cfg.LLMPricing, nil)
+	if err := guard.Check(user.ID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(os.Stderr, "Asking %s for a test generator...\n", cfg.LLMModel)
+	ctx := guard.Context(context.Background(), user.ID)
+	gen, err := client.GenerateTests(ctx, problem, func(gen *db.TestGenerator) ([]string, error) {
+		return runner.CheckGenerator(ctx, problem, gen)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return gen, database.StoreTestGenerator(gen)
+}
+
+func printDiffResult(r *sandbox.DiffResult) {
+	for _, c := range r.Counterexamples {
+		fmt.Printf("Counterexample: %s\n", strings.ToUpper(c.Status))
+		fmt.Printf("  Input:    %s\n", c.Input)
+		if c.Expected != "" {
+			fmt.Printf("  Expected: %s\n", c.Expected)
+		}
+		if c.Got != "" {
+			fmt.Printf("  Got:      %s\n", c.Got)
+		}
+		if c.Error != "" {
+			fmt.Printf("  Error:\n%s\n", indent(c.Error, "    "))
+		}
+	}
+	if r.TimedOut {
+		fmt.Println("Ran out of time before checking every input.")
+	}
+	fmt.Printf("\n%d/%d generated inputs passed\n", r.Passed, r.Checked)
+}
+
+func printComplexity(w io.Writer, c *sandbox.Complexity) {
+	for _, p := range c.Points {
+		fmt.Fprintf(w, "  n = %-7d %s\n", p.N, sandbox.FormatSeconds(p.Seconds))
+	}
+	if c.Error != "" {
+		fmt.Fprintf(w, "The code %s\n", strings.TrimSpace(c.Error))
+	}
+	if c.Class == "" {
+		fmt.Fprintln(w, "\nToo few timings to estimate the complexity")
+		return
+	}
+	fmt.Fprintf(w, "\nEstimated complexity: %s\n", c.Class)
+}
+
+func printRunResult(r *sandbox.Result) {
+	if r.Error != "" {
+		fmt.Printf("Could not load the solution:\n%s\n\n", r.Error)
+	}
+	for _, c := range r.Cases {
+		fmt.Printf("Example %d: %s\n", c.Example, strings.ToUpper(c.Status))
+		if c.Status == sandbox.StatusPass {
+			continue
+		}
+		fmt.Printf("  Input:    %s\n", c.Input)
+		fmt.Printf("  Expected: %s\n", c.Expected)
+		if c.Got != "" {
+			fmt.Printf("  Got:      %s\n", c.Got)
+		}
+		if c.Stdout != "" {
+			fmt.Printf("  Stdout:\n%s\n", indent(c.Stdout, "    "))
+		}
+		if c.Error != "" && r.Error == "" {
+			fmt.Printf("  Error:\n%s\n", indent(c.Error, "    "))
+		}
+	}
+	fmt.Printf("\n%d/%d examples passed\n", r.Passed, r.Run)
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	return prefix + strings.Join(lines, "\n"+prefix)
+}
+
+// loadRubric resolves the grade command's --rubric flag: a rubric file if
+// the value has a rubric file extension, otherwise a name from RUBRICS_DIR.
+func loadRubric(cfg *config.Config, name string) (*rubric.Rubric, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return rubric.LoadFile(name)
+	}
+
+	rubrics, err := rubric.LoadSet(cfg.RubricsDir, cfg.DefaultRubric)
+	if err != nil {
+		return nil, err
+	}
+	rb, ok := rubrics.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown rubric %q", name)
+	}
+	return rb, nil
+}
+
+func runEval(args []string) {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	fixtures := fs.String("fixtures", "testdata/eval", "Directory of labelled answer cases")
+	runs := fs.Int("runs", 1, "Times to grade each case, to measure agreement between runs")
+	parallel := fs.Int("parallel", 1, "Gradings to run at once")
+	baselinePath := fs.String("baseline", "", "Report saved with --save to compare against")
+	savePath := fs.String("save", "", "Write the report as JSON for use as a baseline")
+	fs.Parse(args)
+
+	cfg := config.LoadForCLI()
+
+	cases, err := eval.LoadCases(*fixtures)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading cases: %v\n", err)
+		os.Exit(1)
+	}
+
+	var baseline *eval.Report
+	if *baselinePath != "" {
+		if baseline, err = eval.LoadReport(*baselinePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading baseline: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	rubrics, err := rubric.LoadSet(cfg.RubricsDir, cfg.DefaultRubric)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading rubrics: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Resolve every case before grading anything, so that a typo in a
+	// fixture does not waste a run.
+	problems := make(map[string]*db.Problem, len(cases))
+	caseRubrics := make(map[string]*rubric.Rubric, len(cases))
+	for _, c := range cases {
+		problem, err := database.GetProblemBySlug(c.Problem)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching problem: %v\n", err)
+			os.Exit(1)
+		}
+		if problem == nil {
+			fmt.Fprintf(os.Stderr, "Case %s: problem %q not found\n", c.Name, c.Problem)
+			os.Exit(1)
+		}
+		rb, ok := rubrics.Get(c.Rubric)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Case %s: unknown rubric %q\n", c.Name, c.Rubric)
+			os.Exit(1)
+		}
+		if err := c.Check(rb); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		problems[c.Name] = problem
+		caseRubrics[c.Name] = rb
+	}
+
+	// No cache, so that every run asks the model. Usage is recorded but
+	// not attributed to a user.
+	client, err := newLLMClient(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
+		os.Exit(1)
+	}
+	guard := budget.NewGuard(database, cfg.UserBudget, cfg.GlobalBudget, cfg.LLMPricing, nil)
+	ctx := guard.Context(context.Background(), 0)
+
+	fmt.Fprintf(os.Stderr, "Evaluating %d cases, %d runs each, with %s via %s (%s)...\n\n",
+		len(cases), max(*runs, 1), cfg.LLMModel, cfg.LLMBaseURL, cfg.LLMProvider)
+
+	report := eval.Run(ctx, cases, *runs, *parallel, func(ctx context.Context, c *eval.Case) (*llm.GradingResult, error) {
+		return client.Grade(ctx, problems[c.Name], c.Answer, "", caseRubrics[c.Name])
+	})
+	report.Model = client.Model()
+	report.Provider = cfg.LLMProvider
+	report.CreatedAt = time.Now().UTC().Format(db.TimeFormat)
+
+	report.Write(os.Stdout)
+	if baseline != nil {
+		fmt.Println()
+		report.Compare(baseline).Write(os.Stdout)
+	}
+
+	if *savePath != "" {
+		if err := report.Save(*savePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "\nSaved report to %s\n", *savePath)
+	}
+}
+
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	status := fs.Bool("status", false, "Print the schema version without migrating")
+	fs.Parse(args)
+
+	cfg := config.LoadForCLI()
+
+	database, err := db.OpenUnmigrated(cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	current, err := database.SchemaVersion()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading schema version: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Schema version: %d (latest: %d)\n", current, db.LatestVersion())
+	if *status {
+		return
+	}
+
+	applied, err := database.Migrate()
+	for _, v := range applied {
+		fmt.Printf("Applied migration %d\n", v)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error migrating: %v\n", err)
+		os.Exit(1)
+	}
+	if len(applied) == 0 {
+		fmt.Println("Already up to date")
+	}
+}
+
+const problemsURL = "https://github.com/mcaupybugs/leetcode-problems-db/raw/refs/heads/master/merged_problems.json"
+
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	jsonPath := fs.String("json", "../merged_problems.json", "Path to merged_problems.json")
+	download := fs.Bool("download", false, "Download merged_problems.json instead of reading a local file")
+	url := fs.String("url", problemsURL, "URL to download from with --download")
+	fs.Parse(args)
+
+	cfg := config.LoadForCLI()
+
+	var src io.ReadCloser
+	if *download {
+		fmt.Fprintf(os.Stderr, "Downloading from %s...\n", *url)
+		resp, err := http.Get(*url)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error downloading: %v\n", err)
+			os.Exit(1)
+		}
+		if resp.StatusCode != http.StatusOK {
+			fmt.Fprintf(os.Stderr, "Error downloading: HTTP %d\n", resp.StatusCode)
+			os.Exit(1)
+		}
+		src = resp.Body
+	} else {
+		fmt.Fprintf(os.Stderr, "Reading %s...\n", *jsonPath)
+		f, err := os.Open(*jsonPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
+			os.Exit(1)
+		}
+		src = f
+	}
+
+	questions, err := db.ParseImport(src)
+	src.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing problems: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Found %d problems\n", len(questions))
+
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	stats, err := database.ImportProblems(questions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Imported into %s: %d added, %d updated, %d unchanged", cfg.DBPath, stats.Added, stats.Updated, stats.Unchanged)
+	if stats.Skipped > 0 {
+		fmt.Printf(", %d skipped (missing title or slug)", stats.Skipped)
+	}
+	fmt.Println()
+}
+
+func runReview(args []string) {
+	fs := flag.NewFlagSet("review", flag.ExitOnError)
+	limit := fs.Int("limit", 20, "Maximum number of problems to list")
+	rebuild := fs.Bool("rebuild", false, "Recompute every user's schedule by replaying all stored attempts")
+	userName := fs.String("user", "", "User whose reviews to list (default from QUIZ_USER)")
+	fs.Parse(args)
+
+	cfg := config.LoadForCLI()
+
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	if *rebuild {
+		n, err := review.Rebuild(database)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error rebuilding schedule: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Replayed %d attempts\n\n", n)
+	}
+
+	user, err := resolveUser(database, cfg, *userName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	due, total, err := database.ListDueReviews(user.ID, time.Now(), *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing reviews: %v\n", err)
+		os.Exit(1)
+	}
+	if total == 0 {
+		fmt.Println("Nothing due for review")
+		return
+	}
+
+	fmt.Printf("%d problems due for review\n\n", total)
+	for _, r := range due {
+		fmt.Printf("#%-5s %-45s %-6s last %3.0f%%  due %s\n",
+			r.SourceID, r.Title, r.Difficulty, 100*r.LastScore, r.DueAt)
+	}
+}
+
+func runRandom(args []string) {
+	fs := flag.NewFlagSet("random", flag.ExitOnError)
+	difficulty := fs.String("difficulty", "", "Only pick problems of this difficulty (Easy, Medium, Hard)")
+	topic := fs.String("topic", "", "Only pick problems with this topic")
+	skipRecent := fs.Int("skip-recent", 0, "Skip problems attempted in the last N days")
+	weighted := fs.Bool("weighted", false, "Favour topics with weak past scores")
+	userName := fs.String("user", "", "User whose attempts --skip-recent and --weighted use (default from QUIZ_USER)")
+	fs.Parse(args)
+
+	cfg := config.LoadForCLI()
+
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	params := db.RandomParams{
+		ListParams:     db.ListParams{Difficulty: *difficulty, Topic: *topic},
+		SkipRecentDays: *skipRecent,
+		Weighted:       *weighted,
+	}
+	if *skipRecent > 0 || *weighted {
+		user, err := resolveUser(database, cfg, *userName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		params.UserID = user.ID
+	}
+
+	problem, err := database.RandomProblem(params)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error picking problem: %v\n", err)
+		os.Exit(1)
+	}
+	if problem == nil {
+		fmt.Fprintln(os.Stderr, "No problems match")
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s (#%s) [%s]\n", problem.Title, problem.SourceID, problem.Difficulty)
+	fmt.Printf("Topics: %s\n", strings.Join(problem.Topics, ", "))
+	fmt.Printf("Slug: %s\n", problem.Slug)
+}
+
+func runSimilar(args []string) {
+	fs := flag.NewFlagSet("similar", flag.ExitOnError)
+	problemSlug := fs.String("problem", "", "Problem slug (e.g., two-sum)")
+	problemID := fs.Int("problem-id", 0, "Problem database ID")
+	difficulty := fs.String("difficulty", "", "Only list problems of this difficulty (Easy, Medium, Hard)")
+	topic := fs.String("topic", "", "Only list problems with this topic")
+	limit := fs.Int("limit", 10, "How many problems to list")
+	userName := fs.String("user", "", "User whose LLM budget embedding uses (default from QUIZ_USER)")
+	fs.Parse(args)
+
+	if *problemSlug == "" && *problemID == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: quiz similar --problem <slug> [--topic <name>] [--difficulty <level>]")
+		fmt.Fprintln(os.Stderr, "       quiz similar --problem-id <id>")
+		os.Exit(1)
+	}
+
+	cfg := config.LoadForCLI()
+
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	var problem *db.Problem
+	if *problemID > 0 {
+		problem, err = database.GetProblem(*problemID)
+	} else {
+		problem, err = database.GetProblemBySlug(*problemSlug)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching problem: %v\n", err)
+		os.Exit(1)
+	}
+	if problem == nil {
+		fmt.Fprintln(os.Stderr, "Problem not found")
+		os.Exit(1)
+	}
+
+	client, err := newLLMClient(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
+		os.Exit(1)
+	}
+	index := newSimilarIndex(cfg, database, client)
+
+	ctx := context.Background()
+	if !index.Local() {
+		user, err := resolveUser(database, cfg, *userName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		guard := budget.NewGuard(database, cfg.UserBudget, cfg.GlobalBudget, cfg.LLMPricing, nil)
+		if err := guard.Check(user.ID, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		ctx = guard.Context(ctx, user.ID)
+	}
+
+	n, err := index.Sync(ctx)
+	if n > 0 {
+		fmt.Fprintf(os.Stderr, "Embedded %d problems with %s\n\n", n, index.Model())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error embedding problems: %v\n", err)
+		os.Exit(1)
+	}
+
+	results, err := index.Similar(ctx, problem.ID, db.ListParams{Difficulty: *difficulty, Topic: *topic}, *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "No problems match")
+		os.Exit(1)
+	}
+	for _, r := range results {
+		fmt.Printf("%.2f  %s (#%s) [%s]  %s\n", r.Similarity, r.Title, r.SourceID, r.Difficulty, strings.Join(r.Topics, ", "))
+	}
+}
+
+// resolveUser picks the account a CLI command acts for: the --user flag,
+// then QUIZ_USER, then the only account if there is exactly one.
+func resolveUser(database *db.DB, cfg *config.Config, name string) (*db.User, error) {
+	if name == "" {
+		name = cfg.User
+	}
+	if name != "" {
+		user, err := database.GetUserByUsername(name)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("no user %q", name)
+		}
+		return user, nil
+	}
+
+	users, err := database.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	switch len(users) {
+	case 0:
+		return nil, fmt.Errorf("no user accounts; create one with: quiz user add <name>")
+	case 1:
+		return &users[0], nil
+	}
+	return nil, fmt.Errorf("several user accounts exist; pick one with --user or QUIZ_USER")
+}
+
+func runUser(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: quiz user add [--claim] <name>")
+		fmt.Fprintln(os.Stderr, "       quiz user remove <name>")
+		fmt.Fprintln(os.Stderr, "       quiz user passwd <name>")
+		fmt.Fprintln(os.Stderr, "       quiz user list")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Passwords are prompted for, or read from the first line of stdin.")
+		os.Exit(1)
+	}
+	if len(args) < 1 {
+		usage()
+	}
+
+	fs := flag.NewFlagSet("user "+args[0], flag.ExitOnError)
+	claim := fs.Bool("claim", false, "Give the new user every attempt made before accounts existed")
+	fs.Parse(args[1:])
+
+	cfg := config.LoadForCLI()
+
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	if args[0] == "list" {
+		users, err := database.ListUsers()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing users: %v\n", err)
+			os.Exit(1)
+		}
+		for _, u := range users {
+			fmt.Printf("%-20s created %s\n", u.Username, u.CreatedAt)
+		}
+		return
+	}
+
+	if fs.NArg() != 1 {
+		usage()
+	}
+	name := fs.Arg(0)
+
+	switch args[0] {
+	case "add":
+		hash, err := promptPasswordHash()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		user, err := database.CreateUser(name, hash)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error adding user: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added user %s\n", user.Username)
+
+		if *claim {
+			n, err := database.ClaimUnownedAttempts(user.ID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error claiming attempts: %v\n", err)
+				os.Exit(1)
+			}
+			if _, err := review.Rebuild(database); err != nil {
+				fmt.Fprintf(os.Stderr, "Error rebuilding review schedule: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Claimed %d attempts\n", n)
+		}
+
+	case "remove":
+		removed, err := database.DeleteUser(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing user: %v\n", err)
+			os.Exit(1)
+		}
+		if !removed {
+			fmt.Fprintf(os.Stderr, "No user %q\n", name)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed user %s and their attempts\n", name)
+
+	case "passwd":
+		user, err := database.GetUserByUsername(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching user: %v\n", err)
+			os.Exit(1)
+		}
+		if user == nil {
+			fmt.Fprintf(os.Stderr, "No user %q\n", name)
+			os.Exit(1)
+		}
+		hash, err := promptPasswordHash()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := database.SetPasswordHash(user.ID, hash); err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting password: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Changed password for %s; their sessions were signed out\n", user.Username)
+
+	default:
+		usage()
+	}
+}
+
+func runToken(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: quiz token create [--user <name>] --name <name> [--scopes a,b] [--expires 90d]")
+		fmt.Fprintln(os.Stderr, "       quiz token list [--user <name>]")
+		fmt.Fprintln(os.Stderr, "       quiz token revoke [--user <name>] <id>")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintf(os.Stderr, "Scopes: %s (default: all)\n", strings.Join(auth.AllScopes, ", "))
+		os.Exit(1)
+	}
+	if len(args) < 1 {
+		usage()
+	}
+
+	fs := flag.NewFlagSet("token "+args[0], flag.ExitOnError)
+	userName := fs.String("user", "", "User who owns the tokens (default from QUIZ_USER)")
+	name := fs.String("name", "", "Name to recognise the token by, e.g. the script using it")
+	scopes := fs.String("scopes", "", "Comma-separated scopes")
+	expires := fs.String("expires", "", "Lifetime such as 90d or 720h (default: never expires)")
+	fs.Parse(args[1:])
+
+	cfg := config.LoadForCLI()
+
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	user, err := resolveUser(database, cfg, *userName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "create":
+		ttl, err := auth.ParseTTL(*expires)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		var scopeList []string
+		if *scopes != "" {
+			scopeList = strings.Split(*scopes, ",")
+		}
+
+		secret, token, err := auth.NewTokens(database).Create(user.ID, *name, scopeList, ttl)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating token: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Created token %d (%s) for %s. It will not be shown again:\n", token.ID, token.Name, user.Username)
+		fmt.Println(secret)
+
+	case "list":
+		tokens, err := database.ListAPITokens(user.ID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing tokens: %v\n", err)
+			os.Exit(1)
+		}
+		for _, t := range tokens {
+			expiry := "never expires"
+			if t.ExpiresAt != "" {
+				expiry = "expires " + t.ExpiresAt
+			}
+			lastUsed := "never used"
+			if t.LastUsedAt != "" {
+				lastUsed = "last used " + t.LastUsedAt
+			}
+			fmt.Printf("%-4d %-20s %s…  %-35s %s, %s\n",
+				t.ID, t.Name, t.Prefix, strings.Join(t.Scopes, ","), expiry, lastUsed)
+		}
+
+	case "revoke":
+		if fs.NArg() != 1 {
+			usage()
+		}
+		id, err := strconv.Atoi(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid token id %q\n", fs.Arg(0))
+			os.Exit(1)
+		}
+		revoked, err := database.DeleteAPIToken(user.ID, id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error revoking token: %v\n", err)
+			os.Exit(1)
+		}
+		if !revoked {
+			fmt.Fprintf(os.Stderr, "No token %d for %s\n", id, user.Username)
+			os.Exit(1)
+		}
+		fmt.Printf("Revoked token %d\n", id)
+
+	default:
+		usage()
+	}
+}
+
+func runFakeLLM(args []string) {
+	fs := flag.NewFlagSet("fake-llm", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:4001", "Address to listen on")
+	scriptPath := fs.String("script", "", "YAML file of scripted replies and rules (see testdata/fake-llm.yaml)")
+	latency := fs.Duration("latency", 0, "Delay added to every reply")
+	errorRate := fs.Float64("error-rate", 0, "Fraction of requests answered with a 503")
+	malformedRate := fs.Float64("malformed-rate", 0, "Fraction of tool calls with truncated arguments")
+	seed := fs.Int64("seed", 1, "Seed for injected faults")
+	fs.Parse(args)
+
+	script := &llmtest.Script{}
+	if *scriptPath != "" {
+		var err error
+		if script, err = llmtest.LoadScript(*scriptPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading script: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// Flags override the script's faults when given.
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "latency":
+			script.Latency = *latency
+		case "error-rate":
+			script.ErrorRate = *errorRate
+		case "malformed-rate":
+			script.MalformedRate = *malformedRate
+		case "seed":
+			script.Seed = *seed
+		}
+	})
+	if script.Seed == 0 {
+		script.Seed = *seed
+	}
+
+	server := llmtest.NewServer()
+	if err := server.Load(script); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading script: %v\n", err)
+		os.Exit(1)
+	}
+
+	log.Printf("Fake LLM listening; use LLM_PROVIDER=openai LLM_BASE_URL=http://%s/v1", *addr)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
+
+// seedInitialUser creates the INITIAL_USER account if there are no accounts
+// yet, so that a fresh deploy can be logged into.
+func seedInitialUser(database *db.DB, cfg *config.Config) error {
+	users, err := database.ListUsers()
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		return nil
+	}
+	if cfg.InitialUser == "" {
+		log.Printf("No user accounts yet; create one with: quiz user add <name>, or set INITIAL_USER and INITIAL_PASSWORD")
+		return nil
+	}
+
+	hash, err := auth.HashPassword(cfg.InitialPassword)
+	if err != nil {
+		return err
+	}
+	user, err := database.CreateUser(cfg.InitialUser, hash)
+	if err != nil {
+		return err
+	}
+	log.Printf("Created initial user %s", user.Username)
+	return nil
+}
+
+// promptPasswordHash reads a new password, twice with echo off on a
+// terminal or once from stdin otherwise, and hashes it.
+func promptPasswordHash() (string, error) {
+	var password string
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		first, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		fmt.Fprint(os.Stderr, "Repeat password: ")
+		second, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		if string(first) != string(second) {
+			return "", fmt.Errorf("passwords do not match")
+		}
+		password = string(first)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	return auth.HashPassword(password)
+}
+
+func printResult(r *llm.GradingResult) {
+	for _, c := range r.Criteria {
+		icon := "~"
+		switch c.Points {
+		case 0:
+			icon = "\u2717"
+		case c.MaxPoints:
+			icon = "\u2713"
+		}
+
+		name := c.Label
+		if c.MaxPoints > 1 {
+			name += fmt.Sprintf(" (%d/%d)", c.Points, c.MaxPoints)
+		}
+		if r.Samples > 1 {
+			name += fmt.Sprintf(" [%.0f%% agree]", 100*c.Agreement)
+		}
+		fmt.Printf("[%s] %s\n    %s\n\n", icon, name, c.Comment)
+	}
+
+	fmt.Printf("Score: %s/%s", formatScore(r.Score), formatScore(r.MaxScore))
+	if r.Samples > 1 {
+		fmt.Printf(" (consensus of %d samples)", r.Samples)
+	}
+	if r.HintsUsed > 0 {
+		fmt.Printf(" (-%s for %d hints)", formatScore(r.HintPenalty*r.MaxScore), r.HintsUsed)
+	}
+	fmt.Print("\n\n")
+	fmt.Printf("Overall Feedback:\n%s\n", r.OverallFeedback)
+}
+
+// formatScore prints weighted scores without trailing zeros, e.g. 3 or 2.5.
+func formatScore(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
 }
@@ -10,6 +10,8 @@ import tempfile
 
 PROBLEMS_URL = "https://github.com/mcaupybugs/leetcode-problems-db/raw/refs/heads/master/merged_problems.json"
 
+# Mirrors migration 1 in internal/db/migrate.go. The Go binary applies the
+# remaining migrations (attempts, etc.) when it opens the database.
 SCHEMA = """
 CREATE TABLE IF NOT EXISTS problems (
     id              INTEGER PRIMARY KEY AUTOINCREMENT,